@@ -34,6 +34,7 @@ import (
 	"io"
 	"math"
 	"math/rand/v2"
+	"strings"
 	"sync"
 	"time"
 )
@@ -76,8 +77,10 @@ type Message struct {
 
 // Image represents an image for vision models.
 type Image struct {
-	MimeType string // e.g., "image/jpeg", "image/png"
-	Data     []byte // Raw image bytes (will be base64 encoded)
+	MimeType     string        // e.g., "image/jpeg", "image/png"
+	Data         []byte        // Raw image bytes (will be base64 encoded)
+	FileID       string        // Uploaded file ID (see FileStore); used instead of Data when set
+	CacheControl *CacheControl // Prompt caching breakpoint after this image (Anthropic)
 }
 
 // ImageFromBase64 creates an Image from a base64-encoded string.
@@ -100,16 +103,19 @@ func ImageFromBytes(mimeType string, data []byte) Image {
 
 // Document represents a document (PDF, etc.) for document-aware models.
 type Document struct {
-	MimeType string // e.g., "application/pdf"
-	Data     []byte // Raw document bytes (will be base64 encoded)
-	Name     string // Optional filename
+	MimeType     string        // e.g., "application/pdf"
+	Data         []byte        // Raw document bytes (will be base64 encoded)
+	Name         string        // Optional filename
+	FileID       string        // Uploaded file ID (see FileStore); used instead of Data when set
+	CacheControl *CacheControl // Prompt caching breakpoint after this document (Anthropic)
 }
 
 // Tool defines a function that the model can call.
 type Tool struct {
-	Name        string         // Function name (e.g., "get_weather")
-	Description string         // What the function does
-	Parameters  map[string]any // JSON Schema for parameters
+	Name         string         // Function name (e.g., "get_weather")
+	Description  string         // What the function does
+	Parameters   map[string]any // JSON Schema for parameters
+	CacheControl *CacheControl  // Prompt caching breakpoint after this tool definition (Anthropic)
 }
 
 // ComputerUseTool enables Anthropic's computer use capability.
@@ -152,11 +158,16 @@ type ResponseFormat struct {
 // CacheControl constants for prompt caching.
 const (
 	CacheEphemeral = "ephemeral"
+
+	CacheTTL5m = "5m" // default cache lifetime
+	CacheTTL1h = "1h" // extended cache lifetime, at a higher write price (see Pricing.CacheWrite1h)
 )
 
-// CacheControl marks content for prompt caching.
+// CacheControl marks content for prompt caching. The cached prefix ends at
+// the marked block: a message's last block, an image, a document or a tool.
 type CacheControl struct {
-	Type string // typically "ephemeral"
+	Type string `json:"type"`          // typically "ephemeral"
+	TTL  string `json:"ttl,omitempty"` // CacheTTL5m or CacheTTL1h (empty = provider default, 5 minutes)
 }
 
 // ThinkingConfig configures extended thinking/reasoning.
@@ -175,8 +186,10 @@ const (
 
 // Truncation strategy constants.
 const (
-	TruncateTail = "tail" // keep latest messages
-	TruncateNone = "none" // error if over limit
+	TruncateTail      = "tail"      // keep latest messages
+	TruncateNone      = "none"      // error if over limit
+	TruncateMiddle    = "middle"    // keep the first and last turns, drop the middle
+	TruncateSummarize = "summarize" // replace the dropped middle with a summary
 )
 
 // TokenCount represents pre-request token counting result.
@@ -219,16 +232,28 @@ const (
 
 // ImageRequest represents an image generation request.
 type ImageRequest struct {
-	Prompt  string // Text prompt for image generation
-	Model   string // Image model (empty = provider default)
-	Size    string // Image size (e.g., "1024x1024")
-	Quality string // Image quality (e.g., "standard", "hd")
-	N       int    // Number of images to generate
-}
+	Prompt            string // Text prompt for image generation
+	Model             string // Image model (empty = provider default)
+	Size              string // Image size (e.g., "1024x1024")
+	Quality           string // Image quality (e.g., "standard", "hd", "low", "medium", "high")
+	N                 int    // Number of images to generate
+	ResponseFormat    string // ImageResponseURL or ImageResponseB64JSON (gpt-image models always return base64)
+	Background        string // "transparent", "opaque" or "auto" (gpt-image models)
+	OutputFormat      string // "png", "jpeg" or "webp" (gpt-image models)
+	OutputCompression int    // Compression 0-100 for jpeg/webp output (gpt-image models)
+	Style             string // "vivid" or "natural" (dall-e-3)
+}
+
+// Image response format constants for ImageRequest.ResponseFormat.
+const (
+	ImageResponseURL     = "url"
+	ImageResponseB64JSON = "b64_json"
+)
 
 // GeneratedImage represents a single generated image.
 type GeneratedImage struct {
 	Data          []byte // Image data (if available)
+	MimeType      string // MIME type of Data (e.g., "image/png")
 	URL           string // Image URL (if provider returns URL)
 	RevisedPrompt string // Revised prompt (if provider modified it)
 }
@@ -252,22 +277,36 @@ type Logger interface {
 
 // Hook event type constants.
 const (
-	HookRequest = "request"
-	HookSuccess = "success"
-	HookError   = "error"
-	HookRetry   = "retry"
+	HookRequest     = "request"      // operation started (all operations except Stream)
+	HookSuccess     = "success"      // operation succeeded
+	HookError       = "error"        // operation failed
+	HookRetry       = "retry"        // attempt failed and is retried
+	HookStreamStart = "stream_start" // stream started
+	HookFirstToken  = "first_token"  // stream received its first content
+	HookStreamEnd   = "stream_end"   // stream finished or failed
+	HookToolCall    = "tool_call"    // model requested a tool call
+	HookTruncation  = "truncation"   // messages were dropped to fit the context window
+	HookCacheHit    = "cache_hit"    // Chat response was served from WithCache
 )
 
 // HookEvent contains information about a client event.
 type HookEvent struct {
-	Type         string        // HookRequest, HookSuccess, HookError, HookRetry
-	Provider     string        // Provider name
-	Model        string        // Model used
-	Latency      time.Duration // Request latency
-	InputTokens  int           // Input token count
-	OutputTokens int           // Output token count
-	Error        error         // Error, if any
-	Attempt      int           // Current attempt (1-based)
+	Type              string        // HookRequest, HookSuccess, HookError, ...
+	Operation         string        // OperationChat, OperationStream, ...
+	CorrelationID     string        // ID shared by all events of a call (see WithCorrelationID)
+	Provider          string        // Provider name
+	Model             string        // Model used
+	RequestID         string        // Provider request ID (HookSuccess of chat calls)
+	Latency           time.Duration // Request latency (time to first token for HookFirstToken)
+	InputTokens       int           // Input token count
+	OutputTokens      int           // Output token count
+	CacheReadTokens   int           // Input tokens read from cache
+	CacheWriteTokens  int           // Input tokens written to cache
+	FinishReason      string        // Why generation stopped
+	ToolName          string        // Tool requested by the model (HookToolCall)
+	TruncatedMessages int           // Messages dropped (HookTruncation)
+	Error             error         // Error, if any
+	Attempt           int           // Current attempt (1-based)
 }
 
 // Hook is a callback for observing client events.
@@ -341,29 +380,38 @@ type Citation struct {
 
 // Response contains the LLM response.
 type Response struct {
-	Content           string         // Generated text
-	Citations         []Citation     // Citations parsed from response (provider-dependent)
-	SearchResults     []SearchResult // Web search results used by the model (provider-dependent)
-	ToolCalls         []ToolCall     // Tool calls requested by the model (when FinishReason is "tool_use" or "tool_calls")
-	Provider          string         // Provider name (e.g., "anthropic")
-	Model             string         // Model used (e.g., "claude-sonnet-4-6")
-	InputTokens       int            // Tokens in input
-	OutputTokens      int            // Tokens in output
-	Latency           time.Duration  // Request latency
-	FinishReason      string         // Why generation stopped
-	Thinking          string         // Extended thinking/reasoning content
-	ThinkingTokens    int            // Tokens used for thinking
-	CacheReadTokens   int            // Tokens read from cache
-	CacheWriteTokens  int            // Tokens written to cache
-	LogProbs          []TokenLogProb // Per-token log probabilities (when requested, OpenAI/compatible only)
-	SystemFingerprint string         // System fingerprint for reproducibility tracking (OpenAI/compatible)
-	RequestID         string         // Provider request ID for debugging (e.g., OpenAI x-request-id, Anthropic message ID)
+	Content            string         // Generated text
+	Citations          []Citation     // Citations parsed from response (provider-dependent)
+	SearchResults      []SearchResult // Web search results used by the model (provider-dependent)
+	ToolCalls          []ToolCall     // Tool calls requested by the model (when FinishReason is "tool_use" or "tool_calls")
+	Provider           string         // Provider name (e.g., "anthropic")
+	Model              string         // Model used (e.g., "claude-sonnet-4-6")
+	InputTokens        int            // Tokens in input
+	OutputTokens       int            // Tokens in output
+	Latency            time.Duration  // Request latency
+	FinishReason       string         // Why generation stopped
+	Thinking           string         // Extended thinking/reasoning content
+	ThinkingTokens     int            // Tokens used for thinking
+	CacheReadTokens    int            // Tokens read from cache (part of InputTokens for OpenAI, in addition to it for Anthropic)
+	CacheWriteTokens   int            // Tokens written to cache (Anthropic, in addition to InputTokens)
+	CacheWrite1hTokens int            // Part of CacheWriteTokens written with CacheTTL1h (Anthropic)
+	LogProbs           []TokenLogProb // Per-token log probabilities (when requested, OpenAI/compatible only)
+	SystemFingerprint  string         // System fingerprint for reproducibility tracking (OpenAI/compatible)
+	RequestID          string         // Provider request ID for debugging (e.g., OpenAI x-request-id, Anthropic message ID)
+	ModerationFlags    []string       // Categories flagged by WithModeration in flag mode
+	Cost               float64        // USD cost (0 = pricing unknown, see WithPricing)
+	Cached             bool           // True if served from WithCache (Cost is 0)
 }
 
 // StreamUsage contains token usage information from streaming responses.
 type StreamUsage struct {
-	InputTokens  int // Input tokens
-	OutputTokens int // Output tokens
+	InputTokens        int     // Input tokens
+	OutputTokens       int     // Output tokens
+	CacheReadTokens    int     // Tokens read from cache (see Response.CacheReadTokens)
+	CacheWriteTokens   int     // Tokens written to cache (see Response.CacheWriteTokens)
+	CacheWrite1hTokens int     // Part of CacheWriteTokens written with CacheTTL1h
+	ThinkingTokens     int     // Output tokens used for thinking (where reported)
+	Cost               float64 // USD cost (0 = pricing unknown, set by Client.Stream)
 }
 
 // StreamToolUse represents a tool-use event in a streamed response.
@@ -374,12 +422,13 @@ type StreamToolUse struct {
 
 // StreamChunk represents a chunk of streamed response.
 type StreamChunk struct {
-	Content  string         // Partial content
-	Thinking string         // Thinking/reasoning content (partial, for streaming, Anthropic only)
-	ToolUse  *StreamToolUse // Tool-use event (name + input, nil for non-tool chunks)
-	Done     bool           // True if this is the final chunk
-	Error    error          // Non-nil if streaming failed
-	Usage    *StreamUsage   // Token usage (non-nil only in final chunks, provider-dependent)
+	Content         string         // Partial content
+	Thinking        string         // Thinking/reasoning content (partial, for streaming, Anthropic only)
+	ToolUse         *StreamToolUse // Tool-use event (name + input, nil for non-tool chunks)
+	Done            bool           // True if this is the final chunk
+	Error           error          // Non-nil if streaming failed
+	Usage           *StreamUsage   // Token usage (non-nil only in final chunks, provider-dependent)
+	ModerationFlags []string       // Categories flagged by WithModeration in flag mode (final chunk only)
 }
 
 // EmbedRequest contains parameters for an embedding request.
@@ -472,21 +521,40 @@ type SpeechResponse struct {
 
 // TranscribeRequest represents a speech-to-text request.
 type TranscribeRequest struct {
-	Audio    []byte // Raw audio data
-	Model    string // STT model (e.g., "whisper-1")
-	Language string // ISO language code (optional)
-	Format   string // Input format hint: "mp3", "wav", etc.
-	Prompt   string // Optional prompt for context
+	Audio          []byte // Raw audio data
+	Model          string // STT model (e.g., "whisper-1")
+	Language       string // ISO language code (optional)
+	Format         string // Input format hint: "mp3", "wav", etc.
+	Prompt         string // Optional prompt for context
+	Verbose        bool   // Request verbose output: segments, detected language and duration
+	WordTimestamps bool   // Include word-level timestamps (implies Verbose)
 }
 
 // TranscribeResponse contains the result of speech-to-text.
 type TranscribeResponse struct {
-	Text     string        // Transcribed text
-	Language string        // Detected language
-	Duration float64       // Audio duration in seconds
-	Provider string        // Provider name
-	Model    string        // Model used
-	Latency  time.Duration // Request latency
+	Text     string              // Transcribed text
+	Language string              // Detected language (verbose), otherwise the requested language
+	Duration float64             // Audio duration in seconds (verbose)
+	Segments []TranscriptSegment // Segment timestamps (verbose)
+	Words    []TranscriptWord    // Word timestamps (WordTimestamps)
+	Provider string              // Provider name
+	Model    string              // Model used
+	Latency  time.Duration       // Request latency
+}
+
+// TranscriptSegment is a timestamped span of a transcription.
+type TranscriptSegment struct {
+	ID    int     // Segment index
+	Start float64 // Start time in seconds
+	End   float64 // End time in seconds
+	Text  string  // Segment text
+}
+
+// TranscriptWord is a timestamped word of a transcription.
+type TranscriptWord struct {
+	Word  string  // Word text
+	Start float64 // Start time in seconds
+	End   float64 // End time in seconds
 }
 
 // Speaker is an optional interface for text-to-speech.
@@ -510,8 +578,9 @@ type Model struct {
 	Provider      string   // Provider name
 	ContextWindow int      // Max input tokens (0 = unknown)
 	MaxOutput     int      // Max output tokens (0 = unknown)
-	Capabilities  []string // e.g., "vision", "tools", "streaming", "embeddings"
+	Capabilities  []string // e.g., "vision", "tools", "streaming", "embeddings" (see Capability constants)
 	CreatedAt     int64    // Unix timestamp (0 = unknown)
+	Pricing       *Pricing // Price per million tokens (nil = unknown)
 }
 
 // Option configures the Client.
@@ -555,10 +624,12 @@ func WithMaxTokens(n int) Option {
 }
 
 // WithTemperature sets the default temperature for all requests.
-// This overrides the provider's default temperature.
+// This overrides the provider's default temperature. A temperature of 0 keeps
+// the provider default on the wire, but marks calls as cacheable (see WithCache).
 func WithTemperature(t float64) Option {
 	return func(c *Client) {
 		c.temperature = t
+		c.temperatureSet = true
 	}
 }
 
@@ -640,10 +711,16 @@ func WithLogger(logger Logger) Option {
 	}
 }
 
-// WithHook sets a callback for observing client events (requests, retries, errors).
-func WithHook(hook Hook) Option {
+// WithHook adds callbacks for observing client events (requests, retries,
+// errors, streams, tool calls). It can be given several times; hooks run in
+// the order they were added.
+func WithHook(hooks ...Hook) Option {
 	return func(c *Client) {
-		c.hook = hook
+		for _, hook := range hooks {
+			if hook != nil {
+				c.hooks = append(c.hooks, hook)
+			}
+		}
 	}
 }
 
@@ -675,7 +752,8 @@ func WithEffort(level string) Option {
 }
 
 // WithMaxContextTokens sets a soft limit on context tokens.
-// Requires provider to support TokenCounter interface.
+// Tokens are counted with WithTokenCounter, the provider's TokenCounter, or
+// else a heuristic TokenEstimator.
 func WithMaxContextTokens(n int) Option {
 	return func(c *Client) {
 		c.maxContextTokens = n
@@ -683,7 +761,8 @@ func WithMaxContextTokens(n int) Option {
 }
 
 // WithTruncationStrategy sets how to handle context exceeding MaxContextTokens.
-// "tail" keeps latest messages, "none" returns an error.
+// "tail" keeps latest messages, "middle" keeps the first and last turns,
+// "summarize" replaces the dropped middle with a summary, "none" returns an error.
 func WithTruncationStrategy(strategy string) Option {
 	return func(c *Client) {
 		c.truncationStrategy = strategy
@@ -715,34 +794,55 @@ type Client struct {
 	timeout            time.Duration
 	maxInputLen        int
 	systemPrompt       string
-	model              string          // default chat model
-	maxTokens          int             // default max tokens
-	temperature        float64         // default temperature
-	presencePenalty    float64         // default presence penalty
-	frequencyPenalty   float64         // default frequency penalty
-	embeddingModel     string          // default embedding model
-	tools              []Tool          // available tools for function calling
-	maxRetries         int             // 0 = no retry (default)
-	retryBaseDelay     time.Duration   // initial backoff delay (default 1s)
-	retryMaxDelay      time.Duration   // max backoff delay (default 30s)
-	logger             Logger          // structured logger (nil = no logging)
-	hook               Hook            // event callback (nil = no hook)
-	responseFormat     *ResponseFormat // structured output format
-	thinking           *ThinkingConfig // extended thinking config
-	effort             string          // effort level: low, medium, high, max
-	maxContextTokens   int             // soft limit on context tokens
-	truncationStrategy string          // "tail" or "none"
-	logProbs           bool            // enable log probabilities
-	topLogProbs        int             // number of top log probs per token
-	seed               *int64          // seed for deterministic output
-	usage              UsageStats      // cumulative usage tracking
+	model              string                // default chat model
+	maxTokens          int                   // default max tokens
+	temperature        float64               // default temperature
+	temperatureSet     bool                  // temperature set by WithTemperature, even if 0
+	presencePenalty    float64               // default presence penalty
+	frequencyPenalty   float64               // default frequency penalty
+	embeddingModel     string                // default embedding model
+	tools              []Tool                // available tools for function calling
+	maxRetries         int                   // 0 = no retry (default)
+	retryBaseDelay     time.Duration         // initial backoff delay (default 1s)
+	retryMaxDelay      time.Duration         // max backoff delay (default 30s)
+	logger             Logger                // structured logger (nil = no logging)
+	hooks              []Hook                // event callbacks
+	middleware         []Middleware          // Chat and Stream middlewares, outermost first
+	cache              *responseCache        // response cache (nil = disabled)
+	autoCache          *AutoCachePolicy      // automatic prompt caching breakpoints (nil = disabled)
+	observer           Observer              // operation observer (nil = none)
+	responseFormat     *ResponseFormat       // structured output format
+	thinking           *ThinkingConfig       // extended thinking config
+	effort             string                // effort level: low, medium, high, max
+	maxContextTokens   int                   // soft limit on context tokens
+	truncationStrategy string                // "tail", "middle", "summarize" or "none"
+	truncateKeepFirst  int                   // turns always kept at the start (middle/summarize)
+	truncateKeepLast   int                   // turns always kept at the end (middle/summarize)
+	summaryModel       string                // model for TruncateSummarize (empty = chat model)
+	tokenCounter       TokenCounter          // token counter overriding the provider's (nil = provider)
+	logProbs           bool                  // enable log probabilities
+	topLogProbs        int                   // number of top log probs per token
+	seed               *int64                // seed for deterministic output
+	reranker           Reranker              // dedicated reranker (nil = use provider)
+	moderation         *ModerationPolicy     // content guard (nil = disabled)
+	imageDownload      int64                 // max bytes per downloaded image URL (0 = disabled)
+	audioChunkSize     int                   // max bytes per transcription upload
+	preflight          string                // capability preflight policy (empty = disabled)
+	pricing            map[string]Pricing    // per-model price overrides (nil = catalog)
+	budget             budgetState           // spending limit (guarded by mu)
+	usage              UsageStats            // cumulative usage tracking
+	tagUsage           map[string]UsageStats // cumulative usage per WithUsageTags tag
 }
 
 // UsageStats tracks cumulative LLM usage since client creation.
 type UsageStats struct {
-	Requests     int64 // Total number of requests
-	InputTokens  int64 // Total input tokens consumed
-	OutputTokens int64 // Total output tokens generated
+	Requests         int64   // Total number of requests
+	InputTokens      int64   // Total input tokens consumed
+	OutputTokens     int64   // Total output tokens generated
+	CacheReadTokens  int64   // Input tokens read from cache
+	CacheWriteTokens int64   // Input tokens written to cache
+	ThinkingTokens   int64   // Output tokens used for thinking (where reported)
+	Cost             float64 // Total USD cost of priced models
 }
 
 // clientState holds a snapshot of client fields for use without holding the lock.
@@ -754,6 +854,7 @@ type clientState struct {
 	model              string
 	maxTokens          int
 	temperature        float64
+	temperatureSet     bool
 	presencePenalty    float64
 	frequencyPenalty   float64
 	embeddingModel     string
@@ -762,15 +863,30 @@ type clientState struct {
 	retryBaseDelay     time.Duration
 	retryMaxDelay      time.Duration
 	logger             Logger
-	hook               Hook
+	hooks              []Hook
+	middleware         []Middleware
+	cache              *responseCache
+	autoCache          *AutoCachePolicy
+	observer           Observer
 	responseFormat     *ResponseFormat
 	thinking           *ThinkingConfig
 	effort             string
 	maxContextTokens   int
 	truncationStrategy string
+	truncateKeepFirst  int
+	truncateKeepLast   int
+	summaryModel       string
+	tokenCounter       TokenCounter
 	logProbs           bool
 	topLogProbs        int
 	seed               *int64
+	reranker           Reranker
+	moderation         *ModerationPolicy
+	imageDownload      int64
+	audioChunkSize     int
+	preflight          string
+	pricing            map[string]Pricing
+	client             *Client // owner of the usage and budget totals (nil = not tracked)
 }
 
 // snapshot captures the current client state under a read lock.
@@ -785,6 +901,7 @@ func (c *Client) snapshot() clientState {
 		model:              c.model,
 		maxTokens:          c.maxTokens,
 		temperature:        c.temperature,
+		temperatureSet:     c.temperatureSet,
 		presencePenalty:    c.presencePenalty,
 		frequencyPenalty:   c.frequencyPenalty,
 		embeddingModel:     c.embeddingModel,
@@ -793,15 +910,30 @@ func (c *Client) snapshot() clientState {
 		retryBaseDelay:     c.retryBaseDelay,
 		retryMaxDelay:      c.retryMaxDelay,
 		logger:             c.logger,
-		hook:               c.hook,
+		hooks:              c.hooks,
+		middleware:         c.middleware,
+		cache:              c.cache,
+		autoCache:          c.autoCache,
+		observer:           c.observer,
 		responseFormat:     c.responseFormat,
 		thinking:           c.thinking,
 		effort:             c.effort,
 		maxContextTokens:   c.maxContextTokens,
 		truncationStrategy: c.truncationStrategy,
+		truncateKeepFirst:  c.truncateKeepFirst,
+		truncateKeepLast:   c.truncateKeepLast,
+		summaryModel:       c.summaryModel,
+		tokenCounter:       c.tokenCounter,
 		logProbs:           c.logProbs,
 		topLogProbs:        c.topLogProbs,
 		seed:               c.seed,
+		reranker:           c.reranker,
+		moderation:         c.moderation,
+		imageDownload:      c.imageDownload,
+		audioChunkSize:     c.audioChunkSize,
+		preflight:          c.preflight,
+		pricing:            c.pricing,
+		client:             c,
 	}
 }
 
@@ -813,6 +945,10 @@ func New(provider Provider, opts ...Option) *Client {
 		maxInputLen:    100000, // 100KB default
 		retryBaseDelay: 1 * time.Second,
 		retryMaxDelay:  30 * time.Second,
+		audioChunkSize: MaxAudioChunkSize,
+
+		truncateKeepFirst: 1,
+		truncateKeepLast:  1,
 	}
 	for _, opt := range opts {
 		opt(c)
@@ -965,14 +1101,23 @@ func requestMeta(messages []Message, s clientState) []any {
 	return meta
 }
 
-// truncateMessages applies context window management if maxContextTokens is set.
-// Returns truncated messages or an error if truncation fails.
-func truncateMessages(ctx context.Context, s clientState, messages []Message) ([]Message, error) {
-	// Check if provider supports token counting
+// counter returns the configured TokenCounter, or the provider's if it has one.
+func (s clientState) counter() (TokenCounter, bool) {
+	if s.tokenCounter != nil {
+		return s.tokenCounter, true
+	}
 	counter, ok := s.provider.(TokenCounter)
+	return counter, ok
+}
+
+// truncateMessages applies context window management if maxContextTokens is set.
+// Returns the truncated messages and the number of messages dropped, or an
+// error if truncation fails.
+func truncateMessages(ctx context.Context, s clientState, messages []Message) (truncated []Message, dropped int, err error) {
+	counter, ok := s.counter()
 	if !ok {
-		// Provider doesn't support token counting, return messages as-is
-		return messages, nil
+		// Provider doesn't support token counting, estimate offline
+		counter = defaultEstimator()
 	}
 
 	// Build a temporary request to count tokens
@@ -983,12 +1128,12 @@ func truncateMessages(ctx context.Context, s clientState, messages []Message) ([
 		if s.logger != nil {
 			s.logger.Debug("token counting failed, skipping truncation", "error", err)
 		}
-		return messages, nil
+		return messages, 0, nil
 	}
 
 	// Check if we're over the limit
 	if count.InputTokens <= s.maxContextTokens {
-		return messages, nil
+		return messages, 0, nil
 	}
 
 	// Over limit - apply truncation strategy
@@ -998,49 +1143,21 @@ func truncateMessages(ctx context.Context, s clientState, messages []Message) ([
 	}
 
 	if strategy == TruncateNone {
-		return nil, fmt.Errorf("context exceeds max tokens (%d > %d)", count.InputTokens, s.maxContextTokens)
-	}
-
-	if strategy == TruncateTail {
-		// Keep system messages and remove oldest non-system messages
-		var systemMsgs []Message
-		var otherMsgs []Message
-		for _, m := range messages {
-			if m.Role == RoleSystem {
-				systemMsgs = append(systemMsgs, m)
-			} else {
-				otherMsgs = append(otherMsgs, m)
-			}
-		}
-
-		// Remove oldest non-system messages until under the limit
-		for len(otherMsgs) > 1 {
-			otherMsgs = otherMsgs[1:]
-
-			// Build a new slice to avoid mutating systemMsgs
-			truncated := make([]Message, 0, len(systemMsgs)+len(otherMsgs))
-			truncated = append(truncated, systemMsgs...)
-			truncated = append(truncated, otherMsgs...)
-
-			tempReq := buildRequest(truncated, s)
-			count, err := counter.CountTokens(ctx, tempReq)
-			if err != nil {
-				return truncated, nil
-			}
-
-			if count.InputTokens <= s.maxContextTokens {
-				return truncated, nil
-			}
-		}
+		return nil, 0, fmt.Errorf("context exceeds max tokens (%d > %d)", count.InputTokens, s.maxContextTokens)
+	}
 
-		// Return at least system + last message
-		result := make([]Message, 0, len(systemMsgs)+len(otherMsgs))
-		result = append(result, systemMsgs...)
-		result = append(result, otherMsgs...)
-		return result, nil
+	switch strategy {
+	case TruncateTail:
+		truncated = truncateTail(ctx, s, counter, messages)
+		return truncated, len(messages) - len(truncated), nil
+	case TruncateMiddle:
+		kept, droppedMessages := truncateMiddle(ctx, s, counter, messages, s.maxContextTokens)
+		return kept, len(droppedMessages), nil
+	case TruncateSummarize:
+		return truncateSummarize(ctx, s, counter, messages)
 	}
 
-	return nil, fmt.Errorf("unknown truncation strategy: %s", strategy)
+	return nil, 0, fmt.Errorf("unknown truncation strategy: %s", strategy)
 }
 
 // Complete sends a simple text completion request.
@@ -1056,10 +1173,13 @@ func (c *Client) Chat(ctx context.Context, messages []Message) (*Response, error
 		return nil, ErrNoProvider
 	}
 
-	if s.logger != nil {
-		s.logger.Debug("chat request", requestMeta(messages, s)...)
-	}
+	return observe(ctx, s, s.operation(OperationChat), false, func(ctx context.Context) (*Response, error) {
+		return c.chat(ctx, s, messages)
+	})
+}
 
+// chat sends a Chat request with the snapshot s.
+func (c *Client) chat(ctx context.Context, s clientState, messages []Message) (*Response, error) {
 	if err := validateMessages(messages, s.maxInputLen); err != nil {
 		if s.logger != nil {
 			s.logger.Debug("chat validation failed", "error", err)
@@ -1070,41 +1190,74 @@ func (c *Client) Chat(ctx context.Context, messages []Message) (*Response, error
 	// Context window management: truncate if needed
 	truncatedMessages := messages
 	if s.maxContextTokens > 0 {
+		var dropped int
 		var err error
-		truncatedMessages, err = truncateMessages(ctx, s, messages)
+		truncatedMessages, dropped, err = truncateMessages(ctx, s, messages)
 		if err != nil {
 			if s.logger != nil {
 				s.logger.Debug("context truncation failed", "error", err)
 			}
 			return nil, fmt.Errorf("context truncation: %w", err)
 		}
-		if len(truncatedMessages) < len(messages) && s.logger != nil {
-			s.logger.Debug("context truncated",
-				"original_messages", len(messages),
-				"truncated_messages", len(truncatedMessages),
-			)
+		if dropped > 0 {
+			if s.logger != nil {
+				s.logger.Debug("context truncated",
+					"original_messages", len(messages),
+					"truncated_messages", len(truncatedMessages),
+				)
+			}
+			s.emit(ctx, HookEvent{Type: HookTruncation, TruncatedMessages: dropped})
 		}
 	}
 
 	req := buildRequest(truncatedMessages, s)
 
-	// Validate request parameters for security
-	if err := validateRequest(req); err != nil {
+	req, schemaTool, err := preflight(s, req, true)
+	if err != nil {
 		if s.logger != nil {
-			s.logger.Debug("chat request validation failed", "error", err)
+			s.logger.Debug("chat preflight failed", "error", err)
 		}
-		return nil, fmt.Errorf("invalid request: %w", err)
+		return nil, err
+	}
+	if s.autoCache != nil {
+		req = applyAutoCache(s.autoCache, req)
 	}
 
-	resp, err := retryWithBackoff(ctx, s, func(attemptCtx context.Context) (*Response, error) {
-		return s.provider.Complete(attemptCtx, req)
-	}, "chat")
+	if err := c.checkBudget(); err != nil {
+		return nil, err
+	}
+
+	var inputFlags []string
+	if s.moderation != nil && s.moderation.Input {
+		var err error
+		inputFlags, err = applyModeration(ctx, s, ModerationStageInput, moderationInput(messages))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	resp, err := s.chain().Complete(ctx, req)
 	if err == nil && resp != nil {
-		c.mu.Lock()
-		c.usage.Requests++
-		c.usage.InputTokens += int64(resp.InputTokens)
-		c.usage.OutputTokens += int64(resp.OutputTokens)
-		c.mu.Unlock()
+		if schemaTool != "" {
+			extractSchemaTool(resp, schemaTool)
+		}
+		if !resp.Cached {
+			c.recordUsage(ctx, s.responseUsage(resp))
+		}
+		for _, tc := range resp.ToolCalls {
+			s.emit(ctx, HookEvent{Type: HookToolCall, ToolName: tc.Name})
+		}
+
+		if s.moderation != nil {
+			resp.ModerationFlags = inputFlags
+			if s.moderation.Output && resp.Content != "" {
+				outputFlags, modErr := applyModeration(ctx, s, ModerationStageOutput, []string{resp.Content})
+				if modErr != nil {
+					return nil, modErr
+				}
+				resp.ModerationFlags = mergeFlags(resp.ModerationFlags, outputFlags)
+			}
+		}
 	}
 	return resp, err
 }
@@ -1124,85 +1277,152 @@ func (c *Client) Stream(ctx context.Context, messages []Message) <-chan StreamCh
 			return
 		}
 
-		if s.logger != nil {
-			s.logger.Debug("stream request", requestMeta(messages, s)...)
-		}
-
-		if err := validateMessages(messages, s.maxInputLen); err != nil {
-			if s.logger != nil {
-				s.logger.Debug("stream validation failed", "error", err)
-			}
-			out <- StreamChunk{Error: err}
+		ctx, op := s.startOperation(ctx, s.operation(OperationStream))
+		if op == nil && len(s.hooks) == 0 {
+			c.stream(ctx, s, messages, out)
 			return
 		}
 
-		// Context window management: truncate if needed
-		streamMessages := messages
-		if s.maxContextTokens > 0 {
-			var err error
-			streamMessages, err = truncateMessages(ctx, s, messages)
-			if err != nil {
-				out <- StreamChunk{Error: fmt.Errorf("context truncation: %w", err)}
-				return
+		// Relay chunks to report the first token, tool calls and usage.
+		s.emit(ctx, HookEvent{Type: HookStreamStart, Attempt: 1})
+		start := time.Now()
+		observed := make(chan StreamChunk)
+		go func() {
+			defer close(observed)
+			c.stream(ctx, s, messages, observed)
+		}()
+		var result OperationResult
+		first := true
+		for chunk := range observed {
+			if first && (chunk.Content != "" || chunk.Thinking != "" || chunk.ToolUse != nil) {
+				first = false
+				if op != nil {
+					op.FirstChunk()
+				}
+				s.emit(ctx, HookEvent{Type: HookFirstToken, Latency: time.Since(start)})
+			}
+			if chunk.ToolUse != nil {
+				s.emit(ctx, HookEvent{Type: HookToolCall, ToolName: chunk.ToolUse.Name})
 			}
+			if chunk.Done || chunk.Error != nil {
+				result = streamResult(chunk)
+			}
+			out <- chunk
+		}
+		if op != nil {
+			op.End(result)
 		}
+		event := resultEvent(result, time.Since(start))
+		event.Type = HookStreamEnd
+		s.emit(ctx, event)
+	}()
 
-		req := buildRequest(streamMessages, s)
+	return out
+}
 
-		// Validate request parameters
-		if err := validateRequest(req); err != nil {
-			if s.logger != nil {
-				s.logger.Debug("stream request validation failed", "error", err)
-			}
-			out <- StreamChunk{Error: fmt.Errorf("invalid request: %w", err)}
+// stream sends a Stream request with the snapshot s, writing chunks to out.
+func (c *Client) stream(ctx context.Context, s clientState, messages []Message, out chan<- StreamChunk) {
+	if err := validateMessages(messages, s.maxInputLen); err != nil {
+		if s.logger != nil {
+			s.logger.Debug("stream validation failed", "error", err)
+		}
+		out <- StreamChunk{Error: err}
+		return
+	}
+
+	// Context window management: truncate if needed
+	streamMessages := messages
+	if s.maxContextTokens > 0 {
+		var dropped int
+		var err error
+		streamMessages, dropped, err = truncateMessages(ctx, s, messages)
+		if err != nil {
+			out <- StreamChunk{Error: fmt.Errorf("context truncation: %w", err)}
 			return
 		}
+		if dropped > 0 {
+			s.emit(ctx, HookEvent{Type: HookTruncation, TruncatedMessages: dropped})
+		}
+	}
 
-		streamCtx, cancel := context.WithTimeout(ctx, s.timeout)
-		defer cancel()
+	req := buildRequest(streamMessages, s)
 
-		if s.logger != nil {
-			s.logger.Debug("stream starting", "provider", s.provider.Name(), "model", s.model)
+	req, _, err := preflight(s, req, false)
+	if err != nil {
+		out <- StreamChunk{Error: err}
+		return
+	}
+	if s.autoCache != nil {
+		req = applyAutoCache(s.autoCache, req)
+	}
+
+	if err := c.checkBudget(); err != nil {
+		out <- StreamChunk{Error: err}
+		return
+	}
+
+	var moderationFlags []string
+	if s.moderation != nil && s.moderation.Input {
+		var err error
+		moderationFlags, err = applyModeration(ctx, s, ModerationStageInput, moderationInput(messages))
+		if err != nil {
+			out <- StreamChunk{Error: err}
+			return
 		}
+	}
+
+	streamCtx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
 
-		chunks := s.provider.Stream(streamCtx, req)
+	chunks := s.chain().Stream(streamCtx, req)
 
-		var chunkCount int
-		// Use select to handle context cancellation properly and prevent goroutine leaks
-		for {
-			select {
-			case <-streamCtx.Done():
+	var chunkCount int
+	var content strings.Builder
+	// Use select to handle context cancellation properly and prevent goroutine leaks
+	for {
+		select {
+		case <-streamCtx.Done():
+			if s.logger != nil {
+				s.logger.Debug("stream context done", "provider", s.provider.Name(), "chunks", chunkCount, "error", streamCtx.Err())
+			}
+			out <- StreamChunk{Error: classifyError(streamCtx.Err(), streamCtx)}
+			return
+		case chunk, ok := <-chunks:
+			if !ok {
 				if s.logger != nil {
-					s.logger.Debug("stream context done", "provider", s.provider.Name(), "chunks", chunkCount, "error", streamCtx.Err())
+					s.logger.Debug("stream completed", "provider", s.provider.Name(), "chunks", chunkCount)
 				}
-				out <- StreamChunk{Error: classifyError(streamCtx.Err(), streamCtx)}
 				return
-			case chunk, ok := <-chunks:
-				if !ok {
-					if s.logger != nil {
-						s.logger.Debug("stream completed", "provider", s.provider.Name(), "chunks", chunkCount)
-					}
-					return
+			}
+			chunkCount++
+			// Record usage first: a blocked stream has still been paid for.
+			if chunk.Done {
+				usage := UsageStats{Requests: 1}
+				if chunk.Usage != nil {
+					usage = s.streamUsage(req.Model, chunk.Usage)
 				}
-				chunkCount++
-				out <- chunk
+				c.recordUsage(ctx, usage)
+			}
+			if s.moderation != nil {
+				content.WriteString(chunk.Content)
 				if chunk.Done {
-					if s.logger != nil {
-						s.logger.Debug("stream done", "provider", s.provider.Name(), "chunks", chunkCount)
-					}
-					return
-				}
-				if chunk.Error != nil {
-					if s.logger != nil {
-						s.logger.Debug("stream error", "provider", s.provider.Name(), "chunks", chunkCount, "error", sanitizeError(chunk.Error))
+					if s.moderation.Output && content.Len() > 0 {
+						outputFlags, err := applyModeration(ctx, s, ModerationStageOutput, []string{content.String()})
+						if err != nil {
+							out <- StreamChunk{Error: err}
+							return
+						}
+						moderationFlags = mergeFlags(moderationFlags, outputFlags)
 					}
-					return
+					chunk.ModerationFlags = moderationFlags
 				}
 			}
+			out <- chunk
+			if chunk.Done || chunk.Error != nil {
+				return
+			}
 		}
-	}()
-
-	return out
+	}
 }
 
 // StreamToWriter streams the response directly to an io.Writer.
@@ -1260,18 +1480,20 @@ func (c *Client) Embed(ctx context.Context, input ...string) (*EmbedResponse, er
 		Model: s.embeddingModel,
 	}
 
-	// Use generic retry helper
-	return retryWithBackoff(ctx, s, func(attemptCtx context.Context) (*EmbedResponse, error) {
-		return embedder.Embed(attemptCtx, embedReq)
-	}, "embed")
+	op := Operation{Name: OperationEmbed, Provider: s.provider.Name(), Model: s.embeddingModel}
+	return observe(ctx, s, op, false, func(ctx context.Context) (*EmbedResponse, error) {
+		return retryWithBackoff(ctx, s, func(attemptCtx context.Context) (*EmbedResponse, error) {
+			return embedder.Embed(attemptCtx, embedReq)
+		}, "embed")
+	})
 }
 
 // Models returns available models if the provider supports model listing.
+// Models found in the catalog (see LookupModel) are enriched with its metadata.
 func (c *Client) Models(ctx context.Context) ([]Model, error) {
-	c.mu.RLock()
-	p := c.provider
-	logger := c.logger
-	c.mu.RUnlock()
+	s := c.snapshot()
+	p := s.provider
+	logger := s.logger
 
 	if p == nil {
 		return nil, ErrNoProvider
@@ -1285,7 +1507,10 @@ func (c *Client) Models(ctx context.Context) ([]Model, error) {
 		logger.Debug("models list request", "provider", p.Name())
 	}
 
-	models, err := lister.Models(ctx)
+	models, err := observe(ctx, s, Operation{Name: OperationModels, Provider: p.Name()}, true, lister.Models)
+	for i := range models {
+		models[i] = enrichModel(models[i])
+	}
 	if logger != nil {
 		if err != nil {
 			logger.Debug("models list failed", "provider", p.Name(), "error", sanitizeError(err))
@@ -1324,7 +1549,9 @@ func (c *Client) Model() string {
 	return c.model
 }
 
-// Usage returns cumulative usage stats since client creation.
+// Usage returns cumulative usage stats since client creation: Chat and Stream
+// calls with the summary, moderation and semantic cache calls they make, and
+// Moderate and Rerank calls.
 func (c *Client) Usage() UsageStats {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
@@ -1370,7 +1597,8 @@ func (c *Client) SetEffort(effort string) {
 }
 
 // CountTokens estimates input tokens for the given messages.
-// Returns an error if the provider does not support token counting.
+// Returns an error if the provider does not support token counting and no
+// WithTokenCounter is set.
 func (c *Client) CountTokens(ctx context.Context, messages []Message) (*TokenCount, error) {
 	s := c.snapshot()
 
@@ -1378,7 +1606,7 @@ func (c *Client) CountTokens(ctx context.Context, messages []Message) (*TokenCou
 		return nil, ErrNoProvider
 	}
 
-	counter, ok := s.provider.(TokenCounter)
+	counter, ok := s.counter()
 	if !ok {
 		return nil, fmt.Errorf("%w: token counting", ErrNotSupported)
 	}
@@ -1397,16 +1625,18 @@ func (c *Client) CountTokens(ctx context.Context, messages []Message) (*TokenCou
 		)
 	}
 
-	return counter.CountTokens(ctx, req)
+	op := Operation{Name: OperationCountTokens, Provider: s.provider.Name(), Model: s.model}
+	return observe(ctx, s, op, true, func(ctx context.Context) (*TokenCount, error) {
+		return counter.CountTokens(ctx, req)
+	})
 }
 
 // CreateBatch submits a batch of requests for processing.
 // Returns an error if the provider does not support batch processing.
 func (c *Client) CreateBatch(ctx context.Context, requests []BatchRequest) (*Batch, error) {
-	c.mu.RLock()
-	p := c.provider
-	logger := c.logger
-	c.mu.RUnlock()
+	s := c.snapshot()
+	p := s.provider
+	logger := s.logger
 
 	if p == nil {
 		return nil, ErrNoProvider
@@ -1428,16 +1658,17 @@ func (c *Client) CreateBatch(ctx context.Context, requests []BatchRequest) (*Bat
 		)
 	}
 
-	return batcher.CreateBatch(ctx, requests)
+	return observe(ctx, s, Operation{Name: OperationCreateBatch, Provider: p.Name()}, true, func(ctx context.Context) (*Batch, error) {
+		return batcher.CreateBatch(ctx, requests)
+	})
 }
 
 // GetBatch retrieves the status and results of a batch job.
 // Returns an error if the provider does not support batch processing.
 func (c *Client) GetBatch(ctx context.Context, batchID string) (*Batch, error) {
-	c.mu.RLock()
-	p := c.provider
-	logger := c.logger
-	c.mu.RUnlock()
+	s := c.snapshot()
+	p := s.provider
+	logger := s.logger
 
 	if p == nil {
 		return nil, ErrNoProvider
@@ -1459,7 +1690,21 @@ func (c *Client) GetBatch(ctx context.Context, batchID string) (*Batch, error) {
 		)
 	}
 
-	return batcher.GetBatch(ctx, batchID)
+	batch, err := observe(ctx, s, Operation{Name: OperationGetBatch, Provider: p.Name()}, true, func(ctx context.Context) (*Batch, error) {
+		return batcher.GetBatch(ctx, batchID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, result := range batch.Results {
+		if result.Response == nil || result.Response.Cost != 0 {
+			continue
+		}
+		if pricing, ok := s.pricingFor(result.Response.Model); ok {
+			result.Response.Cost = pricing.BatchCost(result.Response)
+		}
+	}
+	return batch, nil
 }
 
 // HealthStatus represents the result of a provider health check.
@@ -1575,19 +1820,47 @@ func WithImageCount(n int) ImageOption {
 	}
 }
 
+// WithImageResponseFormat sets the response format: ImageResponseURL or ImageResponseB64JSON.
+func WithImageResponseFormat(format string) ImageOption {
+	return func(r *ImageRequest) {
+		r.ResponseFormat = format
+	}
+}
+
+// WithImageBackground sets the background: "transparent", "opaque" or "auto".
+// Transparency requires png or webp output.
+func WithImageBackground(background string) ImageOption {
+	return func(r *ImageRequest) {
+		r.Background = background
+	}
+}
+
+// WithImageOutputFormat sets the output format ("png", "jpeg", "webp") and,
+// for jpeg/webp, the compression level 0-100 (0 = provider default).
+func WithImageOutputFormat(format string, compression int) ImageOption {
+	return func(r *ImageRequest) {
+		r.OutputFormat = format
+		r.OutputCompression = compression
+	}
+}
+
+// WithImageStyle sets the image style: "vivid" or "natural" (dall-e-3).
+func WithImageStyle(style string) ImageOption {
+	return func(r *ImageRequest) {
+		r.Style = style
+	}
+}
+
 // GenerateImage creates images from a text prompt.
 // Returns an error if the provider does not support image generation.
 func (c *Client) GenerateImage(ctx context.Context, prompt string, opts ...ImageOption) (*ImageResponse, error) {
-	c.mu.RLock()
-	p := c.provider
-	logger := c.logger
-	c.mu.RUnlock()
+	s := c.snapshot()
 
-	if p == nil {
+	if s.provider == nil {
 		return nil, ErrNoProvider
 	}
 
-	generator, ok := p.(ImageGenerator)
+	generator, ok := s.provider.(ImageGenerator)
 	if !ok {
 		return nil, fmt.Errorf("%w: image generation", ErrNotSupported)
 	}
@@ -1604,25 +1877,34 @@ func (c *Client) GenerateImage(ctx context.Context, prompt string, opts ...Image
 		return nil, err
 	}
 
-	if logger != nil {
-		logger.Debug("generate image request",
-			"provider", p.Name(),
+	if s.logger != nil {
+		s.logger.Debug("generate image request",
+			"provider", s.provider.Name(),
 			"model", req.Model,
 			"size", req.Size,
 			"n", req.N,
 		)
 	}
 
-	return generator.GenerateImage(ctx, req)
+	op := Operation{Name: OperationGenerateImage, Provider: s.provider.Name(), Model: req.Model}
+	resp, err := observe(ctx, s, op, true, func(ctx context.Context) (*ImageResponse, error) {
+		return generator.GenerateImage(ctx, req)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := s.downloadImages(ctx, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
 }
 
 // Speak converts text to speech.
 // Returns an error if the provider does not support text-to-speech.
 func (c *Client) Speak(ctx context.Context, req *SpeechRequest) (*SpeechResponse, error) {
-	c.mu.RLock()
-	p := c.provider
-	logger := c.logger
-	c.mu.RUnlock()
+	s := c.snapshot()
+	p := s.provider
+	logger := s.logger
 
 	if p == nil {
 		return nil, ErrNoProvider
@@ -1646,22 +1928,32 @@ func (c *Client) Speak(ctx context.Context, req *SpeechRequest) (*SpeechResponse
 		)
 	}
 
-	return speaker.Speak(ctx, req)
+	op := Operation{Name: OperationSpeak, Provider: p.Name(), Model: req.Model}
+	return observe(ctx, s, op, true, func(ctx context.Context) (*SpeechResponse, error) {
+		ctx, cancel := context.WithTimeout(ctx, s.timeout)
+		defer cancel()
+		resp, err := speaker.Speak(ctx, req)
+		if err != nil {
+			return nil, classifyError(err, ctx)
+		}
+		return resp, nil
+	})
 }
 
 // Transcribe converts speech to text.
+// Audio larger than the chunk size (see WithAudioChunkSize) is split into
+// chunks that are transcribed in order and merged, with timestamps offset
+// to the full recording. Splitting supports WAV and MP3. The client timeout
+// applies to each chunk.
 // Returns an error if the provider does not support speech-to-text.
 func (c *Client) Transcribe(ctx context.Context, req *TranscribeRequest) (*TranscribeResponse, error) {
-	c.mu.RLock()
-	p := c.provider
-	logger := c.logger
-	c.mu.RUnlock()
+	s := c.snapshot()
 
-	if p == nil {
+	if s.provider == nil {
 		return nil, ErrNoProvider
 	}
 
-	transcriber, ok := p.(Transcriber)
+	transcriber, ok := s.provider.(Transcriber)
 	if !ok {
 		return nil, fmt.Errorf("%w: speech-to-text", ErrNotSupported)
 	}
@@ -1670,13 +1962,20 @@ func (c *Client) Transcribe(ctx context.Context, req *TranscribeRequest) (*Trans
 		return nil, ErrEmptyInput
 	}
 
-	if logger != nil {
-		logger.Debug("transcribe request",
-			"provider", p.Name(),
+	if s.logger != nil {
+		s.logger.Debug("transcribe request",
+			"provider", s.provider.Name(),
 			"model", req.Model,
 			"format", req.Format,
+			"bytes", len(req.Audio),
 		)
 	}
 
-	return transcriber.Transcribe(ctx, req)
+	op := Operation{Name: OperationTranscribe, Provider: s.provider.Name(), Model: req.Model}
+	return observe(ctx, s, op, true, func(ctx context.Context) (*TranscribeResponse, error) {
+		if len(req.Audio) <= s.audioChunkSize {
+			return s.transcribeOnce(ctx, transcriber, req)
+		}
+		return s.transcribeChunked(ctx, transcriber, req)
+	})
 }
@@ -0,0 +1,296 @@
+// Package allmotel instruments allm clients with OpenTelemetry traces and
+// metrics following the GenAI semantic conventions.
+//
+//	client := allm.New(provider.Anthropic(""), allm.WithObserver(allmotel.New()))
+//
+// Every client operation gets a client span named "{operation} {model}", with
+// a child span per attempt of retried operations. Provider HTTP requests
+// carry the attempt's trace context. Durations, time to first chunk of
+// streams and token usage are recorded as histograms.
+package allmotel
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/kusandriadi/allm-go"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.41.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentationName identifies the tracer and meter.
+const instrumentationName = "github.com/kusandriadi/allm-go/allmotel"
+
+// Attribute keys not covered by the semantic conventions.
+const (
+	operationKey   = attribute.Key("allm.operation")      // allm operation name, e.g. "stream"
+	correlationKey = attribute.Key("allm.correlation_id") // see allm.WithCorrelationID
+	attemptKey     = attribute.Key("allm.attempt")        // 1-based attempt number
+	costKey        = attribute.Key("allm.cost_usd")       // response cost in USD
+)
+
+// Histogram bucket boundaries recommended by the GenAI semantic conventions.
+var (
+	durationBuckets = []float64{0.01, 0.02, 0.04, 0.08, 0.16, 0.32, 0.64, 1.28, 2.56, 5.12, 10.24, 20.48, 40.96, 81.92}
+	tokenBuckets    = []float64{1, 4, 16, 64, 256, 1024, 4096, 16384, 65536, 262144, 1048576, 4194304, 16777216, 67108864}
+)
+
+// Option configures an Observer.
+type Option func(*config)
+
+type config struct {
+	tracerProvider trace.TracerProvider
+	meterProvider  metric.MeterProvider
+	propagator     propagation.TextMapPropagator
+}
+
+// WithTracerProvider sets the tracer provider (default: otel.GetTracerProvider()).
+func WithTracerProvider(tp trace.TracerProvider) Option {
+	return func(c *config) {
+		c.tracerProvider = tp
+	}
+}
+
+// WithMeterProvider sets the meter provider (default: otel.GetMeterProvider()).
+func WithMeterProvider(mp metric.MeterProvider) Option {
+	return func(c *config) {
+		c.meterProvider = mp
+	}
+}
+
+// WithPropagator sets the propagator that injects trace context into provider
+// HTTP requests (default: otel.GetTextMapPropagator()).
+func WithPropagator(p propagation.TextMapPropagator) Option {
+	return func(c *config) {
+		c.propagator = p
+	}
+}
+
+// Observer is an allm.Observer recording OpenTelemetry spans and metrics.
+// It also implements allm.HeaderInjector to propagate trace context.
+type Observer struct {
+	tracer     trace.Tracer
+	propagator propagation.TextMapPropagator
+	duration   metric.Float64Histogram
+	firstChunk metric.Float64Histogram
+	tokens     metric.Int64Histogram
+}
+
+// New creates an Observer. Instruments that fail to register are replaced by
+// no-ops, so New never fails.
+func New(opts ...Option) *Observer {
+	cfg := config{
+		tracerProvider: otel.GetTracerProvider(),
+		meterProvider:  otel.GetMeterProvider(),
+		propagator:     otel.GetTextMapPropagator(),
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	meter := cfg.meterProvider.Meter(instrumentationName)
+	o := &Observer{
+		tracer:     cfg.tracerProvider.Tracer(instrumentationName),
+		propagator: cfg.propagator,
+	}
+	var err error
+	if o.duration, err = meter.Float64Histogram("gen_ai.client.operation.duration",
+		metric.WithDescription("GenAI operation duration."),
+		metric.WithUnit("s"),
+		metric.WithExplicitBucketBoundaries(durationBuckets...)); err != nil {
+		otel.Handle(err)
+	}
+	if o.firstChunk, err = meter.Float64Histogram("gen_ai.client.operation.time_to_first_chunk",
+		metric.WithDescription("Time to receive the first chunk of a streamed response."),
+		metric.WithUnit("s"),
+		metric.WithExplicitBucketBoundaries(durationBuckets...)); err != nil {
+		otel.Handle(err)
+	}
+	if o.tokens, err = meter.Int64Histogram("gen_ai.client.token.usage",
+		metric.WithDescription("Number of input and output tokens used."),
+		metric.WithUnit("{token}"),
+		metric.WithExplicitBucketBoundaries(tokenBuckets...)); err != nil {
+		otel.Handle(err)
+	}
+	return o
+}
+
+// StartOperation starts the span of a client operation.
+func (o *Observer) StartOperation(ctx context.Context, op allm.Operation) (context.Context, allm.OperationObserver) {
+	name := genAIOperation(op.Name)
+	spanName := name
+	if op.Model != "" {
+		spanName += " " + op.Model
+	}
+
+	attrs := []attribute.KeyValue{
+		semconv.GenAIOperationNameKey.String(name),
+		semconv.GenAIProviderNameKey.String(op.Provider),
+		operationKey.String(op.Name),
+	}
+	if op.Model != "" {
+		attrs = append(attrs, semconv.GenAIRequestModel(op.Model))
+	}
+	spanAttrs := slices.Clone(attrs)
+	if op.CorrelationID != "" {
+		spanAttrs = append(spanAttrs, correlationKey.String(op.CorrelationID))
+	}
+	if op.MaxTokens > 0 {
+		spanAttrs = append(spanAttrs, semconv.GenAIRequestMaxTokens(op.MaxTokens))
+	}
+	if op.Temperature > 0 {
+		spanAttrs = append(spanAttrs, semconv.GenAIRequestTemperature(op.Temperature))
+	}
+	if op.Name == allm.OperationStream {
+		spanAttrs = append(spanAttrs, semconv.GenAIRequestStream(true))
+	}
+
+	ctx, span := o.tracer.Start(ctx, spanName,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(spanAttrs...))
+	return ctx, &operation{
+		observer: o,
+		ctx:      ctx,
+		span:     span,
+		name:     spanName,
+		attrs:    slices.Clip(attrs),
+		start:    time.Now(),
+	}
+}
+
+// InjectHeaders adds the trace context of ctx to provider HTTP request headers.
+func (o *Observer) InjectHeaders(ctx context.Context, h http.Header) {
+	o.propagator.Inject(ctx, propagation.HeaderCarrier(h))
+}
+
+// operation is the span and metric state of one client operation.
+type operation struct {
+	observer *Observer
+	ctx      context.Context
+	span     trace.Span
+	name     string
+	attrs    []attribute.KeyValue // metric attributes
+	start    time.Time
+}
+
+// StartAttempt starts a child span for one provider call.
+func (op *operation) StartAttempt(ctx context.Context, attempt int) (context.Context, func(error)) {
+	ctx, span := op.observer.tracer.Start(ctx, op.name+" attempt",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attemptKey.Int(attempt)))
+	return ctx, func(err error) {
+		if err != nil {
+			span.SetAttributes(semconv.ErrorTypeKey.String(errorType(err)))
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}
+}
+
+// FirstChunk records the time to first chunk of a stream.
+func (op *operation) FirstChunk() {
+	elapsed := time.Since(op.start).Seconds()
+	op.span.SetAttributes(semconv.GenAIResponseTimeToFirstChunk(elapsed))
+	if op.observer.firstChunk != nil {
+		op.observer.firstChunk.Record(op.ctx, elapsed, metric.WithAttributes(op.attrs...))
+	}
+}
+
+// End ends the operation span and records its metrics.
+func (op *operation) End(result allm.OperationResult) {
+	attrs := op.attrs
+	if result.Model != "" {
+		model := semconv.GenAIResponseModel(result.Model)
+		attrs = append(attrs, model)
+		op.span.SetAttributes(model)
+	}
+
+	if result.Error != nil {
+		typ := semconv.ErrorTypeKey.String(errorType(result.Error))
+		attrs = append(attrs, typ)
+		op.span.SetAttributes(typ)
+		op.span.SetStatus(codes.Error, result.Error.Error())
+	} else {
+		spanAttrs := []attribute.KeyValue{
+			semconv.GenAIUsageInputTokens(result.InputTokens),
+			semconv.GenAIUsageOutputTokens(result.OutputTokens),
+		}
+		if result.CacheReadTokens > 0 {
+			spanAttrs = append(spanAttrs, semconv.GenAIUsageCacheReadInputTokens(result.CacheReadTokens))
+		}
+		if result.CacheWriteTokens > 0 {
+			spanAttrs = append(spanAttrs, semconv.GenAIUsageCacheCreationInputTokens(result.CacheWriteTokens))
+		}
+		if len(result.FinishReasons) > 0 {
+			spanAttrs = append(spanAttrs, semconv.GenAIResponseFinishReasons(result.FinishReasons...))
+		}
+		if result.ID != "" {
+			spanAttrs = append(spanAttrs, semconv.GenAIResponseID(result.ID))
+		}
+		if result.Cost > 0 {
+			spanAttrs = append(spanAttrs, costKey.Float64(result.Cost))
+		}
+		op.span.SetAttributes(spanAttrs...)
+	}
+	op.span.End()
+
+	o := op.observer
+	if o.duration != nil {
+		o.duration.Record(op.ctx, time.Since(op.start).Seconds(), metric.WithAttributes(attrs...))
+	}
+	if o.tokens != nil && result.Error == nil {
+		attrs = slices.Clip(attrs)
+		if result.InputTokens > 0 {
+			o.tokens.Record(op.ctx, int64(result.InputTokens),
+				metric.WithAttributes(append(attrs, semconv.GenAITokenTypeInput)...))
+		}
+		if result.OutputTokens > 0 {
+			o.tokens.Record(op.ctx, int64(result.OutputTokens),
+				metric.WithAttributes(append(attrs, semconv.GenAITokenTypeOutput)...))
+		}
+	}
+}
+
+// genAIOperation maps an allm operation to a gen_ai.operation.name value.
+func genAIOperation(name string) string {
+	switch name {
+	case allm.OperationChat, allm.OperationStream:
+		return "chat"
+	case allm.OperationEmbed:
+		return "embeddings"
+	}
+	return name
+}
+
+// errorType returns the error.type attribute value for an allm error.
+func errorType(err error) string {
+	types := []struct {
+		err error
+		typ string
+	}{
+		{allm.ErrRateLimited, "rate_limited"},
+		{allm.ErrOverloaded, "overloaded"},
+		{allm.ErrServerError, "server_error"},
+		{allm.ErrTimeout, "timeout"},
+		{allm.ErrCanceled, "canceled"},
+		{allm.ErrContentBlocked, "content_blocked"},
+		{allm.ErrBudgetExceeded, "budget_exceeded"},
+		{allm.ErrNotSupported, "not_supported"},
+		{allm.ErrEmptyResponse, "empty_response"},
+		{allm.ErrProvider, "provider_error"},
+	}
+	for _, t := range types {
+		if errors.Is(err, t.err) {
+			return t.typ
+		}
+	}
+	return "_OTHER"
+}
@@ -0,0 +1,198 @@
+package allmotel
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/kusandriadi/allm-go"
+	"github.com/kusandriadi/allm-go/allmtest"
+	"github.com/kusandriadi/allm-go/provider"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// newTestObserver returns an Observer recording into in-memory exporters.
+func newTestObserver() (*Observer, *tracetest.InMemoryExporter, *sdkmetric.ManualReader) {
+	spans := tracetest.NewInMemoryExporter()
+	reader := sdkmetric.NewManualReader()
+	o := New(
+		WithTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(spans))),
+		WithMeterProvider(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))),
+		WithPropagator(propagation.TraceContext{}),
+	)
+	return o, spans, reader
+}
+
+// attr returns the value of key on span.
+func attr(span tracetest.SpanStub, key string) (attribute.Value, bool) {
+	for _, kv := range span.Attributes {
+		if string(kv.Key) == key {
+			return kv.Value, true
+		}
+	}
+	return attribute.Value{}, false
+}
+
+// histogram returns the data points of the named histogram.
+func histogram[N int64 | float64](t *testing.T, reader *sdkmetric.ManualReader, name string) []metricdata.HistogramDataPoint[N] {
+	t.Helper()
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatalf("Collect: %v", err)
+	}
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if m.Name == name {
+				return m.Data.(metricdata.Histogram[N]).DataPoints
+			}
+		}
+	}
+	return nil
+}
+
+func TestChatSpan(t *testing.T) {
+	o, spans, reader := newTestObserver()
+	mock := allmtest.NewMockProvider("anthropic", allmtest.WithResponse(&allm.Response{
+		Content:      "hi",
+		Model:        "claude-haiku-4-5-20251001",
+		InputTokens:  12,
+		OutputTokens: 5,
+		FinishReason: "end_turn",
+	}))
+	client := allm.New(mock, allm.WithModel("claude-haiku-4-5"), allm.WithMaxTokens(100), allm.WithObserver(o))
+
+	if _, err := client.Complete(context.Background(), "hello"); err != nil {
+		t.Fatalf("Complete: %v", err)
+	}
+
+	stubs := spans.GetSpans()
+	if len(stubs) != 2 {
+		t.Fatalf("got %d spans, want operation and attempt", len(stubs))
+	}
+	attempt, op := stubs[0], stubs[1]
+	if op.Name != "chat claude-haiku-4-5" || attempt.Parent.SpanID() != op.SpanContext.SpanID() {
+		t.Errorf("spans = %q, %q (parent %v)", op.Name, attempt.Name, attempt.Parent.SpanID())
+	}
+	checks := map[string]attribute.Value{
+		"gen_ai.operation.name":          attribute.StringValue("chat"),
+		"gen_ai.provider.name":           attribute.StringValue("anthropic"),
+		"gen_ai.request.model":           attribute.StringValue("claude-haiku-4-5"),
+		"gen_ai.request.max_tokens":      attribute.IntValue(100),
+		"gen_ai.response.model":          attribute.StringValue("claude-haiku-4-5-20251001"),
+		"gen_ai.usage.input_tokens":      attribute.IntValue(12),
+		"gen_ai.usage.output_tokens":     attribute.IntValue(5),
+		"gen_ai.response.finish_reasons": attribute.StringSliceValue([]string{"end_turn"}),
+	}
+	for key, want := range checks {
+		if got, ok := attr(op, key); !ok || got.Emit() != want.Emit() {
+			t.Errorf("%s = %v, want %v", key, got.Emit(), want.Emit())
+		}
+	}
+
+	if points := histogram[float64](t, reader, "gen_ai.client.operation.duration"); len(points) != 1 || points[0].Count != 1 {
+		t.Errorf("duration points = %+v", points)
+	}
+	points := histogram[int64](t, reader, "gen_ai.client.token.usage")
+	if len(points) != 2 || points[0].Sum+points[1].Sum != 17 {
+		t.Errorf("token usage points = %+v", points)
+	}
+}
+
+func TestRetrySpans(t *testing.T) {
+	o, spans, _ := newTestObserver()
+	mock := allmtest.NewMockProvider("test", allmtest.WithError(allm.ErrRateLimited))
+	client := allm.New(mock, allm.WithMaxRetries(2), allm.WithRetryBaseDelay(time.Millisecond), allm.WithObserver(o))
+
+	_, err := client.Complete(context.Background(), "hello")
+	if !errors.Is(err, allm.ErrRateLimited) {
+		t.Fatalf("expected ErrRateLimited, got %v", err)
+	}
+
+	stubs := spans.GetSpans()
+	if len(stubs) != 4 {
+		t.Fatalf("got %d spans, want 3 attempts and the operation", len(stubs))
+	}
+	for i, span := range stubs[:3] {
+		if n, _ := attr(span, "allm.attempt"); n.AsInt64() != int64(i+1) || span.Status.Code != codes.Error {
+			t.Errorf("attempt span %d = %+v", i, span.Attributes)
+		}
+	}
+	op := stubs[3]
+	if typ, _ := attr(op, "error.type"); typ.AsString() != "rate_limited" || op.Status.Code != codes.Error {
+		t.Errorf("operation span = %+v, %+v", op.Attributes, op.Status)
+	}
+}
+
+func TestStreamTimeToFirstChunk(t *testing.T) {
+	o, spans, reader := newTestObserver()
+	mock := allmtest.NewMockProvider("test", allmtest.WithStreamChunks([]allm.StreamChunk{
+		{Content: "a"},
+		{Content: "b"},
+		{Done: true, Usage: &allm.StreamUsage{InputTokens: 3, OutputTokens: 2}},
+	}))
+	client := allm.New(mock, allm.WithObserver(o))
+
+	for chunk := range client.Stream(context.Background(), []allm.Message{{Role: allm.RoleUser, Content: "hi"}}) {
+		if chunk.Error != nil {
+			t.Fatalf("stream error: %v", chunk.Error)
+		}
+	}
+
+	stubs := spans.GetSpans()
+	if len(stubs) != 1 {
+		t.Fatalf("got %d spans, want 1", len(stubs))
+	}
+	if _, ok := attr(stubs[0], "gen_ai.response.time_to_first_chunk"); !ok {
+		t.Error("missing time to first chunk")
+	}
+	if v, _ := attr(stubs[0], "gen_ai.usage.output_tokens"); v.AsInt64() != 2 {
+		t.Errorf("output tokens = %v", v.Emit())
+	}
+	if points := histogram[float64](t, reader, "gen_ai.client.operation.time_to_first_chunk"); len(points) != 1 {
+		t.Errorf("time to first chunk points = %+v", points)
+	}
+}
+
+func TestEmbedSpan(t *testing.T) {
+	o, spans, _ := newTestObserver()
+	client := allm.New(allmtest.NewMockProvider("openai"), allm.WithEmbeddingModel("text-embedding-3-small"), allm.WithObserver(o))
+
+	if _, err := client.Embed(context.Background(), "hello"); err != nil {
+		t.Fatalf("Embed: %v", err)
+	}
+	stubs := spans.GetSpans()
+	if op := stubs[len(stubs)-1]; op.Name != "embeddings text-embedding-3-small" {
+		t.Errorf("span name = %q", op.Name)
+	}
+}
+
+func TestTraceContextPropagation(t *testing.T) {
+	var traceparent string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("Traceparent")
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"id":"1","object":"chat.completion","model":"m","choices":[{"index":0,"message":{"role":"assistant","content":"hi"},"finish_reason":"stop"}],"usage":{"prompt_tokens":1,"completion_tokens":1,"total_tokens":2}}`))
+	}))
+	defer server.Close()
+
+	o, spans, _ := newTestObserver()
+	client := allm.New(provider.Local(server.URL), allm.WithModel("m"), allm.WithObserver(o))
+	if _, err := client.Complete(context.Background(), "hello"); err != nil {
+		t.Fatalf("Complete: %v", err)
+	}
+
+	attempt := spans.GetSpans()[0]
+	want := "00-" + attempt.SpanContext.TraceID().String() + "-" + attempt.SpanContext.SpanID().String() + "-01"
+	if traceparent != want {
+		t.Errorf("traceparent = %q, want %q", traceparent, want)
+	}
+}
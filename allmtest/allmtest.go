@@ -19,16 +19,50 @@
 //
 //	req := mock.LastRequest() // inspect what was sent
 //	count := mock.CallCount() // how many times called
+//	mock.AssertLastMessage(t, allm.RoleUser, "Hello")
+//
+// Script multi-step flows such as tool loops and retries:
+//
+//	mock := allmtest.NewMockProvider("test",
+//	    allmtest.WithResultSequence(
+//	        allmtest.MockResult{Err: allm.ErrServerError},
+//	        allmtest.MockResult{Response: &allm.Response{Content: "OK"}},
+//	    ),
+//	)
+//
+// Use Recorder to record real provider HTTP traffic into a cassette file
+// and replay it deterministically:
+//
+//	rec, _ := allmtest.NewRecorder("testdata/chat.json", allmtest.ModeReplay)
+//	client := allm.New(provider.OpenAI("", provider.WithOpenAIHTTPClient(rec.Client())))
+//
+// Use NewFakeAnthropicServer and NewFakeOpenAIServer to test providers end
+// to end against scripted wire-level responses:
+//
+//	srv := allmtest.NewFakeOpenAIServer()
+//	defer srv.Close()
+//	srv.Enqueue(allmtest.FakeText("Hello!"))
+//	p := provider.OpenAI("key", provider.WithOpenAIHTTPClient(srv.Client()))
+//
+// Use Conformance to check that a Provider implementation follows the allm
+// contract against those servers.
 package allmtest
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"sync"
+	"time"
 
 	"github.com/kusandriadi/allm-go"
 )
 
 // MockProvider implements allm.Provider, allm.ModelLister, and allm.Embedder for testing.
+// It also implements allm.TokenCounter (an offline estimate unless WithTokenCount
+// is set), allm.BatchProvider, allm.ImageGenerator, allm.Speaker and
+// allm.Transcriber; the latter return allm.ErrNotSupported unless configured
+// with WithBatch, WithImageResponse, WithSpeechResponse or WithTranscribeResponse.
 type MockProvider struct {
 	mu            sync.Mutex
 	name          string
@@ -38,6 +72,26 @@ type MockProvider struct {
 	models        []allm.Model
 	embedResponse *allm.EmbedResponse
 	requests      []*allm.Request
+
+	handler    func(*allm.Request) (*allm.Response, error)
+	sequence   []MockResult
+	next       int // index of the next sequence result
+	latencies  []time.Duration
+	failAfter  int // stream chunks sent before streamErr (-1 = never)
+	streamErr  error
+	stallAfter int // stream chunks sent before stalling (-1 = never)
+
+	tokenCount    *allm.TokenCount
+	batch         *allm.Batch
+	image         *allm.ImageResponse
+	speech        *allm.SpeechResponse
+	transcription *allm.TranscribeResponse
+}
+
+// MockResult is one scripted result of a Complete or Stream call.
+type MockResult struct {
+	Response *allm.Response
+	Err      error
 }
 
 // MockOption configures the MockProvider.
@@ -57,6 +111,42 @@ func WithError(err error) MockOption {
 	}
 }
 
+// WithResponseSequence sets responses returned by successive Complete and
+// Stream calls, e.g. a tool call followed by a final answer. Once the
+// sequence is used up, calls return the WithResponse response or WithError error.
+func WithResponseSequence(responses ...*allm.Response) MockOption {
+	return func(m *MockProvider) {
+		for _, resp := range responses {
+			m.sequence = append(m.sequence, MockResult{Response: resp})
+		}
+	}
+}
+
+// WithResultSequence is like WithResponseSequence but each call may fail,
+// e.g. to test retry-then-succeed paths.
+func WithResultSequence(results ...MockResult) MockOption {
+	return func(m *MockProvider) {
+		m.sequence = append(m.sequence, results...)
+	}
+}
+
+// WithHandler computes each Complete and Stream result from the request.
+// It takes precedence over all other response options.
+func WithHandler(fn func(*allm.Request) (*allm.Response, error)) MockOption {
+	return func(m *MockProvider) {
+		m.handler = fn
+	}
+}
+
+// WithLatency delays Complete and Stream calls. Each call uses the next
+// duration and the last one repeats, so WithLatency(0, time.Second) makes
+// the second and later calls slow. Delays end early if the context is done.
+func WithLatency(durations ...time.Duration) MockOption {
+	return func(m *MockProvider) {
+		m.latencies = durations
+	}
+}
+
 // WithStreamChunks sets the chunks returned by Stream.
 func WithStreamChunks(chunks []allm.StreamChunk) MockOption {
 	return func(m *MockProvider) {
@@ -64,6 +154,22 @@ func WithStreamChunks(chunks []allm.StreamChunk) MockOption {
 	}
 }
 
+// WithStreamError makes streams fail with err after n chunks.
+func WithStreamError(n int, err error) MockOption {
+	return func(m *MockProvider) {
+		m.failAfter = n
+		m.streamErr = err
+	}
+}
+
+// WithStreamStall makes streams stop after n chunks until the context is
+// done, then send the context error, to test stream timeouts.
+func WithStreamStall(n int) MockOption {
+	return func(m *MockProvider) {
+		m.stallAfter = n
+	}
+}
+
 // WithModels sets the models returned by Models.
 func WithModels(models []allm.Model) MockOption {
 	return func(m *MockProvider) {
@@ -78,6 +184,41 @@ func WithEmbedResponse(resp *allm.EmbedResponse) MockOption {
 	}
 }
 
+// WithTokenCount sets the result of CountTokens.
+func WithTokenCount(count *allm.TokenCount) MockOption {
+	return func(m *MockProvider) {
+		m.tokenCount = count
+	}
+}
+
+// WithBatch sets the batch returned by CreateBatch and GetBatch.
+func WithBatch(batch *allm.Batch) MockOption {
+	return func(m *MockProvider) {
+		m.batch = batch
+	}
+}
+
+// WithImageResponse sets the response returned by GenerateImage.
+func WithImageResponse(resp *allm.ImageResponse) MockOption {
+	return func(m *MockProvider) {
+		m.image = resp
+	}
+}
+
+// WithSpeechResponse sets the response returned by Speak.
+func WithSpeechResponse(resp *allm.SpeechResponse) MockOption {
+	return func(m *MockProvider) {
+		m.speech = resp
+	}
+}
+
+// WithTranscribeResponse sets the response returned by Transcribe.
+func WithTranscribeResponse(resp *allm.TranscribeResponse) MockOption {
+	return func(m *MockProvider) {
+		m.transcription = resp
+	}
+}
+
 // NewMockProvider creates a new MockProvider with the given name and options.
 func NewMockProvider(name string, opts ...MockOption) *MockProvider {
 	m := &MockProvider{
@@ -87,6 +228,8 @@ func NewMockProvider(name string, opts ...MockOption) *MockProvider {
 			Provider: name,
 			Model:    "mock-model",
 		},
+		failAfter:  -1,
+		stallAfter: -1,
 	}
 	for _, opt := range opts {
 		opt(m)
@@ -105,43 +248,138 @@ func (m *MockProvider) Available() bool {
 }
 
 // Complete records the request and returns the configured response or error.
-func (m *MockProvider) Complete(_ context.Context, req *allm.Request) (*allm.Response, error) {
-	m.mu.Lock()
-	m.requests = append(m.requests, req)
-	m.mu.Unlock()
-
-	if m.err != nil {
-		return nil, m.err
+func (m *MockProvider) Complete(ctx context.Context, req *allm.Request) (*allm.Response, error) {
+	if err := wait(ctx, m.record(req)); err != nil {
+		return nil, err
 	}
-	return m.response, nil
+	return m.result(req)
 }
 
 // Stream records the request and returns configured chunks.
-func (m *MockProvider) Stream(_ context.Context, req *allm.Request) <-chan allm.StreamChunk {
-	m.mu.Lock()
-	m.requests = append(m.requests, req)
-	m.mu.Unlock()
+func (m *MockProvider) Stream(ctx context.Context, req *allm.Request) <-chan allm.StreamChunk {
+	delay := m.record(req)
 
 	out := make(chan allm.StreamChunk)
 	go func() {
 		defer close(out)
-		if m.err != nil {
-			out <- allm.StreamChunk{Error: m.err}
+		if err := wait(ctx, delay); err != nil {
+			out <- allm.StreamChunk{Error: err}
 			return
 		}
-		if len(m.chunks) > 0 {
-			for _, chunk := range m.chunks {
-				out <- chunk
-			}
+		chunks, err := m.streamChunks(req)
+		if err != nil {
+			out <- allm.StreamChunk{Error: err}
 			return
 		}
-		// Default: send response as single chunk
-		out <- allm.StreamChunk{Content: m.response.Content}
-		out <- allm.StreamChunk{Done: true}
+
+		m.mu.Lock()
+		failAfter, streamErr, stallAfter := m.failAfter, m.streamErr, m.stallAfter
+		m.mu.Unlock()
+		for i := 0; i <= len(chunks); i++ {
+			switch {
+			case i == failAfter:
+				out <- allm.StreamChunk{Error: streamErr}
+				return
+			case i == stallAfter:
+				<-ctx.Done()
+				out <- allm.StreamChunk{Error: ctx.Err()}
+				return
+			case i == len(chunks):
+				return
+			}
+			select {
+			case out <- chunks[i]:
+			case <-ctx.Done():
+				return
+			}
+		}
 	}()
 	return out
 }
 
+// record stores req and returns the latency of the call.
+func (m *MockProvider) record(req *allm.Request) time.Duration {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.requests = append(m.requests, req)
+	if len(m.latencies) == 0 {
+		return 0
+	}
+	return m.latencies[min(len(m.requests), len(m.latencies))-1]
+}
+
+// result returns the scripted result of a call: the handler's, the next
+// sequence result, or the configured error or response.
+func (m *MockProvider) result(req *allm.Request) (*allm.Response, error) {
+	m.mu.Lock()
+	handler := m.handler
+	if handler == nil && m.next < len(m.sequence) {
+		r := m.sequence[m.next]
+		m.next++
+		m.mu.Unlock()
+		return checkResult(r.Response, r.Err)
+	}
+	resp, err := m.response, m.err
+	m.mu.Unlock()
+
+	if handler != nil {
+		return checkResult(handler(req))
+	}
+	if err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// checkResult rejects scripted results with neither a response nor an error.
+func checkResult(resp *allm.Response, err error) (*allm.Response, error) {
+	if resp == nil && err == nil {
+		return nil, errors.New("allmtest: scripted result has neither Response nor Err")
+	}
+	return resp, err
+}
+
+// streamChunks returns the chunks of a Stream call. WithStreamChunks is used
+// unless a handler or sequence result applies; responses are sent as one
+// content chunk followed by their tool calls, with token usage on the final
+// chunk.
+func (m *MockProvider) streamChunks(req *allm.Request) ([]allm.StreamChunk, error) {
+	m.mu.Lock()
+	scripted := m.handler != nil || m.next < len(m.sequence)
+	chunks, err := m.chunks, m.err
+	m.mu.Unlock()
+
+	if !scripted && err == nil && len(chunks) > 0 {
+		return chunks, nil
+	}
+	resp, err := m.result(req)
+	if err != nil {
+		return nil, err
+	}
+	// Default: send response as single chunk, then its tool calls
+	chunks = []allm.StreamChunk{{Content: resp.Content}}
+	for _, tc := range resp.ToolCalls {
+		chunks = append(chunks, allm.StreamChunk{ToolUse: &allm.StreamToolUse{Name: tc.Name, Input: tc.Arguments}})
+	}
+	usage := &allm.StreamUsage{InputTokens: resp.InputTokens, OutputTokens: resp.OutputTokens}
+	return append(chunks, allm.StreamChunk{Done: true, Usage: usage}), nil
+}
+
+// wait sleeps for d or until ctx is done.
+func wait(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
 // Models returns configured models.
 func (m *MockProvider) Models(_ context.Context) ([]allm.Model, error) {
 	if m.err != nil {
@@ -174,6 +412,88 @@ func (m *MockProvider) Embed(_ context.Context, req *allm.EmbedRequest) (*allm.E
 	}, nil
 }
 
+// CountTokens returns the WithTokenCount result, or an offline estimate.
+func (m *MockProvider) CountTokens(ctx context.Context, req *allm.Request) (*allm.TokenCount, error) {
+	m.mu.Lock()
+	count, err := m.tokenCount, m.err
+	m.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	if count == nil {
+		return allm.NewTokenEstimator(nil).CountTokens(ctx, req)
+	}
+	return count, nil
+}
+
+// CreateBatch returns the WithBatch batch.
+func (m *MockProvider) CreateBatch(_ context.Context, _ []allm.BatchRequest) (*allm.Batch, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.err != nil {
+		return nil, m.err
+	}
+	if m.batch == nil {
+		return nil, fmt.Errorf("%w: batch processing", allm.ErrNotSupported)
+	}
+	return m.batch, nil
+}
+
+// GetBatch returns the WithBatch batch if batchID matches its ID.
+func (m *MockProvider) GetBatch(_ context.Context, batchID string) (*allm.Batch, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.err != nil {
+		return nil, m.err
+	}
+	if m.batch == nil {
+		return nil, fmt.Errorf("%w: batch processing", allm.ErrNotSupported)
+	}
+	if m.batch.ID != batchID {
+		return nil, fmt.Errorf("allmtest: batch %q not found", batchID)
+	}
+	return m.batch, nil
+}
+
+// GenerateImage returns the WithImageResponse response.
+func (m *MockProvider) GenerateImage(_ context.Context, _ *allm.ImageRequest) (*allm.ImageResponse, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.err != nil {
+		return nil, m.err
+	}
+	if m.image == nil {
+		return nil, fmt.Errorf("%w: image generation", allm.ErrNotSupported)
+	}
+	return m.image, nil
+}
+
+// Speak returns the WithSpeechResponse response.
+func (m *MockProvider) Speak(_ context.Context, _ *allm.SpeechRequest) (*allm.SpeechResponse, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.err != nil {
+		return nil, m.err
+	}
+	if m.speech == nil {
+		return nil, fmt.Errorf("%w: text-to-speech", allm.ErrNotSupported)
+	}
+	return m.speech, nil
+}
+
+// Transcribe returns the WithTranscribeResponse response.
+func (m *MockProvider) Transcribe(_ context.Context, _ *allm.TranscribeRequest) (*allm.TranscribeResponse, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.err != nil {
+		return nil, m.err
+	}
+	if m.transcription == nil {
+		return nil, fmt.Errorf("%w: speech-to-text", allm.ErrNotSupported)
+	}
+	return m.transcription, nil
+}
+
 // LastRequest returns the most recent request, or nil if none.
 func (m *MockProvider) LastRequest() *allm.Request {
 	m.mu.Lock()
@@ -200,11 +520,12 @@ func (m *MockProvider) CallCount() int {
 	return len(m.requests)
 }
 
-// Reset clears all recorded requests.
+// Reset clears all recorded requests and restarts the response sequence.
 func (m *MockProvider) Reset() {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 	m.requests = nil
+	m.next = 0
 }
 
 // SetResponse updates the response for subsequent calls.
@@ -3,7 +3,10 @@ package allmtest
 import (
 	"context"
 	"errors"
+	"fmt"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/kusandriadi/allm-go"
 )
@@ -283,3 +286,227 @@ func TestMockProviderStreamError(t *testing.T) {
 		t.Errorf("expected stream error, got %v", gotError)
 	}
 }
+
+func TestMockProviderResultSequence(t *testing.T) {
+	m := NewMockProvider("test", WithResultSequence(
+		MockResult{Err: allm.ErrServerError},
+		MockResult{Response: &allm.Response{Content: "retried"}},
+	))
+	client := allm.New(m, allm.WithMaxRetries(1), allm.WithRetryBaseDelay(time.Millisecond))
+
+	resp, err := client.Complete(context.Background(), "Hi")
+	if err != nil || resp.Content != "retried" {
+		t.Fatalf("Complete = %v, %v", resp, err)
+	}
+	// Exhausted sequences fall back to the default response.
+	if resp, _ := m.Complete(context.Background(), &allm.Request{}); resp.Content != "mock response" {
+		t.Errorf("after sequence: %q", resp.Content)
+	}
+	m.AssertCallCount(t, 3)
+}
+
+func TestMockProviderToolLoop(t *testing.T) {
+	call := allm.ToolCall{ID: "1", Name: "lookup", Arguments: []byte(`{}`)}
+	m := NewMockProvider("test", WithResponseSequence(
+		&allm.Response{ToolCalls: []allm.ToolCall{call}},
+		&allm.Response{Content: "done"},
+	))
+	client := allm.New(m, allm.WithTools(allm.Tool{Name: "lookup"}))
+	ctx := context.Background()
+
+	messages := []allm.Message{{Role: allm.RoleUser, Content: "Hi"}}
+	resp, err := client.Chat(ctx, messages)
+	if err != nil || len(resp.ToolCalls) != 1 {
+		t.Fatalf("first turn = %+v, %v", resp, err)
+	}
+	messages = append(messages,
+		allm.Message{Role: allm.RoleAssistant, ToolCalls: resp.ToolCalls},
+		allm.Message{Role: allm.RoleTool, ToolResults: []allm.ToolResult{{ToolCallID: call.ID, Content: "result"}}},
+	)
+	resp, err = client.Chat(ctx, messages)
+	if err != nil || resp.Content != "done" {
+		t.Fatalf("second turn = %+v, %v", resp, err)
+	}
+	m.AssertTools(t, "lookup")
+	m.AssertMessages(t, allm.RoleUser, allm.RoleAssistant, allm.RoleTool)
+}
+
+func TestMockProviderHandler(t *testing.T) {
+	m := NewMockProvider("test", WithHandler(func(req *allm.Request) (*allm.Response, error) {
+		return &allm.Response{Content: strings.ToUpper(req.Messages[0].Content)}, nil
+	}))
+	resp, err := m.Complete(context.Background(), &allm.Request{Messages: []allm.Message{{Role: "user", Content: "hi"}}})
+	if err != nil || resp.Content != "HI" {
+		t.Errorf("Complete = %v, %v", resp, err)
+	}
+
+	var content string
+	for chunk := range m.Stream(context.Background(), &allm.Request{Messages: []allm.Message{{Role: "user", Content: "yo"}}}) {
+		content += chunk.Content
+	}
+	if content != "YO" {
+		t.Errorf("stream content = %q", content)
+	}
+}
+
+func TestMockProviderStreamToolCalls(t *testing.T) {
+	call := allm.ToolCall{ID: "1", Name: "lookup", Arguments: []byte(`{"q":"x"}`)}
+	m := NewMockProvider("test", WithResponseSequence(&allm.Response{ToolCalls: []allm.ToolCall{call}}))
+
+	var calls []allm.StreamToolUse
+	for chunk := range m.Stream(context.Background(), &allm.Request{}) {
+		if chunk.ToolUse != nil {
+			calls = append(calls, *chunk.ToolUse)
+		}
+	}
+	if len(calls) != 1 || calls[0].Name != "lookup" || string(calls[0].Input) != `{"q":"x"}` {
+		t.Errorf("streamed tool calls = %+v", calls)
+	}
+}
+
+func TestMockProviderStreamUsage(t *testing.T) {
+	m := NewMockProvider("test", WithResponse(&allm.Response{Content: "hi", InputTokens: 7, OutputTokens: 3}))
+
+	var usage *allm.StreamUsage
+	for chunk := range m.Stream(context.Background(), &allm.Request{}) {
+		if chunk.Done {
+			usage = chunk.Usage
+		}
+	}
+	if usage == nil || usage.InputTokens != 7 || usage.OutputTokens != 3 {
+		t.Errorf("final chunk usage = %+v", usage)
+	}
+}
+
+func TestMockProviderEmptyResult(t *testing.T) {
+	m := NewMockProvider("test", WithResultSequence(MockResult{}, MockResult{}))
+	if _, err := m.Complete(context.Background(), &allm.Request{}); err == nil {
+		t.Error("Complete: expected an error for an empty result")
+	}
+	var gotErr error
+	for chunk := range m.Stream(context.Background(), &allm.Request{}) {
+		gotErr = chunk.Error
+	}
+	if gotErr == nil {
+		t.Error("Stream: expected an error for an empty result")
+	}
+}
+
+func TestMockProviderLatency(t *testing.T) {
+	m := NewMockProvider("test", WithLatency(0, time.Second))
+	if _, err := m.Complete(context.Background(), &allm.Request{}); err != nil {
+		t.Fatalf("first call: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := m.Complete(ctx, &allm.Request{}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected deadline exceeded, got %v", err)
+	}
+}
+
+func TestMockProviderStreamFaults(t *testing.T) {
+	chunks := []allm.StreamChunk{{Content: "a"}, {Content: "b"}, {Done: true}}
+	drain := func(m *MockProvider, ctx context.Context) (string, error) {
+		var content string
+		for chunk := range m.Stream(ctx, &allm.Request{}) {
+			if chunk.Error != nil {
+				return content, chunk.Error
+			}
+			content += chunk.Content
+		}
+		return content, nil
+	}
+
+	failure := errors.New("connection reset")
+	content, err := drain(NewMockProvider("test", WithStreamChunks(chunks), WithStreamError(1, failure)), context.Background())
+	if content != "a" || err != failure {
+		t.Errorf("stream error: content %q, err %v", content, err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	content, err = drain(NewMockProvider("test", WithStreamChunks(chunks), WithStreamStall(2)), ctx)
+	if content != "ab" || !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("stream stall: content %q, err %v", content, err)
+	}
+}
+
+func TestMockProviderOptionalInterfaces(t *testing.T) {
+	ctx := context.Background()
+	m := NewMockProvider("test")
+
+	if count, err := m.CountTokens(ctx, &allm.Request{Messages: []allm.Message{{Role: "user", Content: "Hello there"}}}); err != nil || count.InputTokens == 0 {
+		t.Errorf("estimated CountTokens = %v, %v", count, err)
+	}
+	if _, err := m.GenerateImage(ctx, &allm.ImageRequest{}); !errors.Is(err, allm.ErrNotSupported) {
+		t.Errorf("expected ErrNotSupported, got %v", err)
+	}
+
+	m = NewMockProvider("test",
+		WithTokenCount(&allm.TokenCount{InputTokens: 42}),
+		WithBatch(&allm.Batch{ID: "b1", Status: "completed"}),
+		WithImageResponse(&allm.ImageResponse{Images: []allm.GeneratedImage{{URL: "u"}}}),
+		WithSpeechResponse(&allm.SpeechResponse{Audio: []byte("mp3")}),
+		WithTranscribeResponse(&allm.TranscribeResponse{Text: "hello"}),
+	)
+	client := allm.New(m)
+	if count, _ := client.CountTokens(ctx, []allm.Message{{Role: "user", Content: "Hi"}}); count.InputTokens != 42 {
+		t.Errorf("CountTokens = %d", count.InputTokens)
+	}
+	if batch, err := m.GetBatch(ctx, "b1"); err != nil || batch.Status != "completed" {
+		t.Errorf("GetBatch = %v, %v", batch, err)
+	}
+	if _, err := m.GetBatch(ctx, "other"); err == nil {
+		t.Error("expected error for unknown batch")
+	}
+	if img, err := client.GenerateImage(ctx, "cat"); err != nil || img.Images[0].URL != "u" {
+		t.Errorf("GenerateImage = %v, %v", img, err)
+	}
+	if speech, err := client.Speak(ctx, &allm.SpeechRequest{Input: "hi"}); err != nil || string(speech.Audio) != "mp3" {
+		t.Errorf("Speak = %v, %v", speech, err)
+	}
+	if text, err := client.Transcribe(ctx, &allm.TranscribeRequest{Audio: []byte("audio")}); err != nil || text.Text != "hello" {
+		t.Errorf("Transcribe = %v, %v", text, err)
+	}
+}
+
+// failRecorder records assertion failures instead of failing the test.
+type failRecorder struct {
+	testing.TB
+	failures []string
+}
+
+func (f *failRecorder) Helper() {}
+
+func (f *failRecorder) Errorf(format string, args ...any) {
+	f.failures = append(f.failures, fmt.Sprintf(format, args...))
+}
+
+func TestMockProviderAssertions(t *testing.T) {
+	m := NewMockProvider("test")
+	client := allm.New(m, allm.WithModel("m1"), allm.WithSystemPrompt("Be brief."), allm.WithTemperature(0.2))
+	if _, err := client.Complete(context.Background(), "Hello"); err != nil {
+		t.Fatalf("Complete: %v", err)
+	}
+
+	m.AssertCallCount(t, 1)
+	m.AssertModel(t, "m1")
+	m.AssertMessages(t, allm.RoleSystem, allm.RoleUser)
+	m.AssertLastMessage(t, allm.RoleUser, "Hello")
+	m.AssertTools(t)
+	m.AssertRequest(t, func(req *allm.Request) error {
+		if req.Temperature != 0.2 {
+			return fmt.Errorf("temperature = %v", req.Temperature)
+		}
+		return nil
+	})
+
+	rec := &failRecorder{}
+	m.AssertModel(rec, "m2")
+	m.AssertLastMessage(rec, allm.RoleUser, "Bye")
+	m.AssertTools(rec, "lookup")
+	if len(rec.failures) != 3 {
+		t.Errorf("failures = %v, want 3", rec.failures)
+	}
+}
@@ -0,0 +1,103 @@
+package allmtest
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/kusandriadi/allm-go"
+)
+
+// AssertCallCount fails t unless the provider received n requests.
+func (m *MockProvider) AssertCallCount(t testing.TB, n int) {
+	t.Helper()
+	if got := m.CallCount(); got != n {
+		t.Errorf("allmtest: %d calls, want %d", got, n)
+	}
+}
+
+// AssertLastMessage fails t unless the last message of the last request
+// has the given role and content.
+func (m *MockProvider) AssertLastMessage(t testing.TB, role, content string) {
+	t.Helper()
+	req := m.lastRequest(t)
+	if req == nil {
+		return
+	}
+	if len(req.Messages) == 0 {
+		t.Errorf("allmtest: last request has no messages")
+		return
+	}
+	last := req.Messages[len(req.Messages)-1]
+	if last.Role != role || last.Content != content {
+		t.Errorf("allmtest: last message = %s %q, want %s %q", last.Role, last.Content, role, content)
+	}
+}
+
+// AssertMessages fails t unless the last request's messages have the given
+// roles, in order, e.g. AssertMessages(t, allm.RoleSystem, allm.RoleUser).
+func (m *MockProvider) AssertMessages(t testing.TB, roles ...string) {
+	t.Helper()
+	req := m.lastRequest(t)
+	if req == nil {
+		return
+	}
+	var got []string
+	for _, msg := range req.Messages {
+		got = append(got, msg.Role)
+	}
+	if !slices.Equal(got, roles) {
+		t.Errorf("allmtest: message roles = %v, want %v", got, roles)
+	}
+}
+
+// AssertTools fails t unless the last request offered exactly the named tools.
+func (m *MockProvider) AssertTools(t testing.TB, names ...string) {
+	t.Helper()
+	req := m.lastRequest(t)
+	if req == nil {
+		return
+	}
+	var got []string
+	for _, tool := range req.Tools {
+		got = append(got, tool.Name)
+	}
+	if !slices.Equal(got, names) {
+		t.Errorf("allmtest: tools = %v, want %v", got, names)
+	}
+}
+
+// AssertModel fails t unless the last request used model.
+func (m *MockProvider) AssertModel(t testing.TB, model string) {
+	t.Helper()
+	if req := m.lastRequest(t); req != nil && req.Model != model {
+		t.Errorf("allmtest: model = %q, want %q", req.Model, model)
+	}
+}
+
+// AssertRequest fails t with the error returned by check for the last
+// request, for options without a dedicated assertion:
+//
+//	mock.AssertRequest(t, func(req *allm.Request) error {
+//	    if req.Temperature != 0.2 {
+//	        return fmt.Errorf("temperature = %v", req.Temperature)
+//	    }
+//	    return nil
+//	})
+func (m *MockProvider) AssertRequest(t testing.TB, check func(*allm.Request) error) {
+	t.Helper()
+	if req := m.lastRequest(t); req != nil {
+		if err := check(req); err != nil {
+			t.Errorf("allmtest: %v", err)
+		}
+	}
+}
+
+// lastRequest returns the last request, failing t if there is none.
+func (m *MockProvider) lastRequest(t testing.TB) *allm.Request {
+	t.Helper()
+	req := m.LastRequest()
+	if req == nil {
+		t.Errorf("allmtest: provider %q received no requests", m.name)
+	}
+	return req
+}
@@ -0,0 +1,540 @@
+package allmtest
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kusandriadi/allm-go"
+)
+
+// conformancePrompt is the user message sent by conformance checks.
+const conformancePrompt = "conformance check"
+
+// ConformanceOption configures Conformance.
+type ConformanceOption func(*conformanceConfig)
+
+type conformanceConfig struct {
+	newServer func() *FakeServer
+	model     string
+	timeout   time.Duration
+}
+
+// WithConformanceServer sets the fake server the provider is tested against
+// (default: NewFakeOpenAIServer).
+func WithConformanceServer(newServer func() *FakeServer) ConformanceOption {
+	return func(c *conformanceConfig) { c.newServer = newServer }
+}
+
+// WithConformanceModel sets the model of conformance requests (default: the provider default).
+func WithConformanceModel(model string) ConformanceOption {
+	return func(c *conformanceConfig) { c.model = model }
+}
+
+// WithConformanceTimeout sets how long a call may take before it is
+// considered hung (default: 5s).
+func WithConformanceTimeout(d time.Duration) ConformanceOption {
+	return func(c *conformanceConfig) { c.timeout = d }
+}
+
+// Conformance checks that a Provider implementation follows the allm
+// contract, using a fake server in place of the real API. factory returns
+// the provider under test configured to talk to srv, typically through
+// srv.Client() or srv.URL:
+//
+//	func TestConformance(t *testing.T) {
+//	    allmtest.Conformance(t, func(srv *allmtest.FakeServer) allm.Provider {
+//	        return myprovider.New("key", myprovider.WithHTTPClient(srv.Client()))
+//	    })
+//	}
+//
+// It checks Complete results and token usage, stream chunk rules (exactly
+// one final Done chunk, nothing after Done or Error, the channel always
+// closed), context cancellation, mapping of HTTP 429 and 5xx responses to
+// allm.ErrRateLimited and allm.ErrServerError, and stream failures. Every
+// optional interface the provider implements and the fake servers serve
+// (ModelLister, Embedder, TokenCounter, ImageGenerator, ImageEditor,
+// Speaker, SpeechStreamer, Transcriber, Moderator, Reranker, FileStore,
+// BatchProvider) is exercised too;
+// methods returning allm.ErrNotSupported are skipped.
+func Conformance(t *testing.T, factory func(srv *FakeServer) allm.Provider, opts ...ConformanceOption) {
+	t.Helper()
+	cfg := &conformanceConfig{newServer: NewFakeOpenAIServer, timeout: 5 * time.Second}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	c := &conformance{cfg: cfg, factory: factory}
+
+	t.Run("Basics", c.basics)
+	t.Run("Complete", c.complete)
+	t.Run("Stream", c.stream)
+	t.Run("CompleteCanceled", c.completeCanceled)
+	t.Run("StreamCanceled", c.streamCanceled)
+	t.Run("RateLimited", c.statusError(FakeRateLimit(time.Millisecond), allm.ErrRateLimited))
+	t.Run("ServerError", c.statusError(FakeReply{Status: 500, Message: "internal error", RetryAfter: time.Millisecond}, allm.ErrServerError))
+	t.Run("StreamServerError", c.streamStatusError)
+	t.Run("StreamDisconnect", c.streamFault(FaultDisconnect))
+	t.Run("StreamMalformed", c.streamFault(FaultMalformedSSE))
+
+	p := c.provider(t)
+	optional := []struct {
+		name  string
+		ok    bool
+		check func(*testing.T)
+	}{
+		{"Models", is[allm.ModelLister](p), c.models},
+		{"Embed", is[allm.Embedder](p), c.embed},
+		{"CountTokens", is[allm.TokenCounter](p), c.countTokens},
+		{"GenerateImage", is[allm.ImageGenerator](p), c.generateImage},
+		{"EditImage", is[allm.ImageEditor](p), c.editImage},
+		{"Speak", is[allm.Speaker](p), c.speak},
+		{"SpeakStream", is[allm.SpeechStreamer](p), c.speakStream},
+		{"Transcribe", is[allm.Transcriber](p), c.transcribe},
+		{"Moderate", is[allm.Moderator](p), c.moderate},
+		{"Rerank", is[allm.Reranker](p), c.rerank},
+		{"Files", is[allm.FileStore](p), c.files},
+		{"Batch", is[allm.BatchProvider](p), c.batch},
+	}
+	for _, o := range optional {
+		if o.ok {
+			t.Run(o.name, o.check)
+		}
+	}
+}
+
+// is reports whether p implements T.
+func is[T any](p allm.Provider) bool {
+	_, ok := p.(T)
+	return ok
+}
+
+type conformance struct {
+	cfg     *conformanceConfig
+	factory func(srv *FakeServer) allm.Provider
+}
+
+// setup starts a fake server for t and returns it with the provider under test.
+func (c *conformance) setup(t *testing.T) (*FakeServer, allm.Provider) {
+	t.Helper()
+	srv := c.cfg.newServer()
+	t.Cleanup(srv.Close)
+	p := c.factory(srv)
+	if p == nil {
+		t.Fatal("factory returned a nil provider")
+	}
+	return srv, p
+}
+
+// provider returns a provider for type checks, on a server closed with t.
+func (c *conformance) provider(t *testing.T) allm.Provider {
+	t.Helper()
+	_, p := c.setup(t)
+	return p
+}
+
+// request returns a single-turn request.
+func (c *conformance) request() *allm.Request {
+	return &allm.Request{
+		Model:     c.cfg.model,
+		MaxTokens: 64,
+		Messages:  []allm.Message{{Role: allm.RoleUser, Content: conformancePrompt}},
+	}
+}
+
+// context returns a context bounded by the call timeout.
+func (c *conformance) context(t *testing.T) context.Context {
+	ctx, cancel := context.WithTimeout(context.Background(), c.cfg.timeout)
+	t.Cleanup(cancel)
+	return ctx
+}
+
+// repeat returns n copies of reply, enough to outlast client retries.
+func repeat(reply FakeReply, n int) []FakeReply {
+	replies := make([]FakeReply, n)
+	for i := range replies {
+		replies[i] = reply
+	}
+	return replies
+}
+
+func (c *conformance) basics(t *testing.T) {
+	_, p := c.setup(t)
+	if p.Name() == "" {
+		t.Error("Name() is empty")
+	}
+	if !p.Available() {
+		t.Error("Available() = false for a configured provider")
+	}
+}
+
+func (c *conformance) complete(t *testing.T) {
+	srv, p := c.setup(t)
+	srv.Enqueue(FakeReply{Content: "conformance reply", InputTokens: 12, OutputTokens: 4})
+
+	resp, err := p.Complete(c.context(t), c.request())
+	if err != nil {
+		t.Fatalf("Complete: %v", err)
+	}
+	if resp == nil {
+		t.Fatal("Complete returned a nil response and nil error")
+	}
+	if resp.Content != "conformance reply" {
+		t.Errorf("Content = %q, want %q", resp.Content, "conformance reply")
+	}
+	if resp.InputTokens != 12 || resp.OutputTokens != 4 {
+		t.Errorf("tokens = %d in, %d out; want 12 and 4", resp.InputTokens, resp.OutputTokens)
+	}
+	req := srv.LastRequest()
+	if req == nil || !strings.Contains(string(req.Body), conformancePrompt) {
+		t.Error("request body does not contain the user message")
+	}
+}
+
+func (c *conformance) stream(t *testing.T) {
+	srv, p := c.setup(t)
+	srv.Enqueue(FakeReply{Chunks: []string{"con", "form", "ance"}})
+
+	chunks := c.drain(t, p.Stream(c.context(t), c.request()))
+	var content strings.Builder
+	for _, chunk := range chunks {
+		if chunk.Error != nil {
+			t.Fatalf("stream error: %v", chunk.Error)
+		}
+		content.WriteString(chunk.Content)
+	}
+	if content.String() != "conformance" {
+		t.Errorf("content = %q, want %q", content.String(), "conformance")
+	}
+	if len(chunks) == 0 || !chunks[len(chunks)-1].Done {
+		t.Error("stream did not end with a Done chunk")
+	}
+}
+
+func (c *conformance) completeCanceled(t *testing.T) {
+	srv, p := c.setup(t)
+	srv.Enqueue(FakeReply{Delay: time.Minute})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	done := make(chan error, 1)
+	go func() {
+		_, err := p.Complete(ctx, c.request())
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if !isContextError(err) {
+			t.Errorf("Complete error = %v, want a context error", err)
+		}
+	case <-time.After(c.cfg.timeout):
+		t.Fatal("Complete did not return after the context was canceled")
+	}
+}
+
+func (c *conformance) streamCanceled(t *testing.T) {
+	srv, p := c.setup(t)
+	srv.Enqueue(FakeReply{Chunks: []string{"a", "b", "c"}, Delay: 200 * time.Millisecond})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+	for _, chunk := range c.drain(t, p.Stream(ctx, c.request())) {
+		if chunk.Done {
+			t.Error("canceled stream reported Done")
+		}
+	}
+}
+
+// statusError checks that Complete maps reply's HTTP status to want.
+func (c *conformance) statusError(reply FakeReply, want error) func(*testing.T) {
+	return func(t *testing.T) {
+		srv, p := c.setup(t)
+		srv.Enqueue(repeat(reply, 5)...)
+
+		resp, err := p.Complete(c.context(t), c.request())
+		if !errors.Is(err, want) {
+			t.Errorf("Complete error = %v, want %v", err, want)
+		}
+		if err != nil && resp != nil {
+			t.Error("Complete returned both a response and an error")
+		}
+	}
+}
+
+func (c *conformance) streamStatusError(t *testing.T) {
+	srv, p := c.setup(t)
+	srv.Enqueue(repeat(FakeReply{Status: 500, Message: "internal error", RetryAfter: time.Millisecond}, 5)...)
+
+	err := streamError(t, c.drain(t, p.Stream(c.context(t), c.request())))
+	if !errors.Is(err, allm.ErrServerError) {
+		t.Errorf("stream error = %v, want %v", err, allm.ErrServerError)
+	}
+}
+
+// streamFault checks that a broken stream ends with an error chunk.
+func (c *conformance) streamFault(fault Fault) func(*testing.T) {
+	return func(t *testing.T) {
+		srv, p := c.setup(t)
+		srv.Enqueue(FakeReply{Chunks: []string{"partial", "never sent"}, Fault: fault})
+		streamError(t, c.drain(t, p.Stream(c.context(t), c.request())))
+	}
+}
+
+// drain reads a stream until it closes, checking the chunk rules.
+func (c *conformance) drain(t *testing.T, ch <-chan allm.StreamChunk) []allm.StreamChunk {
+	t.Helper()
+	if ch == nil {
+		t.Fatal("Stream returned a nil channel")
+	}
+	var chunks []allm.StreamChunk
+	timeout := time.After(c.cfg.timeout + time.Second)
+	for {
+		select {
+		case chunk, ok := <-ch:
+			if !ok {
+				return chunks
+			}
+			if n := len(chunks); n > 0 && (chunks[n-1].Done || chunks[n-1].Error != nil) {
+				t.Errorf("chunk received after a Done or Error chunk: %+v", chunk)
+			}
+			if chunk.Done && chunk.Error != nil {
+				t.Errorf("chunk has both Done and Error: %+v", chunk)
+			}
+			chunks = append(chunks, chunk)
+		case <-timeout:
+			t.Fatal("stream channel was not closed")
+		}
+	}
+}
+
+// streamError returns the error ending chunks, failing t if there is none.
+func streamError(t *testing.T, chunks []allm.StreamChunk) error {
+	t.Helper()
+	if len(chunks) == 0 || chunks[len(chunks)-1].Error == nil {
+		t.Error("failed stream did not end with an Error chunk")
+		return nil
+	}
+	return chunks[len(chunks)-1].Error
+}
+
+// isContextError reports whether err reports a canceled or expired context.
+func isContextError(err error) bool {
+	return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) ||
+		errors.Is(err, allm.ErrCanceled) || errors.Is(err, allm.ErrTimeout)
+}
+
+// skipUnsupported skips t if err is allm.ErrNotSupported.
+func skipUnsupported(t *testing.T, err error) {
+	t.Helper()
+	if errors.Is(err, allm.ErrNotSupported) {
+		t.Skipf("not supported: %v", err)
+	}
+}
+
+func (c *conformance) models(t *testing.T) {
+	_, p := c.setup(t)
+	models, err := p.(allm.ModelLister).Models(c.context(t))
+	skipUnsupported(t, err)
+	if err != nil {
+		t.Fatalf("Models: %v", err)
+	}
+	if len(models) == 0 {
+		t.Fatal("Models returned no models")
+	}
+	for _, m := range models {
+		if m.ID == "" {
+			t.Errorf("model without ID: %+v", m)
+		}
+	}
+}
+
+func (c *conformance) embed(t *testing.T) {
+	_, p := c.setup(t)
+	resp, err := p.(allm.Embedder).Embed(c.context(t), &allm.EmbedRequest{Input: []string{"first", "second"}})
+	skipUnsupported(t, err)
+	if err != nil {
+		t.Fatalf("Embed: %v", err)
+	}
+	if len(resp.Embeddings) != 2 {
+		t.Fatalf("%d embeddings, want 2", len(resp.Embeddings))
+	}
+	if len(resp.Embeddings[0]) == 0 || len(resp.Embeddings[0]) != len(resp.Embeddings[1]) {
+		t.Errorf("embedding dimensions = %d and %d", len(resp.Embeddings[0]), len(resp.Embeddings[1]))
+	}
+}
+
+func (c *conformance) countTokens(t *testing.T) {
+	srv, p := c.setup(t)
+	srv.Enqueue(FakeReply{InputTokens: 21})
+	count, err := p.(allm.TokenCounter).CountTokens(c.context(t), c.request())
+	skipUnsupported(t, err)
+	if err != nil {
+		t.Fatalf("CountTokens: %v", err)
+	}
+	if count.InputTokens <= 0 {
+		t.Errorf("InputTokens = %d", count.InputTokens)
+	}
+}
+
+func (c *conformance) generateImage(t *testing.T) {
+	_, p := c.setup(t)
+	resp, err := p.(allm.ImageGenerator).GenerateImage(c.context(t), &allm.ImageRequest{Prompt: "a red square", N: 1})
+	skipUnsupported(t, err)
+	if err != nil {
+		t.Fatalf("GenerateImage: %v", err)
+	}
+	if len(resp.Images) == 0 || (len(resp.Images[0].Data) == 0 && resp.Images[0].URL == "") {
+		t.Errorf("no image data or URL: %+v", resp.Images)
+	}
+}
+
+// editImage edits with a prompt and, with an empty prompt, asks for a
+// variation, which some providers serve from a separate endpoint.
+func (c *conformance) editImage(t *testing.T) {
+	_, p := c.setup(t)
+	png, _ := base64.StdEncoding.DecodeString(fakePNG)
+	for _, prompt := range []string{"make it blue", ""} {
+		resp, err := p.(allm.ImageEditor).EditImage(c.context(t), &allm.ImageEditRequest{
+			Prompt: prompt,
+			Images: []allm.Image{{MimeType: "image/png", Data: png}},
+			N:      1,
+		})
+		skipUnsupported(t, err)
+		if err != nil {
+			t.Fatalf("EditImage(prompt %q): %v", prompt, err)
+		}
+		if len(resp.Images) == 0 || (len(resp.Images[0].Data) == 0 && resp.Images[0].URL == "") {
+			t.Errorf("prompt %q: no image data or URL: %+v", prompt, resp.Images)
+		}
+	}
+}
+
+func (c *conformance) speak(t *testing.T) {
+	srv, p := c.setup(t)
+	srv.Enqueue(FakeText("audio bytes"))
+	resp, err := p.(allm.Speaker).Speak(c.context(t), &allm.SpeechRequest{Input: conformancePrompt})
+	skipUnsupported(t, err)
+	if err != nil {
+		t.Fatalf("Speak: %v", err)
+	}
+	if string(resp.Audio) != "audio bytes" {
+		t.Errorf("Audio = %q, want the response body", resp.Audio)
+	}
+}
+
+func (c *conformance) speakStream(t *testing.T) {
+	srv, p := c.setup(t)
+	srv.Enqueue(FakeText("audio bytes"))
+	r, err := p.(allm.SpeechStreamer).SpeakStream(c.context(t), &allm.SpeechRequest{Input: conformancePrompt})
+	skipUnsupported(t, err)
+	if err != nil {
+		t.Fatalf("SpeakStream: %v", err)
+	}
+	defer func() { _ = r.Close() }()
+	audio, err := io.ReadAll(r)
+	if err != nil || string(audio) != "audio bytes" {
+		t.Errorf("audio = %q, %v", audio, err)
+	}
+}
+
+func (c *conformance) transcribe(t *testing.T) {
+	srv, p := c.setup(t)
+	srv.Enqueue(FakeText("hello world"))
+	resp, err := p.(allm.Transcriber).Transcribe(c.context(t), &allm.TranscribeRequest{Audio: []byte("RIFF"), Format: "wav"})
+	skipUnsupported(t, err)
+	if err != nil {
+		t.Fatalf("Transcribe: %v", err)
+	}
+	if resp.Text != "hello world" {
+		t.Errorf("Text = %q, want %q", resp.Text, "hello world")
+	}
+}
+
+func (c *conformance) moderate(t *testing.T) {
+	_, p := c.setup(t)
+	resp, err := p.(allm.Moderator).Moderate(c.context(t), &allm.ModerationRequest{Input: []string{"first", "second"}})
+	skipUnsupported(t, err)
+	if err != nil {
+		t.Fatalf("Moderate: %v", err)
+	}
+	if len(resp.Results) != 2 {
+		t.Errorf("%d results, want one per input", len(resp.Results))
+	}
+}
+
+func (c *conformance) rerank(t *testing.T) {
+	_, p := c.setup(t)
+	docs := []string{"bananas are yellow", "the sky is blue", "blue whales are large"}
+	resp, err := p.(allm.Reranker).Rerank(c.context(t), &allm.RerankRequest{Query: "blue sky", Documents: docs, TopN: 2})
+	skipUnsupported(t, err)
+	if err != nil {
+		t.Fatalf("Rerank: %v", err)
+	}
+	if len(resp.Results) != 2 {
+		t.Fatalf("%d results, want TopN", len(resp.Results))
+	}
+	for i, r := range resp.Results {
+		if r.Index < 0 || r.Index >= len(docs) || r.Document != docs[r.Index] {
+			t.Errorf("result %d = %+v, want Index and Document of an input", i, r)
+		}
+		if i > 0 && r.Score > resp.Results[i-1].Score {
+			t.Errorf("results not sorted by score: %+v", resp.Results)
+		}
+	}
+	if resp.Results[0].Index != 1 {
+		t.Errorf("best result = %+v, want document 1", resp.Results[0])
+	}
+}
+
+func (c *conformance) files(t *testing.T) {
+	_, p := c.setup(t)
+	store := p.(allm.FileStore)
+	ctx := c.context(t)
+	f, err := store.UploadFile(ctx, &allm.FileUploadRequest{Name: "notes.txt", MimeType: "text/plain", Data: []byte(conformancePrompt)})
+	skipUnsupported(t, err)
+	if err != nil {
+		t.Fatalf("UploadFile: %v", err)
+	}
+	if f.ID == "" || f.Size != int64(len(conformancePrompt)) {
+		t.Errorf("uploaded file = %+v", f)
+	}
+
+	got, err := store.GetFile(ctx, f.ID)
+	if err != nil || got.ID != f.ID || got.Name != "notes.txt" {
+		t.Errorf("GetFile = %+v, %v", got, err)
+	}
+	files, err := store.ListFiles(ctx)
+	if err != nil || len(files) != 1 || files[0].ID != f.ID {
+		t.Errorf("ListFiles = %+v, %v", files, err)
+	}
+	data, err := store.DownloadFile(ctx, f.ID)
+	if err != nil || string(data) != conformancePrompt {
+		t.Errorf("DownloadFile = %q, %v", data, err)
+	}
+
+	if err := store.DeleteFile(ctx, f.ID); err != nil {
+		t.Fatalf("DeleteFile: %v", err)
+	}
+	if _, err := store.GetFile(ctx, f.ID); err == nil {
+		t.Error("GetFile after DeleteFile succeeded")
+	}
+}
+
+func (c *conformance) batch(t *testing.T) {
+	_, p := c.setup(t)
+	batcher := p.(allm.BatchProvider)
+	batch, err := batcher.CreateBatch(c.context(t), []allm.BatchRequest{{CustomID: "one", Messages: c.request().Messages, Model: c.cfg.model, MaxTokens: 64}})
+	skipUnsupported(t, err)
+	if err != nil {
+		t.Fatalf("CreateBatch: %v", err)
+	}
+	if batch.ID == "" {
+		t.Fatal("batch has no ID")
+	}
+	if _, err := batcher.GetBatch(c.context(t), batch.ID); err != nil {
+		t.Errorf("GetBatch: %v", err)
+	}
+}
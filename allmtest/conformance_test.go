@@ -0,0 +1,26 @@
+package allmtest
+
+import (
+	"testing"
+
+	"github.com/kusandriadi/allm-go"
+	"github.com/kusandriadi/allm-go/provider"
+)
+
+func TestConformanceAnthropic(t *testing.T) {
+	Conformance(t, func(srv *FakeServer) allm.Provider {
+		return provider.Anthropic("test-key", provider.WithAnthropicHTTPClient(srv.Client()))
+	}, WithConformanceServer(NewFakeAnthropicServer))
+}
+
+func TestConformanceOpenAI(t *testing.T) {
+	Conformance(t, func(srv *FakeServer) allm.Provider {
+		return provider.OpenAI("test-key", provider.WithOpenAIHTTPClient(srv.Client()))
+	}, WithConformanceModel("gpt-4o"))
+}
+
+func TestConformanceOpenAICompatible(t *testing.T) {
+	Conformance(t, func(srv *FakeServer) allm.Provider {
+		return provider.Local(srv.URL+"/v1", provider.WithRerank("rerank-test"))
+	})
+}
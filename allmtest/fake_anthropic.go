@@ -0,0 +1,334 @@
+package allmtest
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// fakeAnthropicModels are the models listed by the fake Anthropic server.
+var fakeAnthropicModels = []string{"claude-sonnet-4-6", "claude-haiku-4-5"}
+
+// NewFakeAnthropicServer starts a FakeServer speaking the Anthropic Messages
+// API: messages (JSON and SSE), count_tokens, models, message batches and
+// files. Close it when done.
+func NewFakeAnthropicServer() *FakeServer {
+	return newFakeServer(func(s *FakeServer, mux *http.ServeMux) {
+		mux.HandleFunc("POST /v1/messages", s.anthropicMessages)
+		mux.HandleFunc("POST /v1/messages/count_tokens", s.anthropicCountTokens)
+		mux.HandleFunc("GET /v1/models", s.anthropicModels)
+		mux.HandleFunc("GET /v1/models/{id}", s.anthropicModel)
+		mux.HandleFunc("POST /v1/messages/batches", s.anthropicCreateBatch)
+		mux.HandleFunc("GET /v1/messages/batches/{id}", s.anthropicGetBatch)
+		mux.HandleFunc("GET /v1/messages/batches/{id}/results", s.anthropicBatchResults)
+		mux.HandleFunc("POST /v1/files", s.anthropicUploadFile)
+		mux.HandleFunc("GET /v1/files", s.anthropicListFiles)
+		mux.HandleFunc("GET /v1/files/{id}", s.anthropicGetFile)
+		mux.HandleFunc("DELETE /v1/files/{id}", s.anthropicDeleteFile)
+		mux.HandleFunc("GET /v1/files/{id}/content", s.fileContent(anthropicError))
+	})
+}
+
+// anthropicError renders an Anthropic error body.
+func anthropicError(status int, message string) any {
+	kind := "api_error"
+	switch status {
+	case http.StatusBadRequest:
+		kind = "invalid_request_error"
+	case http.StatusUnauthorized:
+		kind = "authentication_error"
+	case http.StatusForbidden:
+		kind = "permission_error"
+	case http.StatusNotFound:
+		kind = "not_found_error"
+	case http.StatusRequestEntityTooLarge:
+		kind = "request_too_large"
+	case http.StatusTooManyRequests:
+		kind = "rate_limit_error"
+	case 529:
+		kind = "overloaded_error"
+	}
+	return map[string]any{"type": "error", "error": map[string]any{"type": kind, "message": message}}
+}
+
+// anthropicMessage renders reply as an Anthropic message object.
+func anthropicMessage(id, model string, reply FakeReply) map[string]any {
+	content := []map[string]any{}
+	if reply.Content != "" {
+		content = append(content, map[string]any{"type": "text", "text": reply.Content})
+	}
+	stopReason := "end_turn"
+	for _, tc := range reply.ToolCalls {
+		content = append(content, map[string]any{"type": "tool_use", "id": tc.ID, "name": tc.Name, "input": toolInput(tc.Arguments)})
+		stopReason = "tool_use"
+	}
+	return map[string]any{
+		"id":            id,
+		"type":          "message",
+		"role":          "assistant",
+		"model":         model,
+		"content":       content,
+		"stop_reason":   stopReason,
+		"stop_sequence": nil,
+		"usage":         map[string]any{"input_tokens": reply.InputTokens, "output_tokens": reply.OutputTokens},
+	}
+}
+
+// toolInput returns tool call arguments as a JSON value, defaulting to {}.
+func toolInput(args json.RawMessage) json.RawMessage {
+	if len(args) == 0 {
+		return json.RawMessage("{}")
+	}
+	return args
+}
+
+func (s *FakeServer) anthropicMessages(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Model  string `json:"model"`
+		Stream bool   `json:"stream"`
+	}
+	readJSON(r, &req)
+	reply := s.next(r)
+	if reply.Model == "" {
+		reply.Model = req.Model
+	}
+	if !req.Stream {
+		if !writeFailure(w, reply, anthropicError) {
+			writeJSON(w, http.StatusOK, anthropicMessage(s.id("msg_"), reply.Model, reply))
+		}
+		return
+	}
+	if reply.Status != 0 {
+		writeFailure(w, reply, anthropicError)
+		return
+	}
+
+	sse := newSSEWriter(w, r, reply.Delay)
+	start := anthropicMessage(s.id("msg_"), reply.Model, reply)
+	start["content"] = []any{}
+	start["stop_reason"] = nil
+	start["usage"] = map[string]any{"input_tokens": reply.InputTokens, "output_tokens": 0}
+	sse.event("message_start", map[string]any{"type": "message_start", "message": start})
+
+	index := 0
+	if len(reply.Chunks) > 0 {
+		sse.event("content_block_start", map[string]any{"type": "content_block_start", "index": index,
+			"content_block": map[string]any{"type": "text", "text": ""}})
+		for i, chunk := range reply.Chunks {
+			sse.event("content_block_delta", map[string]any{"type": "content_block_delta", "index": index,
+				"delta": map[string]any{"type": "text_delta", "text": chunk}})
+			if i == 0 && anthropicStreamFault(sse, reply.Fault) {
+				return
+			}
+		}
+		sse.event("content_block_stop", map[string]any{"type": "content_block_stop", "index": index})
+		index++
+	} else if anthropicStreamFault(sse, reply.Fault) {
+		return
+	}
+
+	stopReason := "end_turn"
+	for _, tc := range reply.ToolCalls {
+		sse.event("content_block_start", map[string]any{"type": "content_block_start", "index": index,
+			"content_block": map[string]any{"type": "tool_use", "id": tc.ID, "name": tc.Name, "input": map[string]any{}}})
+		sse.event("content_block_delta", map[string]any{"type": "content_block_delta", "index": index,
+			"delta": map[string]any{"type": "input_json_delta", "partial_json": string(toolInput(tc.Arguments))}})
+		sse.event("content_block_stop", map[string]any{"type": "content_block_stop", "index": index})
+		index++
+		stopReason = "tool_use"
+	}
+	sse.event("message_delta", map[string]any{"type": "message_delta",
+		"delta": map[string]any{"stop_reason": stopReason, "stop_sequence": nil},
+		"usage": map[string]any{"output_tokens": reply.OutputTokens}})
+	sse.event("message_stop", map[string]any{"type": "message_stop"})
+}
+
+// anthropicStreamFault injects fault into a stream and reports whether the
+// stream must end.
+func anthropicStreamFault(sse *sseWriter, fault Fault) bool {
+	switch fault {
+	case FaultMalformedSSE:
+		sse.event("content_block_delta", `{"type":"content_block_delta","index":`)
+		return true
+	case FaultDisconnect:
+		disconnect(sse.w)
+		return true
+	}
+	return false
+}
+
+func (s *FakeServer) anthropicCountTokens(w http.ResponseWriter, r *http.Request) {
+	reply := s.next(r)
+	if !writeFailure(w, reply, anthropicError) {
+		writeJSON(w, http.StatusOK, map[string]any{"input_tokens": reply.InputTokens})
+	}
+}
+
+// anthropicModelObject renders a model object.
+func anthropicModelObject(id string) map[string]any {
+	return map[string]any{"type": "model", "id": id, "display_name": id, "created_at": "2025-01-01T00:00:00Z"}
+}
+
+func (s *FakeServer) anthropicModels(w http.ResponseWriter, r *http.Request) {
+	if reply := s.next(r); writeFailure(w, reply, anthropicError) {
+		return
+	}
+	var data []map[string]any
+	for _, id := range fakeAnthropicModels {
+		data = append(data, anthropicModelObject(id))
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"data":     data,
+		"has_more": false,
+		"first_id": fakeAnthropicModels[0],
+		"last_id":  fakeAnthropicModels[len(fakeAnthropicModels)-1],
+	})
+}
+
+func (s *FakeServer) anthropicModel(w http.ResponseWriter, r *http.Request) {
+	if reply := s.next(r); writeFailure(w, reply, anthropicError) {
+		return
+	}
+	writeJSON(w, http.StatusOK, anthropicModelObject(r.PathValue("id")))
+}
+
+// anthropicBatch renders a message batch object.
+func anthropicBatch(r *http.Request, id string, customIDs []string, ended bool) map[string]any {
+	now := time.Now().UTC()
+	batch := map[string]any{
+		"id":                  id,
+		"type":                "message_batch",
+		"processing_status":   "in_progress",
+		"created_at":          now.Format(time.RFC3339),
+		"expires_at":          now.Add(24 * time.Hour).Format(time.RFC3339),
+		"archived_at":         nil,
+		"cancel_initiated_at": nil,
+		"ended_at":            nil,
+		"results_url":         nil,
+		"request_counts":      map[string]any{"processing": len(customIDs), "succeeded": 0, "errored": 0, "canceled": 0, "expired": 0},
+	}
+	if ended {
+		batch["processing_status"] = "ended"
+		batch["ended_at"] = now.Format(time.RFC3339)
+		batch["results_url"] = "http://" + r.Host + "/v1/messages/batches/" + id + "/results"
+		batch["request_counts"] = map[string]any{"processing": 0, "succeeded": len(customIDs), "errored": 0, "canceled": 0, "expired": 0}
+	}
+	return batch
+}
+
+func (s *FakeServer) anthropicCreateBatch(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Requests []struct {
+			CustomID string `json:"custom_id"`
+		} `json:"requests"`
+	}
+	readJSON(r, &req)
+	if reply := s.next(r); writeFailure(w, reply, anthropicError) {
+		return
+	}
+	var customIDs []string
+	for _, item := range req.Requests {
+		customIDs = append(customIDs, item.CustomID)
+	}
+	id := s.newBatch("msgbatch_", customIDs)
+	writeJSON(w, http.StatusOK, anthropicBatch(r, id, customIDs, false))
+}
+
+func (s *FakeServer) anthropicGetBatch(w http.ResponseWriter, r *http.Request) {
+	customIDs, ok := s.batch(r.PathValue("id"))
+	if !ok {
+		writeJSON(w, http.StatusNotFound, anthropicError(http.StatusNotFound, "batch not found"))
+		return
+	}
+	if reply := s.next(r); writeFailure(w, reply, anthropicError) {
+		return
+	}
+	writeJSON(w, http.StatusOK, anthropicBatch(r, r.PathValue("id"), customIDs, true))
+}
+
+// anthropicBatchResults writes one result per request, each taking the next
+// reply from the queue; error replies become errored results.
+func (s *FakeServer) anthropicBatchResults(w http.ResponseWriter, r *http.Request) {
+	customIDs, ok := s.batch(r.PathValue("id"))
+	if !ok {
+		writeJSON(w, http.StatusNotFound, anthropicError(http.StatusNotFound, "batch not found"))
+		return
+	}
+	w.Header().Set("Content-Type", "application/x-jsonl")
+	enc := json.NewEncoder(w)
+	for _, customID := range customIDs {
+		reply := s.next(r)
+		if reply.Model == "" {
+			reply.Model = fakeAnthropicModels[0]
+		}
+		result := map[string]any{"type": "succeeded", "message": anthropicMessage(s.id("msg_"), reply.Model, reply)}
+		if reply.Status != 0 {
+			result = map[string]any{"type": "errored", "error": anthropicError(reply.Status, reply.Message)}
+		}
+		_ = enc.Encode(map[string]any{"custom_id": customID, "result": result})
+	}
+}
+
+// anthropicFileMetadata renders file metadata.
+func anthropicFileMetadata(f fakeFile) map[string]any {
+	return map[string]any{
+		"id":           f.id,
+		"type":         "file",
+		"filename":     f.name,
+		"mime_type":    f.mimeType,
+		"size_bytes":   len(f.data),
+		"created_at":   f.createdAt.Format(time.RFC3339),
+		"downloadable": true,
+	}
+}
+
+func (s *FakeServer) anthropicUploadFile(w http.ResponseWriter, r *http.Request) {
+	if reply := s.next(r); writeFailure(w, reply, anthropicError) {
+		return
+	}
+	f, err := s.uploadFile(r, "file_")
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, anthropicError(http.StatusBadRequest, err.Error()))
+		return
+	}
+	writeJSON(w, http.StatusOK, anthropicFileMetadata(f))
+}
+
+func (s *FakeServer) anthropicListFiles(w http.ResponseWriter, r *http.Request) {
+	if reply := s.next(r); writeFailure(w, reply, anthropicError) {
+		return
+	}
+	data := []any{}
+	var firstID, lastID any
+	for _, f := range s.listFiles() {
+		if firstID == nil {
+			firstID = f.id
+		}
+		lastID = f.id
+		data = append(data, anthropicFileMetadata(f))
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"data": data, "has_more": false, "first_id": firstID, "last_id": lastID})
+}
+
+func (s *FakeServer) anthropicGetFile(w http.ResponseWriter, r *http.Request) {
+	f, ok := s.file(r.PathValue("id"))
+	if !ok {
+		writeJSON(w, http.StatusNotFound, anthropicError(http.StatusNotFound, "file not found"))
+		return
+	}
+	if reply := s.next(r); writeFailure(w, reply, anthropicError) {
+		return
+	}
+	writeJSON(w, http.StatusOK, anthropicFileMetadata(f))
+}
+
+func (s *FakeServer) anthropicDeleteFile(w http.ResponseWriter, r *http.Request) {
+	if reply := s.next(r); writeFailure(w, reply, anthropicError) {
+		return
+	}
+	if !s.deleteFile(r.PathValue("id")) {
+		writeJSON(w, http.StatusNotFound, anthropicError(http.StatusNotFound, "file not found"))
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"id": r.PathValue("id"), "type": "file_deleted"})
+}
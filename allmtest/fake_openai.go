@@ -0,0 +1,468 @@
+package allmtest
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// fakeOpenAIModels are the models listed by the fake OpenAI server.
+var fakeOpenAIModels = []string{"gpt-4o", "gpt-4o-mini", "text-embedding-3-small"}
+
+// NewFakeOpenAIServer starts a FakeServer speaking the OpenAI API: chat
+// completions (JSON and SSE), embeddings, models, images, audio speech and
+// transcriptions, moderations, batches and files. It also serves
+// OpenAI-compatible providers, e.g. provider.Local(srv.URL + "/v1"),
+// including a Cohere-style /v1/rerank endpoint. Close it when done.
+func NewFakeOpenAIServer() *FakeServer {
+	return newFakeServer(func(s *FakeServer, mux *http.ServeMux) {
+		mux.HandleFunc("POST /v1/chat/completions", s.openaiChat)
+		mux.HandleFunc("POST /v1/embeddings", s.openaiEmbeddings)
+		mux.HandleFunc("GET /v1/models", s.openaiModels)
+		mux.HandleFunc("GET /v1/models/{id}", s.openaiModel)
+		mux.HandleFunc("POST /v1/images/generations", s.openaiImages)
+		mux.HandleFunc("POST /v1/images/edits", s.openaiImages)
+		mux.HandleFunc("POST /v1/images/variations", s.openaiImages)
+		mux.HandleFunc("POST /v1/audio/speech", s.openaiSpeech)
+		mux.HandleFunc("POST /v1/audio/transcriptions", s.openaiTranscription)
+		mux.HandleFunc("POST /v1/moderations", s.openaiModeration)
+		mux.HandleFunc("POST /v1/batches", s.openaiCreateBatch)
+		mux.HandleFunc("GET /v1/batches/{id}", s.openaiGetBatch)
+		mux.HandleFunc("POST /v1/files", s.openaiUploadFile)
+		mux.HandleFunc("GET /v1/files", s.openaiListFiles)
+		mux.HandleFunc("GET /v1/files/{id}", s.openaiGetFile)
+		mux.HandleFunc("DELETE /v1/files/{id}", s.openaiDeleteFile)
+		mux.HandleFunc("GET /v1/files/{id}/content", s.fileContent(openaiError))
+		mux.HandleFunc("POST /v1/rerank", s.rerank)
+	})
+}
+
+// openaiError renders an OpenAI error body.
+func openaiError(status int, message string) any {
+	kind, code := "server_error", any(nil)
+	switch status {
+	case http.StatusBadRequest:
+		kind = "invalid_request_error"
+	case http.StatusUnauthorized:
+		kind, code = "invalid_request_error", "invalid_api_key"
+	case http.StatusNotFound:
+		kind, code = "invalid_request_error", "model_not_found"
+	case http.StatusTooManyRequests:
+		kind, code = "requests", "rate_limit_exceeded"
+	}
+	return map[string]any{"error": map[string]any{"message": message, "type": kind, "param": nil, "code": code}}
+}
+
+// openaiUsage renders token usage.
+func openaiUsage(reply FakeReply) map[string]any {
+	return map[string]any{
+		"prompt_tokens":     reply.InputTokens,
+		"completion_tokens": reply.OutputTokens,
+		"total_tokens":      reply.InputTokens + reply.OutputTokens,
+	}
+}
+
+// openaiToolCalls renders tool calls; index is set for stream deltas.
+func openaiToolCalls(reply FakeReply, stream bool) []map[string]any {
+	var calls []map[string]any
+	for i, tc := range reply.ToolCalls {
+		call := map[string]any{
+			"id":       tc.ID,
+			"type":     "function",
+			"function": map[string]any{"name": tc.Name, "arguments": string(toolInput(tc.Arguments))},
+		}
+		if stream {
+			call["index"] = i
+		}
+		calls = append(calls, call)
+	}
+	return calls
+}
+
+// finishReason returns the finish reason of reply.
+func finishReason(reply FakeReply) string {
+	if len(reply.ToolCalls) > 0 {
+		return "tool_calls"
+	}
+	return "stop"
+}
+
+func (s *FakeServer) openaiChat(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Model         string `json:"model"`
+		Stream        bool   `json:"stream"`
+		StreamOptions struct {
+			IncludeUsage bool `json:"include_usage"`
+		} `json:"stream_options"`
+	}
+	readJSON(r, &req)
+	reply := s.next(r)
+	if reply.Model == "" {
+		reply.Model = req.Model
+	}
+	id, created := s.id("chatcmpl-"), time.Now().Unix()
+
+	if !req.Stream {
+		if writeFailure(w, reply, openaiError) {
+			return
+		}
+		message := map[string]any{"role": "assistant", "content": reply.Content}
+		if calls := openaiToolCalls(reply, false); calls != nil {
+			message["tool_calls"] = calls
+		}
+		writeJSON(w, http.StatusOK, map[string]any{
+			"id":      id,
+			"object":  "chat.completion",
+			"created": created,
+			"model":   reply.Model,
+			"choices": []any{map[string]any{"index": 0, "message": message, "finish_reason": finishReason(reply)}},
+			"usage":   openaiUsage(reply),
+		})
+		return
+	}
+	if reply.Status != 0 {
+		writeFailure(w, reply, openaiError)
+		return
+	}
+
+	sse := newSSEWriter(w, r, reply.Delay)
+	chunk := func(delta map[string]any, finish any) map[string]any {
+		return map[string]any{
+			"id":      id,
+			"object":  "chat.completion.chunk",
+			"created": created,
+			"model":   reply.Model,
+			"choices": []any{map[string]any{"index": 0, "delta": delta, "finish_reason": finish}},
+		}
+	}
+	sse.event("", chunk(map[string]any{"role": "assistant", "content": ""}, nil))
+	for i, text := range reply.Chunks {
+		sse.event("", chunk(map[string]any{"content": text}, nil))
+		if i == 0 && openaiStreamFault(sse, reply.Fault) {
+			return
+		}
+	}
+	if len(reply.Chunks) == 0 && openaiStreamFault(sse, reply.Fault) {
+		return
+	}
+	if calls := openaiToolCalls(reply, true); calls != nil {
+		sse.event("", chunk(map[string]any{"tool_calls": calls}, nil))
+	}
+	sse.event("", chunk(map[string]any{}, finishReason(reply)))
+	if req.StreamOptions.IncludeUsage {
+		sse.event("", map[string]any{
+			"id":      id,
+			"object":  "chat.completion.chunk",
+			"created": created,
+			"model":   reply.Model,
+			"choices": []any{},
+			"usage":   openaiUsage(reply),
+		})
+	}
+	sse.event("", "[DONE]")
+}
+
+// openaiStreamFault injects fault into a stream and reports whether the
+// stream must end.
+func openaiStreamFault(sse *sseWriter, fault Fault) bool {
+	switch fault {
+	case FaultMalformedSSE:
+		sse.event("", `{"id":"chatcmpl-bad","choices":[`)
+		return true
+	case FaultDisconnect:
+		disconnect(sse.w)
+		return true
+	}
+	return false
+}
+
+func (s *FakeServer) openaiEmbeddings(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Model          string          `json:"model"`
+		Input          json.RawMessage `json:"input"`
+		EncodingFormat string          `json:"encoding_format"`
+	}
+	readJSON(r, &req)
+	reply := s.next(r)
+	if writeFailure(w, reply, openaiError) {
+		return
+	}
+	var inputs []string
+	if err := json.Unmarshal(req.Input, &inputs); err != nil {
+		var single string
+		_ = json.Unmarshal(req.Input, &single)
+		inputs = []string{single}
+	}
+	var data []any
+	for i, text := range inputs {
+		var embedding any = fakeEmbedding(text)
+		if req.EncodingFormat == "base64" {
+			embedding = base64Floats(fakeEmbedding(text))
+		}
+		data = append(data, map[string]any{"object": "embedding", "index": i, "embedding": embedding})
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"object": "list",
+		"data":   data,
+		"model":  req.Model,
+		"usage":  map[string]any{"prompt_tokens": reply.InputTokens, "total_tokens": reply.InputTokens},
+	})
+}
+
+// base64Floats encodes vec as little-endian float32s, as OpenAI does for
+// encoding_format "base64".
+func base64Floats(vec []float64) string {
+	buf := make([]byte, 4*len(vec))
+	for i, v := range vec {
+		binary.LittleEndian.PutUint32(buf[4*i:], math.Float32bits(float32(v)))
+	}
+	return base64.StdEncoding.EncodeToString(buf)
+}
+
+// openaiModelObject renders a model object.
+func openaiModelObject(id string) map[string]any {
+	return map[string]any{"id": id, "object": "model", "created": 1735689600, "owned_by": "openai"}
+}
+
+func (s *FakeServer) openaiModels(w http.ResponseWriter, r *http.Request) {
+	if reply := s.next(r); writeFailure(w, reply, openaiError) {
+		return
+	}
+	var data []any
+	for _, id := range fakeOpenAIModels {
+		data = append(data, openaiModelObject(id))
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"object": "list", "data": data})
+}
+
+func (s *FakeServer) openaiModel(w http.ResponseWriter, r *http.Request) {
+	if reply := s.next(r); writeFailure(w, reply, openaiError) {
+		return
+	}
+	writeJSON(w, http.StatusOK, openaiModelObject(r.PathValue("id")))
+}
+
+// openaiImages serves generations and edits with a 1x1 PNG per image.
+func (s *FakeServer) openaiImages(w http.ResponseWriter, r *http.Request) {
+	n := 1
+	if r.Header.Get("Content-Type") == "application/json" {
+		var req struct {
+			N int `json:"n"`
+		}
+		readJSON(r, &req)
+		n = max(req.N, 1)
+	}
+	reply := s.next(r)
+	if writeFailure(w, reply, openaiError) {
+		return
+	}
+	var data []any
+	for range n {
+		data = append(data, map[string]any{"b64_json": fakePNG, "revised_prompt": reply.Content})
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"created": time.Now().Unix(), "data": data})
+}
+
+// openaiSpeech returns the reply content as the audio bytes.
+func (s *FakeServer) openaiSpeech(w http.ResponseWriter, r *http.Request) {
+	reply := s.next(r)
+	if writeFailure(w, reply, openaiError) {
+		return
+	}
+	w.Header().Set("Content-Type", "audio/mpeg")
+	_, _ = io.WriteString(w, reply.Content)
+}
+
+// openaiTranscription returns the reply content as the transcript.
+func (s *FakeServer) openaiTranscription(w http.ResponseWriter, r *http.Request) {
+	format := r.FormValue("response_format")
+	reply := s.next(r)
+	if writeFailure(w, reply, openaiError) {
+		return
+	}
+	switch format {
+	case "text", "srt", "vtt":
+		w.Header().Set("Content-Type", "text/plain")
+		_, _ = io.WriteString(w, reply.Content)
+	case "verbose_json":
+		writeJSON(w, http.StatusOK, map[string]any{
+			"task":     "transcribe",
+			"text":     reply.Content,
+			"language": "english",
+			"duration": 1.0,
+			"segments": []any{map[string]any{"id": 0, "start": 0.0, "end": 1.0, "text": reply.Content}},
+		})
+	default:
+		writeJSON(w, http.StatusOK, map[string]any{"text": reply.Content})
+	}
+}
+
+// openaiModeration returns one unflagged result per input.
+func (s *FakeServer) openaiModeration(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Input json.RawMessage `json:"input"`
+	}
+	readJSON(r, &req)
+	reply := s.next(r)
+	if writeFailure(w, reply, openaiError) {
+		return
+	}
+	n := 1
+	var inputs []json.RawMessage
+	if json.Unmarshal(req.Input, &inputs) == nil {
+		n = max(len(inputs), 1)
+	}
+	var results []any
+	for range n {
+		results = append(results, map[string]any{
+			"flagged":         false,
+			"categories":      map[string]bool{"harassment": false, "hate": false, "self-harm": false, "sexual": false, "violence": false},
+			"category_scores": map[string]float64{"harassment": 0, "hate": 0, "self-harm": 0, "sexual": 0, "violence": 0},
+		})
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"id": s.id("modr-"), "model": "omni-moderation-latest", "results": results})
+}
+
+// openaiBatch renders a batch object.
+func openaiBatch(id, inputFileID string, completed bool) map[string]any {
+	now := time.Now().Unix()
+	batch := map[string]any{
+		"id":                id,
+		"object":            "batch",
+		"endpoint":          "/v1/chat/completions",
+		"input_file_id":     inputFileID,
+		"completion_window": "24h",
+		"status":            "in_progress",
+		"created_at":        now,
+		"request_counts":    map[string]any{"total": 0, "completed": 0, "failed": 0},
+	}
+	if completed {
+		batch["status"] = "completed"
+		batch["completed_at"] = now
+		batch["output_file_id"] = "file-" + id
+	}
+	return batch
+}
+
+func (s *FakeServer) openaiCreateBatch(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		InputFileID string `json:"input_file_id"`
+	}
+	readJSON(r, &req)
+	if reply := s.next(r); writeFailure(w, reply, openaiError) {
+		return
+	}
+	id := s.newBatch("batch_", []string{req.InputFileID})
+	writeJSON(w, http.StatusOK, openaiBatch(id, req.InputFileID, false))
+}
+
+func (s *FakeServer) openaiGetBatch(w http.ResponseWriter, r *http.Request) {
+	ids, ok := s.batch(r.PathValue("id"))
+	if !ok {
+		writeJSON(w, http.StatusNotFound, openaiError(http.StatusNotFound, "batch not found"))
+		return
+	}
+	if reply := s.next(r); writeFailure(w, reply, openaiError) {
+		return
+	}
+	writeJSON(w, http.StatusOK, openaiBatch(r.PathValue("id"), ids[0], true))
+}
+
+// openaiFileObject renders a file object.
+func openaiFileObject(f fakeFile) map[string]any {
+	return map[string]any{
+		"id":         f.id,
+		"object":     "file",
+		"bytes":      len(f.data),
+		"created_at": f.createdAt.Unix(),
+		"filename":   f.name,
+		"purpose":    f.purpose,
+		"status":     "processed",
+	}
+}
+
+func (s *FakeServer) openaiUploadFile(w http.ResponseWriter, r *http.Request) {
+	if reply := s.next(r); writeFailure(w, reply, openaiError) {
+		return
+	}
+	f, err := s.uploadFile(r, "file-")
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, openaiError(http.StatusBadRequest, err.Error()))
+		return
+	}
+	writeJSON(w, http.StatusOK, openaiFileObject(f))
+}
+
+func (s *FakeServer) openaiListFiles(w http.ResponseWriter, r *http.Request) {
+	if reply := s.next(r); writeFailure(w, reply, openaiError) {
+		return
+	}
+	data := []any{}
+	for _, f := range s.listFiles() {
+		data = append(data, openaiFileObject(f))
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"object": "list", "data": data, "has_more": false})
+}
+
+func (s *FakeServer) openaiGetFile(w http.ResponseWriter, r *http.Request) {
+	f, ok := s.file(r.PathValue("id"))
+	if !ok {
+		writeJSON(w, http.StatusNotFound, openaiError(http.StatusNotFound, "file not found"))
+		return
+	}
+	if reply := s.next(r); writeFailure(w, reply, openaiError) {
+		return
+	}
+	writeJSON(w, http.StatusOK, openaiFileObject(f))
+}
+
+func (s *FakeServer) openaiDeleteFile(w http.ResponseWriter, r *http.Request) {
+	if reply := s.next(r); writeFailure(w, reply, openaiError) {
+		return
+	}
+	if !s.deleteFile(r.PathValue("id")) {
+		writeJSON(w, http.StatusNotFound, openaiError(http.StatusNotFound, "file not found"))
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"id": r.PathValue("id"), "object": "file", "deleted": true})
+}
+
+// rerank serves a Cohere-style rerank endpoint. Documents score by the share
+// of query words they contain, best first.
+func (s *FakeServer) rerank(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Query     string   `json:"query"`
+		Documents []string `json:"documents"`
+		TopN      int      `json:"top_n"`
+	}
+	readJSON(r, &req)
+	if reply := s.next(r); writeFailure(w, reply, openaiError) {
+		return
+	}
+	words := strings.Fields(strings.ToLower(req.Query))
+	results := make([]map[string]any, len(req.Documents))
+	scores := make([]float64, len(req.Documents))
+	for i, doc := range req.Documents {
+		doc = strings.ToLower(doc)
+		for _, word := range words {
+			if strings.Contains(doc, word) {
+				scores[i]++
+			}
+		}
+		scores[i] /= float64(max(len(words), 1))
+		results[i] = map[string]any{"index": i, "relevance_score": scores[i]}
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		return scores[results[i]["index"].(int)] > scores[results[j]["index"].(int)]
+	})
+	if req.TopN > 0 && req.TopN < len(results) {
+		results = results[:req.TopN]
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"id": s.id("rerank-"), "results": results})
+}
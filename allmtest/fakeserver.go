@@ -0,0 +1,411 @@
+package allmtest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/kusandriadi/allm-go"
+)
+
+// Fault is a wire-level failure injected by a FakeServer.
+type Fault int
+
+const (
+	FaultNone         Fault = iota // No fault
+	FaultMalformedSSE              // Send an event with invalid JSON (invalid JSON body when not streaming)
+	FaultDisconnect                // Close the connection after the first chunk (before the body when not streaming)
+)
+
+// FakeReply scripts one response of a FakeServer. The zero value is a
+// successful reply with the content "OK".
+type FakeReply struct {
+	Content      string          // Reply text (default "OK")
+	Chunks       []string        // Streamed text deltas (default: Content as one delta)
+	ToolCalls    []allm.ToolCall // Tool calls made by the reply
+	Model        string          // Model reported (default: the requested model)
+	InputTokens  int             // Reported input tokens (default 10)
+	OutputTokens int             // Reported output tokens (default 5)
+
+	Status     int           // HTTP error status; 0 = success
+	Message    string        // Error message sent with Status
+	RetryAfter time.Duration // Retry-After sent with Status
+	Fault      Fault         // Wire-level fault
+	Delay      time.Duration // Delay before the response and before each streamed chunk
+}
+
+// FakeText returns a successful reply with content.
+func FakeText(content string) FakeReply {
+	return FakeReply{Content: content}
+}
+
+// FakeError returns an HTTP error reply, e.g. FakeError(500, "internal error").
+func FakeError(status int, message string) FakeReply {
+	return FakeReply{Status: status, Message: message}
+}
+
+// FakeRateLimit returns a 429 reply with a Retry-After header.
+func FakeRateLimit(retryAfter time.Duration) FakeReply {
+	return FakeReply{Status: http.StatusTooManyRequests, Message: "rate limit exceeded", RetryAfter: retryAfter}
+}
+
+// FakeOverloaded returns Anthropic's 529 overloaded reply.
+func FakeOverloaded() FakeReply {
+	return FakeReply{Status: 529, Message: "overloaded"}
+}
+
+// FakeFault returns a reply that fails with fault on the wire.
+func FakeFault(fault Fault) FakeReply {
+	return FakeReply{Fault: fault}
+}
+
+// FakeRequest is a request captured by a FakeServer.
+type FakeRequest struct {
+	Method string
+	Path   string
+	Header http.Header
+	Body   []byte
+}
+
+// JSON decodes the request body into v.
+func (r FakeRequest) JSON(v any) error {
+	return json.Unmarshal(r.Body, v)
+}
+
+// FakeServer is an in-process HTTP server speaking a provider's wire
+// protocol, for end-to-end tests of the providers without network access:
+//
+//	srv := allmtest.NewFakeAnthropicServer()
+//	defer srv.Close()
+//	srv.Enqueue(allmtest.FakeText("Hello!"), allmtest.FakeRateLimit(time.Millisecond))
+//	p := provider.Anthropic("key", provider.WithAnthropicHTTPClient(srv.Client()))
+//
+// Every request takes the next reply from the queue; when the queue is empty
+// the zero FakeReply is used. Error replies apply to any endpoint; success
+// replies fill in chat responses, and other endpoints return canned data.
+// The provider SDKs retry 429 and 5xx responses twice, taking one reply per
+// attempt.
+type FakeServer struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	replies  []FakeReply
+	requests []FakeRequest
+	batches  map[string][]string // batch ID -> custom IDs of its requests
+	files    map[string]fakeFile // file ID -> uploaded file
+	seq      int
+}
+
+// fakeFile is a file uploaded to a fake server.
+type fakeFile struct {
+	seq       int // upload order
+	id        string
+	name      string
+	mimeType  string
+	purpose   string
+	data      []byte
+	createdAt time.Time
+}
+
+// newFakeServer starts a server routing requests through mux.
+func newFakeServer(routes func(s *FakeServer, mux *http.ServeMux)) *FakeServer {
+	s := &FakeServer{batches: make(map[string][]string), files: make(map[string]fakeFile)}
+	mux := http.NewServeMux()
+	routes(s, mux)
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		s.mu.Lock()
+		s.requests = append(s.requests, FakeRequest{Method: r.Method, Path: r.URL.Path, Header: r.Header.Clone(), Body: body})
+		s.mu.Unlock()
+		mux.ServeHTTP(w, r)
+	}))
+	return s
+}
+
+// Client returns an HTTP client that sends every request to the server,
+// whatever its host, so providers keep their default base URLs.
+func (s *FakeServer) Client() *http.Client {
+	return &http.Client{Transport: &redirectTransport{target: s.Listener.Addr().String(), base: s.Server.Client().Transport}}
+}
+
+// Enqueue appends replies to the queue.
+func (s *FakeServer) Enqueue(replies ...FakeReply) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.replies = append(s.replies, replies...)
+}
+
+// Requests returns all captured requests.
+func (s *FakeServer) Requests() []FakeRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]FakeRequest(nil), s.requests...)
+}
+
+// LastRequest returns the most recent request, or nil if none.
+func (s *FakeServer) LastRequest() *FakeRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.requests) == 0 {
+		return nil
+	}
+	r := s.requests[len(s.requests)-1]
+	return &r
+}
+
+// next pops the next reply, defaulting unset fields, and waits for its
+// delay or until the client goes away.
+func (s *FakeServer) next(r *http.Request) FakeReply {
+	s.mu.Lock()
+	var reply FakeReply
+	if len(s.replies) > 0 {
+		reply = s.replies[0]
+		s.replies = s.replies[1:]
+	}
+	s.mu.Unlock()
+	pause(r, reply.Delay)
+
+	if reply.Content == "" && len(reply.ToolCalls) == 0 {
+		reply.Content = "OK"
+	}
+	if len(reply.Chunks) == 0 && reply.Content != "" {
+		reply.Chunks = []string{reply.Content}
+	}
+	if reply.InputTokens == 0 {
+		reply.InputTokens = 10
+	}
+	if reply.OutputTokens == 0 {
+		reply.OutputTokens = 5
+	}
+	return reply
+}
+
+// pause waits for d or until the client of r goes away.
+func pause(r *http.Request, d time.Duration) {
+	if d <= 0 {
+		return
+	}
+	select {
+	case <-time.After(d):
+	case <-r.Context().Done():
+	}
+}
+
+// id returns a new identifier with prefix.
+func (s *FakeServer) id(prefix string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.seq++
+	return fmt.Sprintf("%s%d", prefix, s.seq)
+}
+
+// newBatch registers a batch of requests and returns its ID.
+func (s *FakeServer) newBatch(prefix string, customIDs []string) string {
+	id := s.id(prefix)
+	s.mu.Lock()
+	s.batches[id] = customIDs
+	s.mu.Unlock()
+	return id
+}
+
+// batch returns the custom IDs of a batch's requests.
+func (s *FakeServer) batch(id string) ([]string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ids, ok := s.batches[id]
+	return ids, ok
+}
+
+// uploadFile stores the "file" part of a multipart upload under a new ID
+// with prefix.
+func (s *FakeServer) uploadFile(r *http.Request, prefix string) (fakeFile, error) {
+	part, header, err := r.FormFile("file")
+	if err != nil {
+		return fakeFile{}, err
+	}
+	defer func() { _ = part.Close() }()
+	data, err := io.ReadAll(part)
+	if err != nil {
+		return fakeFile{}, err
+	}
+	f := fakeFile{
+		name:      header.Filename,
+		mimeType:  header.Header.Get("Content-Type"),
+		purpose:   r.FormValue("purpose"),
+		data:      data,
+		createdAt: time.Now().UTC().Truncate(time.Second),
+	}
+	s.mu.Lock()
+	s.seq++
+	f.seq, f.id = s.seq, fmt.Sprintf("%s%d", prefix, s.seq)
+	s.files[f.id] = f
+	s.mu.Unlock()
+	return f, nil
+}
+
+// file returns an uploaded file.
+func (s *FakeServer) file(id string) (fakeFile, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f, ok := s.files[id]
+	return f, ok
+}
+
+// listFiles returns the uploaded files, oldest first.
+func (s *FakeServer) listFiles() []fakeFile {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	files := make([]fakeFile, 0, len(s.files))
+	for _, f := range s.files {
+		files = append(files, f)
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].seq < files[j].seq })
+	return files
+}
+
+// deleteFile removes an uploaded file and reports whether it existed.
+func (s *FakeServer) deleteFile(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.files[id]
+	delete(s.files, id)
+	return ok
+}
+
+// fileContent returns a handler serving the data of an uploaded file.
+// errorBody renders the protocol's error JSON.
+func (s *FakeServer) fileContent(errorBody func(status int, message string) any) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		f, ok := s.file(r.PathValue("id"))
+		if !ok {
+			writeJSON(w, http.StatusNotFound, errorBody(http.StatusNotFound, "file not found"))
+			return
+		}
+		if reply := s.next(r); writeFailure(w, reply, errorBody) {
+			return
+		}
+		w.Header().Set("Content-Type", "application/octet-stream")
+		_, _ = w.Write(f.data)
+	}
+}
+
+// writeFailure writes reply's error status or non-streaming fault and
+// reports whether it did. errorBody renders the protocol's error JSON.
+func writeFailure(w http.ResponseWriter, reply FakeReply, errorBody func(status int, message string) any) bool {
+	switch {
+	case reply.Status != 0:
+		if reply.RetryAfter > 0 {
+			w.Header().Set("Retry-After", strconv.Itoa(int((reply.RetryAfter+time.Second-1)/time.Second)))
+			w.Header().Set("Retry-After-Ms", strconv.FormatInt(max(reply.RetryAfter.Milliseconds(), 1), 10))
+		}
+		message := reply.Message
+		if message == "" {
+			message = http.StatusText(reply.Status)
+		}
+		writeJSON(w, reply.Status, errorBody(reply.Status, message))
+		return true
+	case reply.Fault == FaultDisconnect:
+		disconnect(w)
+		return true
+	case reply.Fault == FaultMalformedSSE:
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(w, `{"id":`)
+		return true
+	}
+	return false
+}
+
+// writeJSON writes v as a JSON response.
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// disconnect closes the client connection without completing the response.
+func disconnect(w http.ResponseWriter) {
+	if f, ok := w.(http.Flusher); ok {
+		f.Flush()
+	}
+	if hj, ok := w.(http.Hijacker); ok {
+		if conn, _, err := hj.Hijack(); err == nil {
+			_ = conn.Close()
+			return
+		}
+	}
+	panic(http.ErrAbortHandler)
+}
+
+// sseWriter writes server-sent events.
+type sseWriter struct {
+	w     http.ResponseWriter
+	r     *http.Request
+	delay time.Duration // before each event
+}
+
+// newSSEWriter starts an event stream response to r.
+func newSSEWriter(w http.ResponseWriter, r *http.Request, delay time.Duration) *sseWriter {
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	if f, ok := w.(http.Flusher); ok {
+		f.Flush()
+	}
+	return &sseWriter{w: w, r: r, delay: delay}
+}
+
+// event writes one event; name is omitted when empty.
+func (s *sseWriter) event(name string, data any) {
+	pause(s.r, s.delay)
+	payload, ok := data.(string)
+	if !ok {
+		b, _ := json.Marshal(data)
+		payload = string(b)
+	}
+	if name != "" {
+		_, _ = fmt.Fprintf(s.w, "event: %s\n", name)
+	}
+	_, _ = fmt.Fprintf(s.w, "data: %s\n\n", payload)
+	if f, ok := s.w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// redirectTransport sends requests to target instead of their own host.
+type redirectTransport struct {
+	target string
+	base   http.RoundTripper
+}
+
+func (t *redirectTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	out := req.Clone(req.Context())
+	out.URL.Scheme = "http"
+	out.URL.Host = t.target
+	out.Host = t.target
+	return t.base.RoundTrip(out)
+}
+
+// readJSON decodes the request body into v, ignoring errors.
+func readJSON(r *http.Request, v any) {
+	_ = json.NewDecoder(r.Body).Decode(v)
+}
+
+// fakeEmbedding returns a deterministic unit-free vector for text.
+func fakeEmbedding(text string) []float64 {
+	vec := make([]float64, 8)
+	for i, c := range text {
+		vec[i%len(vec)] += float64(c%32) / 32
+	}
+	return vec
+}
+
+// fakePNG is a 1x1 transparent PNG, base64 encoded.
+const fakePNG = "iVBORw0KGgoAAAANSUhEUgAAAAEAAAABCAQAAAC1HAwCAAAAC0lEQVR42mNkYAAAAAYAAjCB0C8AAAAASUVORK5CYII="
@@ -0,0 +1,206 @@
+package allmtest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kusandriadi/allm-go"
+	"github.com/kusandriadi/allm-go/provider"
+)
+
+// collect drains a stream into its content, tool uses and first error.
+func collect(ch <-chan allm.StreamChunk) (string, []allm.StreamToolUse, error) {
+	var content strings.Builder
+	var calls []allm.StreamToolUse
+	for chunk := range ch {
+		if chunk.Error != nil {
+			return content.String(), calls, chunk.Error
+		}
+		content.WriteString(chunk.Content)
+		if chunk.ToolUse != nil {
+			calls = append(calls, *chunk.ToolUse)
+		}
+	}
+	return content.String(), calls, nil
+}
+
+func newAnthropic(srv *FakeServer) *provider.AnthropicProvider {
+	return provider.Anthropic("test-key", provider.WithAnthropicHTTPClient(srv.Client()), provider.WithAnthropicMaxTokens(100))
+}
+
+func TestFakeAnthropicComplete(t *testing.T) {
+	srv := NewFakeAnthropicServer()
+	defer srv.Close()
+	srv.Enqueue(FakeReply{Content: "Hello!", InputTokens: 7, OutputTokens: 3})
+
+	resp, err := newAnthropic(srv).Complete(context.Background(), &allm.Request{
+		Model:    "claude-test",
+		Messages: []allm.Message{{Role: allm.RoleUser, Content: "Hi"}},
+	})
+	if err != nil {
+		t.Fatalf("Complete: %v", err)
+	}
+	if resp.Content != "Hello!" || resp.InputTokens != 7 || resp.OutputTokens != 3 || resp.Model != "claude-test" {
+		t.Errorf("response = %+v", resp)
+	}
+
+	req := srv.LastRequest()
+	if req.Path != "/v1/messages" || req.Header.Get("X-Api-Key") != "test-key" {
+		t.Errorf("request = %s %v", req.Path, req.Header)
+	}
+	var body struct {
+		Messages []struct {
+			Role string `json:"role"`
+		} `json:"messages"`
+	}
+	if err := req.JSON(&body); err != nil || len(body.Messages) != 1 {
+		t.Errorf("captured body = %s, %v", req.Body, err)
+	}
+}
+
+func TestFakeAnthropicStream(t *testing.T) {
+	srv := NewFakeAnthropicServer()
+	defer srv.Close()
+	p := newAnthropic(srv)
+	req := &allm.Request{Messages: []allm.Message{{Role: allm.RoleUser, Content: "Hi"}}}
+	call := allm.ToolCall{ID: "toolu_1", Name: "lookup", Arguments: json.RawMessage(`{"q":"x"}`)}
+	srv.Enqueue(FakeReply{Chunks: []string{"Hel", "lo"}}, FakeReply{ToolCalls: []allm.ToolCall{call}})
+
+	content, _, err := collect(p.Stream(context.Background(), req))
+	if err != nil || content != "Hello" {
+		t.Errorf("stream = %q, %v", content, err)
+	}
+
+	resp, err := p.Complete(context.Background(), req)
+	if err != nil || len(resp.ToolCalls) != 1 || resp.ToolCalls[0].Name != "lookup" || string(resp.ToolCalls[0].Arguments) != `{"q":"x"}` {
+		t.Errorf("tool calls = %+v, %v", resp, err)
+	}
+}
+
+func TestFakeAnthropicErrors(t *testing.T) {
+	srv := NewFakeAnthropicServer()
+	defer srv.Close()
+	p := newAnthropic(srv)
+	req := &allm.Request{Messages: []allm.Message{{Role: allm.RoleUser, Content: "Hi"}}}
+
+	// The SDK retries a rate limit after Retry-After.
+	srv.Enqueue(FakeRateLimit(time.Millisecond), FakeText("ok"))
+	if resp, err := p.Complete(context.Background(), req); err != nil || resp.Content != "ok" {
+		t.Fatalf("Complete after retry = %v, %v", resp, err)
+	}
+
+	overloaded := FakeOverloaded()
+	overloaded.RetryAfter = time.Millisecond
+	srv.Enqueue(overloaded, overloaded, overloaded)
+	if _, err := p.Complete(context.Background(), req); !errors.Is(err, allm.ErrOverloaded) {
+		t.Errorf("expected ErrOverloaded, got %v", err)
+	}
+
+	srv.Enqueue(FakeError(401, "bad key"))
+	if _, err := p.Complete(context.Background(), req); err == nil || errors.Is(err, allm.ErrServerError) {
+		t.Errorf("expected client error, got %v", err)
+	}
+
+	for _, fault := range []Fault{FaultMalformedSSE, FaultDisconnect} {
+		srv.Enqueue(FakeFault(fault))
+		if _, _, err := collect(p.Stream(context.Background(), req)); err == nil {
+			t.Errorf("fault %d: expected stream error", fault)
+		}
+	}
+}
+
+func TestFakeAnthropicEndpoints(t *testing.T) {
+	srv := NewFakeAnthropicServer()
+	defer srv.Close()
+	p := newAnthropic(srv)
+
+	srv.Enqueue(FakeReply{InputTokens: 42})
+	count, err := p.CountTokens(context.Background(), &allm.Request{Messages: []allm.Message{{Role: allm.RoleUser, Content: "Hi"}}})
+	if err != nil || count.InputTokens != 42 {
+		t.Errorf("CountTokens = %+v, %v", count, err)
+	}
+
+	models, err := p.Models(context.Background())
+	if err != nil || len(models) != len(fakeAnthropicModels) {
+		t.Errorf("Models = %+v, %v", models, err)
+	}
+}
+
+func TestFakeOpenAI(t *testing.T) {
+	srv := NewFakeOpenAIServer()
+	defer srv.Close()
+	ctx := context.Background()
+	p := provider.OpenAI("test-key", provider.WithOpenAIHTTPClient(srv.Client()))
+	req := &allm.Request{Model: "gpt-4o", Messages: []allm.Message{{Role: allm.RoleUser, Content: "Hi"}}}
+
+	srv.Enqueue(FakeText("Hello!"))
+	resp, err := p.Complete(ctx, req)
+	if err != nil || resp.Content != "Hello!" || resp.InputTokens != 10 {
+		t.Fatalf("Complete = %+v, %v", resp, err)
+	}
+	if got := srv.LastRequest().Header.Get("Authorization"); got != "Bearer test-key" {
+		t.Errorf("Authorization = %q", got)
+	}
+
+	srv.Enqueue(FakeReply{Chunks: []string{"a", "b", "c"}})
+	if content, _, err := collect(p.Stream(ctx, req)); err != nil || content != "abc" {
+		t.Errorf("Stream = %q, %v", content, err)
+	}
+
+	emb, err := p.Embed(ctx, &allm.EmbedRequest{Input: []string{"x", "y"}})
+	if err != nil || len(emb.Embeddings) != 2 || len(emb.Embeddings[0]) != 8 {
+		t.Errorf("Embed = %+v, %v", emb, err)
+	}
+
+	img, err := p.GenerateImage(ctx, &allm.ImageRequest{Prompt: "cat", Model: "dall-e-3", ResponseFormat: allm.ImageResponseB64JSON})
+	if err != nil || len(img.Images) != 1 || len(img.Images[0].Data) == 0 {
+		t.Errorf("GenerateImage = %+v, %v", img, err)
+	}
+
+	srv.Enqueue(FakeText("audio"))
+	speech, err := p.Speak(ctx, &allm.SpeechRequest{Input: "Hi"})
+	if err != nil || string(speech.Audio) != "audio" {
+		t.Errorf("Speak = %+v, %v", speech, err)
+	}
+
+	srv.Enqueue(FakeText("hello world"))
+	text, err := p.Transcribe(ctx, &allm.TranscribeRequest{Audio: []byte("RIFF"), Format: "wav", Verbose: true})
+	if err != nil || text.Text != "hello world" || len(text.Segments) != 1 {
+		t.Errorf("Transcribe = %+v, %v", text, err)
+	}
+
+	mod, err := p.Moderate(ctx, &allm.ModerationRequest{Input: []string{"hi"}})
+	if err != nil || len(mod.Results) != 1 || mod.Results[0].Flagged {
+		t.Errorf("Moderate = %+v, %v", mod, err)
+	}
+
+	boom := FakeReply{Status: 500, Message: "boom", RetryAfter: time.Millisecond}
+	srv.Enqueue(boom, boom, boom)
+	if _, err := p.Complete(ctx, req); !errors.Is(err, allm.ErrServerError) {
+		t.Errorf("expected ErrServerError, got %v", err)
+	}
+}
+
+func TestFakeOpenAICompatible(t *testing.T) {
+	srv := NewFakeOpenAIServer()
+	defer srv.Close()
+	p := provider.Local(srv.URL + "/v1")
+
+	srv.Enqueue(FakeReply{Chunks: []string{"lo", "cal"}}, FakeFault(FaultDisconnect))
+	req := &allm.Request{Messages: []allm.Message{{Role: allm.RoleUser, Content: "Hi"}}}
+	if content, _, err := collect(p.Stream(context.Background(), req)); err != nil || content != "local" {
+		t.Errorf("Stream = %q, %v", content, err)
+	}
+	if _, _, err := collect(p.Stream(context.Background(), req)); err == nil {
+		t.Error("expected error after disconnect")
+	}
+
+	models, err := p.Models(context.Background())
+	if err != nil || len(models) != len(fakeOpenAIModels) {
+		t.Errorf("Models = %+v, %v", models, err)
+	}
+}
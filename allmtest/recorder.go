@@ -0,0 +1,379 @@
+package allmtest
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/kusandriadi/allm-go/provider"
+)
+
+// Recorder modes.
+const (
+	ModeRecord        = "record"         // send every request to the API and rewrite the cassette
+	ModeReplay        = "replay"         // serve requests from the cassette only; unmatched requests fail
+	ModeRecordMissing = "record_missing" // replay matches and record requests the cassette lacks
+)
+
+// RecordModeEnv is the environment variable read by ModeFromEnv.
+const RecordModeEnv = "ALLM_RECORD_MODE"
+
+// cassetteVersion is the current cassette file format version.
+const cassetteVersion = 1
+
+// redacted replaces secrets in cassettes.
+const redacted = "[REDACTED]"
+
+// sensitiveHeaders are never written to cassettes.
+var sensitiveHeaders = []string{"Authorization", "X-Api-Key", "Api-Key", "Cookie", "Set-Cookie", "Openai-Organization", "Openai-Project"}
+
+// keyCandidate matches strings that may be API keys or tokens.
+var keyCandidate = regexp.MustCompile(`[A-Za-z0-9_.-]{30,}`)
+
+// ModeFromEnv returns the mode in the ALLM_RECORD_MODE environment variable,
+// or def if it is unset, so one test can replay in CI and record locally:
+//
+//	ALLM_RECORD_MODE=record_missing go test ./...
+func ModeFromEnv(def string) string {
+	if mode := os.Getenv(RecordModeEnv); mode != "" {
+		return mode
+	}
+	return def
+}
+
+// Recorder is an http.RoundTripper that records provider HTTP interactions
+// into a cassette file and replays them, so tests against real APIs run
+// deterministically and without keys in CI:
+//
+//	rec, err := allmtest.NewRecorder("testdata/chat.json", allmtest.ModeFromEnv(allmtest.ModeReplay))
+//	if err != nil {
+//	    t.Fatal(err)
+//	}
+//	t.Cleanup(func() { _ = rec.Save() })
+//	client := allm.New(provider.Anthropic("", provider.WithAnthropicHTTPClient(rec.Client())))
+//
+// Requests are matched on method, URL and body. JSON bodies are compared
+// after normalization (key order and fields ignored with WithIgnoredFields
+// do not matter); multipart bodies match on method and URL only. Identical
+// requests replay their recordings in order, then repeat the last one.
+// Response bodies are stored verbatim, so streamed (SSE) responses replay
+// like JSON ones. Credentials headers are dropped and strings that look like
+// API keys (see provider.DetectKeyInString) are replaced with [REDACTED].
+type Recorder struct {
+	mu        sync.Mutex
+	path      string
+	mode      string
+	transport http.RoundTripper
+	ignored   []string
+	cassette  cassette
+	used      map[string]int // replays served per match key
+	dirty     bool
+}
+
+// RecorderOption configures a Recorder.
+type RecorderOption func(*Recorder)
+
+// WithTransport sets the transport that records real requests (default: http.DefaultTransport).
+func WithTransport(rt http.RoundTripper) RecorderOption {
+	return func(r *Recorder) {
+		r.transport = rt
+	}
+}
+
+// WithIgnoredFields excludes top-level JSON request body fields from
+// matching, e.g. "user" or "metadata" when they vary between runs.
+func WithIgnoredFields(fields ...string) RecorderOption {
+	return func(r *Recorder) {
+		r.ignored = append(r.ignored, fields...)
+	}
+}
+
+// cassette is the file format of recorded interactions.
+type cassette struct {
+	Version      int           `json:"version"`
+	Interactions []interaction `json:"interactions"`
+}
+
+type interaction struct {
+	Request  recordedRequest  `json:"request"`
+	Response recordedResponse `json:"response"`
+}
+
+type recordedRequest struct {
+	Method string      `json:"method"`
+	URL    string      `json:"url"`
+	Header http.Header `json:"header,omitempty"`
+	Body   string      `json:"body,omitempty"`
+}
+
+type recordedResponse struct {
+	Status   int         `json:"status"`
+	Header   http.Header `json:"header,omitempty"`
+	Body     string      `json:"body,omitempty"`
+	Encoding string      `json:"encoding,omitempty"` // "base64" for binary bodies
+}
+
+// NewRecorder creates a Recorder for the cassette at path. Replay and
+// record_missing modes load the cassette; replay fails if it does not exist.
+func NewRecorder(path, mode string, opts ...RecorderOption) (*Recorder, error) {
+	r := &Recorder{
+		path:      path,
+		mode:      mode,
+		transport: http.DefaultTransport,
+		cassette:  cassette{Version: cassetteVersion},
+		used:      make(map[string]int),
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	switch mode {
+	case ModeRecord:
+		return r, nil
+	case ModeReplay, ModeRecordMissing:
+	default:
+		return nil, fmt.Errorf("allmtest: unknown recorder mode %q", mode)
+	}
+
+	data, err := os.ReadFile(path) // #nosec G304 -- cassette path is chosen by the test
+	if errors.Is(err, fs.ErrNotExist) && mode == ModeRecordMissing {
+		return r, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("allmtest: read cassette: %w", err)
+	}
+	if err := json.Unmarshal(data, &r.cassette); err != nil {
+		return nil, fmt.Errorf("allmtest: decode cassette %s: %w", path, err)
+	}
+	if r.cassette.Version != cassetteVersion {
+		return nil, fmt.Errorf("allmtest: unsupported cassette version %d", r.cassette.Version)
+	}
+	return r, nil
+}
+
+// Client returns an HTTP client using the recorder as its transport.
+func (r *Recorder) Client() *http.Client {
+	return &http.Client{Transport: r}
+}
+
+// RoundTrip implements http.RoundTripper.
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		_ = req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	secrets := requestSecrets(req.Header)
+	key := r.matchKey(req, scrub(string(body), secrets))
+
+	if r.mode != ModeRecord {
+		if resp, ok := r.replay(req, key); ok {
+			return resp, nil
+		}
+		if r.mode == ModeReplay {
+			return nil, fmt.Errorf("allmtest: no recorded interaction for %s %s in %s", req.Method, scrub(req.URL.String(), secrets), r.path)
+		}
+	}
+	return r.record(req, body, secrets)
+}
+
+// replay returns the next recorded response matching key.
+func (r *Recorder) replay(req *http.Request, key string) (*http.Response, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var matches []int
+	for i, in := range r.cassette.Interactions {
+		if r.interactionKey(in.Request) == key {
+			matches = append(matches, i)
+		}
+	}
+	if len(matches) == 0 {
+		return nil, false
+	}
+	n := r.used[key]
+	r.used[key]++
+	in := r.cassette.Interactions[matches[min(n, len(matches)-1)]]
+
+	body := []byte(in.Response.Body)
+	if in.Response.Encoding == "base64" {
+		decoded, err := base64.StdEncoding.DecodeString(in.Response.Body)
+		if err != nil {
+			return nil, false
+		}
+		body = decoded
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", in.Response.Status, http.StatusText(in.Response.Status)),
+		StatusCode:    in.Response.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        in.Response.Header.Clone(),
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, true
+}
+
+// record sends req with the real transport and appends the scrubbed interaction.
+func (r *Recorder) record(req *http.Request, body []byte, secrets []string) (*http.Response, error) {
+	out := req.Clone(req.Context())
+	out.Body = io.NopCloser(bytes.NewReader(body))
+	out.ContentLength = int64(len(body))
+	resp, err := r.transport.RoundTrip(out)
+	if err != nil {
+		return nil, err
+	}
+	respBody, err := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(respBody))
+
+	in := interaction{
+		Request: recordedRequest{
+			Method: req.Method,
+			URL:    scrub(req.URL.String(), secrets),
+			Header: scrubHeader(req.Header),
+			Body:   scrub(string(body), secrets),
+		},
+		Response: recordedResponse{
+			Status: resp.StatusCode,
+			Header: scrubHeader(resp.Header),
+		},
+	}
+	if utf8.Valid(respBody) {
+		in.Response.Body = scrub(string(respBody), secrets)
+	} else {
+		in.Response.Body = base64.StdEncoding.EncodeToString(respBody)
+		in.Response.Encoding = "base64"
+	}
+
+	r.mu.Lock()
+	r.cassette.Interactions = append(r.cassette.Interactions, in)
+	r.used[r.interactionKey(in.Request)]++
+	r.dirty = true
+	r.mu.Unlock()
+	return resp, nil
+}
+
+// Save writes the cassette if interactions were recorded. Call it when the
+// test ends, e.g. with t.Cleanup.
+func (r *Recorder) Save() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.dirty {
+		return nil
+	}
+	data, err := json.MarshalIndent(r.cassette, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(r.path), 0o750); err != nil {
+		return fmt.Errorf("allmtest: cassette directory: %w", err)
+	}
+	if err := os.WriteFile(r.path, append(data, '\n'), 0o600); err != nil {
+		return fmt.Errorf("allmtest: write cassette: %w", err)
+	}
+	r.dirty = false
+	return nil
+}
+
+// Len returns the number of interactions in the cassette.
+func (r *Recorder) Len() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.cassette.Interactions)
+}
+
+// matchKey returns the match key of a live request with a scrubbed body.
+func (r *Recorder) matchKey(req *http.Request, body string) string {
+	return r.key(req.Method, scrub(req.URL.String(), requestSecrets(req.Header)), req.Header.Get("Content-Type"), body)
+}
+
+// interactionKey returns the match key of a recorded request.
+func (r *Recorder) interactionKey(req recordedRequest) string {
+	return r.key(req.Method, req.URL, req.Header.Get("Content-Type"), req.Body)
+}
+
+// key normalizes a request into its match key.
+func (r *Recorder) key(method, rawURL, contentType, body string) string {
+	if u, err := url.Parse(rawURL); err == nil {
+		u.RawQuery = u.Query().Encode() // sorts parameters
+		rawURL = u.String()
+	}
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch {
+	case strings.HasPrefix(mediaType, "multipart/"):
+		body = "" // random boundaries
+	case json.Valid([]byte(body)):
+		var v any
+		_ = json.Unmarshal([]byte(body), &v)
+		if m, ok := v.(map[string]any); ok {
+			for _, field := range r.ignored {
+				delete(m, field)
+			}
+		}
+		normalized, _ := json.Marshal(v) // sorts object keys
+		body = string(normalized)
+	}
+	return method + " " + rawURL + "\n" + body
+}
+
+// requestSecrets returns the credentials sent in request headers.
+func requestSecrets(h http.Header) []string {
+	var secrets []string
+	for _, name := range sensitiveHeaders {
+		for _, v := range h.Values(name) {
+			v = strings.TrimSpace(strings.TrimPrefix(v, "Bearer "))
+			if len(v) >= 8 {
+				secrets = append(secrets, v)
+			}
+		}
+	}
+	return secrets
+}
+
+// scrub replaces secrets and strings that look like API keys in s.
+func scrub(s string, secrets []string) string {
+	for _, secret := range secrets {
+		s = strings.ReplaceAll(s, secret, redacted)
+	}
+	return keyCandidate.ReplaceAllStringFunc(s, func(candidate string) string {
+		if len(provider.DetectKeyInString(candidate)) > 0 {
+			return redacted
+		}
+		return candidate
+	})
+}
+
+// scrubHeader returns h without credentials.
+func scrubHeader(h http.Header) http.Header {
+	out := h.Clone()
+	for _, name := range sensitiveHeaders {
+		out.Del(name)
+	}
+	if len(out) == 0 {
+		return nil
+	}
+	return out
+}
@@ -0,0 +1,183 @@
+package allmtest
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/kusandriadi/allm-go"
+	"github.com/kusandriadi/allm-go/provider"
+)
+
+const testKey = "sk-ant-REDACTED"
+
+// fakeAPI is a transport serving canned API responses and counting requests.
+type fakeAPI struct {
+	mu    sync.Mutex
+	calls int
+}
+
+func (f *fakeAPI) RoundTrip(req *http.Request) (*http.Response, error) {
+	f.mu.Lock()
+	f.calls++
+	f.mu.Unlock()
+
+	body, _ := io.ReadAll(req.Body)
+	contentType, payload := "application/json", `{"id":"msg_1","type":"message","role":"assistant","model":"claude-test",`+
+		`"content":[{"type":"text","text":"Hello!"}],"stop_reason":"end_turn","usage":{"input_tokens":3,"output_tokens":2}}`
+	if strings.Contains(string(body), `"stream":true`) {
+		contentType, payload = "text/event-stream",
+			"data: {\"id\":\"c1\",\"object\":\"chat.completion.chunk\",\"model\":\"local\",\"choices\":[{\"index\":0,\"delta\":{\"content\":\"Hel\"}}]}\n\n"+
+				"data: {\"id\":\"c1\",\"object\":\"chat.completion.chunk\",\"model\":\"local\",\"choices\":[{\"index\":0,\"delta\":{\"content\":\"lo\"},\"finish_reason\":\"stop\"}]}\n\n"+
+				"data: [DONE]\n\n"
+	}
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": {contentType}},
+		Body:       io.NopCloser(strings.NewReader(payload)),
+		Request:    req,
+	}, nil
+}
+
+func (f *fakeAPI) count() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.calls
+}
+
+func chatWith(t *testing.T, rec *Recorder) string {
+	t.Helper()
+	client := allm.New(provider.Anthropic(testKey, provider.WithAnthropicHTTPClient(rec.Client())), allm.WithMaxTokens(100))
+	resp, err := client.Complete(context.Background(), "Hi")
+	if err != nil {
+		t.Fatalf("Complete: %v", err)
+	}
+	return resp.Content
+}
+
+func TestRecorderRecordAndReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cassettes", "chat.json")
+	api := &fakeAPI{}
+
+	rec, err := NewRecorder(path, ModeRecord, WithTransport(api))
+	if err != nil {
+		t.Fatalf("NewRecorder: %v", err)
+	}
+	if got := chatWith(t, rec); got != "Hello!" {
+		t.Errorf("recorded content = %q", got)
+	}
+	if err := rec.Save(); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read cassette: %v", err)
+	}
+	if strings.Contains(string(data), testKey) {
+		t.Error("cassette contains the API key")
+	}
+
+	replay, err := NewRecorder(path, ModeReplay, WithTransport(api))
+	if err != nil {
+		t.Fatalf("NewRecorder: %v", err)
+	}
+	for range 2 {
+		if got := chatWith(t, replay); got != "Hello!" {
+			t.Errorf("replayed content = %q", got)
+		}
+	}
+	if api.count() != 1 {
+		t.Errorf("API calls = %d, want 1", api.count())
+	}
+}
+
+func TestRecorderReplayUnmatched(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "chat.json")
+	rec, _ := NewRecorder(path, ModeRecord, WithTransport(&fakeAPI{}))
+	chatWith(t, rec)
+	_ = rec.Save()
+
+	replay, err := NewRecorder(path, ModeReplay)
+	if err != nil {
+		t.Fatalf("NewRecorder: %v", err)
+	}
+	client := allm.New(provider.Anthropic(testKey, provider.WithAnthropicHTTPClient(replay.Client())), allm.WithMaxTokens(100))
+	_, err = client.Complete(context.Background(), "Something else")
+	if err == nil || !strings.Contains(err.Error(), "no recorded interaction") {
+		t.Errorf("expected no recorded interaction error, got %v", err)
+	}
+
+	if _, err := NewRecorder(filepath.Join(t.TempDir(), "missing.json"), ModeReplay); err == nil {
+		t.Error("expected error for a missing cassette in replay mode")
+	}
+	if _, err := NewRecorder(path, "rewind"); err == nil {
+		t.Error("expected error for an unknown mode")
+	}
+}
+
+func TestRecorderRecordMissing(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "chat.json")
+	api := &fakeAPI{}
+
+	rec, err := NewRecorder(path, ModeRecordMissing, WithTransport(api))
+	if err != nil {
+		t.Fatalf("NewRecorder: %v", err)
+	}
+	chatWith(t, rec)
+	chatWith(t, rec)
+	if api.count() != 1 || rec.Len() != 1 {
+		t.Errorf("API calls = %d, interactions = %d; want 1 and 1", api.count(), rec.Len())
+	}
+}
+
+func TestRecorderStream(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "stream.json")
+	api := &fakeAPI{}
+	stream := func(rec *Recorder) string {
+		client := allm.New(provider.Local("http://localhost:11434/v1", provider.WithHTTPClient(rec.Client())))
+		var out strings.Builder
+		for chunk := range client.Stream(context.Background(), []allm.Message{{Role: allm.RoleUser, Content: "Hi"}}) {
+			if chunk.Error != nil {
+				t.Fatalf("stream error: %v", chunk.Error)
+			}
+			out.WriteString(chunk.Content)
+		}
+		return out.String()
+	}
+
+	rec, _ := NewRecorder(path, ModeRecord, WithTransport(api))
+	if got := stream(rec); got != "Hello" {
+		t.Errorf("recorded stream = %q", got)
+	}
+	_ = rec.Save()
+
+	replay, err := NewRecorder(path, ModeReplay)
+	if err != nil {
+		t.Fatalf("NewRecorder: %v", err)
+	}
+	if got := stream(replay); got != "Hello" {
+		t.Errorf("replayed stream = %q", got)
+	}
+}
+
+func TestRecorderMatchNormalized(t *testing.T) {
+	r := &Recorder{ignored: []string{"user"}}
+	a := r.key("POST", "https://api.test/v1?b=2&a=1", "application/json", `{"model":"m","user":"x","n":1}`)
+	b := r.key("POST", "https://api.test/v1?a=1&b=2", "application/json", `{"n":1, "model":"m","user":"y"}`)
+	if a != b {
+		t.Errorf("keys differ:\n%s\n%s", a, b)
+	}
+}
+
+func TestScrub(t *testing.T) {
+	s := scrub(`{"key":"`+testKey+`","token":"secret-token-value","text":"hello"}`, []string{"secret-token-value"})
+	if strings.Contains(s, testKey) || strings.Contains(s, "secret-token-value") || !strings.Contains(s, "hello") {
+		t.Errorf("scrub = %s", s)
+	}
+}
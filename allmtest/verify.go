@@ -4,6 +4,10 @@
 //
 // Verify lets users test their provider setup against a real API.
 // It checks chat, streaming, vision, embeddings, tool use, and model listing.
+// Opt-in checks cover structured output, thinking, stop sequences, documents,
+// parallel and streamed tool calls, prompt caching, token counting and
+// cancellation. Results form a capability matrix that can be written to a
+// JSON or markdown file and committed per provider.
 //
 //	func TestMyProvider(t *testing.T) {
 //	    client := allm.New(provider.Anthropic(os.Getenv("ANTHROPIC_API_KEY")))
@@ -15,10 +19,17 @@
 //	    allmtest.SkipVision(),
 //	    allmtest.SkipEmbeddings(),
 //	)
+//
+//	// Or run every opt-in check and save the matrix:
+//	allmtest.Verify(t, client,
+//	    allmtest.WithChecks(allmtest.AllChecks()...),
+//	    allmtest.WithVerifyReport("testdata/anthropic.md"),
+//	)
 package allmtest
 
 import (
 	"context"
+	"fmt"
 	"strings"
 	"testing"
 	"time"
@@ -35,6 +46,8 @@ type verifyConfig struct {
 	skipToolUse    bool
 	skipStreaming  bool
 	skipModels     bool
+	checks         map[VerifyCheck]bool
+	reportPath     string
 	timeout        time.Duration
 }
 
@@ -68,8 +81,28 @@ func WithVerifyTimeout(d time.Duration) VerifyOption {
 	return func(c *verifyConfig) { c.timeout = d }
 }
 
-// Verify runs feature tests against a real provider.
-// Use in integration tests to validate your provider setup works correctly.
+// WithChecks enables opt-in checks. They call the API more often, and some
+// (PromptCaching) send long prompts, so they are off by default.
+func WithChecks(checks ...VerifyCheck) VerifyOption {
+	return func(c *verifyConfig) {
+		if c.checks == nil {
+			c.checks = make(map[VerifyCheck]bool)
+		}
+		for _, check := range checks {
+			c.checks[check] = true
+		}
+	}
+}
+
+// WithVerifyReport writes the capability matrix to path when Verify finishes,
+// as markdown if path ends in .md and as JSON otherwise.
+func WithVerifyReport(path string) VerifyOption {
+	return func(c *verifyConfig) { c.reportPath = path }
+}
+
+// Verify runs feature tests against a real provider and returns the resulting
+// capability matrix. Use in integration tests to validate your provider setup
+// works correctly.
 //
 //	func TestAnthropic(t *testing.T) {
 //	    if os.Getenv("ANTHROPIC_API_KEY") == "" {
@@ -78,7 +111,7 @@ func WithVerifyTimeout(d time.Duration) VerifyOption {
 //	    client := allm.New(provider.Anthropic(""))
 //	    allmtest.Verify(t, client)
 //	}
-func Verify(t *testing.T, client *allm.Client, opts ...VerifyOption) {
+func Verify(t *testing.T, client *allm.Client, opts ...VerifyOption) *VerifyReport {
 	t.Helper()
 
 	cfg := &verifyConfig{
@@ -88,46 +121,88 @@ func Verify(t *testing.T, client *allm.Client, opts ...VerifyOption) {
 		opt(cfg)
 	}
 
-	t.Run("Chat", func(t *testing.T) {
-		verifyChat(t, client, cfg.timeout)
-	})
-
-	t.Run("MultiTurn", func(t *testing.T) {
-		verifyMultiTurn(t, client, cfg.timeout)
-	})
-
-	if !cfg.skipStreaming {
-		t.Run("Streaming", func(t *testing.T) {
-			verifyStreaming(t, client, cfg.timeout)
-		})
+	report := &VerifyReport{Model: client.Model()}
+	if p := client.Provider(); p != nil {
+		report.Provider = p.Name()
 	}
-
-	if !cfg.skipVision {
-		t.Run("Vision", func(t *testing.T) {
-			verifyVision(t, client, cfg.timeout)
-		})
+	run := func(name string, skip bool, fn verifyFunc) {
+		t.Helper()
+		if skip {
+			report.Results = append(report.Results, VerifyResult{Feature: name, Status: VerifySkip, Detail: "disabled"})
+			return
+		}
+		report.run(t, name, client, cfg.timeout, fn)
 	}
 
-	if !cfg.skipEmbeddings {
-		t.Run("Embeddings", func(t *testing.T) {
-			verifyEmbeddings(t, client, cfg.timeout)
-		})
+	run("Chat", false, verifyChat)
+	run("MultiTurn", false, verifyMultiTurn)
+	run("Streaming", cfg.skipStreaming, verifyStreaming)
+	run("Vision", cfg.skipVision, verifyVision)
+	run("Embeddings", cfg.skipEmbeddings, verifyEmbeddings)
+	run("ToolUse", cfg.skipToolUse, verifyToolUse)
+	run("Models", cfg.skipModels, verifyModels)
+	for _, check := range AllChecks() {
+		if cfg.checks[check] {
+			run(string(check), false, verifyChecks[check])
+		}
 	}
 
-	if !cfg.skipToolUse {
-		t.Run("ToolUse", func(t *testing.T) {
-			verifyToolUse(t, client, cfg.timeout)
-		})
+	if cfg.reportPath != "" {
+		if err := report.WriteFile(cfg.reportPath); err != nil {
+			t.Errorf("write verify report: %v", err)
+		}
 	}
+	return report
+}
+
+// verifyFunc runs one feature test and returns a short description of the result.
+type verifyFunc func(t *testing.T, client *allm.Client, timeout time.Duration) string
+
+// run runs fn as a subtest and records its outcome.
+func (r *VerifyReport) run(t *testing.T, name string, client *allm.Client, timeout time.Duration, fn verifyFunc) {
+	t.Helper()
+	result := VerifyResult{Feature: name, Status: VerifyFail}
+	t.Run(name, func(t *testing.T) {
+		defer func() {
+			switch {
+			case t.Skipped():
+				result.Status = VerifySkip
+			case !t.Failed():
+				result.Status = VerifyPass
+			}
+		}()
+		result.Detail = fn(t, client, timeout)
+		t.Logf("✓ %s: %s", name, result.Detail)
+	})
+	r.Results = append(r.Results, result)
+}
 
-	if !cfg.skipModels {
-		t.Run("Models", func(t *testing.T) {
-			verifyModels(t, client, cfg.timeout)
-		})
+// verifyClient returns a client for the same provider and model as client,
+// configured with opts.
+func verifyClient(client *allm.Client, timeout time.Duration, opts ...allm.Option) *allm.Client {
+	opts = append([]allm.Option{allm.WithModel(client.Model()), allm.WithTimeout(timeout)}, opts...)
+	return allm.New(client.Provider(), opts...)
+}
+
+// cityTool returns a tool that takes a single city argument.
+func cityTool(name, description string) allm.Tool {
+	return allm.Tool{
+		Name:        name,
+		Description: description,
+		Parameters: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"city": map[string]any{
+					"type":        "string",
+					"description": "City name",
+				},
+			},
+			"required": []any{"city"},
+		},
 	}
 }
 
-func verifyChat(t *testing.T, client *allm.Client, timeout time.Duration) {
+func verifyChat(t *testing.T, client *allm.Client, timeout time.Duration) string {
 	t.Helper()
 	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
@@ -148,10 +223,10 @@ func verifyChat(t *testing.T, client *allm.Client, timeout time.Duration) {
 	if resp.OutputTokens == 0 {
 		t.Log("warning: OutputTokens is 0")
 	}
-	t.Logf("✓ Chat: %q (in:%d out:%d latency:%v)", truncate(resp.Content, 50), resp.InputTokens, resp.OutputTokens, resp.Latency)
+	return fmt.Sprintf("%q (in:%d out:%d latency:%v)", truncate(resp.Content, 50), resp.InputTokens, resp.OutputTokens, resp.Latency)
 }
 
-func verifyMultiTurn(t *testing.T, client *allm.Client, timeout time.Duration) {
+func verifyMultiTurn(t *testing.T, client *allm.Client, timeout time.Duration) string {
 	t.Helper()
 	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
@@ -167,10 +242,10 @@ func verifyMultiTurn(t *testing.T, client *allm.Client, timeout time.Duration) {
 	if !strings.Contains(strings.ToLower(resp.Content), "alice") {
 		t.Logf("warning: response may not reference 'Alice': %q", truncate(resp.Content, 100))
 	}
-	t.Logf("✓ MultiTurn: %q", truncate(resp.Content, 50))
+	return fmt.Sprintf("%q", truncate(resp.Content, 50))
 }
 
-func verifyStreaming(t *testing.T, client *allm.Client, timeout time.Duration) {
+func verifyStreaming(t *testing.T, client *allm.Client, timeout time.Duration) string {
 	t.Helper()
 	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
@@ -194,10 +269,10 @@ func verifyStreaming(t *testing.T, client *allm.Client, timeout time.Duration) {
 	if content == "" {
 		t.Fatal("empty streaming content")
 	}
-	t.Logf("✓ Streaming: %d chunks, content: %q", chunkCount, truncate(content, 50))
+	return fmt.Sprintf("%d chunks, content: %q", chunkCount, truncate(content, 50))
 }
 
-func verifyVision(t *testing.T, client *allm.Client, timeout time.Duration) {
+func verifyVision(t *testing.T, client *allm.Client, timeout time.Duration) string {
 	t.Helper()
 	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
@@ -225,10 +300,10 @@ func verifyVision(t *testing.T, client *allm.Client, timeout time.Duration) {
 	if resp.Content == "" {
 		t.Fatal("empty vision response")
 	}
-	t.Logf("✓ Vision: %q", truncate(resp.Content, 50))
+	return fmt.Sprintf("%q", truncate(resp.Content, 50))
 }
 
-func verifyEmbeddings(t *testing.T, client *allm.Client, timeout time.Duration) {
+func verifyEmbeddings(t *testing.T, client *allm.Client, timeout time.Duration) string {
 	t.Helper()
 	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
@@ -243,31 +318,13 @@ func verifyEmbeddings(t *testing.T, client *allm.Client, timeout time.Duration)
 	if len(resp.Embeddings[0]) == 0 {
 		t.Fatal("empty embedding vector")
 	}
-	t.Logf("✓ Embeddings: %d dimensions", len(resp.Embeddings[0]))
+	return fmt.Sprintf("%d dimensions", len(resp.Embeddings[0]))
 }
 
-func verifyToolUse(t *testing.T, client *allm.Client, timeout time.Duration) {
+func verifyToolUse(t *testing.T, client *allm.Client, timeout time.Duration) string {
 	t.Helper()
 
-	// Create a client copy with tools
-	toolClient := allm.New(
-		client.Provider(),
-		allm.WithTools(allm.Tool{
-			Name:        "get_weather",
-			Description: "Get the current weather for a city",
-			Parameters: map[string]any{
-				"type": "object",
-				"properties": map[string]any{
-					"city": map[string]any{
-						"type":        "string",
-						"description": "City name",
-					},
-				},
-				"required": []any{"city"},
-			},
-		}),
-		allm.WithTimeout(timeout),
-	)
+	toolClient := verifyClient(client, timeout, allm.WithTools(cityTool("get_weather", "Get the current weather for a city")))
 
 	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
@@ -278,16 +335,16 @@ func verifyToolUse(t *testing.T, client *allm.Client, timeout time.Duration) {
 	}
 	if len(resp.ToolCalls) == 0 {
 		t.Log("warning: no tool calls returned (model may have answered directly)")
-		return
+		return "no tool calls"
 	}
 	tc := resp.ToolCalls[0]
 	if tc.Name != "get_weather" {
 		t.Fatalf("expected tool 'get_weather', got %q", tc.Name)
 	}
-	t.Logf("✓ ToolUse: %s(%s)", tc.Name, string(tc.Arguments))
+	return fmt.Sprintf("%s(%s)", tc.Name, string(tc.Arguments))
 }
 
-func verifyModels(t *testing.T, client *allm.Client, timeout time.Duration) {
+func verifyModels(t *testing.T, client *allm.Client, timeout time.Duration) string {
 	t.Helper()
 	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
@@ -299,7 +356,7 @@ func verifyModels(t *testing.T, client *allm.Client, timeout time.Duration) {
 	if len(models) == 0 {
 		t.Fatal("no models returned")
 	}
-	t.Logf("✓ Models: %d available", len(models))
+	return fmt.Sprintf("%d available", len(models))
 }
 
 func truncate(s string, n int) string {
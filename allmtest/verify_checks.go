@@ -0,0 +1,322 @@
+package allmtest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kusandriadi/allm-go"
+)
+
+// VerifyCheck names an opt-in Verify check, enabled with WithChecks.
+type VerifyCheck string
+
+// Opt-in checks.
+const (
+	CheckStructuredOutput VerifyCheck = "StructuredOutput" // JSON schema response round trip
+	CheckThinking         VerifyCheck = "Thinking"         // Reasoning trace or tokens with EffortLow
+	CheckStopSequences    VerifyCheck = "StopSequences"    // Request.Stop ends generation
+	CheckDocuments        VerifyCheck = "Documents"        // Model reads a PDF document
+	CheckParallelTools    VerifyCheck = "ParallelTools"    // Several tool calls in one response
+	CheckStreamingTools   VerifyCheck = "StreamingTools"   // Tool-use chunks in a stream
+	CheckPromptCaching    VerifyCheck = "PromptCaching"    // Second request reads a cached prefix
+	CheckTokenCounting    VerifyCheck = "TokenCounting"    // CountTokens within 20% of billed input tokens
+	CheckCancellation     VerifyCheck = "Cancellation"     // Stream closes promptly after cancel
+)
+
+// AllChecks returns every opt-in check, in the order Verify runs them.
+func AllChecks() []VerifyCheck {
+	return []VerifyCheck{
+		CheckStructuredOutput, CheckThinking, CheckStopSequences, CheckDocuments,
+		CheckParallelTools, CheckStreamingTools, CheckPromptCaching,
+		CheckTokenCounting, CheckCancellation,
+	}
+}
+
+var verifyChecks = map[VerifyCheck]verifyFunc{
+	CheckStructuredOutput: verifyStructuredOutput,
+	CheckThinking:         verifyThinking,
+	CheckStopSequences:    verifyStopSequences,
+	CheckDocuments:        verifyDocuments,
+	CheckParallelTools:    verifyParallelTools,
+	CheckStreamingTools:   verifyStreamingTools,
+	CheckPromptCaching:    verifyPromptCaching,
+	CheckTokenCounting:    verifyTokenCounting,
+	CheckCancellation:     verifyCancellation,
+}
+
+const (
+	// tokenCountTolerance is the largest relative error accepted between
+	// CountTokens and the input tokens the provider bills.
+	tokenCountTolerance = 0.2
+
+	// cancelLatencyLimit is the longest a stream may stay open after its
+	// context is canceled.
+	cancelLatencyLimit = 2 * time.Second
+
+	// documentSecret is the word hidden in the verification PDF.
+	documentSecret = "PINEAPPLE"
+)
+
+func verifyStructuredOutput(t *testing.T, client *allm.Client, timeout time.Duration) string {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	schemaClient := verifyClient(client, timeout, allm.WithResponseFormat(&allm.ResponseFormat{
+		Type: "json_schema",
+		Name: "person",
+		Schema: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"name": map[string]any{"type": "string"},
+				"age":  map[string]any{"type": "integer"},
+			},
+			"required":             []any{"name", "age"},
+			"additionalProperties": false,
+		},
+	}))
+	resp, err := schemaClient.Complete(ctx, "Return a person named Ada who is 36 years old.")
+	skipUnsupported(t, err)
+	if err != nil {
+		t.Fatalf("StructuredOutput failed: %v", err)
+	}
+	var person struct {
+		Name string `json:"name"`
+		Age  int    `json:"age"`
+	}
+	if err := json.Unmarshal([]byte(resp.Content), &person); err != nil {
+		t.Fatalf("response is not valid JSON: %v: %q", err, truncate(resp.Content, 100))
+	}
+	if person.Name == "" || person.Age == 0 {
+		t.Fatalf("response does not match the schema: %q", truncate(resp.Content, 100))
+	}
+	return fmt.Sprintf("%q", truncate(resp.Content, 50))
+}
+
+func verifyThinking(t *testing.T, client *allm.Client, timeout time.Duration) string {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	// Anthropic needs max tokens above the EffortLow budget of 1024.
+	thinkingClient := verifyClient(client, timeout, allm.WithEffort(allm.EffortLow), allm.WithMaxTokens(4096))
+	resp, err := thinkingClient.Complete(ctx, "A bat and a ball cost $1.10 in total. The bat costs $1.00 more than the ball. How much does the ball cost?")
+	skipUnsupported(t, err)
+	if err != nil {
+		t.Fatalf("Thinking failed: %v", err)
+	}
+	if resp.Thinking == "" && resp.ThinkingTokens == 0 {
+		t.Fatal("no thinking trace or thinking tokens returned")
+	}
+	return fmt.Sprintf("%d thinking tokens, %d trace characters", resp.ThinkingTokens, len(resp.Thinking))
+}
+
+func verifyStopSequences(t *testing.T, client *allm.Client, timeout time.Duration) string {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	// Stop sequences are a Request field with no client option, so this
+	// check calls the provider directly.
+	resp, err := client.Provider().Complete(ctx, &allm.Request{
+		Model:     client.Model(),
+		MaxTokens: 100,
+		Messages: []allm.Message{
+			{Role: allm.RoleUser, Content: "Count from 1 to 9, separated by commas. Reply with the numbers only."},
+		},
+		Stop: []string{"5"},
+	})
+	skipUnsupported(t, err)
+	if err != nil {
+		t.Fatalf("StopSequences failed: %v", err)
+	}
+	if strings.ContainsAny(resp.Content, "6789") {
+		t.Fatalf("generation continued past the stop sequence: %q", truncate(resp.Content, 100))
+	}
+	return fmt.Sprintf("%q (finish: %s)", truncate(resp.Content, 50), resp.FinishReason)
+}
+
+func verifyDocuments(t *testing.T, client *allm.Client, timeout time.Duration) string {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	resp, err := client.Chat(ctx, []allm.Message{
+		{
+			Role:      allm.RoleUser,
+			Content:   "What is the secret word in this document? Reply with just the word.",
+			Documents: []allm.Document{{MimeType: "application/pdf", Data: verifyPDF("The secret word is " + documentSecret + "."), Name: "secret.pdf"}},
+		},
+	})
+	skipUnsupported(t, err)
+	if err != nil {
+		t.Fatalf("Documents failed: %v", err)
+	}
+	if !strings.Contains(strings.ToUpper(resp.Content), documentSecret) {
+		t.Fatalf("response does not contain the secret word: %q", truncate(resp.Content, 100))
+	}
+	return fmt.Sprintf("%q", truncate(resp.Content, 50))
+}
+
+func verifyParallelTools(t *testing.T, client *allm.Client, timeout time.Duration) string {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	toolClient := verifyClient(client, timeout, allm.WithTools(
+		cityTool("get_weather", "Get the current weather for a city"),
+		cityTool("get_time", "Get the current local time for a city"),
+	))
+	resp, err := toolClient.Complete(ctx, "What is the weather in Tokyo and the local time in Paris? Call both tools at once.")
+	skipUnsupported(t, err)
+	if err != nil {
+		t.Fatalf("ParallelTools failed: %v", err)
+	}
+	if len(resp.ToolCalls) < 2 {
+		t.Fatalf("expected at least 2 tool calls, got %d", len(resp.ToolCalls))
+	}
+	names := make([]string, len(resp.ToolCalls))
+	for i, tc := range resp.ToolCalls {
+		names[i] = tc.Name
+	}
+	return fmt.Sprintf("%d calls: %s", len(names), strings.Join(names, ", "))
+}
+
+func verifyStreamingTools(t *testing.T, client *allm.Client, timeout time.Duration) string {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	toolClient := verifyClient(client, timeout, allm.WithTools(cityTool("get_weather", "Get the current weather for a city")))
+	var uses []allm.StreamToolUse
+	for chunk := range toolClient.Stream(ctx, []allm.Message{{Role: allm.RoleUser, Content: "What is the weather in Tokyo?"}}) {
+		if chunk.Error != nil {
+			skipUnsupported(t, chunk.Error)
+			t.Fatalf("Stream error: %v", chunk.Error)
+		}
+		if chunk.ToolUse != nil {
+			uses = append(uses, *chunk.ToolUse)
+		}
+	}
+	if len(uses) == 0 {
+		t.Fatal("no tool-use chunks in stream")
+	}
+	return fmt.Sprintf("%s(%s)", uses[0].Name, string(uses[0].Input))
+}
+
+func verifyPromptCaching(t *testing.T, client *allm.Client, timeout time.Duration) string {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 2*timeout)
+	defer cancel()
+
+	// About 4k tokens: above the minimum cacheable prefix of every model.
+	var system strings.Builder
+	system.WriteString("You are a warehouse assistant. Use the reference table below to answer questions.\n")
+	for i := 1; i <= 300; i++ {
+		fmt.Fprintf(&system, "Item %d is stored on shelf %d in aisle %d.\n", i, i*7%97, i%13)
+	}
+	cacheClient := verifyClient(client, timeout, allm.WithSystemPrompt(system.String()), allm.WithAutoCache(allm.AutoCachePolicy{}))
+
+	messages := []allm.Message{{Role: allm.RoleUser, Content: "Reply with exactly: OK"}}
+	if _, err := cacheClient.Chat(ctx, messages); err != nil {
+		t.Fatalf("PromptCaching warm-up failed: %v", err)
+	}
+	resp, err := cacheClient.Chat(ctx, messages)
+	if err != nil {
+		t.Fatalf("PromptCaching failed: %v", err)
+	}
+	if resp.CacheReadTokens == 0 {
+		t.Fatalf("no cache read tokens on repeated prefix (in:%d cache write:%d)", resp.InputTokens, resp.CacheWriteTokens)
+	}
+	return fmt.Sprintf("%d of %d input tokens read from cache", resp.CacheReadTokens, resp.InputTokens)
+}
+
+func verifyTokenCounting(t *testing.T, client *allm.Client, timeout time.Duration) string {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	messages := []allm.Message{
+		{Role: allm.RoleUser, Content: "Summarize in one sentence: The quick brown fox jumps over the lazy dog while the farmer watches from the porch, sipping coffee."},
+	}
+	count, err := client.CountTokens(ctx, messages)
+	skipUnsupported(t, err)
+	if err != nil {
+		t.Fatalf("CountTokens failed: %v", err)
+	}
+	resp, err := client.Chat(ctx, messages)
+	if err != nil {
+		t.Fatalf("Chat failed: %v", err)
+	}
+	if resp.InputTokens == 0 {
+		t.Fatal("provider reported no input tokens")
+	}
+	diff := float64(count.InputTokens-resp.InputTokens) / float64(resp.InputTokens)
+	if diff < -tokenCountTolerance || diff > tokenCountTolerance {
+		t.Fatalf("counted %d tokens, provider billed %d (%+.0f%%)", count.InputTokens, resp.InputTokens, diff*100)
+	}
+	return fmt.Sprintf("counted %d, billed %d (%+.0f%%)", count.InputTokens, resp.InputTokens, diff*100)
+}
+
+func verifyCancellation(t *testing.T, client *allm.Client, timeout time.Duration) string {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	streamCtx, stop := context.WithCancel(ctx)
+	defer stop()
+
+	chunks := client.Stream(streamCtx, []allm.Message{
+		{Role: allm.RoleUser, Content: "Write a 500 word story about a lighthouse keeper."},
+	})
+	var canceled time.Time
+	for chunk := range chunks {
+		if !canceled.IsZero() {
+			continue
+		}
+		if chunk.Error != nil {
+			t.Fatalf("Stream error before cancel: %v", chunk.Error)
+		}
+		stop()
+		canceled = time.Now()
+	}
+	if canceled.IsZero() {
+		t.Fatal("stream closed without chunks")
+	}
+	latency := time.Since(canceled)
+	if latency > cancelLatencyLimit {
+		t.Fatalf("stream closed %v after cancel, limit %v", latency, cancelLatencyLimit)
+	}
+	return fmt.Sprintf("stream closed %v after cancel", latency.Round(time.Millisecond))
+}
+
+// verifyPDF returns a one-page PDF showing text.
+func verifyPDF(text string) []byte {
+	content := fmt.Sprintf("BT /F1 24 Tf 72 720 Td (%s) Tj ET", text)
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 612 792] /Contents 4 0 R /Resources << /Font << /F1 5 0 R >> >> >>",
+		fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", len(content), content),
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>",
+	}
+
+	var b bytes.Buffer
+	b.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, obj := range objects {
+		offsets[i] = b.Len()
+		fmt.Fprintf(&b, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+	xref := b.Len()
+	fmt.Fprintf(&b, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&b, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&b, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+	return b.Bytes()
+}
@@ -0,0 +1,82 @@
+package allmtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// VerifyStatus is the outcome of one Verify feature test.
+type VerifyStatus string
+
+// Verify outcomes.
+const (
+	VerifyPass VerifyStatus = "pass"
+	VerifyFail VerifyStatus = "fail"
+	VerifySkip VerifyStatus = "skip" // disabled by an option or not supported by the provider
+)
+
+// VerifyResult is one row of the capability matrix.
+type VerifyResult struct {
+	Feature string       `json:"feature"`
+	Status  VerifyStatus `json:"status"`
+	Detail  string       `json:"detail,omitempty"` // What the provider returned, for passing tests
+}
+
+// VerifyReport is the capability matrix produced by Verify.
+type VerifyReport struct {
+	Provider string         `json:"provider"`
+	Model    string         `json:"model,omitempty"`
+	Results  []VerifyResult `json:"results"`
+}
+
+// Status returns the outcome of feature, or "" if it was not tested.
+func (r *VerifyReport) Status(feature string) VerifyStatus {
+	for _, res := range r.Results {
+		if res.Feature == feature {
+			return res.Status
+		}
+	}
+	return ""
+}
+
+// JSON returns the report as indented JSON.
+func (r *VerifyReport) JSON() ([]byte, error) {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+// Markdown returns the report as a markdown table.
+func (r *VerifyReport) Markdown() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n\n", r.Provider)
+	if r.Model != "" {
+		fmt.Fprintf(&b, "Model: `%s`\n\n", r.Model)
+	}
+	b.WriteString("| Feature | Status | Detail |\n|---|:-:|---|\n")
+	for _, res := range r.Results {
+		detail := strings.ReplaceAll(res.Detail, "|", `\|`)
+		fmt.Fprintf(&b, "| %s | %s | %s |\n", res.Feature, res.Status, detail)
+	}
+	return b.String()
+}
+
+// WriteFile writes the report to path, as markdown if path ends in .md and
+// as JSON otherwise.
+func (r *VerifyReport) WriteFile(path string) error {
+	var data []byte
+	if ext := strings.ToLower(filepath.Ext(path)); ext == ".md" || ext == ".markdown" {
+		data = []byte(r.Markdown())
+	} else {
+		var err error
+		if data, err = r.JSON(); err != nil {
+			return err
+		}
+	}
+	return os.WriteFile(path, data, 0o600)
+}
@@ -1,6 +1,11 @@
 package allmtest_test
 
 import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/kusandriadi/allm-go"
@@ -63,3 +68,133 @@ func TestTruncate(t *testing.T) {
 		allmtest.SkipStreaming(),
 	)
 }
+
+// capableProvider answers every opt-in Verify check the way a fully capable
+// provider would.
+func capableProvider() *allmtest.MockProvider {
+	return allmtest.NewMockProvider("capable",
+		allmtest.WithTokenCount(&allm.TokenCount{InputTokens: 40}),
+		allmtest.WithHandler(func(req *allm.Request) (*allm.Response, error) {
+			resp := &allm.Response{Content: "hello", Provider: "capable", InputTokens: 42, OutputTokens: 5}
+			last := req.Messages[len(req.Messages)-1]
+			switch {
+			case req.ResponseFormat != nil:
+				resp.Content = `{"name":"Ada","age":36}`
+			case req.Effort != "":
+				resp.Content, resp.Thinking, resp.ThinkingTokens = "5 cents", "The ball costs x...", 12
+			case len(req.Stop) > 0:
+				resp.Content, resp.FinishReason = "1, 2, 3, 4, ", "stop_sequence"
+			case len(last.Documents) > 0:
+				text, err := allm.ExtractDocumentText(last.Documents[0])
+				if err != nil {
+					return nil, err
+				}
+				resp.Content = text
+			case len(req.Tools) > 0:
+				resp.Content = ""
+				for _, tool := range req.Tools {
+					resp.ToolCalls = append(resp.ToolCalls, allm.ToolCall{ID: tool.Name, Name: tool.Name, Arguments: json.RawMessage(`{"city":"Tokyo"}`)})
+				}
+			case req.Messages[0].Role == allm.RoleSystem && len(req.Messages[0].Content) > 1000:
+				resp.InputTokens, resp.CacheReadTokens = 4100, 4000
+			}
+			return resp, nil
+		}),
+	)
+}
+
+func TestVerifyChecks(t *testing.T) {
+	client := allm.New(capableProvider(), allm.WithModel("capable-1"))
+	report := allmtest.Verify(t, client,
+		allmtest.SkipVision(),
+		allmtest.SkipEmbeddings(),
+		allmtest.SkipModels(),
+		allmtest.WithChecks(allmtest.AllChecks()...),
+	)
+
+	if len(report.Results) != 7+len(allmtest.AllChecks()) {
+		t.Fatalf("results = %+v", report.Results)
+	}
+	for _, check := range allmtest.AllChecks() {
+		if got := report.Status(string(check)); got != allmtest.VerifyPass {
+			t.Errorf("%s = %q, want pass", check, got)
+		}
+	}
+	if got := report.Status("Vision"); got != allmtest.VerifySkip {
+		t.Errorf("Vision = %q, want skip", got)
+	}
+	if report.Provider != "capable" || report.Model != "capable-1" {
+		t.Errorf("report = %s/%s", report.Provider, report.Model)
+	}
+}
+
+func TestVerifyChecksOptIn(t *testing.T) {
+	mock := allmtest.NewMockProvider("basic", allmtest.WithHandler(func(req *allm.Request) (*allm.Response, error) {
+		if len(req.Stop) > 0 {
+			return nil, fmt.Errorf("%w: stop sequences", allm.ErrNotSupported)
+		}
+		return &allm.Response{Content: "hello", Provider: "basic"}, nil
+	}))
+	report := allmtest.Verify(t, allm.New(mock),
+		allmtest.SkipVision(),
+		allmtest.SkipEmbeddings(),
+		allmtest.SkipToolUse(),
+		allmtest.SkipModels(),
+		allmtest.WithChecks(allmtest.CheckStopSequences, allmtest.CheckCancellation),
+	)
+	if got := report.Status(string(allmtest.CheckStopSequences)); got != allmtest.VerifySkip {
+		t.Errorf("StopSequences = %q, want skip", got)
+	}
+	if got := report.Status(string(allmtest.CheckCancellation)); got != allmtest.VerifyPass {
+		t.Errorf("Cancellation = %q, want pass", got)
+	}
+	if got := report.Status(string(allmtest.CheckThinking)); got != "" {
+		t.Errorf("Thinking = %q, want not run", got)
+	}
+}
+
+func TestVerifyReport(t *testing.T) {
+	dir := t.TempDir()
+	client := allm.New(capableProvider())
+	for _, name := range []string{"matrix.json", "matrix.md"} {
+		allmtest.Verify(t, client,
+			allmtest.SkipVision(),
+			allmtest.SkipEmbeddings(),
+			allmtest.SkipModels(),
+			allmtest.WithChecks(allmtest.CheckStructuredOutput, allmtest.CheckParallelTools),
+			allmtest.WithVerifyReport(filepath.Join(dir, name)),
+		)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "matrix.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var report allmtest.VerifyReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		t.Fatalf("decode report: %v\n%s", err, data)
+	}
+	if report.Provider != "capable" || report.Status("ParallelTools") != allmtest.VerifyPass {
+		t.Errorf("report = %+v", report)
+	}
+
+	md, err := os.ReadFile(filepath.Join(dir, "matrix.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"# capable", "| Feature | Status | Detail |", "| StructuredOutput | pass |", "| Models | skip | disabled |"} {
+		if !strings.Contains(string(md), want) {
+			t.Errorf("markdown missing %q:\n%s", want, md)
+		}
+	}
+}
+
+func TestVerifyReportMarkdownEscapes(t *testing.T) {
+	report := &allmtest.VerifyReport{
+		Provider: "p",
+		Results:  []allmtest.VerifyResult{{Feature: "Chat", Status: allmtest.VerifyFail, Detail: "a|b"}},
+	}
+	if md := report.Markdown(); !strings.Contains(md, `| Chat | fail | a\|b |`) {
+		t.Errorf("markdown = %s", md)
+	}
+}
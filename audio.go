@@ -0,0 +1,381 @@
+package allm
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// SpeechStreamer is an optional interface for streaming text-to-speech.
+// Supported by: OpenAI.
+type SpeechStreamer interface {
+	// SpeakStream converts text to speech, returning audio as it is produced.
+	// The caller must close the returned reader.
+	SpeakStream(ctx context.Context, req *SpeechRequest) (io.ReadCloser, error)
+}
+
+// SpeakStream converts text to speech and returns the audio as a stream,
+// so playback can start before synthesis finishes. The caller must close
+// the reader. Providers without streaming support fall back to Speak.
+// Returns an error if the provider does not support text-to-speech.
+func (c *Client) SpeakStream(ctx context.Context, req *SpeechRequest) (io.ReadCloser, error) {
+	s := c.snapshot()
+
+	if s.provider == nil {
+		return nil, ErrNoProvider
+	}
+
+	streamer, canStream := s.provider.(SpeechStreamer)
+	speaker, canSpeak := s.provider.(Speaker)
+	if !canStream && !canSpeak {
+		return nil, fmt.Errorf("%w: text-to-speech", ErrNotSupported)
+	}
+
+	if req.Input == "" {
+		return nil, ErrEmptyInput
+	}
+
+	if s.logger != nil {
+		s.logger.Debug("speak stream request",
+			"provider", s.provider.Name(),
+			"model", req.Model,
+			"voice", req.Voice,
+			"format", req.Format,
+		)
+	}
+
+	op := Operation{Name: OperationSpeakStream, Provider: s.provider.Name(), Model: req.Model}
+	return observe(ctx, s, op, true, func(ctx context.Context) (io.ReadCloser, error) {
+		// The timeout covers reading the stream, so it ends when the reader is closed.
+		ctx, cancel := context.WithTimeout(ctx, s.timeout)
+		if canStream {
+			audio, err := streamer.SpeakStream(ctx, req)
+			if err != nil {
+				err = classifyError(err, ctx)
+				cancel()
+				return nil, err
+			}
+			return &cancelReadCloser{ReadCloser: audio, cancel: cancel}, nil
+		}
+		defer cancel()
+		resp, err := speaker.Speak(ctx, req)
+		if err != nil {
+			return nil, classifyError(err, ctx)
+		}
+		return io.NopCloser(bytes.NewReader(resp.Audio)), nil
+	})
+}
+
+// cancelReadCloser cancels the context of a stream when it is closed.
+type cancelReadCloser struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+// Close closes the stream and cancels its context.
+func (r *cancelReadCloser) Close() error {
+	err := r.ReadCloser.Close()
+	r.cancel()
+	return err
+}
+
+// WithAudioChunkSize sets the largest audio upload for Transcribe
+// (default MaxAudioChunkSize). Larger WAV and MP3 input is split into chunks.
+func WithAudioChunkSize(n int) Option {
+	return func(c *Client) {
+		if n > 0 {
+			c.audioChunkSize = n
+		}
+	}
+}
+
+// maxChunkPromptLen is how much of the previous chunk's text is passed as the
+// prompt for the next chunk, to keep spelling and style consistent.
+const maxChunkPromptLen = 500
+
+// transcribeOnce sends a single transcription request bounded by the client
+// timeout.
+func (s clientState) transcribeOnce(ctx context.Context, t Transcriber, req *TranscribeRequest) (*TranscribeResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	resp, err := t.Transcribe(ctx, req)
+	if err != nil {
+		return nil, classifyError(err, ctx)
+	}
+	return resp, nil
+}
+
+// transcribeChunked splits audio into chunks, transcribes them in order and
+// merges the results.
+func (s clientState) transcribeChunked(ctx context.Context, t Transcriber, req *TranscribeRequest) (*TranscribeResponse, error) {
+	chunks, err := splitAudio(req.Audio, req.Format, s.audioChunkSize)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.logger != nil {
+		s.logger.Debug("transcribe chunked",
+			"provider", s.provider.Name(),
+			"chunks", len(chunks),
+		)
+	}
+
+	merged := &TranscribeResponse{}
+	var texts []string
+	offset := 0.0
+	for i, chunk := range chunks {
+		chunkReq := *req
+		chunkReq.Audio = chunk.data
+		if req.Prompt == "" && i > 0 {
+			chunkReq.Prompt = tail(merged.Text, maxChunkPromptLen)
+		}
+
+		resp, err := s.transcribeOnce(ctx, t, &chunkReq)
+		if err != nil {
+			return nil, fmt.Errorf("transcribe chunk %d/%d: %w", i+1, len(chunks), err)
+		}
+
+		if text := strings.TrimSpace(resp.Text); text != "" {
+			texts = append(texts, text)
+			merged.Text = strings.Join(texts, " ")
+		}
+		if merged.Language == "" {
+			merged.Language = resp.Language
+		}
+		for _, seg := range resp.Segments {
+			seg.ID = len(merged.Segments)
+			seg.Start += offset
+			seg.End += offset
+			merged.Segments = append(merged.Segments, seg)
+		}
+		for _, w := range resp.Words {
+			w.Start += offset
+			w.End += offset
+			merged.Words = append(merged.Words, w)
+		}
+		merged.Provider = resp.Provider
+		merged.Model = resp.Model
+		merged.Latency += resp.Latency
+
+		// Prefer the splitter's duration: it is exact for the chunk boundaries.
+		duration := chunk.duration
+		if duration == 0 {
+			duration = resp.Duration
+		}
+		offset += duration
+	}
+	if req.Verbose || req.WordTimestamps {
+		merged.Duration = offset
+	}
+	return merged, nil
+}
+
+// tail returns at most n bytes from the end of s, starting at a word boundary.
+func tail(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	s = s[len(s)-n:]
+	if i := strings.IndexByte(s, ' '); i >= 0 {
+		s = s[i+1:]
+	}
+	return s
+}
+
+// audioChunk is a self-contained piece of a larger recording.
+type audioChunk struct {
+	data     []byte
+	duration float64 // seconds (0 = unknown)
+}
+
+// splitAudio splits WAV or MP3 audio into chunks of at most maxBytes.
+func splitAudio(data []byte, format string, maxBytes int) ([]audioChunk, error) {
+	switch {
+	case strings.EqualFold(format, "wav") || (len(data) >= 12 && string(data[0:4]) == "RIFF" && string(data[8:12]) == "WAVE"):
+		return splitWAV(data, maxBytes)
+	case strings.EqualFold(format, "mp3") || bytes.HasPrefix(data, []byte("ID3")) || (len(data) > 1 && data[0] == 0xFF && data[1]&0xE0 == 0xE0):
+		return splitMP3(data, maxBytes)
+	}
+	return nil, fmt.Errorf("%w: audio exceeds %d bytes and only WAV and MP3 can be split", ErrInputTooLong, maxBytes)
+}
+
+// splitWAV splits PCM WAV audio on sample boundaries, giving each chunk its own header.
+func splitWAV(data []byte, maxBytes int) ([]audioChunk, error) {
+	if len(data) < 12 || string(data[0:4]) != "RIFF" || string(data[8:12]) != "WAVE" {
+		return nil, fmt.Errorf("invalid WAV data")
+	}
+
+	var fmtChunk []byte
+	var pcm []byte
+	for pos := 12; pos+8 <= len(data); {
+		id := string(data[pos : pos+4])
+		size := int(binary.LittleEndian.Uint32(data[pos+4 : pos+8]))
+		body := data[pos+8:]
+		if size > len(body) {
+			size = len(body) // tolerate truncated or streamed WAVs
+		}
+		switch id {
+		case "fmt ":
+			fmtChunk = body[:size]
+		case "data":
+			pcm = body[:size]
+		}
+		pos += 8 + size + size%2
+	}
+	if len(fmtChunk) < 16 || pcm == nil {
+		return nil, fmt.Errorf("invalid WAV data: missing fmt or data chunk")
+	}
+
+	byteRate := int(binary.LittleEndian.Uint32(fmtChunk[8:12]))
+	blockAlign := int(binary.LittleEndian.Uint16(fmtChunk[12:14]))
+	if blockAlign <= 0 {
+		blockAlign = 1
+	}
+
+	headerSize := 12 + 8 + len(fmtChunk) + 8
+	per := (maxBytes - headerSize) / blockAlign * blockAlign
+	if per <= 0 {
+		return nil, fmt.Errorf("audio chunk size %d is too small for WAV", maxBytes)
+	}
+
+	var chunks []audioChunk
+	for start := 0; start < len(pcm); start += per {
+		end := min(start+per, len(pcm))
+		part := pcm[start:end]
+
+		var b bytes.Buffer
+		b.WriteString("RIFF")
+		_ = binary.Write(&b, binary.LittleEndian, uint32(4+8+len(fmtChunk)+8+len(part)))
+		b.WriteString("WAVEfmt ")
+		_ = binary.Write(&b, binary.LittleEndian, uint32(len(fmtChunk)))
+		b.Write(fmtChunk)
+		b.WriteString("data")
+		_ = binary.Write(&b, binary.LittleEndian, uint32(len(part)))
+		b.Write(part)
+
+		chunk := audioChunk{data: b.Bytes()}
+		if byteRate > 0 {
+			chunk.duration = float64(len(part)) / float64(byteRate)
+		}
+		chunks = append(chunks, chunk)
+	}
+	return chunks, nil
+}
+
+// MPEG audio Layer III tables.
+var (
+	mp3BitratesV1 = [16]int{0, 32, 40, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320, 0}
+	mp3BitratesV2 = [16]int{0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160, 0}
+	mp3Rates      = map[int][3]int{
+		3: {44100, 48000, 32000}, // MPEG-1
+		2: {22050, 24000, 16000}, // MPEG-2
+		0: {11025, 12000, 8000},  // MPEG-2.5
+	}
+)
+
+// mp3Frame parses a Layer III frame header, returning the frame length in
+// bytes and its duration in seconds. ok is false if b is not a frame header.
+func mp3Frame(b []byte) (length int, duration float64, ok bool) {
+	if len(b) < 4 || b[0] != 0xFF || b[1]&0xE0 != 0xE0 {
+		return 0, 0, false
+	}
+	version := int(b[1]>>3) & 0x03
+	layer := int(b[1]>>1) & 0x03
+	bitrateIdx := int(b[2] >> 4)
+	rateIdx := int(b[2]>>2) & 0x03
+	padding := int(b[2]>>1) & 0x01
+	if version == 1 || layer != 1 || rateIdx == 3 {
+		return 0, 0, false // reserved version, not Layer III, or reserved rate
+	}
+
+	rate := mp3Rates[version][rateIdx]
+	bitrate := mp3BitratesV1[bitrateIdx]
+	coef, samples := 144, 1152
+	if version != 3 {
+		bitrate = mp3BitratesV2[bitrateIdx]
+		coef, samples = 72, 576
+	}
+	if bitrate == 0 {
+		return 0, 0, false // free format or invalid
+	}
+	length = coef*bitrate*1000/rate + padding
+	return length, float64(samples) / float64(rate), true
+}
+
+// splitMP3 splits MP3 audio on frame boundaries. An ID3v2 tag is kept with
+// the first chunk.
+func splitMP3(data []byte, maxBytes int) ([]audioChunk, error) {
+	pos := 0
+	if len(data) >= 10 && string(data[0:3]) == "ID3" {
+		size := int(data[6]&0x7F)<<21 | int(data[7]&0x7F)<<14 | int(data[8]&0x7F)<<7 | int(data[9]&0x7F)
+		pos = min(10+size, len(data))
+	}
+
+	var chunks []audioChunk
+	start := 0
+	cur := audioChunk{}
+	for pos < len(data) {
+		length, dur, ok := mp3Frame(data[pos:])
+		if !ok {
+			pos++ // resync on garbage
+			continue
+		}
+		if length > maxBytes {
+			return nil, fmt.Errorf("audio chunk size %d is too small for MP3 frames", maxBytes)
+		}
+		end := min(pos+length, len(data))
+		if end-start > maxBytes {
+			cur.data = data[start:pos]
+			chunks = append(chunks, cur)
+			start, cur = pos, audioChunk{}
+		}
+		cur.duration += dur
+		pos = end
+	}
+	if start < len(data) {
+		cur.data = data[start:]
+		chunks = append(chunks, cur)
+	}
+	if len(chunks) == 0 || (len(chunks) == 1 && cur.duration == 0) {
+		return nil, fmt.Errorf("invalid MP3 data: no frames found")
+	}
+	return chunks, nil
+}
+
+// SRT returns the segments as SubRip subtitles.
+// Requires a verbose transcription; returns "" when there are no segments.
+func (r *TranscribeResponse) SRT() string {
+	var b strings.Builder
+	for i, seg := range r.Segments {
+		fmt.Fprintf(&b, "%d\n%s --> %s\n%s\n\n", i+1,
+			formatTimestamp(seg.Start, ","), formatTimestamp(seg.End, ","), strings.TrimSpace(seg.Text))
+	}
+	return b.String()
+}
+
+// VTT returns the segments as WebVTT subtitles.
+// Requires a verbose transcription; returns "" when there are no segments.
+func (r *TranscribeResponse) VTT() string {
+	if len(r.Segments) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString("WEBVTT\n\n")
+	for _, seg := range r.Segments {
+		fmt.Fprintf(&b, "%s --> %s\n%s\n\n",
+			formatTimestamp(seg.Start, "."), formatTimestamp(seg.End, "."), strings.TrimSpace(seg.Text))
+	}
+	return b.String()
+}
+
+// formatTimestamp formats seconds as HH:MM:SS<sep>mmm.
+func formatTimestamp(seconds float64, sep string) string {
+	if seconds < 0 {
+		seconds = 0
+	}
+	ms := int64(seconds*1000 + 0.5)
+	return fmt.Sprintf("%02d:%02d:%02d%s%03d", ms/3_600_000, ms/60_000%60, ms/1000%60, sep, ms%1000)
+}
@@ -0,0 +1,272 @@
+package allm
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// mockAudio implements Provider, Speaker and Transcriber. Each transcription
+// returns one segment spanning the chunk, reporting its byte size as text.
+type mockAudio struct {
+	mockProvider
+	audioMu sync.Mutex
+	chunks  [][]byte
+	prompts []string
+}
+
+func (m *mockAudio) Speak(_ context.Context, req *SpeechRequest) (*SpeechResponse, error) {
+	return &SpeechResponse{Audio: []byte("audio:" + req.Input)}, nil
+}
+
+func (m *mockAudio) Transcribe(_ context.Context, req *TranscribeRequest) (*TranscribeResponse, error) {
+	m.audioMu.Lock()
+	m.chunks = append(m.chunks, req.Audio)
+	m.prompts = append(m.prompts, req.Prompt)
+	n := len(m.chunks)
+	m.audioMu.Unlock()
+	text := strings.Repeat("w", n)
+	return &TranscribeResponse{
+		Text:     text,
+		Language: "english",
+		Duration: 99, // ignored when the splitter knows the duration
+		Segments: []TranscriptSegment{{ID: 0, Start: 0.5, End: 1.0, Text: text}},
+		Words:    []TranscriptWord{{Word: text, Start: 0.5, End: 1.0}},
+	}, nil
+}
+
+// mockAudioStreamer adds SpeechStreamer to mockAudio.
+type mockAudioStreamer struct {
+	mockAudio
+}
+
+func (m *mockAudioStreamer) SpeakStream(_ context.Context, req *SpeechRequest) (io.ReadCloser, error) {
+	return io.NopCloser(strings.NewReader("stream:" + req.Input)), nil
+}
+
+// buildWAV builds a 16-bit mono PCM WAV with the given sample rate and seconds.
+func buildWAV(rate, seconds int) []byte {
+	pcm := make([]byte, rate*2*seconds)
+	var b bytes.Buffer
+	b.WriteString("RIFF")
+	_ = binary.Write(&b, binary.LittleEndian, uint32(36+len(pcm)))
+	b.WriteString("WAVEfmt ")
+	_ = binary.Write(&b, binary.LittleEndian, uint32(16))
+	_ = binary.Write(&b, binary.LittleEndian, uint16(1))      // PCM
+	_ = binary.Write(&b, binary.LittleEndian, uint16(1))      // mono
+	_ = binary.Write(&b, binary.LittleEndian, uint32(rate))   // sample rate
+	_ = binary.Write(&b, binary.LittleEndian, uint32(rate*2)) // byte rate
+	_ = binary.Write(&b, binary.LittleEndian, uint16(2))      // block align
+	_ = binary.Write(&b, binary.LittleEndian, uint16(16))     // bits per sample
+	b.WriteString("data")
+	_ = binary.Write(&b, binary.LittleEndian, uint32(len(pcm)))
+	b.Write(pcm)
+	return b.Bytes()
+}
+
+// buildMP3 builds an MPEG-1 Layer III stream of 128kbps/44.1kHz frames (417 bytes each).
+func buildMP3(frames int) []byte {
+	frame := make([]byte, 417)
+	frame[0], frame[1], frame[2], frame[3] = 0xFF, 0xFB, 0x90, 0x00
+	var b bytes.Buffer
+	b.Write([]byte{'I', 'D', '3', 4, 0, 0, 0, 0, 0, 5, 1, 2, 3, 4, 5}) // 5-byte ID3v2 tag
+	for i := 0; i < frames; i++ {
+		b.Write(frame)
+	}
+	return b.Bytes()
+}
+
+func TestTranscribeChunksWAV(t *testing.T) {
+	p := &mockAudio{mockProvider: mockProvider{name: "test", available: true}}
+	// 1000 samples/s * 2 bytes = 2000 bytes/s; 10s of audio in ~4s chunks
+	c := New(p, WithAudioChunkSize(8044))
+
+	resp, err := c.Transcribe(context.Background(), &TranscribeRequest{Audio: buildWAV(1000, 10), Verbose: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(p.chunks) != 3 {
+		t.Fatalf("expected 3 chunks, got %d", len(p.chunks))
+	}
+	for i, chunk := range p.chunks {
+		if len(chunk) > 8044 || string(chunk[0:4]) != "RIFF" || string(chunk[36:40]) != "data" {
+			t.Errorf("chunk %d is not a valid WAV of at most 8044 bytes (%d bytes)", i, len(chunk))
+		}
+	}
+	if resp.Text != "w ww www" {
+		t.Errorf("unexpected merged text %q", resp.Text)
+	}
+	if resp.Duration != 10 {
+		t.Errorf("expected duration 10, got %v", resp.Duration)
+	}
+	if len(resp.Segments) != 3 || resp.Segments[1].Start != 4.5 || resp.Segments[2].ID != 2 || resp.Segments[2].End != 9.0 {
+		t.Errorf("unexpected segments: %+v", resp.Segments)
+	}
+	if resp.Words[2].Start != 8.5 {
+		t.Errorf("unexpected word offset: %+v", resp.Words[2])
+	}
+	if p.prompts[0] != "" || p.prompts[2] != "w ww" {
+		t.Errorf("expected previous text as prompt, got %q", p.prompts)
+	}
+}
+
+func TestTranscribeChunksMP3(t *testing.T) {
+	p := &mockAudio{mockProvider: mockProvider{name: "test", available: true}}
+	c := New(p, WithAudioChunkSize(417*40))
+
+	audio := buildMP3(100)
+	resp, err := c.Transcribe(context.Background(), &TranscribeRequest{Audio: audio, Format: "mp3"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(p.chunks) != 3 {
+		t.Fatalf("expected 3 chunks, got %d", len(p.chunks))
+	}
+	total := 0
+	for _, chunk := range p.chunks {
+		total += len(chunk)
+	}
+	if total != len(audio) {
+		t.Errorf("chunks cover %d bytes, want %d", total, len(audio))
+	}
+	if p.chunks[1][0] != 0xFF || p.chunks[2][0] != 0xFF {
+		t.Error("later chunks should start on a frame header")
+	}
+	// 40 frames * 1152 / 44100 ≈ 1.045s per full chunk
+	if got := resp.Segments[1].Start; got < 1.5 || got > 1.6 {
+		t.Errorf("unexpected segment offset %v", got)
+	}
+	if resp.Duration != 0 {
+		t.Error("duration is only reported for verbose transcriptions")
+	}
+}
+
+func TestTranscribeChunkUnsupportedFormat(t *testing.T) {
+	p := &mockAudio{mockProvider: mockProvider{name: "test", available: true}}
+	c := New(p, WithAudioChunkSize(10))
+	_, err := c.Transcribe(context.Background(), &TranscribeRequest{Audio: []byte("OggS-and-more-bytes"), Format: "ogg"})
+	if !errors.Is(err, ErrInputTooLong) {
+		t.Errorf("expected ErrInputTooLong, got %v", err)
+	}
+}
+
+func TestTranscribeSmallAudioNotChunked(t *testing.T) {
+	p := &mockAudio{mockProvider: mockProvider{name: "test", available: true}}
+	c := New(p)
+	resp, err := c.Transcribe(context.Background(), &TranscribeRequest{Audio: buildWAV(1000, 1)})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(p.chunks) != 1 || resp.Duration != 99 {
+		t.Errorf("expected a single pass-through call, got %d chunks, duration %v", len(p.chunks), resp.Duration)
+	}
+}
+
+// slowAudio delays each Speak and Transcribe call until delay has passed or
+// the context is done.
+type slowAudio struct {
+	mockAudio
+	delay time.Duration
+}
+
+func (m *slowAudio) wait(ctx context.Context) error {
+	select {
+	case <-time.After(m.delay):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (m *slowAudio) Speak(ctx context.Context, req *SpeechRequest) (*SpeechResponse, error) {
+	if err := m.wait(ctx); err != nil {
+		return nil, err
+	}
+	return m.mockAudio.Speak(ctx, req)
+}
+
+func (m *slowAudio) Transcribe(ctx context.Context, req *TranscribeRequest) (*TranscribeResponse, error) {
+	if err := m.wait(ctx); err != nil {
+		return nil, err
+	}
+	return m.mockAudio.Transcribe(ctx, req)
+}
+
+func TestAudioTimeout(t *testing.T) {
+	p := &slowAudio{mockAudio: mockAudio{mockProvider: mockProvider{name: "test", available: true}}, delay: time.Second}
+	c := New(p, WithTimeout(20*time.Millisecond))
+
+	if _, err := c.Speak(context.Background(), &SpeechRequest{Input: "hi"}); !errors.Is(err, ErrTimeout) {
+		t.Errorf("Speak: expected ErrTimeout, got %v", err)
+	}
+	if _, err := c.Transcribe(context.Background(), &TranscribeRequest{Audio: buildWAV(1000, 1)}); !errors.Is(err, ErrTimeout) {
+		t.Errorf("Transcribe: expected ErrTimeout, got %v", err)
+	}
+
+	// The timeout bounds each chunk, not the whole recording.
+	p.delay = 60 * time.Millisecond
+	c = New(p, WithTimeout(100*time.Millisecond), WithAudioChunkSize(8044))
+	if _, err := c.Transcribe(context.Background(), &TranscribeRequest{Audio: buildWAV(1000, 10)}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(p.chunks) != 3 {
+		t.Errorf("expected 3 chunks, got %d", len(p.chunks))
+	}
+}
+
+func TestSpeakStream(t *testing.T) {
+	p := &mockAudioStreamer{mockAudio: mockAudio{mockProvider: mockProvider{name: "test", available: true}}}
+	rc, err := New(p).SpeakStream(context.Background(), &SpeechRequest{Input: "hi"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	data, _ := io.ReadAll(rc)
+	_ = rc.Close()
+	if string(data) != "stream:hi" {
+		t.Errorf("unexpected audio %q", data)
+	}
+
+	// Speaker-only providers fall back to buffered audio
+	fallback := &mockAudio{mockProvider: mockProvider{name: "test", available: true}}
+	rc, err = New(fallback).SpeakStream(context.Background(), &SpeechRequest{Input: "hi"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	data, _ = io.ReadAll(rc)
+	if string(data) != "audio:hi" {
+		t.Errorf("unexpected fallback audio %q", data)
+	}
+
+	_, err = New(&mockProvider{name: "test"}).SpeakStream(context.Background(), &SpeechRequest{Input: "hi"})
+	if !errors.Is(err, ErrNotSupported) {
+		t.Errorf("expected ErrNotSupported, got %v", err)
+	}
+	_, err = New(p).SpeakStream(context.Background(), &SpeechRequest{})
+	if !errors.Is(err, ErrEmptyInput) {
+		t.Errorf("expected ErrEmptyInput, got %v", err)
+	}
+}
+
+func TestTranscriptSubtitles(t *testing.T) {
+	resp := &TranscribeResponse{Segments: []TranscriptSegment{
+		{Start: 0, End: 2.5, Text: " Hello there."},
+		{Start: 3661.0405, End: 3662, Text: "Bye."},
+	}}
+	wantSRT := "1\n00:00:00,000 --> 00:00:02,500\nHello there.\n\n2\n01:01:01,041 --> 01:01:02,000\nBye.\n\n"
+	if got := resp.SRT(); got != wantSRT {
+		t.Errorf("SRT:\n%q\nwant\n%q", got, wantSRT)
+	}
+	wantVTT := "WEBVTT\n\n00:00:00.000 --> 00:00:02.500\nHello there.\n\n01:01:01.041 --> 01:01:02.000\nBye.\n\n"
+	if got := resp.VTT(); got != wantVTT {
+		t.Errorf("VTT:\n%q\nwant\n%q", got, wantVTT)
+	}
+	if (&TranscribeResponse{Text: "x"}).VTT() != "" {
+		t.Error("expected empty VTT without segments")
+	}
+}
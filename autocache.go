@@ -0,0 +1,137 @@
+package allm
+
+import "slices"
+
+// maxCacheBreakpoints is the number of cache breakpoints Anthropic allows per request.
+const maxCacheBreakpoints = 4
+
+// defaultCacheMinTokens is the smallest prefix Anthropic caches for most models.
+const defaultCacheMinTokens = 1024
+
+// AutoCachePolicy configures WithAutoCache.
+type AutoCachePolicy struct {
+	TTL       string          // CacheTTL5m (default) or CacheTTL1h
+	MinTokens int             // Smallest prefix or document worth a breakpoint (default 1024; Haiku models need 2048)
+	Estimator *TokenEstimator // Sizes prefixes (nil = heuristic estimator)
+}
+
+// WithAutoCache places prompt caching breakpoints on Chat and Stream requests,
+// so long stable prefixes are cached without setting CacheControl by hand.
+// Up to four breakpoints are used, including any set by hand, in this order
+// of priority:
+//
+//   - the conversation prefix, on the last message, so the next turn reads it;
+//   - the system prompt;
+//   - the tool list;
+//   - documents of at least MinTokens in earlier messages.
+//
+// Prefixes (tools, then system, then messages, as the API orders them) shorter
+// than MinTokens get no breakpoint, as they would not be cached. Breakpoints
+// are honored by Anthropic and ignored by other providers. Cache effectiveness
+// is visible in UsageStats.CacheHitRate.
+func WithAutoCache(policy AutoCachePolicy) Option {
+	return func(c *Client) {
+		if policy.MinTokens <= 0 {
+			policy.MinTokens = defaultCacheMinTokens
+		}
+		if policy.Estimator == nil {
+			policy.Estimator = defaultEstimator()
+		}
+		c.autoCache = &policy
+	}
+}
+
+// applyAutoCache returns req with breakpoints placed by policy. Messages and
+// tools are copied before they are marked; req itself is not modified.
+func applyAutoCache(policy *AutoCachePolicy, req *Request) *Request {
+	slots := maxCacheBreakpoints - countBreakpoints(req)
+	if slots <= 0 {
+		return req
+	}
+	cc := &CacheControl{Type: CacheEphemeral, TTL: policy.TTL}
+	est := policy.Estimator
+	out := *req
+
+	// Prefix sizes in API order: tools, system, messages.
+	toolTokens := 0
+	if len(req.Tools) > 0 {
+		toolTokens = est.EstimateRequest(&Request{Tools: req.Tools}) - replyTokenOverhead
+	}
+	systemTokens, lastSystem, lastMessage := toolTokens, -1, -1
+	total := toolTokens
+	for i, m := range req.Messages {
+		tokens := est.EstimateMessage(m)
+		total += tokens
+		if m.Role == RoleSystem {
+			systemTokens += tokens
+			lastSystem = i
+		} else {
+			lastMessage = i
+		}
+	}
+
+	cloned := false
+	messages := func() []Message {
+		if !cloned {
+			out.Messages = slices.Clone(req.Messages)
+			cloned = true
+		}
+		return out.Messages
+	}
+	mark := func(i int) {
+		if slots > 0 && out.Messages[i].CacheControl == nil {
+			messages()[i].CacheControl = cc
+			slots--
+		}
+	}
+
+	if lastMessage >= 0 && total >= policy.MinTokens {
+		mark(lastMessage)
+	}
+	if lastSystem >= 0 && systemTokens >= policy.MinTokens {
+		mark(lastSystem)
+	}
+	if n := len(out.Tools); n > 0 && slots > 0 && toolTokens >= policy.MinTokens && out.Tools[n-1].CacheControl == nil {
+		out.Tools = slices.Clone(out.Tools)
+		out.Tools[n-1].CacheControl = cc
+		slots--
+	}
+	for i := 0; i < lastMessage && slots > 0; i++ {
+		for j, doc := range out.Messages[i].Documents {
+			if slots == 0 || doc.CacheControl != nil || est.estimateDocumentTokens(doc) < policy.MinTokens {
+				continue
+			}
+			docs := slices.Clone(out.Messages[i].Documents)
+			docs[j].CacheControl = cc
+			messages()[i].Documents = docs
+			slots--
+		}
+	}
+	return &out
+}
+
+// countBreakpoints returns the number of cache breakpoints set on req.
+func countBreakpoints(req *Request) int {
+	n := 0
+	for _, t := range req.Tools {
+		if t.CacheControl != nil {
+			n++
+		}
+	}
+	for _, m := range req.Messages {
+		if m.CacheControl != nil {
+			n++
+		}
+		for _, img := range m.Images {
+			if img.CacheControl != nil {
+				n++
+			}
+		}
+		for _, doc := range m.Documents {
+			if doc.CacheControl != nil {
+				n++
+			}
+		}
+	}
+	return n
+}
@@ -0,0 +1,86 @@
+package allm
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestAutoCacheBreakpoints(t *testing.T) {
+	long := strings.Repeat("policy text ", 600)
+	p := &mockProvider{name: "test", response: &Response{Content: "ok"}}
+	c := New(p, WithSystemPrompt(long), WithTools(Tool{Name: "a"}, Tool{Name: "b"}),
+		WithAutoCache(AutoCachePolicy{TTL: CacheTTL1h}))
+
+	msgs := []Message{
+		{Role: RoleUser, Content: "first"},
+		{Role: RoleAssistant, Content: "answer"},
+		{Role: RoleUser, Content: "second"},
+	}
+	if _, err := c.Chat(context.Background(), msgs); err != nil {
+		t.Fatalf("Chat: %v", err)
+	}
+	req := p.getLastReq()
+
+	if cc := req.Messages[0].CacheControl; cc == nil || cc.TTL != CacheTTL1h {
+		t.Errorf("system breakpoint = %+v", cc)
+	}
+	if req.Messages[3].CacheControl == nil {
+		t.Error("missing breakpoint on the last message")
+	}
+	if req.Messages[1].CacheControl != nil || req.Tools[1].CacheControl != nil {
+		t.Error("unexpected breakpoint on an earlier message or small tool list")
+	}
+	if msgs[2].CacheControl != nil {
+		t.Error("caller's messages were modified")
+	}
+}
+
+func TestAutoCacheSmallPrefix(t *testing.T) {
+	p := &mockProvider{name: "test", response: &Response{Content: "ok"}}
+	c := New(p, WithSystemPrompt("short"), WithAutoCache(AutoCachePolicy{}))
+
+	if _, err := c.Complete(context.Background(), "hi"); err != nil {
+		t.Fatalf("Complete: %v", err)
+	}
+	if n := countBreakpoints(p.getLastReq()); n != 0 {
+		t.Errorf("breakpoints = %d, want none below MinTokens", n)
+	}
+}
+
+func TestAutoCacheLimit(t *testing.T) {
+	doc := Document{MimeType: "text/plain", Data: []byte(strings.Repeat("clause ", 2000))}
+	manual := &CacheControl{Type: CacheEphemeral}
+	req := &Request{
+		Tools: []Tool{{Name: "t", Description: strings.Repeat("describe ", 1500)}},
+		Messages: []Message{
+			{Role: RoleSystem, Content: "system", CacheControl: manual},
+			{Role: RoleUser, Content: "doc 1", Documents: []Document{doc}},
+			{Role: RoleUser, Content: "doc 2", Documents: []Document{doc}},
+			{Role: RoleUser, Content: "question"},
+		},
+	}
+	out := applyAutoCache(&AutoCachePolicy{MinTokens: 1024, Estimator: NewTokenEstimator(nil)}, req)
+
+	if n := countBreakpoints(out); n != maxCacheBreakpoints {
+		t.Errorf("breakpoints = %d, want %d", n, maxCacheBreakpoints)
+	}
+	if out.Messages[3].CacheControl == nil || out.Tools[0].CacheControl == nil || out.Messages[1].Documents[0].CacheControl == nil {
+		t.Errorf("expected last message, tools and first document marked: %+v", out)
+	}
+	if out.Messages[2].Documents[0].CacheControl != nil {
+		t.Error("more than four breakpoints")
+	}
+	if countBreakpoints(req) != 1 {
+		t.Error("request was modified")
+	}
+}
+
+func TestCacheHitRate(t *testing.T) {
+	if rate := (UsageStats{}).CacheHitRate(); rate != 0 {
+		t.Errorf("empty rate = %v", rate)
+	}
+	if rate := (UsageStats{CacheReadTokens: 3000, CacheWriteTokens: 1000}).CacheHitRate(); rate != 0.75 {
+		t.Errorf("rate = %v, want 0.75", rate)
+	}
+}
@@ -0,0 +1,440 @@
+package allm
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxSemanticEntries caps the embeddings kept for semantic cache lookups.
+const maxSemanticEntries = 10000
+
+// CacheStore stores cached Chat responses by request key.
+// Implementations must be safe for concurrent use.
+type CacheStore interface {
+	// Get returns the response stored under key, and false if there is none
+	// or it expired.
+	Get(ctx context.Context, key string) (*Response, bool, error)
+
+	// Set stores resp under key.
+	Set(ctx context.Context, key string, resp *Response) error
+}
+
+// CacheOption configures WithCache.
+type CacheOption func(*responseCache)
+
+// WithCacheSemantic also serves cached responses for prompts whose embedding
+// has at least threshold cosine similarity (e.g. 0.95) with a cached prompt
+// sent with the same parameters. Prompts are embedded with the client's
+// provider and embedding model (see WithEmbeddingModel), so it needs an
+// Embedder. Embeddings are kept in memory, so only prompts cached by this
+// client are matched.
+func WithCacheSemantic(threshold float64) CacheOption {
+	return func(c *responseCache) {
+		c.threshold = threshold
+	}
+}
+
+// WithCache serves repeated deterministic Chat calls from store. A call is
+// deterministic if it has a seed (see WithSeed) or the client's temperature
+// was set to 0 explicitly (see WithTemperature). An unset temperature is the
+// provider's default, which samples, so those calls are not cached. The key
+// is a hash of the provider name and the request after middlewares (see
+// WithMiddleware). Hits are marked with Response.Cached, reported as
+// HookCacheHit and are not counted in Usage or budgets. Streams are not
+// cached. Store errors are logged and treated as misses.
+func WithCache(store CacheStore, opts ...CacheOption) Option {
+	return func(c *Client) {
+		if store == nil {
+			c.cache = nil
+			return
+		}
+		rc := &responseCache{store: store}
+		for _, opt := range opts {
+			opt(rc)
+		}
+		c.cache = rc
+	}
+}
+
+// responseCache is the cache state of a client.
+type responseCache struct {
+	store     CacheStore
+	threshold float64 // semantic match threshold (0 = exact matches only)
+
+	mu      sync.Mutex
+	entries []semanticEntry // oldest first
+}
+
+// semanticEntry is the embedding of a cached prompt.
+type semanticEntry struct {
+	params string // key of the request without the prompt
+	key    string // cache key of the request
+	vector []float64
+}
+
+// cacheMiddleware serves deterministic Complete calls from the client's cache.
+func cacheMiddleware(s clientState) Middleware {
+	return CompleteMiddleware(func(next CompleteFunc) CompleteFunc {
+		return func(ctx context.Context, req *Request) (*Response, error) {
+			rc := s.cache
+			if rc == nil || !s.deterministic(req) {
+				return next(ctx, req)
+			}
+			key, err := cacheKey(s.provider.Name(), req)
+			if err != nil {
+				return next(ctx, req)
+			}
+
+			if resp, ok := rc.get(ctx, s, key); ok {
+				return s.cacheHit(ctx, resp), nil
+			}
+			var params string
+			var vector []float64
+			if rc.threshold > 0 {
+				params, vector = rc.embed(ctx, s, req)
+				if resp, ok := rc.nearest(ctx, s, params, vector); ok {
+					return s.cacheHit(ctx, resp), nil
+				}
+			}
+
+			resp, err := next(ctx, req)
+			if err != nil || resp == nil {
+				return resp, err
+			}
+			if err := rc.store.Set(ctx, key, cloneResponse(resp)); err != nil {
+				if s.logger != nil {
+					s.logger.Debug("cache set failed", "error", sanitizeError(err))
+				}
+				return resp, nil
+			}
+			if vector != nil {
+				rc.add(semanticEntry{params: params, key: key, vector: vector})
+			}
+			return resp, nil
+		}
+	})
+}
+
+// deterministic reports whether req has a seed or its temperature was set to
+// 0 with WithTemperature.
+func (s clientState) deterministic(req *Request) bool {
+	return req.Seed != nil || (s.temperatureSet && req.Temperature == 0)
+}
+
+// cacheHit marks resp as served from the cache and reports it to the hooks.
+func (s clientState) cacheHit(ctx context.Context, resp *Response) *Response {
+	resp.Cached = true
+	resp.Cost = 0
+	s.emit(ctx, HookEvent{
+		Type:         HookCacheHit,
+		Model:        resp.Model,
+		RequestID:    resp.RequestID,
+		InputTokens:  resp.InputTokens,
+		OutputTokens: resp.OutputTokens,
+		FinishReason: resp.FinishReason,
+	})
+	return resp
+}
+
+// get returns a copy of the response cached under key.
+func (rc *responseCache) get(ctx context.Context, s clientState, key string) (*Response, bool) {
+	resp, ok, err := rc.store.Get(ctx, key)
+	if err != nil {
+		if s.logger != nil {
+			s.logger.Debug("cache get failed", "error", sanitizeError(err))
+		}
+		return nil, false
+	}
+	if !ok || resp == nil {
+		return nil, false
+	}
+	return cloneResponse(resp), true
+}
+
+// embed returns the key of req without its prompt and the embedding of the
+// prompt, or a nil vector if it cannot be embedded.
+func (rc *responseCache) embed(ctx context.Context, s clientState, req *Request) (string, []float64) {
+	embedder, ok := s.provider.(Embedder)
+	if !ok {
+		return "", nil
+	}
+	prompt, params, err := promptKey(s.provider.Name(), req)
+	if err != nil || prompt == "" {
+		return "", nil
+	}
+	// The lookup is optional, so it is skipped rather than failing the call.
+	if s.checkBudget() != nil {
+		return "", nil
+	}
+	resp, err := embedder.Embed(ctx, &EmbedRequest{Input: []string{prompt}, Model: s.embeddingModel})
+	if err == nil {
+		model := resp.Model
+		if model == "" {
+			model = s.embeddingModel
+		}
+		s.recordUsage(ctx, s.callUsage(model, resp.InputTokens, 0))
+	}
+	if err != nil || len(resp.Embeddings) == 0 {
+		if s.logger != nil && err != nil {
+			s.logger.Debug("cache embedding failed", "error", sanitizeError(err))
+		}
+		return "", nil
+	}
+	return params, resp.Embeddings[0]
+}
+
+// nearest returns the cached response of the most similar prompt sent with
+// the same parameters, if its similarity reaches the threshold.
+func (rc *responseCache) nearest(ctx context.Context, s clientState, params string, vector []float64) (*Response, bool) {
+	if vector == nil {
+		return nil, false
+	}
+	rc.mu.Lock()
+	var best string
+	bestScore := rc.threshold
+	for _, e := range rc.entries {
+		if e.params != params {
+			continue
+		}
+		if score := CosineSimilarity(vector, e.vector); score >= bestScore {
+			best, bestScore = e.key, score
+		}
+	}
+	rc.mu.Unlock()
+	if best == "" {
+		return nil, false
+	}
+	return rc.get(ctx, s, best)
+}
+
+// add records the embedding of a cached prompt, dropping the oldest beyond
+// maxSemanticEntries.
+func (rc *responseCache) add(e semanticEntry) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	rc.entries = append(rc.entries, e)
+	if len(rc.entries) > maxSemanticEntries {
+		rc.entries = slices.Delete(rc.entries, 0, len(rc.entries)-maxSemanticEntries)
+	}
+}
+
+// cacheKey returns the hex SHA-256 of the provider name and request.
+func cacheKey(provider string, req *Request) (string, error) {
+	data, err := json.Marshal(struct {
+		Provider string
+		Request  *Request
+	}{provider, req})
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// promptKey splits req into the text of its last user message and the cache
+// key of the request without that text.
+func promptKey(provider string, req *Request) (prompt, params string, err error) {
+	i := len(req.Messages) - 1
+	for i >= 0 && req.Messages[i].Role != RoleUser {
+		i--
+	}
+	if i < 0 {
+		return "", "", nil
+	}
+	stripped := *req
+	stripped.Messages = slices.Clone(req.Messages)
+	prompt = strings.TrimSpace(stripped.Messages[i].Content)
+	stripped.Messages[i].Content = ""
+	params, err = cacheKey(provider, &stripped)
+	return prompt, params, err
+}
+
+// cloneResponse returns a copy of resp that shares no slices with it.
+func cloneResponse(resp *Response) *Response {
+	out := *resp
+	out.Citations = slices.Clone(resp.Citations)
+	out.SearchResults = slices.Clone(resp.SearchResults)
+	out.ToolCalls = slices.Clone(resp.ToolCalls)
+	out.LogProbs = slices.Clone(resp.LogProbs)
+	out.ModerationFlags = slices.Clone(resp.ModerationFlags)
+	return &out
+}
+
+// MemoryCache is an in-memory CacheStore evicting the least recently used
+// entries beyond its size and entries older than its TTL.
+type MemoryCache struct {
+	mu         sync.Mutex
+	maxEntries int
+	ttl        time.Duration
+	order      *list.List // most recently used first
+	items      map[string]*list.Element
+}
+
+// memoryEntry is a MemoryCache item.
+type memoryEntry struct {
+	key     string
+	resp    *Response
+	expires time.Time // zero = never
+}
+
+// NewMemoryCache creates a MemoryCache holding at most maxEntries responses
+// (0 = unlimited) for at most ttl each (0 = no expiry).
+func NewMemoryCache(maxEntries int, ttl time.Duration) *MemoryCache {
+	return &MemoryCache{
+		maxEntries: maxEntries,
+		ttl:        ttl,
+		order:      list.New(),
+		items:      make(map[string]*list.Element),
+	}
+}
+
+// Get implements CacheStore.
+func (m *MemoryCache) Get(_ context.Context, key string) (*Response, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	el, ok := m.items[key]
+	if !ok {
+		return nil, false, nil
+	}
+	e := el.Value.(*memoryEntry)
+	if !e.expires.IsZero() && time.Now().After(e.expires) {
+		m.order.Remove(el)
+		delete(m.items, key)
+		return nil, false, nil
+	}
+	m.order.MoveToFront(el)
+	return cloneResponse(e.resp), true, nil
+}
+
+// Set implements CacheStore.
+func (m *MemoryCache) Set(_ context.Context, key string, resp *Response) error {
+	e := &memoryEntry{key: key, resp: cloneResponse(resp)}
+	if m.ttl > 0 {
+		e.expires = time.Now().Add(m.ttl)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if el, ok := m.items[key]; ok {
+		el.Value = e
+		m.order.MoveToFront(el)
+		return nil
+	}
+	m.items[key] = m.order.PushFront(e)
+	if m.maxEntries > 0 && m.order.Len() > m.maxEntries {
+		oldest := m.order.Back()
+		m.order.Remove(oldest)
+		delete(m.items, oldest.Value.(*memoryEntry).key)
+	}
+	return nil
+}
+
+// Len returns the number of cached responses, including expired ones not yet evicted.
+func (m *MemoryCache) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.order.Len()
+}
+
+// FileCache is a CacheStore keeping one JSON file per response in a
+// directory, so cached responses survive restarts and can be shared by
+// processes on the same machine.
+type FileCache struct {
+	dir string
+	ttl time.Duration
+}
+
+// fileEntry is the on-disk format of a FileCache entry.
+type fileEntry struct {
+	Expires  time.Time `json:"expires,omitzero"`
+	Response *Response `json:"response"`
+}
+
+// NewFileCache creates a FileCache in dir, creating the directory if needed.
+// Entries older than ttl (0 = no expiry) are ignored and removed on access.
+func NewFileCache(dir string, ttl time.Duration) (*FileCache, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("cache directory: %w", err)
+	}
+	return &FileCache{dir: dir, ttl: ttl}, nil
+}
+
+// Get implements CacheStore.
+func (f *FileCache) Get(_ context.Context, key string) (*Response, bool, error) {
+	path, err := f.path(key)
+	if err != nil {
+		return nil, false, err
+	}
+	data, err := os.ReadFile(path) // #nosec G304 -- path is a validated key inside the cache directory
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	var e fileEntry
+	if err := json.Unmarshal(data, &e); err != nil || e.Response == nil {
+		_ = os.Remove(path)
+		return nil, false, nil
+	}
+	if !e.Expires.IsZero() && time.Now().After(e.Expires) {
+		_ = os.Remove(path)
+		return nil, false, nil
+	}
+	return e.Response, true, nil
+}
+
+// Set implements CacheStore. Files are written atomically.
+func (f *FileCache) Set(_ context.Context, key string, resp *Response) error {
+	path, err := f.path(key)
+	if err != nil {
+		return err
+	}
+	e := fileEntry{Response: resp}
+	if f.ttl > 0 {
+		e.Expires = time.Now().Add(f.ttl)
+	}
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(f.dir, ".tmp-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	return nil
+}
+
+// path returns the file of key, rejecting keys that are not plain file names.
+func (f *FileCache) path(key string) (string, error) {
+	if key == "" || strings.ContainsAny(key, `/\`) || strings.HasPrefix(key, ".") {
+		return "", fmt.Errorf("invalid cache key %q", key)
+	}
+	return filepath.Join(f.dir, key+".json"), nil
+}
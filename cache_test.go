@@ -0,0 +1,176 @@
+package allm
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCacheHit(t *testing.T) {
+	var rec eventRecorder
+	p := &failNProvider{name: "test", response: &Response{Content: "ok", Model: "m", InputTokens: 10, OutputTokens: 2}}
+	c := New(p, WithTemperature(0), WithCache(NewMemoryCache(10, 0)), WithHook(rec.hook))
+
+	first, err := c.Complete(context.Background(), "hi")
+	if err != nil {
+		t.Fatalf("Complete: %v", err)
+	}
+	second, err := c.Complete(context.Background(), "hi")
+	if err != nil {
+		t.Fatalf("Complete: %v", err)
+	}
+
+	if p.calls != 1 {
+		t.Errorf("provider calls = %d, want 1", p.calls)
+	}
+	if first.Cached || !second.Cached || second.Content != "ok" {
+		t.Errorf("cached = %v, %v; content %q", first.Cached, second.Cached, second.Content)
+	}
+	if u := c.Usage(); u.Requests != 1 || u.InputTokens != 10 {
+		t.Errorf("usage = %+v, want only the first call", u)
+	}
+	if got := strings.Join(rec.types(), ","); got != "request,success,cache_hit" {
+		t.Errorf("events = %s", got)
+	}
+}
+
+func TestCacheSkipsNonDeterministic(t *testing.T) {
+	p := &failNProvider{name: "test", response: &Response{Content: "ok"}}
+	c := New(p, WithTemperature(0.7), WithCache(NewMemoryCache(10, 0)))
+
+	for range 2 {
+		if _, err := c.Complete(context.Background(), "hi"); err != nil {
+			t.Fatalf("Complete: %v", err)
+		}
+	}
+	if p.calls != 2 {
+		t.Errorf("provider calls = %d, want 2", p.calls)
+	}
+
+	c = New(p, WithTemperature(0.7), WithSeed(1), WithCache(NewMemoryCache(10, 0)))
+	for range 2 {
+		_, _ = c.Complete(context.Background(), "hi")
+	}
+	if p.calls != 3 {
+		t.Errorf("provider calls = %d, want seeded calls cached", p.calls)
+	}
+
+	// An unset temperature is the provider's default, which samples.
+	c = New(p, WithCache(NewMemoryCache(10, 0)))
+	for range 2 {
+		_, _ = c.Complete(context.Background(), "hi")
+	}
+	if p.calls != 5 {
+		t.Errorf("provider calls = %d, want calls without temperature not cached", p.calls)
+	}
+}
+
+func TestCacheKeyIncludesParameters(t *testing.T) {
+	p := &failNProvider{name: "test", response: &Response{Content: "ok"}}
+	store := NewMemoryCache(10, 0)
+	c := New(p, WithSeed(1), WithCache(store))
+
+	_, _ = c.Complete(context.Background(), "hi")
+	c.SetModel("other")
+	_, _ = c.Complete(context.Background(), "hi")
+	_, _ = c.Complete(context.Background(), "hello")
+
+	if p.calls != 3 || store.Len() != 3 {
+		t.Errorf("provider calls = %d, entries = %d; want 3 and 3", p.calls, store.Len())
+	}
+}
+
+func TestMemoryCacheLRU(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemoryCache(2, 0)
+	_ = m.Set(ctx, "a", &Response{Content: "a"})
+	_ = m.Set(ctx, "b", &Response{Content: "b"})
+	_, _, _ = m.Get(ctx, "a")
+	_ = m.Set(ctx, "c", &Response{Content: "c"})
+
+	if _, ok, _ := m.Get(ctx, "b"); ok {
+		t.Error("least recently used entry was not evicted")
+	}
+	if resp, ok, _ := m.Get(ctx, "a"); !ok || resp.Content != "a" {
+		t.Errorf("a = %v, %v", resp, ok)
+	}
+}
+
+func TestMemoryCacheTTL(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemoryCache(0, 10*time.Millisecond)
+	_ = m.Set(ctx, "a", &Response{Content: "a"})
+	if _, ok, _ := m.Get(ctx, "a"); !ok {
+		t.Fatal("fresh entry missing")
+	}
+	time.Sleep(20 * time.Millisecond)
+	if _, ok, _ := m.Get(ctx, "a"); ok || m.Len() != 0 {
+		t.Error("expired entry returned")
+	}
+}
+
+func TestFileCache(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	f, err := NewFileCache(dir, 0)
+	if err != nil {
+		t.Fatalf("NewFileCache: %v", err)
+	}
+	if err := f.Set(ctx, "abc", &Response{Content: "hi", InputTokens: 3}); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+
+	// A second store on the same directory sees the entry.
+	g, _ := NewFileCache(dir, 0)
+	resp, ok, err := g.Get(ctx, "abc")
+	if err != nil || !ok || resp.Content != "hi" || resp.InputTokens != 3 {
+		t.Errorf("Get = %+v, %v, %v", resp, ok, err)
+	}
+	if _, ok, _ := g.Get(ctx, "missing"); ok {
+		t.Error("missing key found")
+	}
+	if err := f.Set(ctx, "../escape", &Response{}); err == nil {
+		t.Error("expected error for a key outside the directory")
+	}
+
+	expiring, _ := NewFileCache(dir, time.Nanosecond)
+	_ = expiring.Set(ctx, "old", &Response{Content: "old"})
+	time.Sleep(time.Millisecond)
+	if _, ok, _ := expiring.Get(ctx, "old"); ok {
+		t.Error("expired entry returned")
+	}
+}
+
+func TestCacheSemantic(t *testing.T) {
+	p := &mockEmbedder{
+		mockProvider: mockProvider{name: "test", response: &Response{Content: "paris"}},
+		embedResp:    &EmbedResponse{Embeddings: [][]float64{{1, 0}}, InputTokens: 5},
+	}
+	c := New(p, WithTemperature(0), WithCache(NewMemoryCache(10, 0), WithCacheSemantic(0.9)))
+
+	if _, err := c.Complete(context.Background(), "capital of France?"); err != nil {
+		t.Fatalf("Complete: %v", err)
+	}
+	p.mu.Lock()
+	p.lastReq = nil
+	p.mu.Unlock()
+
+	resp, err := c.Complete(context.Background(), "What is the capital of France?")
+	if err != nil {
+		t.Fatalf("Complete: %v", err)
+	}
+	if !resp.Cached || resp.Content != "paris" || p.getLastReq() != nil {
+		t.Errorf("semantic match not served from cache: %+v", resp)
+	}
+	// One chat call and two prompt embeddings.
+	if u := c.Usage(); u.Requests != 3 || u.InputTokens != 10 {
+		t.Errorf("usage = %+v", u)
+	}
+
+	// Different parameters never match semantically.
+	c.SetSystemPrompt("Answer in French.")
+	if resp, _ := c.Complete(context.Background(), "capital of France?"); resp.Cached {
+		t.Error("semantic match across different parameters")
+	}
+}
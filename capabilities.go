@@ -0,0 +1,339 @@
+package allm
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Preflight policy constants for WithPreflight.
+const (
+	PreflightStrict = "strict" // reject requests using unsupported features
+	PreflightWarn   = "warn"   // log unsupported features and send the request as-is
+	PreflightAdapt  = "adapt"  // drop or emulate unsupported features, reject what cannot be adapted
+)
+
+// schemaToolDescription describes the tool that emulates a JSON schema response format.
+const schemaToolDescription = "Return the final answer as the arguments of this function."
+
+// Capabilities lists the Request features a provider honors. Features a
+// provider does not honor are ignored by it or fail when the request is sent.
+type Capabilities struct {
+	Vision            bool // Message.Images
+	Documents         bool // Message.Documents
+	Tools             bool // Request.Tools, tool calls and tool results
+	JSONMode          bool // ResponseFormat of type json_object
+	JSONSchema        bool // ResponseFormat of type json_schema
+	Thinking          bool // Request.Thinking budgets
+	Effort            bool // Request.Effort
+	StopSequences     bool // Request.Stop
+	WebSearch         bool // Request.WebSearch
+	ComputerUse       bool // Request.ComputerUse
+	LogProbs          bool // Request.LogProbs
+	Seed              bool // Request.Seed
+	Prediction        bool // Request.Prediction
+	ParallelToolCalls bool // Request.ParallelToolCalls
+}
+
+// CapabilityReporter is an optional interface for providers to report the
+// Request features they honor, used by WithPreflight.
+// Supported by: Anthropic (and GLM, Kimi, MiniMax), OpenAI, OpenAI-compatible, Claude CLI.
+type CapabilityReporter interface {
+	// Capabilities returns the features the provider honors.
+	Capabilities() Capabilities
+}
+
+// UnsupportedFeaturesError is returned by the preflight check when a request
+// uses features the provider or model cannot honor. It wraps ErrNotSupported.
+type UnsupportedFeaturesError struct {
+	Provider string   // Provider name
+	Model    string   // Requested model (empty = provider default)
+	Features []string // Unsupported features, e.g. "images", "json schema"
+}
+
+func (e *UnsupportedFeaturesError) Error() string {
+	target := e.Provider
+	if e.Model != "" {
+		target += "/" + e.Model
+	}
+	return fmt.Sprintf("%s: %s does not support %s", ErrNotSupported.Error(), target, strings.Join(e.Features, ", "))
+}
+
+func (e *UnsupportedFeaturesError) Unwrap() error {
+	return ErrNotSupported
+}
+
+// WithPreflight checks each Chat and Stream request against the provider's
+// Capabilities and the model catalog before it is sent. Policy is
+// PreflightStrict, PreflightWarn or PreflightAdapt (default: no check).
+func WithPreflight(policy string) Option {
+	return func(c *Client) {
+		c.preflight = policy
+	}
+}
+
+// Capabilities returns the features the client's provider and model honor.
+// Providers that do not implement CapabilityReporter are assumed to support
+// everything; models in the catalog narrow the result to their capabilities.
+func (c *Client) Capabilities() Capabilities {
+	s := c.snapshot()
+	return s.capabilities(s.model)
+}
+
+// allCapabilities returns Capabilities with every feature supported.
+func allCapabilities() Capabilities {
+	return Capabilities{
+		Vision: true, Documents: true, Tools: true, JSONMode: true, JSONSchema: true,
+		Thinking: true, Effort: true, StopSequences: true, WebSearch: true, ComputerUse: true,
+		LogProbs: true, Seed: true, Prediction: true, ParallelToolCalls: true,
+	}
+}
+
+// capabilities returns the features the provider and model honor.
+func (s clientState) capabilities(model string) Capabilities {
+	caps := allCapabilities()
+	if reporter, ok := s.provider.(CapabilityReporter); ok {
+		caps = reporter.Capabilities()
+	}
+	if m, ok := LookupModel(model); ok && len(m.Capabilities) > 0 {
+		caps.Vision = caps.Vision && m.Supports(CapabilityVision)
+		caps.Documents = caps.Documents && m.Supports(CapabilityPDF)
+		caps.Tools = caps.Tools && m.Supports(CapabilityTools)
+		caps.JSONSchema = caps.JSONSchema && m.Supports(CapabilityJSONSchema)
+		caps.Thinking = caps.Thinking && m.Supports(CapabilityThinking)
+		caps.Effort = caps.Effort && m.Supports(CapabilityThinking)
+	}
+	return caps
+}
+
+// requestFeature is a Request feature checked by the preflight.
+type requestFeature struct {
+	name      string
+	used      func(*Request) bool
+	supported func(Capabilities) bool
+	drop      func(*Request) // nil = cannot be dropped
+}
+
+// requestFeatures lists the features checked by the preflight. Documents and
+// response formats are adapted separately in adaptRequest.
+var requestFeatures = []requestFeature{
+	{"images", func(r *Request) bool { return anyMessage(r, func(m Message) bool { return len(m.Images) > 0 }) },
+		func(c Capabilities) bool { return c.Vision }, dropImages},
+	{"documents", func(r *Request) bool { return anyMessage(r, func(m Message) bool { return len(m.Documents) > 0 }) },
+		func(c Capabilities) bool { return c.Documents }, nil},
+	{"tools", func(r *Request) bool {
+		return len(r.Tools) > 0 || anyMessage(r, func(m Message) bool { return len(m.ToolCalls) > 0 || len(m.ToolResults) > 0 })
+	}, func(c Capabilities) bool { return c.Tools }, nil},
+	{"json mode", func(r *Request) bool { return r.ResponseFormat != nil && r.ResponseFormat.Type == ResponseFormatJSON },
+		func(c Capabilities) bool { return c.JSONMode }, nil},
+	{"json schema", func(r *Request) bool {
+		return r.ResponseFormat != nil && r.ResponseFormat.Type == ResponseFormatJSONSchema
+	},
+		func(c Capabilities) bool { return c.JSONSchema }, nil},
+	{"thinking", func(r *Request) bool { return r.Thinking != nil },
+		func(c Capabilities) bool { return c.Thinking }, func(r *Request) { r.Thinking = nil }},
+	{"effort", func(r *Request) bool { return r.Effort != "" },
+		func(c Capabilities) bool { return c.Effort }, func(r *Request) { r.Effort = "" }},
+	{"stop sequences", func(r *Request) bool { return len(r.Stop) > 0 },
+		func(c Capabilities) bool { return c.StopSequences }, func(r *Request) { r.Stop = nil }},
+	{"web search", func(r *Request) bool { return r.WebSearch != nil },
+		func(c Capabilities) bool { return c.WebSearch }, func(r *Request) { r.WebSearch = nil }},
+	{"computer use", func(r *Request) bool { return r.ComputerUse != nil },
+		func(c Capabilities) bool { return c.ComputerUse }, func(r *Request) { r.ComputerUse = nil }},
+	{"logprobs", func(r *Request) bool { return r.LogProbs },
+		func(c Capabilities) bool { return c.LogProbs }, func(r *Request) { r.LogProbs, r.TopLogProbs = false, 0 }},
+	{"seed", func(r *Request) bool { return r.Seed != nil },
+		func(c Capabilities) bool { return c.Seed }, func(r *Request) { r.Seed = nil }},
+	{"prediction", func(r *Request) bool { return r.Prediction != nil },
+		func(c Capabilities) bool { return c.Prediction }, func(r *Request) { r.Prediction = nil }},
+	{"parallel tool calls", func(r *Request) bool { return r.ParallelToolCalls != nil },
+		func(c Capabilities) bool { return c.ParallelToolCalls }, func(r *Request) { r.ParallelToolCalls = nil }},
+}
+
+// anyMessage reports whether any message of r matches fn.
+func anyMessage(r *Request, fn func(Message) bool) bool {
+	for _, m := range r.Messages {
+		if fn(m) {
+			return true
+		}
+	}
+	return false
+}
+
+// unsupportedFeatures returns the names of the features req uses that caps lacks.
+func unsupportedFeatures(req *Request, caps Capabilities) []string {
+	var names []string
+	for _, f := range requestFeatures {
+		if f.used(req) && !f.supported(caps) {
+			names = append(names, f.name)
+		}
+	}
+	return names
+}
+
+// preflight applies the WithPreflight policy to req. It returns the request
+// to send (a copy when adapted) and, when a JSON schema is emulated with a
+// tool, that tool's name. emulateSchema allows tool emulation (Chat only).
+func preflight(s clientState, req *Request, emulateSchema bool) (*Request, string, error) {
+	if s.preflight == "" {
+		return req, "", nil
+	}
+	caps := s.capabilities(req.Model)
+	features := unsupportedFeatures(req, caps)
+	if len(features) == 0 {
+		return req, "", nil
+	}
+	unsupported := &UnsupportedFeaturesError{Provider: s.provider.Name(), Model: req.Model, Features: features}
+
+	switch s.preflight {
+	case PreflightStrict:
+		return nil, "", unsupported
+	case PreflightWarn:
+		if s.logger != nil {
+			s.logger.Warn("request uses unsupported features", "provider", s.provider.Name(), "model", req.Model, "features", features)
+		}
+		return req, "", nil
+	case PreflightAdapt:
+		adapted, schemaTool, err := adaptRequest(req, caps, emulateSchema)
+		var unadaptable *UnsupportedFeaturesError
+		if errors.As(err, &unadaptable) {
+			unadaptable.Provider = s.provider.Name()
+		}
+		if err != nil {
+			return nil, "", err
+		}
+		if s.logger != nil {
+			s.logger.Debug("request adapted to provider capabilities", "provider", s.provider.Name(), "model", req.Model, "features", features)
+		}
+		return adapted, schemaTool, nil
+	}
+	return nil, "", fmt.Errorf("unknown preflight policy: %s", s.preflight)
+}
+
+// adaptRequest returns a copy of req without the features caps lacks:
+// documents become extracted text, response formats become instructions
+// (or a tool, see preflight), and the rest is dropped. Tools cannot be adapted.
+func adaptRequest(req *Request, caps Capabilities, emulateSchema bool) (*Request, string, error) {
+	out := *req
+	out.Messages = append([]Message(nil), req.Messages...)
+	var unadaptable []string
+
+	for _, f := range requestFeatures {
+		if !f.used(&out) || f.supported(caps) || f.drop == nil {
+			continue
+		}
+		f.drop(&out)
+	}
+
+	if anyMessage(&out, func(m Message) bool { return len(m.Documents) > 0 }) && !caps.Documents {
+		if err := inlineDocuments(&out); err != nil {
+			return nil, "", err
+		}
+	}
+
+	if anyMessage(&out, func(m Message) bool { return len(m.ToolCalls) > 0 || len(m.ToolResults) > 0 }) || len(out.Tools) > 0 {
+		if !caps.Tools {
+			unadaptable = append(unadaptable, "tools")
+		}
+	}
+
+	var schemaTool string
+	if rf := out.ResponseFormat; rf != nil {
+		switch {
+		case rf.Type == ResponseFormatJSON && !caps.JSONMode:
+			out.ResponseFormat = nil
+			addInstruction(&out, "Respond only with valid JSON.")
+		case rf.Type == ResponseFormatJSONSchema && !caps.JSONSchema:
+			schema, err := json.Marshal(rf.Schema)
+			if err != nil {
+				return nil, "", fmt.Errorf("encode response schema: %w", err)
+			}
+			out.ResponseFormat = nil
+			switch {
+			case emulateSchema && caps.Tools:
+				schemaTool = rf.Name
+				if schemaTool == "" {
+					schemaTool = "structured_output"
+				}
+				out.Tools = append(append([]Tool(nil), out.Tools...), Tool{Name: schemaTool, Description: schemaToolDescription, Parameters: rf.Schema})
+				addInstruction(&out, fmt.Sprintf("Respond only by calling the %s function with your answer.", schemaTool))
+			case caps.JSONMode:
+				out.ResponseFormat = &ResponseFormat{Type: ResponseFormatJSON}
+				addInstruction(&out, "Respond only with JSON matching this JSON schema:\n"+string(schema))
+			default:
+				addInstruction(&out, "Respond only with JSON matching this JSON schema:\n"+string(schema))
+			}
+		}
+	}
+
+	if len(unadaptable) > 0 {
+		return nil, "", &UnsupportedFeaturesError{Model: req.Model, Features: unadaptable}
+	}
+	return &out, schemaTool, nil
+}
+
+// dropImages removes images from the messages of r, which must be a copy.
+func dropImages(r *Request) {
+	for i, m := range r.Messages {
+		if len(m.Images) > 0 {
+			m.Images = nil
+			r.Messages[i] = m
+		}
+	}
+}
+
+// inlineDocuments replaces the documents of r, which must be a copy, with
+// their extracted text appended to the message content.
+func inlineDocuments(r *Request) error {
+	for i, m := range r.Messages {
+		if len(m.Documents) == 0 {
+			continue
+		}
+		var b strings.Builder
+		b.WriteString(m.Content)
+		for _, doc := range m.Documents {
+			text, err := ExtractDocumentText(doc)
+			if err != nil {
+				return fmt.Errorf("inline document %q: %w", doc.Name, err)
+			}
+			if b.Len() > 0 {
+				b.WriteString("\n\n")
+			}
+			if doc.Name != "" {
+				fmt.Fprintf(&b, "[Document: %s]\n", doc.Name)
+			}
+			b.WriteString(text)
+		}
+		m.Content = b.String()
+		m.Documents = nil
+		r.Messages[i] = m
+	}
+	return nil
+}
+
+// addInstruction appends text to the first system message of r, which must be
+// a copy, or prepends a system message.
+func addInstruction(r *Request, text string) {
+	if len(r.Messages) > 0 && r.Messages[0].Role == RoleSystem {
+		r.Messages[0].Content += "\n\n" + text
+		return
+	}
+	r.Messages = append([]Message{{Role: RoleSystem, Content: text}}, r.Messages...)
+}
+
+// extractSchemaTool moves the arguments of the call to the schema emulation
+// tool into resp.Content.
+func extractSchemaTool(resp *Response, name string) {
+	for i, tc := range resp.ToolCalls {
+		if tc.Name != name {
+			continue
+		}
+		resp.Content = string(tc.Arguments)
+		resp.ToolCalls = append(resp.ToolCalls[:i:i], resp.ToolCalls[i+1:]...)
+		if len(resp.ToolCalls) == 0 {
+			resp.ToolCalls = nil
+		}
+		return
+	}
+}
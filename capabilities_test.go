@@ -0,0 +1,157 @@
+package allm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// mockReporter implements CapabilityReporter.
+type mockReporter struct {
+	mockProvider
+	caps Capabilities
+}
+
+func (m *mockReporter) Capabilities() Capabilities {
+	return m.caps
+}
+
+func TestPreflightStrict(t *testing.T) {
+	provider := &mockReporter{
+		mockProvider: mockProvider{name: "test", response: &Response{Content: "ok"}},
+		caps:         Capabilities{Tools: true},
+	}
+	client := New(provider, WithPreflight(PreflightStrict), WithSeed(42))
+
+	msgs := []Message{{Role: RoleUser, Content: "look", Images: []Image{{MimeType: "image/png", Data: []byte{1}}}}}
+	_, err := client.Chat(context.Background(), msgs)
+	var unsupported *UnsupportedFeaturesError
+	if !errors.As(err, &unsupported) || !errors.Is(err, ErrNotSupported) {
+		t.Fatalf("expected UnsupportedFeaturesError, got %v", err)
+	}
+	if strings.Join(unsupported.Features, ",") != "images,seed" || unsupported.Provider != "test" {
+		t.Errorf("error = %+v", unsupported)
+	}
+	if provider.getLastReq() != nil {
+		t.Error("request was sent despite unsupported features")
+	}
+
+	for chunk := range client.Stream(context.Background(), msgs) {
+		if !errors.Is(chunk.Error, ErrNotSupported) {
+			t.Errorf("stream chunk = %+v, want ErrNotSupported", chunk)
+		}
+	}
+}
+
+func TestPreflightWarn(t *testing.T) {
+	provider := &mockReporter{mockProvider: mockProvider{name: "test", response: &Response{Content: "ok"}}}
+	client := New(provider, WithPreflight(PreflightWarn), WithSeed(42))
+
+	if _, err := client.Chat(context.Background(), []Message{{Role: RoleUser, Content: "hi"}}); err != nil {
+		t.Fatalf("Chat: %v", err)
+	}
+	if req := provider.getLastReq(); req == nil || req.Seed == nil {
+		t.Errorf("request should be sent unchanged: %+v", req)
+	}
+}
+
+func TestPreflightAdapt(t *testing.T) {
+	provider := &mockReporter{mockProvider: mockProvider{name: "test", response: &Response{Content: "ok"}}}
+	client := New(provider, WithPreflight(PreflightAdapt), WithSeed(42), WithLogProbs(3), WithResponseFormat(&ResponseFormat{Type: ResponseFormatJSON}))
+
+	msgs := []Message{{
+		Role:      RoleUser,
+		Content:   "summarize",
+		Images:    []Image{{MimeType: "image/png", Data: []byte{1}}},
+		Documents: []Document{{MimeType: "text/plain", Name: "notes.txt", Data: []byte("meeting notes")}},
+	}}
+	if _, err := client.Chat(context.Background(), msgs); err != nil {
+		t.Fatalf("Chat: %v", err)
+	}
+
+	req := provider.getLastReq()
+	if req.Seed != nil || req.LogProbs || req.ResponseFormat != nil {
+		t.Errorf("unsupported options not dropped: %+v", req)
+	}
+	if len(req.Messages) != 2 || req.Messages[0].Role != RoleSystem || !strings.Contains(req.Messages[0].Content, "JSON") {
+		t.Fatalf("expected JSON instruction, got %+v", req.Messages)
+	}
+	user := req.Messages[1]
+	if len(user.Images) != 0 || len(user.Documents) != 0 || !strings.Contains(user.Content, "[Document: notes.txt]\nmeeting notes") {
+		t.Errorf("user message not adapted: %+v", user)
+	}
+	if len(msgs[0].Images) != 1 || len(msgs[0].Documents) != 1 {
+		t.Error("caller's messages were modified")
+	}
+}
+
+func TestPreflightAdaptTools(t *testing.T) {
+	provider := &mockReporter{mockProvider: mockProvider{name: "test", response: &Response{Content: "ok"}}}
+	client := New(provider, WithPreflight(PreflightAdapt), WithTools(Tool{Name: "lookup"}))
+
+	_, err := client.Chat(context.Background(), []Message{{Role: RoleUser, Content: "hi"}})
+	var unsupported *UnsupportedFeaturesError
+	if !errors.As(err, &unsupported) || unsupported.Features[0] != "tools" || unsupported.Provider != "test" {
+		t.Fatalf("expected tools to be unadaptable, got %v", err)
+	}
+}
+
+func TestPreflightAdaptJSONSchema(t *testing.T) {
+	schema := map[string]any{"type": "object", "properties": map[string]any{"city": map[string]any{"type": "string"}}}
+	provider := &mockReporter{
+		mockProvider: mockProvider{name: "test", response: &Response{
+			ToolCalls: []ToolCall{{ID: "1", Name: "weather", Arguments: json.RawMessage(`{"city":"Oslo"}`)}},
+		}},
+		caps: Capabilities{Tools: true},
+	}
+	client := New(provider, WithPreflight(PreflightAdapt),
+		WithResponseFormat(&ResponseFormat{Type: ResponseFormatJSONSchema, Name: "weather", Schema: schema}))
+
+	resp, err := client.Chat(context.Background(), []Message{{Role: RoleUser, Content: "where?"}})
+	if err != nil {
+		t.Fatalf("Chat: %v", err)
+	}
+	if resp.Content != `{"city":"Oslo"}` || resp.ToolCalls != nil {
+		t.Errorf("schema tool call not extracted: %+v", resp)
+	}
+	req := provider.getLastReq()
+	if req.ResponseFormat != nil || len(req.Tools) != 1 || req.Tools[0].Name != "weather" {
+		t.Errorf("schema not emulated with a tool: %+v", req)
+	}
+
+	// Streams cannot use the tool, so the schema becomes an instruction.
+	provider.chunks = []StreamChunk{{Content: "{}"}, {Done: true}}
+	for range client.Stream(context.Background(), []Message{{Role: RoleUser, Content: "where?"}}) {
+	}
+	req = provider.getLastReq()
+	if len(req.Tools) != 0 || !strings.Contains(req.Messages[0].Content, `"city"`) {
+		t.Errorf("stream schema not adapted to an instruction: %+v", req)
+	}
+}
+
+func TestCapabilitiesFromCatalog(t *testing.T) {
+	provider := &mockProvider{name: "test"}
+	if caps := New(provider).Capabilities(); caps != allCapabilities() {
+		t.Errorf("providers without a reporter should support everything: %+v", caps)
+	}
+
+	caps := New(provider, WithModel("o3-mini")).Capabilities()
+	if caps.Vision || !caps.Tools || !caps.Effort {
+		t.Errorf("o3-mini capabilities = %+v", caps)
+	}
+
+	reporter := &mockReporter{mockProvider: mockProvider{name: "test"}, caps: Capabilities{Vision: true}}
+	if caps := New(reporter, WithModel("claude-haiku-4-5")).Capabilities(); !caps.Vision || caps.Tools {
+		t.Errorf("catalog should only narrow provider capabilities: %+v", caps)
+	}
+}
+
+func TestPreflightUnknownPolicy(t *testing.T) {
+	provider := &mockReporter{mockProvider: mockProvider{name: "test", response: &Response{Content: "ok"}}}
+	client := New(provider, WithPreflight("lenient"), WithSeed(1))
+	if _, err := client.Chat(context.Background(), []Message{{Role: RoleUser, Content: "hi"}}); err == nil {
+		t.Error("expected error for unknown policy")
+	}
+}
@@ -0,0 +1,258 @@
+package allm
+
+import (
+	"bytes"
+	_ "embed" // model catalog
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Model capability names used in Model.Capabilities.
+const (
+	CapabilityChat            = "chat"
+	CapabilityStreaming       = "streaming"
+	CapabilityTools           = "tools"
+	CapabilityVision          = "vision"
+	CapabilityThinking        = "thinking"
+	CapabilityJSONSchema      = "json-schema"
+	CapabilityPDF             = "pdf"
+	CapabilityCaching         = "caching"
+	CapabilityEmbeddings      = "embeddings"
+	CapabilityImageGeneration = "image-generation"
+	CapabilitySpeechToText    = "speech-to-text"
+	CapabilityTextToSpeech    = "text-to-speech"
+)
+
+//go:embed catalog.json
+var embeddedCatalog []byte
+
+// modelVersionSuffix matches the dated or "latest" suffix of a model snapshot
+// ID, e.g. "-20250514" or "-2024-08-06".
+var modelVersionSuffix = regexp.MustCompile(`^-(latest|\d{8}|\d{4}-\d{2}-\d{2})$`)
+
+// catalogFile is the JSON format of the model catalog.
+type catalogFile struct {
+	Models []catalogEntry `json:"models"`
+}
+
+// catalogEntry is a model in the catalog file.
+type catalogEntry struct {
+	ID            string          `json:"id"`
+	Name          string          `json:"name,omitempty"`
+	Provider      string          `json:"provider,omitempty"`
+	Aliases       []string        `json:"aliases,omitempty"`
+	ContextWindow int             `json:"context_window,omitempty"`
+	MaxOutput     int             `json:"max_output,omitempty"`
+	Capabilities  []string        `json:"capabilities,omitempty"`
+	Pricing       *catalogPricing `json:"pricing,omitempty"`
+}
+
+// catalogPricing is a model price in the catalog file, in USD per million tokens.
+type catalogPricing struct {
+	Input         float64 `json:"input"`
+	Output        float64 `json:"output"`
+	CacheRead     float64 `json:"cache_read,omitempty"`
+	CacheWrite    float64 `json:"cache_write,omitempty"`
+	CacheWrite1h  float64 `json:"cache_write_1h,omitempty"`
+	BatchDiscount float64 `json:"batch_discount,omitempty"`
+}
+
+// modelCatalog holds models by lower-case ID and alias.
+type modelCatalog struct {
+	mu      sync.RWMutex
+	models  map[string]Model
+	aliases map[string]string // lower-case alias → lower-case ID
+}
+
+// catalog returns the process-wide catalog, initialized from catalog.json.
+var catalog = sync.OnceValue(func() *modelCatalog {
+	c := &modelCatalog{models: make(map[string]Model), aliases: make(map[string]string)}
+	if err := c.load(bytes.NewReader(embeddedCatalog)); err != nil {
+		panic(fmt.Sprintf("allm: invalid embedded model catalog: %v", err))
+	}
+	return c
+})
+
+// load merges the catalog in r, replacing models with the same ID.
+func (c *modelCatalog) load(r io.Reader) error {
+	var file catalogFile
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&file); err != nil {
+		return fmt.Errorf("decode model catalog: %w", err)
+	}
+	for i, e := range file.Models {
+		if strings.TrimSpace(e.ID) == "" {
+			return fmt.Errorf("model catalog entry %d has no id", i)
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, e := range file.Models {
+		m := Model{
+			ID:            e.ID,
+			Name:          e.Name,
+			Provider:      e.Provider,
+			ContextWindow: e.ContextWindow,
+			MaxOutput:     e.MaxOutput,
+			Capabilities:  e.Capabilities,
+		}
+		if e.Pricing != nil {
+			m.Pricing = &Pricing{
+				Input:         e.Pricing.Input,
+				Output:        e.Pricing.Output,
+				CacheRead:     e.Pricing.CacheRead,
+				CacheWrite:    e.Pricing.CacheWrite,
+				CacheWrite1h:  e.Pricing.CacheWrite1h,
+				BatchDiscount: e.Pricing.BatchDiscount,
+			}
+		}
+		c.register(m, e.Aliases)
+	}
+	return nil
+}
+
+// register adds or replaces m. The caller must hold c.mu.
+func (c *modelCatalog) register(m Model, aliases []string) {
+	id := strings.ToLower(m.ID)
+	c.models[id] = cloneModel(m)
+	for _, alias := range aliases {
+		c.aliases[strings.ToLower(alias)] = id
+	}
+}
+
+// lookup finds a model by ID or alias, then by snapshot ID ("gpt-4o-2024-08-06"
+// matches "gpt-4o").
+func (c *modelCatalog) lookup(id string) (Model, bool) {
+	key := strings.ToLower(strings.TrimSpace(id))
+	if key == "" {
+		return Model{}, false
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if m, ok := c.find(key); ok {
+		return cloneModel(m), true
+	}
+	for i := strings.LastIndexByte(key, '-'); i > 0; i = strings.LastIndexByte(key[:i], '-') {
+		if !modelVersionSuffix.MatchString(key[i:]) {
+			continue
+		}
+		if m, ok := c.find(key[:i]); ok {
+			return cloneModel(m), true
+		}
+	}
+	return Model{}, false
+}
+
+// find looks up a lower-case ID or alias. The caller must hold c.mu.
+func (c *modelCatalog) find(key string) (Model, bool) {
+	if m, ok := c.models[key]; ok {
+		return m, true
+	}
+	if id, ok := c.aliases[key]; ok {
+		m, ok := c.models[id]
+		return m, ok
+	}
+	return Model{}, false
+}
+
+// cloneModel copies m so catalog entries cannot be modified through results.
+func cloneModel(m Model) Model {
+	m.Capabilities = slices.Clone(m.Capabilities)
+	if m.Pricing != nil {
+		p := *m.Pricing
+		m.Pricing = &p
+	}
+	return m
+}
+
+// LookupModel returns catalog metadata (context window, max output,
+// capabilities and pricing) for a model ID or alias. Dated snapshot IDs such
+// as "claude-sonnet-4-5-20250929" resolve to their base model.
+func LookupModel(id string) (Model, bool) {
+	return catalog().lookup(id)
+}
+
+// CatalogModels returns all models in the catalog, sorted by provider and ID.
+func CatalogModels() []Model {
+	c := catalog()
+	c.mu.RLock()
+	models := make([]Model, 0, len(c.models))
+	for _, m := range c.models {
+		models = append(models, cloneModel(m))
+	}
+	c.mu.RUnlock()
+
+	sort.Slice(models, func(i, j int) bool {
+		if models[i].Provider != models[j].Provider {
+			return models[i].Provider < models[j].Provider
+		}
+		return models[i].ID < models[j].ID
+	})
+	return models
+}
+
+// RegisterModel adds a model to the catalog or replaces the entry with the
+// same ID, e.g. for fine-tuned or self-hosted models or updated prices.
+func RegisterModel(m Model, aliases ...string) error {
+	if strings.TrimSpace(m.ID) == "" {
+		return fmt.Errorf("model id is required")
+	}
+	c := catalog()
+	c.mu.Lock()
+	c.register(m, aliases)
+	c.mu.Unlock()
+	return nil
+}
+
+// LoadModelCatalog merges a JSON catalog into the built-in one, replacing
+// models with the same ID. The format is that of the embedded catalog.json:
+//
+//	{"models": [{"id": "my-model", "provider": "local", "context_window": 32768,
+//	  "capabilities": ["chat", "tools"], "pricing": {"input": 0.5, "output": 1.5}}]}
+func LoadModelCatalog(r io.Reader) error {
+	return catalog().load(r)
+}
+
+// Supports reports whether the model has the given capability.
+func (m Model) Supports(capability string) bool {
+	return slices.Contains(m.Capabilities, capability)
+}
+
+// enrichModel fills the fields a provider left empty from the catalog and
+// merges the catalog capabilities.
+func enrichModel(m Model) Model {
+	info, ok := LookupModel(m.ID)
+	if !ok {
+		return m
+	}
+	if (m.Name == "" || m.Name == m.ID) && info.Name != "" {
+		m.Name = info.Name
+	}
+	if m.Provider == "" && info.Provider != "" {
+		m.Provider = info.Provider
+	}
+	if m.ContextWindow == 0 {
+		m.ContextWindow = info.ContextWindow
+	}
+	if m.MaxOutput == 0 {
+		m.MaxOutput = info.MaxOutput
+	}
+	if m.Pricing == nil {
+		m.Pricing = info.Pricing
+	}
+	for _, capability := range info.Capabilities {
+		if !m.Supports(capability) {
+			m.Capabilities = append(m.Capabilities, capability)
+		}
+	}
+	return m
+}
@@ -0,0 +1,164 @@
+package allm
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestEmbeddedCatalog(t *testing.T) {
+	models := CatalogModels()
+	if len(models) == 0 {
+		t.Fatal("embedded catalog is empty")
+	}
+	for i, m := range models {
+		if m.ID == "" || m.Provider == "" || len(m.Capabilities) == 0 {
+			t.Errorf("incomplete catalog entry: %+v", m)
+		}
+		if i > 0 && models[i-1].Provider == m.Provider && models[i-1].ID >= m.ID {
+			t.Errorf("catalog not sorted at %q", m.ID)
+		}
+	}
+}
+
+func TestLookupModel(t *testing.T) {
+	tests := []struct {
+		id     string
+		wantID string
+	}{
+		{"claude-sonnet-4-6", "claude-sonnet-4-6"},
+		{"Claude-Sonnet-4-6", "claude-sonnet-4-6"},
+		{"sonnet", "claude-sonnet-4-6"},
+		{"claude-sonnet-4-5-20250929", "claude-sonnet-4-5"},
+		{"claude-opus-4-20250514", "claude-opus-4-0"},
+		{"gpt-4o-2024-08-06", "gpt-4o"},
+		{"gpt-4o-mini", "gpt-4o-mini"},
+		{"minimax-m2", "MiniMax-M2"},
+	}
+	for _, tt := range tests {
+		m, ok := LookupModel(tt.id)
+		if !ok || m.ID != tt.wantID {
+			t.Errorf("LookupModel(%q) = %q, %v; want %q", tt.id, m.ID, ok, tt.wantID)
+		}
+	}
+
+	for _, id := range []string{"", "unknown-model", "gpt-4o-custom", "gpt-5.1-codex"} {
+		if m, ok := LookupModel(id); ok {
+			t.Errorf("LookupModel(%q) = %q, want not found", id, m.ID)
+		}
+	}
+}
+
+func TestLookupModelMetadata(t *testing.T) {
+	m, ok := LookupModel("claude-haiku-4-5")
+	if !ok {
+		t.Fatal("claude-haiku-4-5 not found")
+	}
+	if m.Provider != string(Anthropic) || m.ContextWindow != 200000 || m.MaxOutput == 0 {
+		t.Errorf("unexpected metadata: %+v", m)
+	}
+	if !m.Supports(CapabilityVision) || !m.Supports(CapabilityPDF) || m.Supports(CapabilityEmbeddings) {
+		t.Errorf("unexpected capabilities: %v", m.Capabilities)
+	}
+	if m.Pricing == nil || m.Pricing.Input != 1 || m.Pricing.Output != 5 || m.Pricing.CacheWrite1h != 2 {
+		t.Errorf("unexpected pricing: %+v", m.Pricing)
+	}
+
+	// Results are copies.
+	m.Capabilities[0] = "changed"
+	m.Pricing.Input = 100
+	again, _ := LookupModel("claude-haiku-4-5")
+	if again.Capabilities[0] == "changed" || again.Pricing.Input == 100 {
+		t.Error("LookupModel result shares state with the catalog")
+	}
+}
+
+func TestRegisterModel(t *testing.T) {
+	if err := RegisterModel(Model{}); err == nil {
+		t.Error("expected error for empty id")
+	}
+
+	err := RegisterModel(Model{
+		ID:            "test-register-model",
+		Provider:      string(Local),
+		ContextWindow: 32768,
+		Capabilities:  []string{CapabilityChat},
+	}, "test-register-alias")
+	if err != nil {
+		t.Fatalf("RegisterModel: %v", err)
+	}
+	m, ok := LookupModel("test-register-alias")
+	if !ok || m.ContextWindow != 32768 {
+		t.Errorf("LookupModel = %+v, %v", m, ok)
+	}
+	if got := DetectProvider("test-register-model"); got != Local {
+		t.Errorf("DetectProvider = %q, want %q", got, Local)
+	}
+}
+
+func TestLoadModelCatalog(t *testing.T) {
+	data := `{"models": [{"id": "test-load-model", "provider": "openai", "max_output": 2048,
+		"capabilities": ["chat"], "pricing": {"input": 0.5, "output": 1.5, "cache_read": 0.05}}]}`
+	if err := LoadModelCatalog(strings.NewReader(data)); err != nil {
+		t.Fatalf("LoadModelCatalog: %v", err)
+	}
+	m, ok := LookupModel("test-load-model")
+	if !ok || m.MaxOutput != 2048 || m.Pricing == nil || m.Pricing.CacheRead != 0.05 {
+		t.Errorf("LookupModel = %+v, %v", m, ok)
+	}
+
+	for _, bad := range []string{`{`, `{"models": [{"name": "no id"}]}`, `{"models": [{"id": "x", "window": 1}]}`} {
+		if err := LoadModelCatalog(strings.NewReader(bad)); err == nil {
+			t.Errorf("LoadModelCatalog(%s) should fail", bad)
+		}
+	}
+}
+
+func TestDetectProvider(t *testing.T) {
+	tests := []struct {
+		model string
+		want  ProviderName
+	}{
+		{"claude-sonnet-4-6", Anthropic},
+		{"haiku", Anthropic},
+		{"gpt-4.1-mini", OpenAI},
+		{"o3", OpenAI},
+		{"text-embedding-3-small", OpenAI},
+		{"glm-4.6", GLM},
+		{"kimi-k2-thinking", Kimi},
+		{"MiniMax-M2.5", MiniMax},
+		{"llama3.2", Local},
+		{"something-else", ""},
+	}
+	for _, tt := range tests {
+		if got := DetectProvider(tt.model); got != tt.want {
+			t.Errorf("DetectProvider(%q) = %q, want %q", tt.model, got, tt.want)
+		}
+	}
+}
+
+func TestModelsEnrichedFromCatalog(t *testing.T) {
+	p := &mockModelLister{
+		mockProvider: mockProvider{name: "test", available: true},
+		models: []Model{
+			{ID: "gpt-4o-2024-08-06", Name: "gpt-4o-2024-08-06", Provider: "openai", Capabilities: []string{CapabilityChat}},
+			{ID: "claude-opus-4-6", Name: "Claude Opus 4.6", Provider: "anthropic", ContextWindow: 1000000},
+			{ID: "custom", Name: "Custom"},
+		},
+	}
+	models, err := New(p).Models(context.Background())
+	if err != nil {
+		t.Fatalf("Models: %v", err)
+	}
+
+	if m := models[0]; m.Name != "GPT-4o" || m.ContextWindow != 128000 || m.Pricing == nil ||
+		!m.Supports(CapabilityVision) || strings.Count(strings.Join(m.Capabilities, ","), CapabilityChat) != 1 {
+		t.Errorf("gpt-4o not enriched: %+v", m)
+	}
+	if m := models[1]; m.ContextWindow != 1000000 || m.MaxOutput == 0 {
+		t.Errorf("provider values should win over the catalog: %+v", m)
+	}
+	if m := models[2]; m.Pricing != nil || len(m.Capabilities) != 0 {
+		t.Errorf("unknown model should be unchanged: %+v", m)
+	}
+}
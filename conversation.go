@@ -0,0 +1,385 @@
+package allm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+)
+
+// ConversationUsage is the cumulative usage of a conversation.
+type ConversationUsage struct {
+	Requests         int64   `json:"requests"`                     // Model calls made
+	InputTokens      int64   `json:"input_tokens"`                 // Total input tokens
+	OutputTokens     int64   `json:"output_tokens"`                // Total output tokens
+	CacheReadTokens  int64   `json:"cache_read_tokens,omitempty"`  // Input tokens read from cache
+	CacheWriteTokens int64   `json:"cache_write_tokens,omitempty"` // Input tokens written to cache
+	ThinkingTokens   int64   `json:"thinking_tokens,omitempty"`    // Output tokens used for thinking (where reported)
+	Cost             float64 `json:"cost"`                         // Total USD cost (0 without pricing)
+}
+
+// Conversation is a chat history bound to a Client. It appends user, assistant
+// and tool turns, tracks usage and cost, and can be forked and saved as JSON.
+// Methods are safe for concurrent use; sends on one conversation are serialized.
+type Conversation struct {
+	mu       sync.Mutex
+	sendMu   sync.Mutex // held for the duration of a model call
+	client   *Client
+	system   string
+	messages []Message
+	usage    ConversationUsage
+	pricing  *Pricing
+	metadata map[string]string
+}
+
+// ConversationOption configures a Conversation.
+type ConversationOption func(*Conversation)
+
+// WithConversationSystem sets the conversation's system prompt. It is sent in
+// addition to the client's system prompt.
+func WithConversationSystem(prompt string) ConversationOption {
+	return func(c *Conversation) {
+		c.system = prompt
+	}
+}
+
+// WithConversationPricing sets the pricing used to compute ConversationUsage.Cost
+// instead of the Response.Cost computed by the client.
+func WithConversationPricing(p Pricing) ConversationOption {
+	return func(c *Conversation) {
+		c.pricing = &p
+	}
+}
+
+// WithConversationMetadata attaches metadata (e.g., user or session IDs)
+// that is saved with the conversation.
+func WithConversationMetadata(metadata map[string]string) ConversationOption {
+	return func(c *Conversation) {
+		c.metadata = make(map[string]string, len(metadata))
+		for k, v := range metadata {
+			c.metadata[k] = v
+		}
+	}
+}
+
+// NewConversation starts an empty conversation that sends through this client.
+func (c *Client) NewConversation(opts ...ConversationOption) *Conversation {
+	conv := &Conversation{client: c}
+	for _, opt := range opts {
+		opt(conv)
+	}
+	return conv
+}
+
+// Send adds a user message and returns the model's reply, which is appended
+// to the history. On error the history is left unchanged.
+func (c *Conversation) Send(ctx context.Context, content string) (*Response, error) {
+	return c.SendMessage(ctx, Message{Role: RoleUser, Content: content})
+}
+
+// SendMessage adds a message (e.g., with images or documents) and returns the
+// model's reply, which is appended to the history. On error the history is
+// left unchanged.
+func (c *Conversation) SendMessage(ctx context.Context, msg Message) (*Response, error) {
+	if msg.Role == "" {
+		msg.Role = RoleUser
+	}
+	return c.send(ctx, msg)
+}
+
+// SendToolResults adds the results of the last reply's tool calls and returns
+// the model's next reply.
+func (c *Conversation) SendToolResults(ctx context.Context, results ...ToolResult) (*Response, error) {
+	if len(results) == 0 {
+		return nil, ErrEmptyInput
+	}
+	return c.send(ctx, Message{Role: RoleTool, ToolResults: results})
+}
+
+// send calls the model with the history plus msg and records both turns.
+func (c *Conversation) send(ctx context.Context, msg Message) (*Response, error) {
+	if c.client == nil {
+		return nil, ErrNoProvider
+	}
+
+	c.sendMu.Lock()
+	defer c.sendMu.Unlock()
+
+	c.mu.Lock()
+	history := c.requestMessages(msg)
+	c.mu.Unlock()
+
+	resp, err := c.client.Chat(ctx, history)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.messages = append(c.messages, msg, Message{
+		Role:      RoleAssistant,
+		Content:   resp.Content,
+		ToolCalls: resp.ToolCalls,
+	})
+	c.usage.Requests++
+	c.usage.InputTokens += int64(resp.InputTokens)
+	c.usage.OutputTokens += int64(resp.OutputTokens)
+	c.usage.CacheReadTokens += int64(resp.CacheReadTokens)
+	c.usage.CacheWriteTokens += int64(resp.CacheWriteTokens)
+	c.usage.ThinkingTokens += int64(resp.ThinkingTokens)
+	if c.pricing != nil {
+		c.usage.Cost += c.pricing.ResponseCost(resp)
+	} else {
+		c.usage.Cost += resp.Cost
+	}
+	return resp, nil
+}
+
+// requestMessages builds the messages for a model call. The caller must hold mu.
+func (c *Conversation) requestMessages(next Message) []Message {
+	msgs := make([]Message, 0, len(c.messages)+2)
+	if c.system != "" {
+		msgs = append(msgs, Message{Role: RoleSystem, Content: c.system})
+	}
+	msgs = append(msgs, c.messages...)
+	return append(msgs, next)
+}
+
+// Append adds messages to the history without calling the model.
+func (c *Conversation) Append(msgs ...Message) {
+	c.mu.Lock()
+	c.messages = append(c.messages, msgs...)
+	c.mu.Unlock()
+}
+
+// Messages returns a copy of the history (without the system prompt).
+func (c *Conversation) Messages() []Message {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]Message(nil), c.messages...)
+}
+
+// Len returns the number of messages in the history.
+func (c *Conversation) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.messages)
+}
+
+// System returns the conversation's system prompt.
+func (c *Conversation) System() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.system
+}
+
+// SetSystem replaces the conversation's system prompt.
+func (c *Conversation) SetSystem(prompt string) {
+	c.mu.Lock()
+	c.system = prompt
+	c.mu.Unlock()
+}
+
+// Metadata returns a copy of the conversation's metadata.
+func (c *Conversation) Metadata() map[string]string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	out := make(map[string]string, len(c.metadata))
+	for k, v := range c.metadata {
+		out[k] = v
+	}
+	return out
+}
+
+// Usage returns the cumulative usage of the conversation.
+func (c *Conversation) Usage() ConversationUsage {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.usage
+}
+
+// Reset clears the history and usage, keeping the system prompt, pricing and metadata.
+func (c *Conversation) Reset() {
+	c.mu.Lock()
+	c.messages = nil
+	c.usage = ConversationUsage{}
+	c.mu.Unlock()
+}
+
+// Fork returns an independent copy of the conversation. The fork starts with
+// zero usage so costs are not counted twice.
+func (c *Conversation) Fork() *Conversation {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.branch(len(c.messages))
+}
+
+// Branch returns an independent copy holding the first n messages, to
+// explore an alternative continuation from an earlier turn.
+func (c *Conversation) Branch(n int) (*Conversation, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if n < 0 || n > len(c.messages) {
+		return nil, fmt.Errorf("branch point %d out of range (0-%d)", n, len(c.messages))
+	}
+	return c.branch(n), nil
+}
+
+// branch copies the first n messages. The caller must hold mu.
+func (c *Conversation) branch(n int) *Conversation {
+	fork := &Conversation{
+		client:   c.client,
+		system:   c.system,
+		messages: append([]Message(nil), c.messages[:n]...),
+		metadata: make(map[string]string, len(c.metadata)),
+	}
+	if c.pricing != nil {
+		p := *c.pricing
+		fork.pricing = &p
+	}
+	for k, v := range c.metadata {
+		fork.metadata[k] = v
+	}
+	return fork
+}
+
+// conversationVersion is the current JSON format version.
+const conversationVersion = 1
+
+// conversationJSON is the JSON form of a Conversation.
+type conversationJSON struct {
+	Version  int               `json:"version"`
+	System   string            `json:"system,omitempty"`
+	Messages []messageJSON     `json:"messages"`
+	Usage    ConversationUsage `json:"usage"`
+	Pricing  *Pricing          `json:"pricing,omitempty"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
+type messageJSON struct {
+	Role         string         `json:"role"`
+	Content      string         `json:"content,omitempty"`
+	Images       []imageJSON    `json:"images,omitempty"`
+	Documents    []documentJSON `json:"documents,omitempty"`
+	ToolCalls    []toolCallJSON `json:"tool_calls,omitempty"`
+	ToolResults  []toolResJSON  `json:"tool_results,omitempty"`
+	CacheControl *CacheControl  `json:"cache_control,omitempty"`
+}
+
+type imageJSON struct {
+	MimeType     string        `json:"mime_type,omitempty"`
+	Data         []byte        `json:"data,omitempty"`
+	FileID       string        `json:"file_id,omitempty"`
+	CacheControl *CacheControl `json:"cache_control,omitempty"`
+}
+
+type documentJSON struct {
+	MimeType     string        `json:"mime_type,omitempty"`
+	Data         []byte        `json:"data,omitempty"`
+	Name         string        `json:"name,omitempty"`
+	FileID       string        `json:"file_id,omitempty"`
+	CacheControl *CacheControl `json:"cache_control,omitempty"`
+}
+
+type toolCallJSON struct {
+	ID        string          `json:"id"`
+	Name      string          `json:"name"`
+	Arguments json.RawMessage `json:"arguments,omitempty"`
+}
+
+type toolResJSON struct {
+	ToolCallID string `json:"tool_call_id"`
+	Content    string `json:"content"`
+	IsError    bool   `json:"is_error,omitempty"`
+}
+
+// MarshalJSON encodes the history, usage, pricing and metadata. Images and
+// documents with a FileID are saved by reference; inline data is base64 encoded.
+// Upload large attachments first (see FileStore) to keep saved sessions small.
+func (c *Conversation) MarshalJSON() ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	out := conversationJSON{
+		Version:  conversationVersion,
+		System:   c.system,
+		Messages: make([]messageJSON, 0, len(c.messages)),
+		Usage:    c.usage,
+		Pricing:  c.pricing,
+		Metadata: c.metadata,
+	}
+	for _, m := range c.messages {
+		mj := messageJSON{Role: m.Role, Content: m.Content, CacheControl: m.CacheControl}
+		for _, img := range m.Images {
+			ij := imageJSON{MimeType: img.MimeType, FileID: img.FileID, CacheControl: img.CacheControl}
+			if img.FileID == "" {
+				ij.Data = img.Data
+			}
+			mj.Images = append(mj.Images, ij)
+		}
+		for _, doc := range m.Documents {
+			dj := documentJSON{MimeType: doc.MimeType, Name: doc.Name, FileID: doc.FileID, CacheControl: doc.CacheControl}
+			if doc.FileID == "" {
+				dj.Data = doc.Data
+			}
+			mj.Documents = append(mj.Documents, dj)
+		}
+		for _, tc := range m.ToolCalls {
+			mj.ToolCalls = append(mj.ToolCalls, toolCallJSON(tc))
+		}
+		for _, tr := range m.ToolResults {
+			mj.ToolResults = append(mj.ToolResults, toolResJSON(tr))
+		}
+		out.Messages = append(out.Messages, mj)
+	}
+	return json.Marshal(out)
+}
+
+// UnmarshalJSON restores a conversation saved with MarshalJSON. The client
+// binding is kept; use Client.LoadConversation to restore into a new conversation.
+func (c *Conversation) UnmarshalJSON(data []byte) error {
+	var in conversationJSON
+	if err := json.Unmarshal(data, &in); err != nil {
+		return fmt.Errorf("decode conversation: %w", err)
+	}
+	if in.Version != conversationVersion {
+		return fmt.Errorf("unsupported conversation version %d", in.Version)
+	}
+
+	messages := make([]Message, 0, len(in.Messages))
+	for _, mj := range in.Messages {
+		m := Message{Role: mj.Role, Content: mj.Content, CacheControl: mj.CacheControl}
+		for _, ij := range mj.Images {
+			m.Images = append(m.Images, Image(ij))
+		}
+		for _, dj := range mj.Documents {
+			m.Documents = append(m.Documents, Document(dj))
+		}
+		for _, tc := range mj.ToolCalls {
+			m.ToolCalls = append(m.ToolCalls, ToolCall(tc))
+		}
+		for _, tr := range mj.ToolResults {
+			m.ToolResults = append(m.ToolResults, ToolResult(tr))
+		}
+		messages = append(messages, m)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.system = in.System
+	c.messages = messages
+	c.usage = in.Usage
+	c.pricing = in.Pricing
+	c.metadata = in.Metadata
+	return nil
+}
+
+// LoadConversation restores a conversation saved with Conversation.MarshalJSON
+// and binds it to this client.
+func (c *Client) LoadConversation(data []byte) (*Conversation, error) {
+	conv := c.NewConversation()
+	if err := conv.UnmarshalJSON(data); err != nil {
+		return nil, err
+	}
+	return conv, nil
+}
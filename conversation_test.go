@@ -0,0 +1,198 @@
+package allm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestConversationSend(t *testing.T) {
+	p := &mockProvider{name: "test", available: true, response: &Response{Content: "hi there", InputTokens: 1000, OutputTokens: 500}}
+	client := New(p, WithSystemPrompt("client prompt"))
+	conv := client.NewConversation(
+		WithConversationSystem("be terse"),
+		WithConversationPricing(Pricing{Input: 3, Output: 15}),
+	)
+
+	if _, err := conv.Send(context.Background(), "hello"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := conv.Send(context.Background(), "again"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	msgs := p.getLastReq().Messages
+	if len(msgs) != 5 || msgs[0].Content != "client prompt" || msgs[1].Content != "be terse" ||
+		msgs[2].Content != "hello" || msgs[3].Content != "hi there" || msgs[4].Content != "again" {
+		t.Errorf("unexpected request messages: %+v", msgs)
+	}
+	if conv.Len() != 4 || conv.Messages()[3].Role != RoleAssistant {
+		t.Errorf("unexpected history: %+v", conv.Messages())
+	}
+
+	u := conv.Usage()
+	if u.Requests != 2 || u.InputTokens != 2000 || u.OutputTokens != 1000 {
+		t.Errorf("unexpected usage: %+v", u)
+	}
+	// 2 * (1000*3 + 500*15) / 1M
+	if math.Abs(u.Cost-0.021) > 1e-9 {
+		t.Errorf("expected cost 0.021, got %v", u.Cost)
+	}
+}
+
+func TestConversationUsageCache(t *testing.T) {
+	p := &mockProvider{name: "test", available: true, response: &Response{
+		Content: "ok", Provider: "openai", InputTokens: 1000, OutputTokens: 100,
+		CacheReadTokens: 800, ThinkingTokens: 40,
+	}}
+	conv := New(p).NewConversation(WithConversationPricing(Pricing{Input: 1, Output: 2, CacheRead: 0.1}))
+	if _, err := conv.Send(context.Background(), "hello"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	u := conv.Usage()
+	if u.CacheReadTokens != 800 || u.ThinkingTokens != 40 {
+		t.Errorf("unexpected usage: %+v", u)
+	}
+	// (200*1 + 800*0.1 + 100*2) / 1M
+	if math.Abs(u.Cost-0.00048) > 1e-12 {
+		t.Errorf("expected cost 0.00048, got %v", u.Cost)
+	}
+}
+
+func TestConversationErrorKeepsHistory(t *testing.T) {
+	p := &mockProvider{name: "test", available: true, err: ErrProvider}
+	conv := New(p).NewConversation()
+	if _, err := conv.Send(context.Background(), "hello"); !errors.Is(err, ErrProvider) {
+		t.Fatalf("expected ErrProvider, got %v", err)
+	}
+	if conv.Len() != 0 || conv.Usage().Requests != 0 {
+		t.Error("failed send should not change history or usage")
+	}
+
+	var unbound Conversation
+	if _, err := unbound.Send(context.Background(), "hi"); !errors.Is(err, ErrNoProvider) {
+		t.Errorf("expected ErrNoProvider, got %v", err)
+	}
+}
+
+func TestConversationToolResults(t *testing.T) {
+	call := ToolCall{ID: "call_1", Name: "lookup", Arguments: json.RawMessage(`{"q":"go"}`)}
+	p := &mockProvider{name: "test", available: true, response: &Response{ToolCalls: []ToolCall{call}}}
+	conv := New(p).NewConversation()
+
+	resp, err := conv.Send(context.Background(), "search go")
+	if err != nil || len(resp.ToolCalls) != 1 {
+		t.Fatalf("unexpected response: %+v, %v", resp, err)
+	}
+
+	p.mu.Lock()
+	p.response = &Response{Content: "Go is a language"}
+	p.mu.Unlock()
+	if _, err := conv.SendToolResults(context.Background(), ToolResult{ToolCallID: "call_1", Content: "result"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	msgs := conv.Messages()
+	if len(msgs) != 4 || msgs[1].ToolCalls[0].ID != "call_1" || msgs[2].Role != RoleTool || msgs[2].ToolResults[0].Content != "result" {
+		t.Errorf("unexpected history: %+v", msgs)
+	}
+	if _, err := conv.SendToolResults(context.Background()); !errors.Is(err, ErrEmptyInput) {
+		t.Errorf("expected ErrEmptyInput, got %v", err)
+	}
+}
+
+func TestConversationForkAndBranch(t *testing.T) {
+	p := &mockProvider{name: "test", available: true, response: &Response{Content: "ok", InputTokens: 10}}
+	conv := New(p).NewConversation(WithConversationMetadata(map[string]string{"user": "u1"}))
+	_, _ = conv.Send(context.Background(), "one")
+	_, _ = conv.Send(context.Background(), "two")
+
+	fork := conv.Fork()
+	_, _ = fork.Send(context.Background(), "three")
+	if conv.Len() != 4 || fork.Len() != 6 {
+		t.Errorf("fork should not affect parent: parent %d, fork %d", conv.Len(), fork.Len())
+	}
+	if fork.Usage().Requests != 1 || fork.Metadata()["user"] != "u1" {
+		t.Errorf("unexpected fork state: %+v %v", fork.Usage(), fork.Metadata())
+	}
+
+	branch, err := conv.Branch(2)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if branch.Len() != 2 || branch.Messages()[0].Content != "one" {
+		t.Errorf("unexpected branch: %+v", branch.Messages())
+	}
+	if _, err := conv.Branch(5); err == nil {
+		t.Error("expected error for out-of-range branch point")
+	}
+
+	conv.Reset()
+	if conv.Len() != 0 || conv.Usage().Requests != 0 || conv.Metadata()["user"] != "u1" {
+		t.Error("Reset should clear history and usage only")
+	}
+}
+
+func TestConversationJSON(t *testing.T) {
+	p := &mockProvider{name: "test", available: true, response: &Response{Content: "ok", InputTokens: 3, OutputTokens: 4}}
+	client := New(p)
+	conv := client.NewConversation(
+		WithConversationSystem("sys"),
+		WithConversationPricing(Pricing{Input: 1, Output: 2}),
+		WithConversationMetadata(map[string]string{"session": "s1"}),
+	)
+	_, err := conv.SendMessage(context.Background(), Message{
+		Content:   "describe",
+		Images:    []Image{{MimeType: "image/png", FileID: "file-img"}, {MimeType: "image/png", Data: []byte{1, 2}}},
+		Documents: []Document{{MimeType: "application/pdf", Name: "a.pdf", FileID: "file-doc"}},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	conv.Append(
+		Message{Role: RoleAssistant, ToolCalls: []ToolCall{{ID: "c1", Name: "f", Arguments: json.RawMessage(`{"a":1}`)}}},
+		Message{Role: RoleTool, ToolResults: []ToolResult{{ToolCallID: "c1", Content: "bad", IsError: true}}},
+	)
+
+	data, err := json.Marshal(conv)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+
+	restored, err := client.LoadConversation(data)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if restored.System() != "sys" || restored.Usage() != conv.Usage() || restored.Metadata()["session"] != "s1" {
+		t.Errorf("unexpected restored state: %q %+v %v", restored.System(), restored.Usage(), restored.Metadata())
+	}
+	msgs := restored.Messages()
+	if len(msgs) != 4 {
+		t.Fatalf("expected 4 messages, got %d", len(msgs))
+	}
+	if msgs[0].Images[0].FileID != "file-img" || string(msgs[0].Images[1].Data) != "\x01\x02" ||
+		msgs[0].Documents[0].FileID != "file-doc" || msgs[0].Documents[0].Name != "a.pdf" {
+		t.Errorf("unexpected attachments: %+v", msgs[0])
+	}
+	if string(msgs[2].ToolCalls[0].Arguments) != `{"a":1}` || !msgs[3].ToolResults[0].IsError {
+		t.Errorf("unexpected tool turns: %+v %+v", msgs[2], msgs[3])
+	}
+
+	// Restored conversations keep sending through the client, with pricing
+	if _, err := restored.Send(context.Background(), "more"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if restored.Usage().Requests != 2 || restored.Usage().Cost <= conv.Usage().Cost {
+		t.Errorf("unexpected usage after restore: %+v", restored.Usage())
+	}
+
+	if _, err := client.LoadConversation([]byte(`{"version":99}`)); err == nil {
+		t.Error("expected error for unknown version")
+	}
+	if _, err := client.LoadConversation([]byte(`not json`)); err == nil {
+		t.Error("expected error for invalid JSON")
+	}
+}
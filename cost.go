@@ -0,0 +1,309 @@
+package allm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrBudgetExceeded is returned when WithBudget's limit for the current period
+// has been spent. The call is rejected before it is sent.
+var ErrBudgetExceeded = errors.New("allm: budget exceeded")
+
+// Pricing is a model's price in USD per million tokens.
+type Pricing struct {
+	Input         float64 // USD per million input tokens
+	Output        float64 // USD per million output tokens
+	CacheRead     float64 // USD per million cache-read input tokens (0 = Input)
+	CacheWrite    float64 // USD per million cache-write input tokens (0 = Input)
+	CacheWrite1h  float64 // USD per million cache-write input tokens with CacheTTL1h (0 = CacheWrite)
+	BatchDiscount float64 // Fraction off for batch API requests (e.g., 0.5)
+}
+
+// ResponseCost returns the USD cost of resp. Cache reads and writes are
+// charged at CacheRead and CacheWrite (Input when unset), and 1-hour cache
+// writes at CacheWrite1h, whether resp.Provider counts them in InputTokens or
+// reports them separately. Thinking tokens are billed as output tokens.
+func (p Pricing) ResponseCost(resp *Response) float64 {
+	return p.tokenCost(resp.InputTokens, resp.OutputTokens, resp.CacheReadTokens, resp.CacheWriteTokens,
+		resp.CacheWrite1hTokens, cacheSeparate(resp.Provider))
+}
+
+// BatchCost returns the USD cost of resp when it was processed by a batch API,
+// applying BatchDiscount.
+func (p Pricing) BatchCost(resp *Response) float64 {
+	return p.ResponseCost(resp) * (1 - p.BatchDiscount)
+}
+
+// cacheSeparate reports whether provider reports cache reads and writes
+// separately from input tokens, as the Anthropic Messages API does. GLM, Kimi
+// and MiniMax are served through the same API. OpenAI-compatible APIs count
+// cached tokens in prompt_tokens.
+func cacheSeparate(provider string) bool {
+	switch ProviderName(provider) {
+	case Anthropic, GLM, Kimi, MiniMax:
+		return true
+	default:
+		return false
+	}
+}
+
+// tokenCost returns the USD cost of the given token counts. cacheWrite1h is
+// the part of cacheWrite written with a 1-hour TTL. If separate is false,
+// input includes cacheRead and cacheWrite.
+func (p Pricing) tokenCost(input, output, cacheRead, cacheWrite, cacheWrite1h int, separate bool) float64 {
+	readPrice, writePrice := p.CacheRead, p.CacheWrite
+	if readPrice == 0 {
+		readPrice = p.Input
+	}
+	if writePrice == 0 {
+		writePrice = p.Input
+	}
+	write1hPrice := p.CacheWrite1h
+	if write1hPrice == 0 {
+		write1hPrice = writePrice
+	}
+	cacheWrite1h = min(cacheWrite1h, cacheWrite)
+	uncached := input
+	if !separate {
+		uncached = max(input-cacheRead-cacheWrite, 0)
+	}
+	return (float64(uncached)*p.Input + float64(cacheRead)*readPrice +
+		float64(cacheWrite-cacheWrite1h)*writePrice + float64(cacheWrite1h)*write1hPrice +
+		float64(output)*p.Output) / 1_000_000
+}
+
+// WithPricing sets the price of a model, overriding the model catalog.
+// Response.Cost, UsageStats.Cost and WithBudget use catalog prices by default.
+func WithPricing(model string, p Pricing) Option {
+	return func(c *Client) {
+		if c.pricing == nil {
+			c.pricing = make(map[string]Pricing)
+		}
+		c.pricing[model] = p
+	}
+}
+
+// WithBudget limits spending to limit USD per period (0 = over the client's
+// lifetime). Once the current period's spend reaches the limit, Chat, Stream,
+// Moderate and Rerank fail with ErrBudgetExceeded before sending, as do
+// truncation summaries. Spend is the sum of the costs in Usage, so calls to
+// models without pricing are not counted.
+func WithBudget(limit float64, period time.Duration) Option {
+	return func(c *Client) {
+		c.budget = budgetState{limit: limit, period: period}
+	}
+}
+
+// BudgetStatus is the state of the WithBudget limit.
+type BudgetStatus struct {
+	Limit   float64       // USD per period (0 = no budget)
+	Spent   float64       // USD spent in the current period
+	Period  time.Duration // Budget period (0 = client lifetime)
+	ResetAt time.Time     // When the current period ends (zero = never or not started)
+}
+
+// budgetState tracks spending against WithBudget. Guarded by Client.mu.
+type budgetState struct {
+	limit  float64
+	period time.Duration
+	spent  float64
+	start  time.Time // start of the current period (zero = no spend yet)
+}
+
+// roll starts a new period if the current one has ended.
+func (b *budgetState) roll(now time.Time) {
+	if b.period <= 0 || b.start.IsZero() {
+		return
+	}
+	if elapsed := now.Sub(b.start); elapsed >= b.period {
+		b.start = b.start.Add(elapsed / b.period * b.period)
+		b.spent = 0
+	}
+}
+
+// add records spend at now.
+func (b *budgetState) add(cost float64, now time.Time) {
+	b.roll(now)
+	if b.start.IsZero() {
+		b.start = now
+	}
+	b.spent += cost
+}
+
+// Budget returns the WithBudget limit and the spend in the current period.
+func (c *Client) Budget() BudgetStatus {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.budget.roll(time.Now())
+	status := BudgetStatus{
+		Limit:  c.budget.limit,
+		Spent:  c.budget.spent,
+		Period: c.budget.period,
+	}
+	if c.budget.period > 0 && !c.budget.start.IsZero() {
+		status.ResetAt = c.budget.start.Add(c.budget.period)
+	}
+	return status
+}
+
+// checkBudget returns ErrBudgetExceeded if the current period's budget is spent.
+func (c *Client) checkBudget() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.budget.limit <= 0 {
+		return nil
+	}
+	c.budget.roll(time.Now())
+	if c.budget.spent >= c.budget.limit {
+		return fmt.Errorf("%w: spent $%.4f of $%.4f", ErrBudgetExceeded, c.budget.spent, c.budget.limit)
+	}
+	return nil
+}
+
+// usageTagsKey is the context key for WithUsageTags.
+type usageTagsKey struct{}
+
+// WithUsageTags returns a context whose calls are also counted under the given
+// tags in Client.UsageByTag, e.g. per customer or feature.
+func WithUsageTags(ctx context.Context, tags ...string) context.Context {
+	existing, _ := ctx.Value(usageTagsKey{}).([]string)
+	merged := append(append([]string(nil), existing...), tags...)
+	return context.WithValue(ctx, usageTagsKey{}, merged)
+}
+
+// UsageByTag returns cumulative usage per tag set with WithUsageTags.
+func (c *Client) UsageByTag() map[string]UsageStats {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	out := make(map[string]UsageStats, len(c.tagUsage))
+	for tag, u := range c.tagUsage {
+		out[tag] = u
+	}
+	return out
+}
+
+// add accumulates u into s.
+func (s *UsageStats) add(u UsageStats) {
+	s.Requests += u.Requests
+	s.InputTokens += u.InputTokens
+	s.OutputTokens += u.OutputTokens
+	s.CacheReadTokens += u.CacheReadTokens
+	s.CacheWriteTokens += u.CacheWriteTokens
+	s.ThinkingTokens += u.ThinkingTokens
+	s.Cost += u.Cost
+}
+
+// CacheHitRate returns the share of cached prompt tokens that were read from
+// the cache rather than written to it: CacheReadTokens / (CacheReadTokens +
+// CacheWriteTokens), or 0 if nothing was cached. A rate near 1 means cached
+// prefixes are reused; a low rate means breakpoints are paid for but missed.
+func (s UsageStats) CacheHitRate() float64 {
+	total := s.CacheReadTokens + s.CacheWriteTokens
+	if total == 0 {
+		return 0
+	}
+	return float64(s.CacheReadTokens) / float64(total)
+}
+
+// recordUsage adds the usage of one call to the client, tag and budget totals.
+func (c *Client) recordUsage(ctx context.Context, u UsageStats) {
+	tags, _ := ctx.Value(usageTagsKey{}).([]string)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.usage.add(u)
+	for _, tag := range tags {
+		if c.tagUsage == nil {
+			c.tagUsage = make(map[string]UsageStats)
+		}
+		t := c.tagUsage[tag]
+		t.add(u)
+		c.tagUsage[tag] = t
+	}
+	if u.Cost > 0 {
+		c.budget.add(u.Cost, time.Now())
+	}
+}
+
+// recordUsage adds the usage of a call made on behalf of the client, such as a
+// truncation summary or a semantic cache embedding, to its totals.
+func (s clientState) recordUsage(ctx context.Context, u UsageStats) {
+	if s.client != nil {
+		s.client.recordUsage(ctx, u)
+	}
+}
+
+// checkBudget returns ErrBudgetExceeded if the client's budget is spent.
+func (s clientState) checkBudget() error {
+	if s.client == nil {
+		return nil
+	}
+	return s.client.checkBudget()
+}
+
+// callUsage returns the usage of one call to model with the given token
+// counts, priced from WithPricing or the catalog.
+func (s clientState) callUsage(model string, input, output int) UsageStats {
+	u := UsageStats{Requests: 1, InputTokens: int64(input), OutputTokens: int64(output)}
+	if model == "" {
+		return u
+	}
+	if p, ok := s.pricingFor(model); ok {
+		u.Cost = p.tokenCost(input, output, 0, 0, 0, false)
+	}
+	return u
+}
+
+// pricingFor returns the price of model from WithPricing or the model catalog.
+func (s clientState) pricingFor(model string) (Pricing, bool) {
+	if model == "" {
+		model = s.model
+	}
+	if p, ok := s.pricing[model]; ok {
+		return p, true
+	}
+	if m, ok := LookupModel(model); ok && m.Pricing != nil {
+		return *m.Pricing, true
+	}
+	return Pricing{}, false
+}
+
+// responseUsage sets resp.Cost (unless the provider reported it) and returns
+// the usage of resp.
+func (s clientState) responseUsage(resp *Response) UsageStats {
+	if resp.Cost == 0 {
+		if p, ok := s.pricingFor(resp.Model); ok {
+			resp.Cost = p.ResponseCost(resp)
+		}
+	}
+	return UsageStats{
+		Requests:         1,
+		InputTokens:      int64(resp.InputTokens),
+		OutputTokens:     int64(resp.OutputTokens),
+		CacheReadTokens:  int64(resp.CacheReadTokens),
+		CacheWriteTokens: int64(resp.CacheWriteTokens),
+		ThinkingTokens:   int64(resp.ThinkingTokens),
+		Cost:             resp.Cost,
+	}
+}
+
+// streamUsage sets u.Cost for a stream of model and returns its usage.
+func (s clientState) streamUsage(model string, u *StreamUsage) UsageStats {
+	if u.Cost == 0 {
+		if p, ok := s.pricingFor(model); ok {
+			u.Cost = p.tokenCost(u.InputTokens, u.OutputTokens, u.CacheReadTokens, u.CacheWriteTokens,
+				u.CacheWrite1hTokens, cacheSeparate(s.provider.Name()))
+		}
+	}
+	return UsageStats{
+		Requests:         1,
+		InputTokens:      int64(u.InputTokens),
+		OutputTokens:     int64(u.OutputTokens),
+		CacheReadTokens:  int64(u.CacheReadTokens),
+		CacheWriteTokens: int64(u.CacheWriteTokens),
+		ThinkingTokens:   int64(u.ThinkingTokens),
+		Cost:             u.Cost,
+	}
+}
@@ -0,0 +1,242 @@
+package allm
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+	"time"
+)
+
+func approx(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+func TestPricingResponseCost(t *testing.T) {
+	p := Pricing{Input: 3, Output: 15, CacheRead: 0.3, CacheWrite: 3.75, BatchDiscount: 0.5}
+	resp := &Response{InputTokens: 1_000_000, OutputTokens: 100_000, CacheReadTokens: 400_000, CacheWriteTokens: 100_000}
+
+	// 500k uncached * $3 + 400k * $0.3 + 100k * $3.75 + 100k * $15
+	want := 1.5 + 0.12 + 0.375 + 1.5
+	if got := p.ResponseCost(resp); !approx(got, want) {
+		t.Errorf("ResponseCost = %v, want %v", got, want)
+	}
+	if got := p.BatchCost(resp); !approx(got, want/2) {
+		t.Errorf("BatchCost = %v, want %v", got, want/2)
+	}
+
+	// Without cache prices, cached tokens cost the input price.
+	plain := Pricing{Input: 2, Output: 8}
+	if got, want := plain.ResponseCost(resp), 2.0+0.8; !approx(got, want) {
+		t.Errorf("ResponseCost without cache prices = %v, want %v", got, want)
+	}
+
+	// Anthropic reports cache tokens in addition to input tokens.
+	anthropic := &Response{Provider: "anthropic", InputTokens: 500_000, OutputTokens: 100_000, CacheReadTokens: 400_000, CacheWriteTokens: 100_000}
+	if got := p.ResponseCost(anthropic); !approx(got, want) {
+		t.Errorf("Anthropic ResponseCost = %v, want %v", got, want)
+	}
+
+	// 1-hour cache writes are charged at CacheWrite1h, or CacheWrite when unset.
+	anthropic.CacheWrite1hTokens = 40_000
+	if got := p.ResponseCost(anthropic); !approx(got, want) {
+		t.Errorf("ResponseCost without CacheWrite1h = %v, want %v", got, want)
+	}
+	p.CacheWrite1h = 6
+	// 60k * $3.75 + 40k * $6 instead of 100k * $3.75
+	if got, want := p.ResponseCost(anthropic), want-0.375+0.225+0.24; !approx(got, want) {
+		t.Errorf("ResponseCost with CacheWrite1h = %v, want %v", got, want)
+	}
+}
+
+func TestChatCostFromCatalog(t *testing.T) {
+	provider := &mockProvider{response: &Response{
+		Content:         "ok",
+		Model:           "claude-haiku-4-5",
+		InputTokens:     2000,
+		OutputTokens:    1000,
+		CacheReadTokens: 1000,
+		ThinkingTokens:  300,
+	}}
+	client := New(provider)
+
+	resp, err := client.Chat(context.Background(), []Message{{Role: RoleUser, Content: "hi"}})
+	if err != nil {
+		t.Fatalf("Chat: %v", err)
+	}
+	// 1000 * $1 + 1000 * $0.1 + 1000 * $5 per million
+	want := (1000*1.0 + 1000*0.1 + 1000*5.0) / 1_000_000
+	if !approx(resp.Cost, want) {
+		t.Errorf("Cost = %v, want %v", resp.Cost, want)
+	}
+
+	usage := client.Usage()
+	if usage.Requests != 1 || usage.CacheReadTokens != 1000 || usage.ThinkingTokens != 300 || !approx(usage.Cost, want) {
+		t.Errorf("usage = %+v", usage)
+	}
+}
+
+func TestWithPricing(t *testing.T) {
+	provider := &mockProvider{response: &Response{Content: "ok", Model: "my-model", InputTokens: 1_000_000}}
+	client := New(provider, WithPricing("my-model", Pricing{Input: 0.5}))
+
+	resp, err := client.Chat(context.Background(), []Message{{Role: RoleUser, Content: "hi"}})
+	if err != nil {
+		t.Fatalf("Chat: %v", err)
+	}
+	if !approx(resp.Cost, 0.5) {
+		t.Errorf("Cost = %v, want 0.5", resp.Cost)
+	}
+
+	// Unknown models have no cost.
+	provider.response = &Response{Content: "ok", Model: "unknown", InputTokens: 1_000_000}
+	resp, _ = client.Chat(context.Background(), []Message{{Role: RoleUser, Content: "hi"}})
+	if resp.Cost != 0 {
+		t.Errorf("Cost = %v, want 0", resp.Cost)
+	}
+}
+
+func TestUsageByTag(t *testing.T) {
+	provider := &mockProvider{response: &Response{Content: "ok", Model: "m", InputTokens: 1_000_000}}
+	client := New(provider, WithPricing("m", Pricing{Input: 1}))
+	msgs := []Message{{Role: RoleUser, Content: "hi"}}
+
+	ctx := WithUsageTags(context.Background(), "team-a")
+	_, _ = client.Chat(ctx, msgs)
+	_, _ = client.Chat(WithUsageTags(ctx, "feature-x"), msgs)
+	_, _ = client.Chat(context.Background(), msgs)
+
+	tags := client.UsageByTag()
+	if tags["team-a"].Requests != 2 || !approx(tags["team-a"].Cost, 2) {
+		t.Errorf("team-a = %+v", tags["team-a"])
+	}
+	if tags["feature-x"].Requests != 1 {
+		t.Errorf("feature-x = %+v", tags["feature-x"])
+	}
+	if got := client.Usage(); got.Requests != 3 || !approx(got.Cost, 3) {
+		t.Errorf("client usage = %+v", got)
+	}
+}
+
+func TestWithBudget(t *testing.T) {
+	provider := &mockProvider{response: &Response{Content: "ok", Model: "m", InputTokens: 600_000}}
+	client := New(provider, WithPricing("m", Pricing{Input: 1}), WithBudget(1, 0))
+	msgs := []Message{{Role: RoleUser, Content: "hi"}}
+
+	for i := 0; i < 2; i++ {
+		if _, err := client.Chat(context.Background(), msgs); err != nil {
+			t.Fatalf("call %d: %v", i, err)
+		}
+	}
+
+	provider.mu.Lock()
+	provider.lastReq = nil
+	provider.mu.Unlock()
+	_, err := client.Chat(context.Background(), msgs)
+	if !errors.Is(err, ErrBudgetExceeded) {
+		t.Fatalf("expected ErrBudgetExceeded, got %v", err)
+	}
+	if provider.getLastReq() != nil {
+		t.Error("request was sent after the budget was exceeded")
+	}
+
+	for chunk := range client.Stream(context.Background(), msgs) {
+		if !errors.Is(chunk.Error, ErrBudgetExceeded) {
+			t.Errorf("stream chunk = %+v, want ErrBudgetExceeded", chunk)
+		}
+	}
+
+	status := client.Budget()
+	if status.Limit != 1 || !approx(status.Spent, 1.2) || !status.ResetAt.IsZero() {
+		t.Errorf("Budget = %+v", status)
+	}
+	if FormatError(err) == FormatError(errors.New("other")) {
+		t.Error("FormatError has no message for ErrBudgetExceeded")
+	}
+}
+
+func TestBudgetPeriod(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	b := budgetState{limit: 1, period: time.Hour}
+
+	b.add(0.5, start)
+	b.add(0.7, start.Add(30*time.Minute))
+	if !approx(b.spent, 1.2) {
+		t.Errorf("spent = %v, want 1.2", b.spent)
+	}
+
+	b.roll(start.Add(150 * time.Minute))
+	if b.spent != 0 || !b.start.Equal(start.Add(2*time.Hour)) {
+		t.Errorf("after roll: spent = %v, start = %v", b.spent, b.start)
+	}
+}
+
+func TestStreamRecordsUsage(t *testing.T) {
+	provider := &mockProvider{chunks: []StreamChunk{
+		{Content: "hi"},
+		{Done: true, Usage: &StreamUsage{InputTokens: 1_000_000, OutputTokens: 1_000_000, CacheReadTokens: 500_000, ThinkingTokens: 300}},
+	}}
+	client := New(provider, WithModel("m"), WithPricing("m", Pricing{Input: 1, Output: 2, CacheRead: 0.1}))
+
+	var final StreamChunk
+	for chunk := range client.Stream(context.Background(), []Message{{Role: RoleUser, Content: "hi"}}) {
+		if chunk.Done {
+			final = chunk
+		}
+	}
+	want := 0.5 + 0.05 + 2
+	if final.Usage == nil || !approx(final.Usage.Cost, want) {
+		t.Fatalf("final usage = %+v, want cost %v", final.Usage, want)
+	}
+	if usage := client.Usage(); usage.Requests != 1 || usage.CacheReadTokens != 500_000 || usage.ThinkingTokens != 300 || !approx(usage.Cost, want) {
+		t.Errorf("usage = %+v", usage)
+	}
+}
+
+func TestRerankRecordsUsage(t *testing.T) {
+	p := &mockProvider{response: &Response{Content: `[{"index": 0, "score": 1}]`, Model: "m", InputTokens: 600_000, OutputTokens: 1000}}
+	client := New(p, WithReranker(NewLLMReranker(p, "m")), WithPricing("m", Pricing{Input: 1}), WithBudget(1, 0))
+	req := &RerankRequest{Query: "q", Documents: []string{"a"}}
+
+	for i := 0; i < 2; i++ {
+		if _, err := client.Rerank(context.Background(), req); err != nil {
+			t.Fatalf("call %d: %v", i, err)
+		}
+	}
+	if usage := client.Usage(); usage.Requests != 2 || usage.InputTokens != 1_200_000 || !approx(usage.Cost, 1.2) {
+		t.Errorf("usage = %+v", usage)
+	}
+	if _, err := client.Rerank(context.Background(), req); !errors.Is(err, ErrBudgetExceeded) {
+		t.Errorf("expected ErrBudgetExceeded, got %v", err)
+	}
+}
+
+// mockBatcher implements BatchProvider.
+type mockBatcher struct {
+	mockProvider
+	batch *Batch
+}
+
+func (m *mockBatcher) CreateBatch(context.Context, []BatchRequest) (*Batch, error) {
+	return m.batch, nil
+}
+
+func (m *mockBatcher) GetBatch(context.Context, string) (*Batch, error) {
+	return m.batch, nil
+}
+
+func TestGetBatchCost(t *testing.T) {
+	provider := &mockBatcher{batch: &Batch{ID: "b1", Results: []BatchResult{
+		{CustomID: "1", Response: &Response{Model: "m", InputTokens: 1_000_000}},
+		{CustomID: "2", Error: errors.New("failed")},
+	}}}
+	client := New(provider, WithPricing("m", Pricing{Input: 2, BatchDiscount: 0.5}))
+
+	batch, err := client.GetBatch(context.Background(), "b1")
+	if err != nil {
+		t.Fatalf("GetBatch: %v", err)
+	}
+	if got := batch.Results[0].Response.Cost; !approx(got, 1) {
+		t.Errorf("batch cost = %v, want 1", got)
+	}
+}
@@ -20,9 +20,13 @@ var providerPrefixes = map[string]ProviderName{
 	"codellama": Local,
 }
 
-// DetectProvider detects the provider name from a model name by matching
-// known substrings. Returns an empty ProviderName if no match is found.
+// DetectProvider detects the provider name from a model name, using the model
+// catalog first and then known substrings. Returns an empty ProviderName if no
+// match is found.
 func DetectProvider(model string) ProviderName {
+	if m, ok := LookupModel(model); ok && m.Provider != "" {
+		return ProviderName(m.Provider)
+	}
 	model = strings.ToLower(model)
 	for prefix, prov := range providerPrefixes {
 		if strings.Contains(model, prefix) {
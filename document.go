@@ -0,0 +1,467 @@
+package allm
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// ErrNoDocumentText is returned by ExtractDocumentText when a document has
+// no extractable text (e.g., a scanned PDF or an unsupported encoding).
+var ErrNoDocumentText = errors.New("allm: no extractable text in document")
+
+// Limits on decompressed PDF content, so that small PDFs cannot inflate to
+// gigabytes in memory.
+const (
+	maxPDFStreamSize = 64 * 1024 * 1024  // per FlateDecode stream
+	maxPDFTotalSize  = 256 * 1024 * 1024 // across all streams of a document
+)
+
+// IsTextDocument reports whether a MIME type can be used as plain text
+// (text/*, JSON, XML, YAML).
+func IsTextDocument(mimeType string) bool {
+	mt := strings.ToLower(strings.TrimSpace(strings.SplitN(mimeType, ";", 2)[0]))
+	switch mt {
+	case "application/json", "application/xml", "application/yaml", "application/x-yaml":
+		return true
+	}
+	return strings.HasPrefix(mt, "text/")
+}
+
+// ExtractDocumentText returns the plain text of a document.
+// Text documents are returned as-is. PDFs are handled by a best-effort,
+// dependency-free extractor that reads the text operators of content streams
+// (uncompressed or FlateDecode); it does not handle OCR, CID font remapping or
+// layout reconstruction. Returns ErrNoDocumentText when nothing was found.
+func ExtractDocumentText(doc Document) (string, error) {
+	if doc.FileID != "" && len(doc.Data) == 0 {
+		return "", fmt.Errorf("%w: text extraction of file references", ErrNotSupported)
+	}
+	if IsTextDocument(doc.MimeType) {
+		if !utf8.Valid(doc.Data) {
+			return "", fmt.Errorf("document %q is not valid UTF-8", doc.Name)
+		}
+		return string(doc.Data), nil
+	}
+	if strings.EqualFold(doc.MimeType, "application/pdf") || bytes.HasPrefix(doc.Data, []byte("%PDF")) {
+		text := strings.TrimSpace(extractPDFText(doc.Data, maxPDFTotalSize))
+		if text == "" {
+			return "", ErrNoDocumentText
+		}
+		return text, nil
+	}
+	return "", fmt.Errorf("%w: text extraction for %s", ErrNotSupported, doc.MimeType)
+}
+
+// extractPDFText extracts text from all content streams in a PDF. Compressed
+// streams are decompressed until limit bytes in total have been inflated;
+// later compressed streams are skipped.
+func extractPDFText(data []byte, limit int64) string {
+	var out strings.Builder
+	rest := data
+	for {
+		i := bytes.Index(rest, []byte("stream"))
+		if i < 0 {
+			break
+		}
+		// Skip "endstream" and other words ending in "stream"
+		if i > 0 && isLetter(rest[i-1]) {
+			rest = rest[i+len("stream"):]
+			continue
+		}
+		dict := pdfStreamDict(rest[:i])
+		body := rest[i+len("stream"):]
+		body = bytes.TrimPrefix(body, []byte("\r"))
+		body = bytes.TrimPrefix(body, []byte("\n"))
+		end := bytes.Index(body, []byte("endstream"))
+		if end < 0 {
+			break
+		}
+		content := body[:end]
+		rest = body[end+len("endstream"):]
+
+		if bytes.Contains(dict, []byte("/FlateDecode")) {
+			if limit <= 0 {
+				continue
+			}
+			decoded, err := io.ReadAll(io.LimitReader(flateReader(content), min(maxPDFStreamSize, limit)))
+			limit -= int64(len(decoded))
+			if err != nil && len(decoded) == 0 {
+				continue
+			}
+			content = decoded
+		} else if bytes.Contains(dict, []byte("/Filter")) {
+			continue // images and other encodings
+		}
+		if bytes.Contains(dict, []byte("/Type/XRef")) || bytes.Contains(dict, []byte("/Type /XRef")) {
+			continue
+		}
+
+		if text := pdfContentText(content); text != "" {
+			if out.Len() > 0 {
+				out.WriteString("\n")
+			}
+			out.WriteString(text)
+		}
+	}
+	return out.String()
+}
+
+// pdfStreamDict returns the dictionary that precedes a stream keyword.
+func pdfStreamDict(before []byte) []byte {
+	start := bytes.LastIndex(before, []byte("obj"))
+	if start < 0 {
+		start = 0
+	}
+	return before[start:]
+}
+
+// flateReader returns a reader that inflates zlib data, tolerating truncation.
+func flateReader(data []byte) io.Reader {
+	r, err := zlib.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return bytes.NewReader(nil)
+	}
+	return r
+}
+
+// pdfContentText interprets the text operators of a content stream.
+// Tj, TJ, ' and " show text; Td, TD, T* and ' start a new line.
+func pdfContentText(content []byte) string {
+	var out strings.Builder
+	var operands []string
+	inText := false
+	newline := func() {
+		if out.Len() > 0 && !strings.HasSuffix(out.String(), "\n") {
+			out.WriteString("\n")
+		}
+	}
+
+	lex := pdfLexer{data: content}
+	for {
+		tok, kind := lex.next()
+		if kind == pdfTokEOF {
+			break
+		}
+		switch kind {
+		case pdfTokString, pdfTokNumber, pdfTokArray:
+			operands = append(operands, tok)
+			continue
+		case pdfTokOther:
+			// names, dicts: operands without text meaning
+			operands = append(operands, "")
+			continue
+		}
+
+		switch tok {
+		case "BT":
+			inText = true
+		case "ET":
+			inText = false
+			newline()
+		case "Tj", "TJ":
+			if inText && len(operands) > 0 {
+				out.WriteString(operands[len(operands)-1])
+			}
+		case "'", "\"":
+			if inText && len(operands) > 0 {
+				newline()
+				out.WriteString(operands[len(operands)-1])
+			}
+		case "T*":
+			newline()
+		case "Td", "TD":
+			if len(operands) >= 2 && parsePDFNumber(operands[len(operands)-1]) != 0 {
+				newline()
+			} else if len(operands) >= 2 && out.Len() > 0 && !strings.HasSuffix(out.String(), " ") {
+				out.WriteString(" ")
+			}
+		}
+		operands = operands[:0]
+	}
+	return strings.TrimSpace(out.String())
+}
+
+// PDF content stream token kinds.
+const (
+	pdfTokEOF = iota
+	pdfTokOperator
+	pdfTokString
+	pdfTokNumber
+	pdfTokArray
+	pdfTokOther
+)
+
+// pdfLexer tokenizes PDF content streams. Strings are decoded to text,
+// TJ arrays are flattened to their text (large negative kerns become spaces).
+type pdfLexer struct {
+	data []byte
+	pos  int
+}
+
+func (l *pdfLexer) next() (string, int) {
+	l.skipSpace()
+	if l.pos >= len(l.data) {
+		return "", pdfTokEOF
+	}
+	c := l.data[l.pos]
+	switch {
+	case c == '(':
+		return l.literal(), pdfTokString
+	case c == '<' && l.peek(1) == '<':
+		l.skipDict()
+		return "", pdfTokOther
+	case c == '<':
+		return l.hex(), pdfTokString
+	case c == '[':
+		return l.array(), pdfTokArray
+	case c == '/':
+		l.pos++
+		l.word()
+		return "", pdfTokOther
+	case c == '-' || c == '+' || c == '.' || (c >= '0' && c <= '9'):
+		return l.word(), pdfTokNumber
+	case c == ']' || c == '>' || c == ')' || c == '{' || c == '}':
+		l.pos++
+		return "", pdfTokOther
+	}
+	if c == '\'' || c == '"' {
+		l.pos++
+		return string(c), pdfTokOperator
+	}
+	return l.word(), pdfTokOperator
+}
+
+func (l *pdfLexer) peek(n int) byte {
+	if l.pos+n < len(l.data) {
+		return l.data[l.pos+n]
+	}
+	return 0
+}
+
+func (l *pdfLexer) skipSpace() {
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+		if c == '%' {
+			for l.pos < len(l.data) && l.data[l.pos] != '\n' && l.data[l.pos] != '\r' {
+				l.pos++
+			}
+			continue
+		}
+		if c != ' ' && c != '\t' && c != '\r' && c != '\n' && c != '\f' && c != 0 {
+			return
+		}
+		l.pos++
+	}
+}
+
+func (l *pdfLexer) word() string {
+	start := l.pos
+	for l.pos < len(l.data) && !strings.ContainsRune(" \t\r\n\f\x00()<>[]{}/%", rune(l.data[l.pos])) {
+		l.pos++
+	}
+	if l.pos == start {
+		l.pos++ // always make progress
+	}
+	return string(l.data[start:l.pos])
+}
+
+func (l *pdfLexer) skipDict() {
+	depth := 0
+	for l.pos < len(l.data) {
+		if l.data[l.pos] == '<' && l.peek(1) == '<' {
+			depth++
+			l.pos += 2
+			continue
+		}
+		if l.data[l.pos] == '>' && l.peek(1) == '>' {
+			depth--
+			l.pos += 2
+			if depth == 0 {
+				return
+			}
+			continue
+		}
+		l.pos++
+	}
+}
+
+// literal reads a (string) with escapes and balanced parentheses.
+func (l *pdfLexer) literal() string {
+	var b []byte
+	depth := 0
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+		l.pos++
+		switch c {
+		case '(':
+			if depth > 0 {
+				b = append(b, c)
+			}
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return pdfDecodeText(b)
+			}
+			b = append(b, c)
+		case '\\':
+			if l.pos >= len(l.data) {
+				break
+			}
+			e := l.data[l.pos]
+			l.pos++
+			switch e {
+			case 'n':
+				b = append(b, '\n')
+			case 'r':
+				b = append(b, '\r')
+			case 't':
+				b = append(b, '\t')
+			case 'b', 'f':
+			case '\r', '\n':
+				// line continuation
+			default:
+				if e >= '0' && e <= '7' {
+					v := int(e - '0')
+					for k := 0; k < 2 && l.pos < len(l.data) && l.data[l.pos] >= '0' && l.data[l.pos] <= '7'; k++ {
+						v = v*8 + int(l.data[l.pos]-'0')
+						l.pos++
+					}
+					b = append(b, byte(v))
+				} else {
+					b = append(b, e)
+				}
+			}
+		default:
+			b = append(b, c)
+		}
+	}
+	return pdfDecodeText(b)
+}
+
+// hex reads a <hex string>.
+func (l *pdfLexer) hex() string {
+	l.pos++ // '<'
+	var b []byte
+	hi := -1
+	for l.pos < len(l.data) && l.data[l.pos] != '>' {
+		v := hexVal(l.data[l.pos])
+		l.pos++
+		if v < 0 {
+			continue
+		}
+		if hi < 0 {
+			hi = v
+		} else {
+			b = append(b, byte(hi<<4|v))
+			hi = -1
+		}
+	}
+	if hi >= 0 {
+		b = append(b, byte(hi<<4))
+	}
+	l.pos++ // '>'
+	return pdfDecodeText(b)
+}
+
+// array reads a TJ array, concatenating strings and turning wide gaps into spaces.
+func (l *pdfLexer) array() string {
+	l.pos++ // '['
+	var out strings.Builder
+	for {
+		l.skipSpace()
+		if l.pos >= len(l.data) {
+			break
+		}
+		if l.data[l.pos] == ']' {
+			l.pos++
+			break
+		}
+		tok, kind := l.next()
+		switch kind {
+		case pdfTokString:
+			out.WriteString(tok)
+		case pdfTokNumber:
+			if parsePDFNumber(tok) < -200 {
+				out.WriteString(" ")
+			}
+		case pdfTokEOF:
+			return out.String()
+		}
+	}
+	return out.String()
+}
+
+// parsePDFNumber parses a numeric operand, returning 0 for malformed input.
+func parsePDFNumber(s string) float64 {
+	n, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0
+	}
+	return n
+}
+
+func isLetter(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func hexVal(c byte) int {
+	switch {
+	case c >= '0' && c <= '9':
+		return int(c - '0')
+	case c >= 'a' && c <= 'f':
+		return int(c-'a') + 10
+	case c >= 'A' && c <= 'F':
+		return int(c-'A') + 10
+	}
+	return -1
+}
+
+// pdfDecodeText decodes a PDF string: UTF-16BE with BOM, two-byte strings
+// with zero high bytes, or single-byte text (Latin-1 approximation).
+func pdfDecodeText(b []byte) string {
+	if len(b) >= 2 && b[0] == 0xFE && b[1] == 0xFF {
+		return decodeUTF16BE(b[2:])
+	}
+	if len(b) >= 2 && len(b)%2 == 0 {
+		wide := true
+		for i := 0; i < len(b); i += 2 {
+			if b[i] != 0 {
+				wide = false
+				break
+			}
+		}
+		if wide {
+			return decodeUTF16BE(b)
+		}
+	}
+	var sb strings.Builder
+	for _, c := range b {
+		if c == '\n' || c == '\t' || c >= 0x20 {
+			sb.WriteRune(rune(c))
+		}
+	}
+	return sb.String()
+}
+
+func decodeUTF16BE(b []byte) string {
+	var sb strings.Builder
+	for i := 0; i+1 < len(b); i += 2 {
+		r := rune(b[i])<<8 | rune(b[i+1])
+		if r >= 0xD800 && r < 0xDC00 && i+3 < len(b) {
+			r2 := rune(b[i+2])<<8 | rune(b[i+3])
+			r = (r-0xD800)<<10 + (r2 - 0xDC00) + 0x10000
+			i += 2
+		}
+		if r == '\n' || r == '\t' || r >= 0x20 {
+			sb.WriteRune(r)
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,112 @@
+package allm
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// buildPDF wraps content streams in a minimal PDF. If compress is true,
+// streams are FlateDecode-encoded.
+func buildPDF(compress bool, streams ...string) []byte {
+	var b bytes.Buffer
+	b.WriteString("%PDF-1.4\n")
+	for i, s := range streams {
+		data := []byte(s)
+		filter := ""
+		if compress {
+			var z bytes.Buffer
+			w := zlib.NewWriter(&z)
+			_, _ = w.Write(data)
+			_ = w.Close()
+			data = z.Bytes()
+			filter = " /Filter /FlateDecode"
+		}
+		fmt.Fprintf(&b, "%d 0 obj\n<< /Length %d%s >>\nstream\n", i+1, len(data), filter)
+		b.Write(data)
+		b.WriteString("\nendstream\nendobj\n")
+	}
+	b.WriteString("%%EOF\n")
+	return b.Bytes()
+}
+
+func TestExtractDocumentTextPDF(t *testing.T) {
+	content := "BT /F1 12 Tf 72 712 Td (Hello \\(world\\)) Tj 0 -14 Td [(Kern)-120(ed) -400 (words)] TJ T* <FEFF00C900740065> Tj ET"
+	for _, compress := range []bool{false, true} {
+		text, err := ExtractDocumentText(Document{MimeType: "application/pdf", Data: buildPDF(compress, content)})
+		if err != nil {
+			t.Fatalf("compress=%v: unexpected error: %v", compress, err)
+		}
+		want := "Hello (world)\nKerned words\nÉte"
+		if text != want {
+			t.Errorf("compress=%v: got %q, want %q", compress, text, want)
+		}
+	}
+}
+
+func TestExtractDocumentTextMultipleStreams(t *testing.T) {
+	pdf := buildPDF(true, "BT (Page one) Tj ET", "q 1 0 0 1 0 0 cm Q", "BT (Page two) Tj ET")
+	text, err := ExtractDocumentText(Document{MimeType: "application/pdf", Data: pdf})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if text != "Page one\nPage two" {
+		t.Errorf("got %q", text)
+	}
+}
+
+func TestExtractPDFTextTotalLimit(t *testing.T) {
+	pdf := buildPDF(true, "BT (Page one) Tj ET", "BT (Page two) Tj ET", "BT (Page three) Tj ET")
+	if text := extractPDFText(pdf, 40); text != "Page one\nPage two" {
+		t.Errorf("got %q", text)
+	}
+	if text := extractPDFText(pdf, 0); text != "" {
+		t.Errorf("expected no text once the limit is spent, got %q", text)
+	}
+}
+
+func TestExtractDocumentTextNoText(t *testing.T) {
+	pdf := buildPDF(false, "q 100 0 0 100 0 0 cm /Im1 Do Q")
+	_, err := ExtractDocumentText(Document{MimeType: "application/pdf", Data: pdf})
+	if !errors.Is(err, ErrNoDocumentText) {
+		t.Errorf("expected ErrNoDocumentText, got %v", err)
+	}
+}
+
+func TestExtractDocumentTextPlain(t *testing.T) {
+	text, err := ExtractDocumentText(Document{MimeType: "text/csv; charset=utf-8", Data: []byte("a,b\n1,2")})
+	if err != nil || text != "a,b\n1,2" {
+		t.Errorf("got %q, %v", text, err)
+	}
+	if _, err := ExtractDocumentText(Document{MimeType: "text/plain", Data: []byte{0xff, 0xfe}}); err == nil {
+		t.Error("expected error for invalid UTF-8")
+	}
+}
+
+func TestExtractDocumentTextUnsupported(t *testing.T) {
+	_, err := ExtractDocumentText(Document{MimeType: "application/msword", Data: []byte("x")})
+	if !errors.Is(err, ErrNotSupported) {
+		t.Errorf("expected ErrNotSupported, got %v", err)
+	}
+	_, err = ExtractDocumentText(Document{FileID: "file-1"})
+	if !errors.Is(err, ErrNotSupported) {
+		t.Errorf("expected ErrNotSupported for file reference, got %v", err)
+	}
+}
+
+func TestExtractDocumentTextMalformed(t *testing.T) {
+	// Truncated streams and unbalanced strings must not panic
+	inputs := [][]byte{
+		[]byte("%PDF-1.4\n1 0 obj\n<< >>\nstream\nBT (unterminated"),
+		[]byte("%PDF-1.4\n1 0 obj\n<< /Filter /FlateDecode >>\nstream\nnot zlib\nendstream"),
+		[]byte("%PDF-1.4\nstream\nBT [(a) <4 ET\nendstream"),
+	}
+	for _, in := range inputs {
+		_, _ = ExtractDocumentText(Document{MimeType: "application/pdf", Data: in})
+	}
+	if !IsTextDocument("application/json") || IsTextDocument("application/pdf") || !IsTextDocument("text/x-go") {
+		t.Error("unexpected IsTextDocument result")
+	}
+}
@@ -20,6 +20,10 @@ func FormatError(err error) string {
 		return "Provider is overloaded. Please try again later."
 	case errors.Is(err, ErrEmptyResponse):
 		return "Received an empty response. Please try again."
+	case errors.Is(err, ErrBudgetExceeded):
+		return "Spending limit reached. Please try again later."
+	case errors.Is(err, ErrContentBlocked):
+		return "This content was blocked by the moderation policy."
 	case errors.Is(err, ErrNotSupported):
 		return "This feature is not supported by the current provider."
 	case errors.Is(err, ErrNoProvider):
@@ -0,0 +1,178 @@
+package allm
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"image"
+	_ "image/gif"  // register decoders for image size estimation
+	_ "image/jpeg" // register decoders for image size estimation
+	_ "image/png"  // register decoders for image size estimation
+	"regexp"
+	"sync"
+)
+
+// Overheads added by TokenEstimator on top of the text it counts, following
+// the chat formats of the major providers.
+const (
+	messageTokenOverhead = 4    // role and message delimiters
+	replyTokenOverhead   = 3    // priming of the assistant reply
+	toolTokenOverhead    = 8    // tool definition framing
+	imageMaxTokens       = 1600 // largest image after provider-side resizing
+	imageMaxEdge         = 1568 // longest image edge after provider-side resizing
+	pdfPageTokens        = 1500 // page without extractable text
+	maxCachedDocuments   = 256  // document counts kept per estimator
+)
+
+// pdfPagePattern matches page objects (but not the /Pages tree) in a PDF.
+var pdfPagePattern = regexp.MustCompile(`/Type\s*/Page\b`)
+
+// TokenEstimator implements TokenCounter offline with a Tokenizer, so any
+// provider can be used with context window management and CountTokens without
+// a network round trip. It counts message text, the system prompt, tool
+// definitions and calls, structured output schemas, images and documents.
+// Counts are estimates; exact counts depend on the provider's tokenizer.
+//
+// Document counts are cached by content, as extracting the text of a PDF
+// means decompressing it. Later calibration of the tokenizer does not change
+// the counts of documents already seen.
+type TokenEstimator struct {
+	tokenizer Tokenizer
+
+	mu   sync.Mutex
+	docs map[[sha256.Size]byte]int // document token counts by content hash
+}
+
+// defaultEstimator is the heuristic estimator used when no TokenCounter is
+// available, shared so that its document cache outlives a single call.
+var defaultEstimator = sync.OnceValue(func() *TokenEstimator { return NewTokenEstimator(nil) })
+
+// NewTokenEstimator returns a TokenEstimator using tok.
+// A nil tok uses a HeuristicTokenizer.
+func NewTokenEstimator(tok Tokenizer) *TokenEstimator {
+	if tok == nil {
+		tok = NewHeuristicTokenizer()
+	}
+	return &TokenEstimator{tokenizer: tok}
+}
+
+// WithTokenCounter sets the TokenCounter used for context window management
+// and CountTokens instead of the provider's, e.g. NewTokenEstimator(bpe) to
+// avoid counting requests over the network.
+func WithTokenCounter(counter TokenCounter) Option {
+	return func(c *Client) {
+		c.tokenCounter = counter
+	}
+}
+
+// CountTokens estimates the input tokens of req.
+func (e *TokenEstimator) CountTokens(_ context.Context, req *Request) (*TokenCount, error) {
+	return &TokenCount{
+		InputTokens: e.EstimateRequest(req),
+		Provider:    "estimate",
+		Model:       req.Model,
+	}, nil
+}
+
+// EstimateRequest returns the estimated input tokens of req.
+func (e *TokenEstimator) EstimateRequest(req *Request) int {
+	total := replyTokenOverhead
+	for _, m := range req.Messages {
+		total += e.EstimateMessage(m)
+	}
+	for _, t := range req.Tools {
+		total += toolTokenOverhead + e.tokenizer.Count(t.Name) + e.tokenizer.Count(t.Description)
+		if len(t.Parameters) > 0 {
+			total += e.countJSON(t.Parameters)
+		}
+	}
+	if req.ResponseFormat != nil && len(req.ResponseFormat.Schema) > 0 {
+		total += e.countJSON(req.ResponseFormat.Schema)
+	}
+	return total
+}
+
+// EstimateMessage returns the estimated tokens of a single message.
+func (e *TokenEstimator) EstimateMessage(m Message) int {
+	total := messageTokenOverhead + e.tokenizer.Count(m.Content)
+	for _, img := range m.Images {
+		total += estimateImageTokens(img)
+	}
+	for _, doc := range m.Documents {
+		total += e.estimateDocumentTokens(doc)
+	}
+	for _, tc := range m.ToolCalls {
+		total += e.tokenizer.Count(tc.Name) + e.tokenizer.Count(string(tc.Arguments))
+	}
+	for _, tr := range m.ToolResults {
+		total += messageTokenOverhead + e.tokenizer.Count(tr.Content)
+	}
+	return total
+}
+
+// countJSON counts the tokens of v serialized as JSON.
+func (e *TokenEstimator) countJSON(v any) int {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return 0
+	}
+	return e.tokenizer.Count(string(data))
+}
+
+// estimateDocumentTokens counts the extractable text of doc, or estimates per
+// page when there is none (scanned PDFs, file references). Counts of inline
+// documents are cached.
+func (e *TokenEstimator) estimateDocumentTokens(doc Document) int {
+	if len(doc.Data) == 0 {
+		return e.countDocument(doc)
+	}
+	h := sha256.New()
+	h.Write([]byte(doc.MimeType))
+	h.Write([]byte{0})
+	h.Write(doc.Data)
+	var key [sha256.Size]byte
+	h.Sum(key[:0])
+
+	e.mu.Lock()
+	n, ok := e.docs[key]
+	e.mu.Unlock()
+	if ok {
+		return n
+	}
+	n = e.countDocument(doc)
+	e.mu.Lock()
+	if e.docs == nil || len(e.docs) >= maxCachedDocuments {
+		e.docs = make(map[[sha256.Size]byte]int)
+	}
+	e.docs[key] = n
+	e.mu.Unlock()
+	return n
+}
+
+// countDocument counts the tokens of doc without the cache.
+func (e *TokenEstimator) countDocument(doc Document) int {
+	if text, err := ExtractDocumentText(doc); err == nil {
+		return e.tokenizer.Count(text)
+	}
+	pages := len(pdfPagePattern.FindAll(doc.Data, -1))
+	return max(pages, 1) * pdfPageTokens
+}
+
+// estimateImageTokens estimates image tokens as width*height/750 after
+// scaling the longest edge down to imageMaxEdge. Images whose size cannot be
+// decoded (WebP, file references) count as imageMaxTokens.
+func estimateImageTokens(img Image) int {
+	if len(img.Data) == 0 {
+		return imageMaxTokens
+	}
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(img.Data))
+	if err != nil || cfg.Width <= 0 || cfg.Height <= 0 {
+		return imageMaxTokens
+	}
+	w, h := float64(cfg.Width), float64(cfg.Height)
+	if edge := max(w, h); edge > imageMaxEdge {
+		w, h = w*imageMaxEdge/edge, h*imageMaxEdge/edge
+	}
+	return min(max(int(w*h/750), 1), imageMaxTokens)
+}
@@ -3,6 +3,7 @@ package allm
 import (
 	"context"
 	"errors"
+	"strings"
 	"testing"
 )
 
@@ -343,7 +344,8 @@ func (m *mockImageGenerator) GenerateImage(ctx context.Context, req *ImageReques
 	}, nil
 }
 
-// TestTruncateMessagesWithoutTokenCounter tests truncation without token counting support
+// TestTruncateMessagesWithoutTokenCounter tests that truncation falls back to
+// the offline estimator when the provider cannot count tokens
 func TestTruncateMessagesWithoutTokenCounter(t *testing.T) {
 	provider := &mockProvider{}
 	s := clientState{
@@ -356,13 +358,26 @@ func TestTruncateMessagesWithoutTokenCounter(t *testing.T) {
 		{Role: RoleUser, Content: "message 2"},
 	}
 
-	// Should return messages unchanged when provider doesn't support token counting
-	truncated, err := truncateMessages(context.Background(), s, messages)
-	if err != nil {
-		t.Errorf("truncateMessages should not error when provider doesn't support counting: %v", err)
+	// Short messages fit within the estimated budget
+	truncated, dropped, err := truncateMessages(context.Background(), s, messages)
+	if err != nil || dropped != 0 || len(truncated) != len(messages) {
+		t.Errorf("truncateMessages = %d messages, %d dropped, %v; want all messages kept", len(truncated), dropped, err)
 	}
-	if len(truncated) != len(messages) {
-		t.Errorf("truncateMessages should return unchanged messages when provider doesn't support counting")
+
+	// Long messages exceed the estimated budget
+	long := []Message{
+		{Role: RoleUser, Content: strings.Repeat("word ", 100)},
+		{Role: RoleUser, Content: strings.Repeat("word ", 100)},
+	}
+	if _, _, err := truncateMessages(context.Background(), s, long); err == nil || !strings.Contains(err.Error(), "context exceeds max tokens") {
+		t.Errorf("expected estimated count over the limit, got %v", err)
+	}
+
+	s.maxContextTokens = 150
+	s.truncationStrategy = TruncateTail
+	truncated, dropped, err = truncateMessages(context.Background(), s, long)
+	if err != nil || dropped != 1 || len(truncated) != 1 {
+		t.Errorf("truncateMessages = %d messages, %d dropped, %v; want the oldest dropped", len(truncated), dropped, err)
 	}
 }
 
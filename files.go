@@ -0,0 +1,203 @@
+package allm
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// File purpose constants for FileUploadRequest.Purpose.
+// Anthropic ignores the purpose; OpenAI requires one (default: user_data).
+const (
+	FilePurposeUserData = "user_data" // General input for chat/responses (OpenAI default)
+	FilePurposeVision   = "vision"    // Images for vision input
+	FilePurposeBatch    = "batch"     // Batch API input
+)
+
+// File describes a file stored with a provider.
+type File struct {
+	ID        string    // Provider file ID, used as Document.FileID / Image.FileID
+	Name      string    // Original filename
+	MimeType  string    // MIME type (may be empty if the provider does not report it)
+	Size      int64     // Size in bytes
+	Purpose   string    // Purpose (OpenAI only)
+	CreatedAt time.Time // Upload time
+	Provider  string    // Provider name
+}
+
+// Document returns a Document that references this file by ID.
+func (f *File) Document() Document {
+	return Document{MimeType: f.MimeType, Name: f.Name, FileID: f.ID}
+}
+
+// Image returns an Image that references this file by ID.
+func (f *File) Image() Image {
+	return Image{MimeType: f.MimeType, FileID: f.ID}
+}
+
+// FileUploadRequest contains the file to upload.
+type FileUploadRequest struct {
+	Name     string // Filename (required)
+	MimeType string // MIME type (e.g., "application/pdf")
+	Data     []byte // File contents
+	Purpose  string // Upload purpose (empty = FilePurposeUserData)
+}
+
+// FileStore is an optional interface for uploading files once and
+// referencing them by ID in later requests.
+// Supported by: Anthropic (Files API, beta), OpenAI.
+type FileStore interface {
+	// UploadFile uploads a file.
+	UploadFile(ctx context.Context, req *FileUploadRequest) (*File, error)
+	// ListFiles lists stored files.
+	ListFiles(ctx context.Context) ([]File, error)
+	// GetFile returns metadata for a file.
+	GetFile(ctx context.Context, fileID string) (*File, error)
+	// DeleteFile deletes a file.
+	DeleteFile(ctx context.Context, fileID string) error
+	// DownloadFile returns the contents of a file.
+	DownloadFile(ctx context.Context, fileID string) ([]byte, error)
+}
+
+// UploadFile uploads a file to the provider.
+// Returns an error if the provider does not support file storage.
+func (c *Client) UploadFile(ctx context.Context, req *FileUploadRequest) (*File, error) {
+	s := c.snapshot()
+
+	store, err := s.fileStore()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := validateFileUpload(req); err != nil {
+		return nil, err
+	}
+
+	if s.logger != nil {
+		s.logger.Debug("upload file request",
+			"provider", s.provider.Name(),
+			"mime_type", req.MimeType,
+			"bytes", len(req.Data),
+		)
+	}
+
+	op := Operation{Name: OperationUploadFile, Provider: s.provider.Name()}
+	return observe(ctx, s, op, true, func(ctx context.Context) (*File, error) {
+		return store.UploadFile(ctx, req)
+	})
+}
+
+// ListFiles lists the files stored with the provider.
+// Returns an error if the provider does not support file storage.
+func (c *Client) ListFiles(ctx context.Context) ([]File, error) {
+	s := c.snapshot()
+
+	store, err := s.fileStore()
+	if err != nil {
+		return nil, err
+	}
+
+	if s.logger != nil {
+		s.logger.Debug("list files request", "provider", s.provider.Name())
+	}
+
+	op := Operation{Name: OperationListFiles, Provider: s.provider.Name()}
+	return observe(ctx, s, op, true, func(ctx context.Context) ([]File, error) {
+		return store.ListFiles(ctx)
+	})
+}
+
+// GetFile returns metadata for a stored file.
+// Returns an error if the provider does not support file storage.
+func (c *Client) GetFile(ctx context.Context, fileID string) (*File, error) {
+	s := c.snapshot()
+
+	store, err := s.fileStore()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := validateFileID(fileID); err != nil {
+		return nil, err
+	}
+
+	if s.logger != nil {
+		s.logger.Debug("get file request",
+			"provider", s.provider.Name(),
+			"file_id", fileID,
+		)
+	}
+
+	op := Operation{Name: OperationGetFile, Provider: s.provider.Name()}
+	return observe(ctx, s, op, true, func(ctx context.Context) (*File, error) {
+		return store.GetFile(ctx, fileID)
+	})
+}
+
+// DeleteFile deletes a stored file.
+// Returns an error if the provider does not support file storage.
+func (c *Client) DeleteFile(ctx context.Context, fileID string) error {
+	s := c.snapshot()
+
+	store, err := s.fileStore()
+	if err != nil {
+		return err
+	}
+
+	if err := validateFileID(fileID); err != nil {
+		return err
+	}
+
+	if s.logger != nil {
+		s.logger.Debug("delete file request",
+			"provider", s.provider.Name(),
+			"file_id", fileID,
+		)
+	}
+
+	op := Operation{Name: OperationDeleteFile, Provider: s.provider.Name()}
+	_, err = observe(ctx, s, op, true, func(ctx context.Context) (struct{}, error) {
+		return struct{}{}, store.DeleteFile(ctx, fileID)
+	})
+	return err
+}
+
+// DownloadFile returns the contents of a stored file.
+// Anthropic only allows downloading files created by tools or skills, not uploads.
+// Returns an error if the provider does not support file storage.
+func (c *Client) DownloadFile(ctx context.Context, fileID string) ([]byte, error) {
+	s := c.snapshot()
+
+	store, err := s.fileStore()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := validateFileID(fileID); err != nil {
+		return nil, err
+	}
+
+	if s.logger != nil {
+		s.logger.Debug("download file request",
+			"provider", s.provider.Name(),
+			"file_id", fileID,
+		)
+	}
+
+	op := Operation{Name: OperationDownloadFile, Provider: s.provider.Name()}
+	return observe(ctx, s, op, true, func(ctx context.Context) ([]byte, error) {
+		return store.DownloadFile(ctx, fileID)
+	})
+}
+
+// fileStore resolves the provider's FileStore implementation.
+func (s clientState) fileStore() (FileStore, error) {
+	if s.provider == nil {
+		return nil, ErrNoProvider
+	}
+	store, ok := s.provider.(FileStore)
+	if !ok {
+		return nil, fmt.Errorf("%w: file storage", ErrNotSupported)
+	}
+	return store, nil
+}
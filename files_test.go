@@ -0,0 +1,151 @@
+package allm
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// mockFileStore implements both Provider and FileStore
+type mockFileStore struct {
+	mockProvider
+	files map[string][]byte
+}
+
+func (m *mockFileStore) UploadFile(_ context.Context, req *FileUploadRequest) (*File, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.files == nil {
+		m.files = make(map[string][]byte)
+	}
+	id := "file-" + req.Name
+	m.files[id] = req.Data
+	return &File{ID: id, Name: req.Name, MimeType: req.MimeType, Size: int64(len(req.Data)), Provider: m.name}, nil
+}
+
+func (m *mockFileStore) ListFiles(_ context.Context) ([]File, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var files []File
+	for id, data := range m.files {
+		files = append(files, File{ID: id, Size: int64(len(data))})
+	}
+	return files, nil
+}
+
+func (m *mockFileStore) GetFile(_ context.Context, fileID string) (*File, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	data, ok := m.files[fileID]
+	if !ok {
+		return nil, ErrProvider
+	}
+	return &File{ID: fileID, Size: int64(len(data))}, nil
+}
+
+func (m *mockFileStore) DeleteFile(_ context.Context, fileID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.files, fileID)
+	return nil
+}
+
+func (m *mockFileStore) DownloadFile(_ context.Context, fileID string) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.files[fileID], nil
+}
+
+func TestFileStoreRoundTrip(t *testing.T) {
+	p := &mockFileStore{mockProvider: mockProvider{name: "test", available: true}}
+	c := New(p)
+	ctx := context.Background()
+
+	f, err := c.UploadFile(ctx, &FileUploadRequest{Name: "doc.pdf", MimeType: "application/pdf", Data: []byte("%PDF")})
+	if err != nil {
+		t.Fatalf("upload: %v", err)
+	}
+	if f.ID != "file-doc.pdf" || f.Size != 4 {
+		t.Errorf("unexpected file: %+v", f)
+	}
+
+	files, err := c.ListFiles(ctx)
+	if err != nil || len(files) != 1 {
+		t.Fatalf("list: %v, %d files", err, len(files))
+	}
+
+	data, err := c.DownloadFile(ctx, f.ID)
+	if err != nil || string(data) != "%PDF" {
+		t.Errorf("download: %v, %q", err, data)
+	}
+
+	if err := c.DeleteFile(ctx, f.ID); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if _, err := c.GetFile(ctx, f.ID); err == nil {
+		t.Error("expected error for deleted file")
+	}
+}
+
+func TestFileStoreNotSupported(t *testing.T) {
+	c := New(&mockProvider{name: "test", available: true})
+	_, err := c.ListFiles(context.Background())
+	if !errors.Is(err, ErrNotSupported) {
+		t.Errorf("expected ErrNotSupported, got %v", err)
+	}
+	_, err = New(nil).GetFile(context.Background(), "file-1")
+	if !errors.Is(err, ErrNoProvider) {
+		t.Errorf("expected ErrNoProvider, got %v", err)
+	}
+}
+
+func TestFileStoreValidation(t *testing.T) {
+	p := &mockFileStore{mockProvider: mockProvider{name: "test", available: true}}
+	c := New(p)
+	ctx := context.Background()
+
+	if _, err := c.UploadFile(ctx, &FileUploadRequest{Name: "a.txt"}); !errors.Is(err, ErrEmptyInput) {
+		t.Errorf("expected ErrEmptyInput for empty data, got %v", err)
+	}
+	if _, err := c.UploadFile(ctx, &FileUploadRequest{Data: []byte("x")}); err == nil {
+		t.Error("expected error for missing name")
+	}
+	if _, err := c.GetFile(ctx, ""); !errors.Is(err, ErrEmptyInput) {
+		t.Errorf("expected ErrEmptyInput for empty ID, got %v", err)
+	}
+	if err := c.DeleteFile(ctx, "../admin"); err == nil {
+		t.Error("expected error for file ID with path characters")
+	}
+	if _, err := c.DownloadFile(ctx, strings.Repeat("x", MaxFileIDLength+1)); err == nil {
+		t.Error("expected error for overlong file ID")
+	}
+}
+
+func TestFileReferencesInMessages(t *testing.T) {
+	p := &mockProvider{name: "test", available: true, response: &Response{Content: "ok"}}
+	c := New(p)
+	f := &File{ID: "file-abc", Name: "report.pdf", MimeType: "application/pdf"}
+
+	_, err := c.Chat(context.Background(), []Message{{
+		Role:      RoleUser,
+		Content:   "Summarize",
+		Documents: []Document{f.Document()},
+		Images:    []Image{{FileID: "file-img"}},
+	}})
+	if err != nil {
+		t.Fatalf("file references without data should be accepted: %v", err)
+	}
+	doc := p.getLastReq().Messages[0].Documents[0]
+	if doc.FileID != "file-abc" || doc.Name != "report.pdf" {
+		t.Errorf("unexpected document: %+v", doc)
+	}
+
+	_, err = c.Chat(context.Background(), []Message{{
+		Role:   RoleUser,
+		Images: []Image{{FileID: "bad id"}},
+	}})
+	if err == nil {
+		t.Error("expected error for invalid image file ID")
+	}
+}
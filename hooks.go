@@ -0,0 +1,76 @@
+package allm
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+)
+
+// correlationIDKey is the context key for WithCorrelationID.
+type correlationIDKey struct{}
+
+// WithCorrelationID returns a context whose client calls report id as the
+// HookEvent.CorrelationID and Operation.CorrelationID, e.g. to tie events to
+// an incoming request. Calls without one get a random ID per operation.
+func WithCorrelationID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, correlationIDKey{}, id)
+}
+
+// CorrelationID returns the correlation ID of ctx, or "" if none is set.
+func CorrelationID(ctx context.Context) string {
+	id, _ := ctx.Value(correlationIDKey{}).(string)
+	return id
+}
+
+// newCorrelationID returns a random 16-character hex ID.
+func newCorrelationID() string {
+	var b [8]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// emit sends event to the client's hooks, filling in the operation, provider,
+// model and correlation ID from s and ctx.
+func (s clientState) emit(ctx context.Context, event HookEvent) {
+	if len(s.hooks) == 0 {
+		return
+	}
+	if o, ok := ctx.Value(observationKey{}).(observation); ok {
+		if event.Operation == "" {
+			event.Operation = o.operation.Name
+		}
+		if event.Model == "" {
+			event.Model = o.operation.Model
+		}
+	}
+	if event.Provider == "" && s.provider != nil {
+		event.Provider = s.provider.Name()
+	}
+	event.CorrelationID = CorrelationID(ctx)
+	for _, hook := range s.hooks {
+		hook(event)
+	}
+}
+
+// resultEvent returns the HookSuccess or HookError event for result.
+func resultEvent(result OperationResult, latency time.Duration) HookEvent {
+	event := HookEvent{
+		Type:             HookSuccess,
+		Model:            result.Model,
+		RequestID:        result.ID,
+		Latency:          latency,
+		InputTokens:      result.InputTokens,
+		OutputTokens:     result.OutputTokens,
+		CacheReadTokens:  result.CacheReadTokens,
+		CacheWriteTokens: result.CacheWriteTokens,
+		Error:            result.Error,
+	}
+	if result.Error != nil {
+		event.Type = HookError
+	}
+	if len(result.FinishReasons) > 0 {
+		event.FinishReason = result.FinishReasons[0]
+	}
+	return event
+}
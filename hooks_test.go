@@ -0,0 +1,160 @@
+package allm
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// eventRecorder collects hook events.
+type eventRecorder struct {
+	mu     sync.Mutex
+	events []HookEvent
+}
+
+func (r *eventRecorder) hook(e HookEvent) {
+	r.mu.Lock()
+	r.events = append(r.events, e)
+	r.mu.Unlock()
+}
+
+func (r *eventRecorder) types() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var types []string
+	for _, e := range r.events {
+		types = append(types, e.Type)
+	}
+	return types
+}
+
+func TestMultipleHooks(t *testing.T) {
+	var first, second eventRecorder
+	p := &mockProvider{name: "test", response: &Response{Content: "ok"}}
+	c := New(p, WithHook(first.hook), WithHook(second.hook, nil))
+
+	if _, err := c.Complete(context.Background(), "hi"); err != nil {
+		t.Fatalf("Complete: %v", err)
+	}
+	if len(first.events) != 2 || len(second.events) != 2 {
+		t.Errorf("events = %d, %d; want 2 each", len(first.events), len(second.events))
+	}
+}
+
+func TestHookChatEvents(t *testing.T) {
+	var rec eventRecorder
+	p := &mockProvider{name: "test", response: &Response{
+		Content:         "",
+		Model:           "m-1",
+		RequestID:       "req_1",
+		InputTokens:     10,
+		CacheReadTokens: 4,
+		FinishReason:    "tool_use",
+		ToolCalls:       []ToolCall{{ID: "1", Name: "lookup", Arguments: json.RawMessage(`{}`)}},
+	}}
+	c := New(p, WithModel("m"), WithHook(rec.hook))
+
+	ctx := WithCorrelationID(context.Background(), "corr-1")
+	if _, err := c.Complete(ctx, "hi"); err != nil {
+		t.Fatalf("Complete: %v", err)
+	}
+
+	if got := strings.Join(rec.types(), ","); got != "request,success,tool_call" {
+		t.Fatalf("events = %s", got)
+	}
+	success := rec.events[1]
+	if success.Operation != OperationChat || success.CorrelationID != "corr-1" || success.RequestID != "req_1" ||
+		success.CacheReadTokens != 4 || success.FinishReason != "tool_use" || success.Model != "m-1" {
+		t.Errorf("success event = %+v", success)
+	}
+	if rec.events[0].Model != "m" || rec.events[2].ToolName != "lookup" || rec.events[2].CorrelationID != "corr-1" {
+		t.Errorf("events = %+v", rec.events)
+	}
+}
+
+func TestHookCorrelationIDGenerated(t *testing.T) {
+	var rec eventRecorder
+	c := New(&mockProvider{name: "test", response: &Response{Content: "ok"}}, WithHook(rec.hook))
+
+	_, _ = c.Complete(context.Background(), "a")
+	_, _ = c.Complete(context.Background(), "b")
+
+	ids := []string{rec.events[0].CorrelationID, rec.events[1].CorrelationID, rec.events[2].CorrelationID}
+	if ids[0] == "" || ids[0] != ids[1] || ids[1] == ids[2] {
+		t.Errorf("correlation IDs = %v; want one per call", ids)
+	}
+}
+
+func TestHookStreamEvents(t *testing.T) {
+	var rec eventRecorder
+	p := &mockProvider{name: "test", chunks: []StreamChunk{
+		{Content: "a"},
+		{ToolUse: &StreamToolUse{Name: "Read"}},
+		{Done: true, Usage: &StreamUsage{InputTokens: 5, OutputTokens: 2}},
+	}}
+	c := New(p, WithHook(rec.hook))
+
+	for range c.Stream(context.Background(), []Message{{Role: RoleUser, Content: "hi"}}) {
+	}
+
+	if got := strings.Join(rec.types(), ","); got != "stream_start,first_token,tool_call,stream_end" {
+		t.Fatalf("events = %s", got)
+	}
+	end := rec.events[3]
+	if end.Operation != OperationStream || end.InputTokens != 5 || end.OutputTokens != 2 || end.Error != nil {
+		t.Errorf("stream end = %+v", end)
+	}
+}
+
+func TestHookOperations(t *testing.T) {
+	var rec eventRecorder
+	p := &mockBatcher{batch: &Batch{ID: "b1"}}
+	p.name = "test"
+	c := New(p, WithTokenCounter(NewTokenEstimator(nil)), WithHook(rec.hook))
+
+	if _, err := c.CreateBatch(context.Background(), []BatchRequest{{CustomID: "1", Messages: []Message{{Role: RoleUser, Content: "hi"}}}}); err != nil {
+		t.Fatalf("CreateBatch: %v", err)
+	}
+	if _, err := c.CountTokens(context.Background(), []Message{{Role: RoleUser, Content: "hi"}}); err != nil {
+		t.Fatalf("CountTokens: %v", err)
+	}
+
+	want := []struct{ typ, op string }{
+		{HookRequest, OperationCreateBatch},
+		{HookSuccess, OperationCreateBatch},
+		{HookRequest, OperationCountTokens},
+		{HookSuccess, OperationCountTokens},
+	}
+	if len(rec.events) != len(want) {
+		t.Fatalf("events = %v", rec.types())
+	}
+	for i, w := range want {
+		if e := rec.events[i]; e.Type != w.typ || e.Operation != w.op {
+			t.Errorf("event %d = %s/%s, want %s/%s", i, e.Type, e.Operation, w.typ, w.op)
+		}
+	}
+	if rec.events[1].RequestID != "b1" {
+		t.Errorf("batch event = %+v", rec.events[1])
+	}
+}
+
+func TestHookTruncation(t *testing.T) {
+	var rec eventRecorder
+	c := New(&mockProvider{name: "test", response: &Response{Content: "ok"}},
+		WithMaxContextTokens(120), WithTruncationStrategy(TruncateTail), WithHook(rec.hook))
+
+	long := strings.Repeat("word ", 60)
+	msgs := []Message{
+		{Role: RoleUser, Content: long},
+		{Role: RoleAssistant, Content: long},
+		{Role: RoleUser, Content: "latest"},
+	}
+	if _, err := c.Chat(context.Background(), msgs); err != nil {
+		t.Fatalf("Chat: %v", err)
+	}
+	if rec.events[0].Type != HookTruncation || rec.events[0].TruncatedMessages != 1 {
+		t.Errorf("events = %+v", rec.events)
+	}
+}
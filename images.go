@@ -0,0 +1,175 @@
+package allm
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ImageEditRequest represents an image edit (inpainting) or variation request.
+// With a prompt, the source images are edited (masked areas only, if a mask
+// is set). Without a prompt, a variation of the single source image is created
+// (dall-e-2).
+type ImageEditRequest struct {
+	Prompt            string  // Edit instructions (empty = create variations)
+	Images            []Image // Source images (gpt-image models accept up to 16)
+	Mask              *Image  // Optional PNG mask; fully transparent areas are edited
+	Model             string  // Image model (empty = provider default)
+	Size              string  // Output size (e.g., "1024x1024")
+	Quality           string  // Image quality (gpt-image models)
+	N                 int     // Number of images to generate
+	ResponseFormat    string  // ImageResponseURL or ImageResponseB64JSON
+	Background        string  // "transparent", "opaque" or "auto" (gpt-image models)
+	OutputFormat      string  // "png", "jpeg" or "webp" (gpt-image models)
+	OutputCompression int     // Compression 0-100 for jpeg/webp output (gpt-image models)
+}
+
+// ImageEditor is an optional interface for editing images and creating variations.
+// Supported by: OpenAI (gpt-image-1, dall-e-2).
+type ImageEditor interface {
+	// EditImage edits source images or creates variations of one.
+	EditImage(ctx context.Context, req *ImageEditRequest) (*ImageResponse, error)
+}
+
+// EditImage edits images (optionally within a mask) or creates variations.
+// Returns an error if the provider does not support image editing.
+func (c *Client) EditImage(ctx context.Context, req *ImageEditRequest) (*ImageResponse, error) {
+	s := c.snapshot()
+
+	if s.provider == nil {
+		return nil, ErrNoProvider
+	}
+
+	editor, ok := s.provider.(ImageEditor)
+	if !ok {
+		return nil, fmt.Errorf("%w: image editing", ErrNotSupported)
+	}
+
+	if err := validateImageEditRequest(req); err != nil {
+		return nil, err
+	}
+
+	if s.logger != nil {
+		s.logger.Debug("edit image request",
+			"provider", s.provider.Name(),
+			"model", req.Model,
+			"images", len(req.Images),
+			"mask", req.Mask != nil,
+		)
+	}
+
+	op := Operation{Name: OperationEditImage, Provider: s.provider.Name(), Model: req.Model}
+	resp, err := observe(ctx, s, op, true, func(ctx context.Context) (*ImageResponse, error) {
+		return editor.EditImage(ctx, req)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := s.downloadImages(ctx, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// WithImageDownload fetches URL-only image results into GeneratedImage.Data
+// for GenerateImage and EditImage. Downloads larger than maxBytes fail;
+// maxBytes <= 0 uses MaxImageSize. Only http and https URLs are fetched, and
+// redirects are followed only to https URLs.
+func WithImageDownload(maxBytes int64) Option {
+	return func(c *Client) {
+		if maxBytes <= 0 {
+			maxBytes = MaxImageSize
+		}
+		c.imageDownload = maxBytes
+	}
+}
+
+// downloadImages fills Data for images that only have a URL, if enabled.
+func (s clientState) downloadImages(ctx context.Context, resp *ImageResponse) error {
+	if s.imageDownload <= 0 || resp == nil {
+		return nil
+	}
+	for i := range resp.Images {
+		img := &resp.Images[i]
+		if len(img.Data) > 0 || img.URL == "" {
+			continue
+		}
+		dlCtx, cancel := context.WithTimeout(ctx, s.timeout)
+		data, mimeType, err := downloadImage(dlCtx, img.URL, s.imageDownload)
+		cancel()
+		if err != nil {
+			return fmt.Errorf("download image %d: %w", i, err)
+		}
+		img.Data = data
+		if img.MimeType == "" {
+			img.MimeType = mimeType
+		}
+	}
+	return nil
+}
+
+// maxImageRedirects is the number of redirects an image download may follow.
+const maxImageRedirects = 3
+
+// imageHTTPClient downloads images. The URLs come from the provider's
+// response, so it follows only a few redirects, only to https, and gives up
+// after a fixed time even if the context has no deadline.
+var imageHTTPClient = &http.Client{
+	Timeout: 5 * time.Minute,
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		if len(via) > maxImageRedirects {
+			return fmt.Errorf("stopped after %d redirects", maxImageRedirects)
+		}
+		if req.URL.Scheme != "https" {
+			return fmt.Errorf("refusing redirect to non-https URL %s", req.URL.Redacted())
+		}
+		return nil
+	},
+}
+
+// downloadImage fetches an image URL with a size limit.
+func downloadImage(ctx context.Context, rawURL string, maxBytes int64) ([]byte, string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid image URL: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, "", fmt.Errorf("image URL must use http or https scheme, got: %s", u.Scheme)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	httpResp, err := imageHTTPClient.Do(httpReq)
+	if err != nil {
+		return nil, "", classifyError(err, ctx)
+	}
+	defer func() { _ = httpResp.Body.Close() }()
+
+	if httpResp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("%w: image download returned status %d", ErrProvider, httpResp.StatusCode)
+	}
+	if httpResp.ContentLength > maxBytes {
+		return nil, "", fmt.Errorf("image exceeds download limit of %d bytes (%d bytes)", maxBytes, httpResp.ContentLength)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(httpResp.Body, maxBytes+1))
+	if err != nil {
+		return nil, "", classifyError(err, ctx)
+	}
+	if int64(len(data)) > maxBytes {
+		return nil, "", fmt.Errorf("image exceeds download limit of %d bytes", maxBytes)
+	}
+
+	mimeType, _, _ := mime.ParseMediaType(httpResp.Header.Get("Content-Type"))
+	if !strings.HasPrefix(mimeType, "image/") {
+		mimeType = http.DetectContentType(data)
+	}
+	return data, mimeType, nil
+}
@@ -0,0 +1,169 @@
+package allm
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// mockImageEditor implements Provider, ImageGenerator and ImageEditor,
+// returning images at a fixed URL.
+type mockImageEditor struct {
+	mockProvider
+	url      string
+	lastEdit *ImageEditRequest
+}
+
+func (m *mockImageEditor) GenerateImage(_ context.Context, req *ImageRequest) (*ImageResponse, error) {
+	return &ImageResponse{Images: []GeneratedImage{{URL: m.url}}, Model: req.Model}, nil
+}
+
+func (m *mockImageEditor) EditImage(_ context.Context, req *ImageEditRequest) (*ImageResponse, error) {
+	m.mu.Lock()
+	m.lastEdit = req
+	m.mu.Unlock()
+	return &ImageResponse{Images: []GeneratedImage{{URL: m.url}, {Data: []byte("inline"), MimeType: "image/webp"}}}, nil
+}
+
+func TestEditImage(t *testing.T) {
+	p := &mockImageEditor{mockProvider: mockProvider{name: "test", available: true}, url: "https://example.com/a.png"}
+	c := New(p)
+
+	src := ImageFromBytes("image/png", []byte("png"))
+	resp, err := c.EditImage(context.Background(), &ImageEditRequest{
+		Prompt: "add a hat",
+		Images: []Image{src},
+		Mask:   &Image{MimeType: "image/png", Data: []byte("mask")},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(resp.Images) != 2 || resp.Images[0].Data != nil {
+		t.Errorf("expected URL result without download, got %+v", resp.Images)
+	}
+	if p.lastEdit.Mask == nil {
+		t.Error("mask not passed to provider")
+	}
+}
+
+func TestEditImageValidation(t *testing.T) {
+	p := &mockImageEditor{mockProvider: mockProvider{name: "test", available: true}}
+	c := New(p)
+	img := Image{MimeType: "image/png", Data: []byte("png")}
+
+	tests := []struct {
+		name string
+		req  *ImageEditRequest
+	}{
+		{"nil", nil},
+		{"no images", &ImageEditRequest{Prompt: "x"}},
+		{"empty image", &ImageEditRequest{Prompt: "x", Images: []Image{{MimeType: "image/png"}}}},
+		{"variation with two images", &ImageEditRequest{Images: []Image{img, img}}},
+		{"variation with mask", &ImageEditRequest{Images: []Image{img}, Mask: &img}},
+		{"bad mime", &ImageEditRequest{Prompt: "x", Images: []Image{{MimeType: "image/tiff", Data: []byte("x")}}}},
+		{"too many sources", &ImageEditRequest{Prompt: "x", Images: make([]Image, MaxImageEditSources+1)}},
+		{"transparent jpeg", &ImageEditRequest{Prompt: "x", Images: []Image{img}, Background: "transparent", OutputFormat: "jpeg"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := c.EditImage(context.Background(), tt.req); err == nil {
+				t.Error("expected validation error")
+			}
+		})
+	}
+
+	if _, err := c.EditImage(context.Background(), &ImageEditRequest{Images: []Image{img}}); err != nil {
+		t.Errorf("variation request should be valid: %v", err)
+	}
+}
+
+func TestEditImageNotSupported(t *testing.T) {
+	c := New(&mockImageGenerator{})
+	_, err := c.EditImage(context.Background(), &ImageEditRequest{Prompt: "x", Images: []Image{{Data: []byte("x")}}})
+	if !errors.Is(err, ErrNotSupported) {
+		t.Errorf("expected ErrNotSupported, got %v", err)
+	}
+}
+
+func TestGenerateImageOptionsValidation(t *testing.T) {
+	c := New(&mockImageGenerator{})
+	tests := []struct {
+		name string
+		opt  ImageOption
+	}{
+		{"response format", WithImageResponseFormat("gif")},
+		{"background", WithImageBackground("green")},
+		{"output format", WithImageOutputFormat("bmp", 0)},
+		{"compression", WithImageOutputFormat("jpeg", 101)},
+		{"style", WithImageStyle("cartoon")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := c.GenerateImage(context.Background(), "a cat", tt.opt); err == nil {
+				t.Error("expected validation error")
+			}
+		})
+	}
+
+	_, err := c.GenerateImage(context.Background(), "a cat",
+		WithImageResponseFormat(ImageResponseB64JSON),
+		WithImageBackground("transparent"),
+		WithImageOutputFormat("webp", 80),
+		WithImageStyle("natural"),
+	)
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestWithImageDownload(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/big.png" {
+			w.Header().Set("Content-Type", "image/png")
+			_, _ = w.Write([]byte(strings.Repeat("x", 100)))
+			return
+		}
+		w.Header().Set("Content-Type", "image/png")
+		_, _ = w.Write([]byte("\x89PNG"))
+	}))
+	defer srv.Close()
+
+	p := &mockImageEditor{mockProvider: mockProvider{name: "test", available: true}, url: srv.URL + "/a.png"}
+	c := New(p, WithImageDownload(50))
+
+	resp, err := c.GenerateImage(context.Background(), "a cat")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(resp.Images[0].Data) != "\x89PNG" || resp.Images[0].MimeType != "image/png" {
+		t.Errorf("expected downloaded data, got %+v", resp.Images[0])
+	}
+
+	resp, err = c.EditImage(context.Background(), &ImageEditRequest{Prompt: "x", Images: []Image{{Data: []byte("x")}}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(resp.Images[1].Data) != "inline" || resp.Images[1].MimeType != "image/webp" {
+		t.Errorf("inline image should be left alone, got %+v", resp.Images[1])
+	}
+
+	p.url = srv.URL + "/big.png"
+	if _, err := c.GenerateImage(context.Background(), "a cat"); err == nil || !strings.Contains(err.Error(), "download limit") {
+		t.Errorf("expected download limit error, got %v", err)
+	}
+
+	redirect := httptest.NewServer(http.RedirectHandler(srv.URL+"/a.png", http.StatusFound))
+	defer redirect.Close()
+	p.url = redirect.URL
+	if _, err := c.GenerateImage(context.Background(), "a cat"); err == nil || !strings.Contains(err.Error(), "non-https") {
+		t.Errorf("expected redirect to http to be refused, got %v", err)
+	}
+
+	p.url = "file:///etc/passwd"
+	if _, err := c.GenerateImage(context.Background(), "a cat"); err == nil {
+		t.Error("expected error for non-http URL")
+	}
+}
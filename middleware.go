@@ -0,0 +1,186 @@
+package allm
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Middleware wraps the provider that Chat and Stream requests are sent to, to
+// rewrite requests, post-process responses or add cross-cutting behavior:
+//
+//	redact := allm.CompleteMiddleware(func(next allm.CompleteFunc) allm.CompleteFunc {
+//		return func(ctx context.Context, req *allm.Request) (*allm.Response, error) {
+//			resp, err := next(ctx, req)
+//			if err == nil {
+//				resp.Content = scrub(resp.Content)
+//			}
+//			return resp, err
+//		}
+//	})
+//	client := allm.New(provider.Anthropic(""), allm.WithMiddleware(redact))
+//
+// The returned Provider must forward Name and Available to next; embedding
+// next in a struct does that. Optional provider interfaces (Embedder,
+// BatchProvider, ...) are always used on the client's provider directly.
+type Middleware func(next Provider) Provider
+
+// CompleteFunc is the signature of Provider.Complete.
+type CompleteFunc func(ctx context.Context, req *Request) (*Response, error)
+
+// StreamFunc is the signature of Provider.Stream.
+type StreamFunc func(ctx context.Context, req *Request) <-chan StreamChunk
+
+// CompleteMiddleware returns a Middleware wrapping only Complete; streams
+// pass through unchanged.
+func CompleteMiddleware(fn func(next CompleteFunc) CompleteFunc) Middleware {
+	return func(next Provider) Provider {
+		return &middlewareProvider{Provider: next, complete: fn(next.Complete), stream: next.Stream}
+	}
+}
+
+// StreamMiddleware returns a Middleware wrapping only Stream; Complete calls
+// pass through unchanged.
+func StreamMiddleware(fn func(next StreamFunc) StreamFunc) Middleware {
+	return func(next Provider) Provider {
+		return &middlewareProvider{Provider: next, complete: next.Complete, stream: fn(next.Stream)}
+	}
+}
+
+// WithMiddleware appends middlewares to the chain Chat and Stream requests go
+// through. The first middleware is the outermost: it sees the request built
+// by the client first and the response last. The built-in stages run inside
+// all middlewares, in this order: the response cache (see WithCache), request
+// validation, logging, retries with backoff (Complete only) and the provider.
+// Middlewares therefore run once per call, not once per retry, and rewritten
+// requests are still validated.
+func WithMiddleware(mw ...Middleware) Option {
+	return func(c *Client) {
+		for _, m := range mw {
+			if m != nil {
+				c.middleware = append(c.middleware, m)
+			}
+		}
+	}
+}
+
+// middlewareProvider is a Provider with its Complete and Stream replaced.
+type middlewareProvider struct {
+	Provider
+	complete CompleteFunc
+	stream   StreamFunc
+}
+
+func (p *middlewareProvider) Complete(ctx context.Context, req *Request) (*Response, error) {
+	return p.complete(ctx, req)
+}
+
+func (p *middlewareProvider) Stream(ctx context.Context, req *Request) <-chan StreamChunk {
+	return p.stream(ctx, req)
+}
+
+// chain returns the provider of s wrapped in the built-in stages and the
+// client's middlewares.
+func (s clientState) chain() Provider {
+	p := retryMiddleware(s)(s.provider)
+	p = loggingMiddleware(s)(p)
+	p = validationMiddleware(s)(p)
+	if s.cache != nil {
+		p = cacheMiddleware(s)(p)
+	}
+	for i := len(s.middleware) - 1; i >= 0; i-- {
+		p = s.middleware[i](p)
+	}
+	return p
+}
+
+// validationMiddleware rejects requests with invalid parameters.
+func validationMiddleware(s clientState) Middleware {
+	validate := func(req *Request) error {
+		if err := validateRequest(req); err != nil {
+			if s.logger != nil {
+				s.logger.Debug("request validation failed", "error", err)
+			}
+			return fmt.Errorf("invalid request: %w", err)
+		}
+		return nil
+	}
+	return func(next Provider) Provider {
+		return &middlewareProvider{
+			Provider: next,
+			complete: func(ctx context.Context, req *Request) (*Response, error) {
+				if err := validate(req); err != nil {
+					return nil, err
+				}
+				return next.Complete(ctx, req)
+			},
+			stream: func(ctx context.Context, req *Request) <-chan StreamChunk {
+				if err := validate(req); err != nil {
+					out := make(chan StreamChunk, 1)
+					out <- StreamChunk{Error: err}
+					close(out)
+					return out
+				}
+				return next.Stream(ctx, req)
+			},
+		}
+	}
+}
+
+// loggingMiddleware logs request metadata and outcomes. Content is never logged.
+func loggingMiddleware(s clientState) Middleware {
+	return func(next Provider) Provider {
+		if s.logger == nil {
+			return next
+		}
+		return &middlewareProvider{
+			Provider: next,
+			complete: func(ctx context.Context, req *Request) (*Response, error) {
+				s.logger.Debug("chat request", requestMeta(req.Messages, s)...)
+				start := time.Now()
+				resp, err := next.Complete(ctx, req)
+				logOutcome(s, "chat", resp, err, time.Since(start))
+				return resp, err
+			},
+			stream: func(ctx context.Context, req *Request) <-chan StreamChunk {
+				s.logger.Debug("stream starting", requestMeta(req.Messages, s)...)
+				in := next.Stream(ctx, req)
+				out := make(chan StreamChunk)
+				go func() {
+					defer close(out)
+					var chunkCount int
+					for chunk := range in {
+						chunkCount++
+						switch {
+						case chunk.Error != nil:
+							s.logger.Debug("stream error", "provider", s.provider.Name(), "chunks", chunkCount, "error", sanitizeError(chunk.Error))
+						case chunk.Done:
+							s.logger.Debug("stream done", "provider", s.provider.Name(), "chunks", chunkCount)
+						}
+						select {
+						case out <- chunk:
+						case <-ctx.Done():
+							// Drain in so the provider's goroutine can finish.
+							for range in {
+							}
+							return
+						}
+					}
+				}()
+				return out
+			},
+		}
+	}
+}
+
+// retryMiddleware retries transient Complete errors with exponential backoff.
+// Streams are not retried, as chunks may already have been delivered.
+func retryMiddleware(s clientState) Middleware {
+	return CompleteMiddleware(func(next CompleteFunc) CompleteFunc {
+		return func(ctx context.Context, req *Request) (*Response, error) {
+			return retry(ctx, s, func(attemptCtx context.Context) (*Response, error) {
+				return next(attemptCtx, req)
+			}, "chat")
+		}
+	})
+}
@@ -0,0 +1,180 @@
+package allm
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// tagMiddleware appends name to the order list on each Complete call and
+// suffixes the response content with it.
+func tagMiddleware(name string, mu *sync.Mutex, order *[]string) Middleware {
+	return CompleteMiddleware(func(next CompleteFunc) CompleteFunc {
+		return func(ctx context.Context, req *Request) (*Response, error) {
+			mu.Lock()
+			*order = append(*order, name)
+			mu.Unlock()
+			resp, err := next(ctx, req)
+			if err == nil {
+				resp.Content += name
+			}
+			return resp, err
+		}
+	})
+}
+
+func TestMiddlewareOrder(t *testing.T) {
+	var mu sync.Mutex
+	var order []string
+	p := &mockProvider{name: "test", response: &Response{Content: "ok:"}}
+	c := New(p, WithMiddleware(tagMiddleware("a", &mu, &order), tagMiddleware("b", &mu, &order)))
+
+	resp, err := c.Complete(context.Background(), "hi")
+	if err != nil {
+		t.Fatalf("Complete: %v", err)
+	}
+	if strings.Join(order, ",") != "a,b" {
+		t.Errorf("order = %v, want a,b", order)
+	}
+	if resp.Content != "ok:ba" {
+		t.Errorf("content = %q, want inner middleware applied first", resp.Content)
+	}
+}
+
+func TestMiddlewareRewritesRequest(t *testing.T) {
+	p := &mockProvider{name: "test", response: &Response{Content: "ok"}}
+	rewrite := CompleteMiddleware(func(next CompleteFunc) CompleteFunc {
+		return func(ctx context.Context, req *Request) (*Response, error) {
+			req.Model = "rewritten"
+			return next(ctx, req)
+		}
+	})
+	c := New(p, WithModel("m"), WithMiddleware(rewrite))
+
+	if _, err := c.Complete(context.Background(), "hi"); err != nil {
+		t.Fatalf("Complete: %v", err)
+	}
+	if got := p.getLastReq().Model; got != "rewritten" {
+		t.Errorf("model = %q, want rewritten", got)
+	}
+}
+
+func TestMiddlewareRequestValidated(t *testing.T) {
+	p := &mockProvider{name: "test", response: &Response{Content: "ok"}}
+	invalid := CompleteMiddleware(func(next CompleteFunc) CompleteFunc {
+		return func(ctx context.Context, req *Request) (*Response, error) {
+			req.Temperature = 5
+			return next(ctx, req)
+		}
+	})
+	c := New(p, WithMiddleware(invalid))
+
+	_, err := c.Complete(context.Background(), "hi")
+	if err == nil || !strings.Contains(err.Error(), "invalid request") {
+		t.Fatalf("expected invalid request error, got %v", err)
+	}
+	if p.getLastReq() != nil {
+		t.Error("invalid request reached the provider")
+	}
+}
+
+func TestMiddlewareOncePerCall(t *testing.T) {
+	var mu sync.Mutex
+	var order []string
+	p := &failNProvider{name: "test", failCount: 2, failErr: ErrServerError, response: &Response{Content: "ok"}}
+	c := New(p, WithMaxRetries(2), WithRetryBaseDelay(time.Millisecond),
+		WithMiddleware(tagMiddleware("mw", &mu, &order)))
+
+	if _, err := c.Complete(context.Background(), "hi"); err != nil {
+		t.Fatalf("Complete: %v", err)
+	}
+	if len(order) != 1 || p.calls != 3 {
+		t.Errorf("middleware calls = %d, provider calls = %d; want 1 and 3", len(order), p.calls)
+	}
+}
+
+func TestStreamMiddleware(t *testing.T) {
+	p := &mockProvider{name: "test", chunks: []StreamChunk{
+		{Content: "a"},
+		{Content: "b"},
+		{Done: true},
+	}}
+	upper := StreamMiddleware(func(next StreamFunc) StreamFunc {
+		return func(ctx context.Context, req *Request) <-chan StreamChunk {
+			out := make(chan StreamChunk)
+			go func() {
+				defer close(out)
+				for chunk := range next(ctx, req) {
+					chunk.Content = strings.ToUpper(chunk.Content)
+					out <- chunk
+				}
+			}()
+			return out
+		}
+	})
+	c := New(p, WithMiddleware(upper), WithLogger(&mockLogger{}))
+
+	var content strings.Builder
+	for chunk := range c.Stream(context.Background(), []Message{{Role: RoleUser, Content: "hi"}}) {
+		if chunk.Error != nil {
+			t.Fatalf("stream error: %v", chunk.Error)
+		}
+		content.WriteString(chunk.Content)
+	}
+	if content.String() != "AB" {
+		t.Errorf("content = %q, want AB", content.String())
+	}
+}
+
+// blockingStreamer sends chunks without watching the context and closes done
+// once its stream goroutine returns.
+type blockingStreamer struct {
+	mockProvider
+	done chan struct{}
+}
+
+func (p *blockingStreamer) Stream(_ context.Context, _ *Request) <-chan StreamChunk {
+	ch := make(chan StreamChunk)
+	go func() {
+		defer close(p.done)
+		defer close(ch)
+		for _, chunk := range []StreamChunk{{Content: "a"}, {Content: "b"}, {Done: true}} {
+			ch <- chunk
+		}
+	}()
+	return ch
+}
+
+func TestLoggingMiddlewareDrainsOnCancel(t *testing.T) {
+	p := &blockingStreamer{mockProvider: mockProvider{name: "test"}, done: make(chan struct{})}
+	s := New(p, WithLogger(&mockLogger{})).snapshot()
+	ctx, cancel := context.WithCancel(context.Background())
+
+	out := loggingMiddleware(s)(p).Stream(ctx, &Request{})
+	<-out
+	cancel()
+
+	select {
+	case <-p.done:
+	case <-time.After(time.Second):
+		t.Fatal("provider stream blocked after cancellation")
+	}
+}
+
+func TestMiddlewareShortCircuit(t *testing.T) {
+	p := &mockProvider{name: "test", err: errors.New("unreachable")}
+	canned := CompleteMiddleware(func(CompleteFunc) CompleteFunc {
+		return func(context.Context, *Request) (*Response, error) {
+			return &Response{Content: "canned"}, nil
+		}
+	})
+	c := New(p, WithMiddleware(nil, canned))
+
+	resp, err := c.Complete(context.Background(), "hi")
+	if err != nil || resp.Content != "canned" {
+		t.Fatalf("Complete = %v, %v", resp, err)
+	}
+}
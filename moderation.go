@@ -0,0 +1,293 @@
+package allm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ErrContentBlocked is returned when WithModeration blocks input or output.
+// Use errors.As with *ContentBlockedError to get the categories that fired.
+var ErrContentBlocked = errors.New("allm: content blocked by moderation")
+
+// Moderation action constants.
+const (
+	ModerationBlock = "block" // reject the call with ErrContentBlocked
+	ModerationFlag  = "flag"  // let the call through and report categories on the response
+)
+
+// Moderation stage constants, reported in ContentBlockedError.Stage.
+const (
+	ModerationStageInput  = "input"
+	ModerationStageOutput = "output"
+)
+
+// ModerationRequest contains parameters for a moderation request.
+type ModerationRequest struct {
+	Input []string // Texts to classify
+	Model string   // Moderation model (empty = provider default)
+}
+
+// ModerationResult is the classification of a single input.
+type ModerationResult struct {
+	Flagged    bool               // True if the provider flagged any category
+	Categories map[string]bool    // Category → flagged by the provider
+	Scores     map[string]float64 // Category → confidence score (0-1)
+}
+
+// ModerationResponse contains the moderation result.
+type ModerationResponse struct {
+	Results  []ModerationResult // One result per input
+	Model    string             // Model used
+	Provider string             // Provider name
+	Latency  time.Duration      // Request latency
+}
+
+// Moderator is an optional interface for content moderation.
+// Supported by: OpenAI (/moderations).
+type Moderator interface {
+	// Moderate classifies texts for policy violations.
+	Moderate(ctx context.Context, req *ModerationRequest) (*ModerationResponse, error)
+}
+
+// ContentBlockedError reports which moderation categories blocked a call.
+// It unwraps to ErrContentBlocked.
+type ContentBlockedError struct {
+	Stage      string   // ModerationStageInput or ModerationStageOutput
+	Categories []string // Categories that fired, sorted
+}
+
+func (e *ContentBlockedError) Error() string {
+	return fmt.Sprintf("%s (%s): %s", ErrContentBlocked.Error(), e.Stage, strings.Join(e.Categories, ", "))
+}
+
+func (e *ContentBlockedError) Unwrap() error {
+	return ErrContentBlocked
+}
+
+// ModerationPolicy configures the content guard installed by WithModeration.
+type ModerationPolicy struct {
+	Action     string             // ModerationBlock (default) or ModerationFlag
+	Input      bool               // Check new user input before Chat/Stream dispatch
+	Output     bool               // Check model output after the call
+	Threshold  float64            // Score threshold for all categories (0 = use the provider's flagged categories)
+	Thresholds map[string]float64 // Per-category thresholds, override Threshold (e.g., "violence": 0.7)
+	Model      string             // Moderation model (empty = provider default)
+	Moderator  Moderator          // Moderation backend (nil = the client's provider)
+	FailOpen   bool               // Let calls through when the moderation request fails (default: fail closed)
+}
+
+// WithModeration screens Chat and Stream traffic with a Moderator.
+// If neither Input nor Output is set, both are checked.
+//
+// Input checks cover the user messages of the newest turn (after the last
+// assistant message), so earlier history is not re-screened on every call.
+// Output checks run on the final text; for streams this happens when the
+// stream finishes, and a block replaces the Done chunk with an error.
+//
+// The policy needs a backend: policy.Moderator, or a provider that implements
+// Moderator (OpenAI). Without one, for example with Anthropic alone, every
+// screened call fails with ErrNotSupported, regardless of FailOpen.
+func WithModeration(policy ModerationPolicy) Option {
+	return func(c *Client) {
+		if !policy.Input && !policy.Output {
+			policy.Input = true
+			policy.Output = true
+		}
+		if policy.Action == "" {
+			policy.Action = ModerationBlock
+		}
+		c.moderation = &policy
+	}
+}
+
+// Moderate classifies one or more texts for policy violations.
+// Uses the moderator from WithModeration if set, otherwise the provider.
+// Returns an error if neither supports moderation.
+func (c *Client) Moderate(ctx context.Context, input ...string) (*ModerationResponse, error) {
+	s := c.snapshot()
+
+	if s.provider == nil {
+		return nil, ErrNoProvider
+	}
+
+	moderator, model, err := s.moderator()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(input) == 0 {
+		return nil, ErrEmptyInput
+	}
+	for i, text := range input {
+		if len(text) > s.maxInputLen {
+			return nil, fmt.Errorf("input %d exceeds max length", i)
+		}
+	}
+
+	if s.logger != nil {
+		s.logger.Debug("moderation request",
+			"provider", s.provider.Name(),
+			"model", model,
+			"inputs", len(input),
+		)
+	}
+
+	if err := s.checkBudget(); err != nil {
+		return nil, err
+	}
+
+	req := &ModerationRequest{Input: input, Model: model}
+	op := Operation{Name: OperationModerate, Provider: s.provider.Name(), Model: model}
+	resp, err := observe(ctx, s, op, false, func(ctx context.Context) (*ModerationResponse, error) {
+		return retryWithBackoff(ctx, s, func(attemptCtx context.Context) (*ModerationResponse, error) {
+			return moderator.Moderate(attemptCtx, req)
+		}, "moderation")
+	})
+	if err == nil {
+		s.recordUsage(ctx, UsageStats{Requests: 1})
+	}
+	return resp, err
+}
+
+// moderator resolves the moderation backend and model for a snapshot.
+func (s clientState) moderator() (Moderator, string, error) {
+	var model string
+	if s.moderation != nil {
+		model = s.moderation.Model
+		if s.moderation.Moderator != nil {
+			return s.moderation.Moderator, model, nil
+		}
+	}
+	m, ok := s.provider.(Moderator)
+	if !ok {
+		return nil, "", fmt.Errorf("%w: moderation", ErrNotSupported)
+	}
+	return m, model, nil
+}
+
+// moderationInput returns the user texts of the newest turn.
+func moderationInput(messages []Message) []string {
+	start := 0
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Role == RoleAssistant {
+			start = i + 1
+			break
+		}
+	}
+	var texts []string
+	for _, m := range messages[start:] {
+		if m.Role == RoleUser && m.Content != "" {
+			texts = append(texts, m.Content)
+		}
+	}
+	return texts
+}
+
+// applyModeration checks texts against the client's moderation policy.
+// In block mode it returns a *ContentBlockedError when a category fires.
+// In flag mode it returns the categories that fired.
+func applyModeration(ctx context.Context, s clientState, stage string, texts []string) ([]string, error) {
+	if len(texts) == 0 {
+		return nil, nil
+	}
+	policy := s.moderation
+
+	moderator, model, err := s.moderator()
+	if err != nil {
+		return nil, err
+	}
+
+	modCtx, cancel := context.WithTimeout(ctx, s.timeout)
+	resp, err := moderator.Moderate(modCtx, &ModerationRequest{Input: texts, Model: model})
+	if err != nil {
+		err = classifyError(err, modCtx)
+	}
+	cancel()
+	if err != nil {
+		if s.logger != nil {
+			s.logger.Warn("moderation request failed",
+				"provider", s.provider.Name(),
+				"stage", stage,
+				"fail_open", policy.FailOpen,
+				"error", sanitizeError(err),
+			)
+		}
+		if policy.FailOpen {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("moderation: %w", err)
+	}
+
+	// Moderation endpoints report no token usage.
+	s.recordUsage(ctx, UsageStats{Requests: 1})
+
+	categories := policy.firedCategories(resp.Results)
+	if len(categories) == 0 {
+		return nil, nil
+	}
+
+	if s.logger != nil {
+		s.logger.Warn("moderation triggered",
+			"provider", s.provider.Name(),
+			"stage", stage,
+			"action", policy.Action,
+			"categories", categories,
+		)
+	}
+
+	if policy.Action == ModerationFlag {
+		return categories, nil
+	}
+	return nil, &ContentBlockedError{Stage: stage, Categories: categories}
+}
+
+// firedCategories returns the sorted, de-duplicated categories that exceed
+// the policy thresholds in any result.
+func (p *ModerationPolicy) firedCategories(results []ModerationResult) []string {
+	fired := make(map[string]bool)
+	for _, r := range results {
+		for cat, score := range r.Scores {
+			threshold := p.Threshold
+			if t, ok := p.Thresholds[cat]; ok {
+				threshold = t
+			}
+			if threshold > 0 && score >= threshold {
+				fired[cat] = true
+			}
+		}
+		for cat, flagged := range r.Categories {
+			_, hasOwn := p.Thresholds[cat]
+			if flagged && p.Threshold == 0 && !hasOwn {
+				fired[cat] = true
+			}
+		}
+	}
+
+	categories := make([]string, 0, len(fired))
+	for cat := range fired {
+		categories = append(categories, cat)
+	}
+	sort.Strings(categories)
+	return categories
+}
+
+// mergeFlags returns the sorted union of two category lists.
+func mergeFlags(a, b []string) []string {
+	if len(b) == 0 {
+		return a
+	}
+	seen := make(map[string]bool, len(a)+len(b))
+	var merged []string
+	for _, cat := range append(append([]string{}, a...), b...) {
+		if !seen[cat] {
+			seen[cat] = true
+			merged = append(merged, cat)
+		}
+	}
+	sort.Strings(merged)
+	return merged
+}
@@ -0,0 +1,272 @@
+package allm
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// mockModerator implements both Provider and Moderator.
+// Texts containing "bad" are flagged for "violence" with score 0.9;
+// texts containing "meh" score 0.4 for "harassment" without being flagged.
+type mockModerator struct {
+	mockProvider
+	modMu    sync.Mutex
+	modCalls [][]string
+	modErr   error
+}
+
+func (m *mockModerator) Moderate(_ context.Context, req *ModerationRequest) (*ModerationResponse, error) {
+	m.modMu.Lock()
+	m.modCalls = append(m.modCalls, req.Input)
+	m.modMu.Unlock()
+	if m.modErr != nil {
+		return nil, m.modErr
+	}
+	resp := &ModerationResponse{Provider: m.name}
+	for _, text := range req.Input {
+		r := ModerationResult{
+			Categories: map[string]bool{"violence": false, "harassment": false},
+			Scores:     map[string]float64{"violence": 0.01, "harassment": 0.01},
+		}
+		if strings.Contains(text, "bad") {
+			r.Flagged = true
+			r.Categories["violence"] = true
+			r.Scores["violence"] = 0.9
+		}
+		if strings.Contains(text, "meh") {
+			r.Scores["harassment"] = 0.4
+		}
+		resp.Results = append(resp.Results, r)
+	}
+	return resp, nil
+}
+
+func (m *mockModerator) calls() [][]string {
+	m.modMu.Lock()
+	defer m.modMu.Unlock()
+	return m.modCalls
+}
+
+func TestModerateSupported(t *testing.T) {
+	p := &mockModerator{mockProvider: mockProvider{name: "test", available: true}}
+	c := New(p)
+
+	resp, err := c.Moderate(context.Background(), "fine", "bad words")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(resp.Results) != 2 || resp.Results[0].Flagged || !resp.Results[1].Flagged {
+		t.Errorf("unexpected results: %+v", resp.Results)
+	}
+}
+
+func TestModerateNotSupported(t *testing.T) {
+	c := New(&mockProvider{name: "test", available: true})
+	_, err := c.Moderate(context.Background(), "hello")
+	if !errors.Is(err, ErrNotSupported) {
+		t.Errorf("expected ErrNotSupported, got %v", err)
+	}
+}
+
+func TestModerateNoProviderAndEmptyInput(t *testing.T) {
+	if _, err := New(nil).Moderate(context.Background(), "x"); !errors.Is(err, ErrNoProvider) {
+		t.Errorf("expected ErrNoProvider, got %v", err)
+	}
+	p := &mockModerator{mockProvider: mockProvider{name: "test", available: true}}
+	if _, err := New(p).Moderate(context.Background()); !errors.Is(err, ErrEmptyInput) {
+		t.Errorf("expected ErrEmptyInput, got %v", err)
+	}
+}
+
+func TestModerationBlocksInput(t *testing.T) {
+	p := &mockModerator{mockProvider: mockProvider{name: "test", available: true, response: &Response{Content: "OK"}}}
+	c := New(p, WithModeration(ModerationPolicy{}))
+
+	_, err := c.Complete(context.Background(), "something bad")
+	if !errors.Is(err, ErrContentBlocked) {
+		t.Fatalf("expected ErrContentBlocked, got %v", err)
+	}
+	var blocked *ContentBlockedError
+	if !errors.As(err, &blocked) {
+		t.Fatal("expected *ContentBlockedError")
+	}
+	if blocked.Stage != ModerationStageInput || !reflect.DeepEqual(blocked.Categories, []string{"violence"}) {
+		t.Errorf("unexpected blocked error: %+v", blocked)
+	}
+	if p.getLastReq() != nil {
+		t.Error("provider should not be called for blocked input")
+	}
+}
+
+func TestModerationBlocksOutput(t *testing.T) {
+	p := &mockModerator{mockProvider: mockProvider{name: "test", available: true, response: &Response{Content: "a bad answer"}}}
+	c := New(p, WithModeration(ModerationPolicy{Output: true}))
+
+	_, err := c.Complete(context.Background(), "hello")
+	var blocked *ContentBlockedError
+	if !errors.As(err, &blocked) || blocked.Stage != ModerationStageOutput {
+		t.Fatalf("expected output block, got %v", err)
+	}
+	if calls := p.calls(); len(calls) != 1 || calls[0][0] != "a bad answer" {
+		t.Errorf("expected only output to be moderated, got %v", calls)
+	}
+}
+
+func TestModerationFlagMode(t *testing.T) {
+	p := &mockModerator{mockProvider: mockProvider{name: "test", available: true, response: &Response{Content: "OK"}}}
+	c := New(p, WithModeration(ModerationPolicy{Action: ModerationFlag}))
+
+	resp, err := c.Complete(context.Background(), "bad")
+	if err != nil {
+		t.Fatalf("flag mode should not block: %v", err)
+	}
+	if !reflect.DeepEqual(resp.ModerationFlags, []string{"violence"}) {
+		t.Errorf("expected violence flag, got %v", resp.ModerationFlags)
+	}
+}
+
+func TestModerationThresholds(t *testing.T) {
+	p := &mockModerator{mockProvider: mockProvider{name: "test", available: true, response: &Response{Content: "OK"}}}
+
+	// Per-category threshold fires on a score the provider did not flag.
+	c := New(p, WithModeration(ModerationPolicy{Input: true, Thresholds: map[string]float64{"harassment": 0.3}}))
+	_, err := c.Complete(context.Background(), "meh")
+	var blocked *ContentBlockedError
+	if !errors.As(err, &blocked) || !reflect.DeepEqual(blocked.Categories, []string{"harassment"}) {
+		t.Fatalf("expected harassment block, got %v", err)
+	}
+
+	// A global threshold above the score lets flagged content through.
+	c = New(p, WithModeration(ModerationPolicy{Input: true, Threshold: 0.95}))
+	if _, err := c.Complete(context.Background(), "bad"); err != nil {
+		t.Errorf("expected pass under high threshold, got %v", err)
+	}
+}
+
+func TestModerationInputNewestTurnOnly(t *testing.T) {
+	p := &mockModerator{mockProvider: mockProvider{name: "test", available: true, response: &Response{Content: "OK"}}}
+	c := New(p, WithModeration(ModerationPolicy{Input: true}))
+
+	_, err := c.Chat(context.Background(), []Message{
+		{Role: RoleUser, Content: "bad old message"},
+		{Role: RoleAssistant, Content: "reply"},
+		{Role: RoleUser, Content: "new question"},
+	})
+	if err != nil {
+		t.Fatalf("history should not be re-screened: %v", err)
+	}
+	if calls := p.calls(); len(calls) != 1 || !reflect.DeepEqual(calls[0], []string{"new question"}) {
+		t.Errorf("unexpected moderation input: %v", calls)
+	}
+}
+
+func TestModerationFailClosedAndOpen(t *testing.T) {
+	modErr := errors.New("moderation down")
+	p := &mockModerator{
+		mockProvider: mockProvider{name: "test", available: true, response: &Response{Content: "OK"}},
+		modErr:       modErr,
+	}
+
+	c := New(p, WithModeration(ModerationPolicy{Input: true}))
+	if _, err := c.Complete(context.Background(), "hi"); !errors.Is(err, modErr) {
+		t.Errorf("expected fail closed with moderation error, got %v", err)
+	}
+
+	c = New(p, WithModeration(ModerationPolicy{Input: true, FailOpen: true}))
+	if _, err := c.Complete(context.Background(), "hi"); err != nil {
+		t.Errorf("expected fail open, got %v", err)
+	}
+}
+
+func TestModerationSeparateModerator(t *testing.T) {
+	mod := &mockModerator{mockProvider: mockProvider{name: "mod", available: true}}
+	p := &mockProvider{name: "test", available: true, response: &Response{Content: "OK"}}
+	c := New(p, WithModeration(ModerationPolicy{Moderator: mod}))
+
+	if _, err := c.Complete(context.Background(), "bad"); !errors.Is(err, ErrContentBlocked) {
+		t.Errorf("expected block from dedicated moderator, got %v", err)
+	}
+}
+
+func TestModerationWithoutBackend(t *testing.T) {
+	p := &mockProvider{name: "test", available: true, response: &Response{Content: "OK"}}
+	c := New(p, WithModeration(ModerationPolicy{FailOpen: true}))
+
+	if _, err := c.Complete(context.Background(), "hi"); !errors.Is(err, ErrNotSupported) {
+		t.Errorf("expected ErrNotSupported, got %v", err)
+	}
+	if p.getLastReq() != nil {
+		t.Error("request was sent without a moderation backend")
+	}
+}
+
+func TestModerationStream(t *testing.T) {
+	p := &mockModerator{mockProvider: mockProvider{
+		name: "test", available: true,
+		chunks: []StreamChunk{{Content: "this is "}, {Content: "bad"}, {Done: true, Usage: &StreamUsage{InputTokens: 7}}},
+	}}
+	c := New(p, WithModeration(ModerationPolicy{}))
+
+	var content string
+	var lastErr error
+	var done bool
+	for chunk := range c.Stream(context.Background(), []Message{{Role: RoleUser, Content: "hi"}}) {
+		content += chunk.Content
+		if chunk.Error != nil {
+			lastErr = chunk.Error
+		}
+		done = done || chunk.Done
+	}
+	if done {
+		t.Error("blocked stream should not deliver Done")
+	}
+	if !errors.Is(lastErr, ErrContentBlocked) {
+		t.Errorf("expected ErrContentBlocked, got %v", lastErr)
+	}
+	if content != "this is bad" {
+		t.Errorf("unexpected content %q", content)
+	}
+	// The blocked stream was paid for.
+	if u := c.Usage(); u.InputTokens != 7 {
+		t.Errorf("usage = %+v, want blocked stream counted", u)
+	}
+
+	// Blocked input never reaches the provider.
+	for chunk := range c.Stream(context.Background(), []Message{{Role: RoleUser, Content: "bad"}}) {
+		if chunk.Error == nil || !errors.Is(chunk.Error, ErrContentBlocked) {
+			t.Errorf("expected blocked input chunk, got %+v", chunk)
+		}
+	}
+}
+
+func TestModerationStreamFlags(t *testing.T) {
+	p := &mockModerator{mockProvider: mockProvider{
+		name: "test", available: true,
+		chunks: []StreamChunk{{Content: "bad"}, {Done: true}},
+	}}
+	c := New(p, WithModeration(ModerationPolicy{Action: ModerationFlag}))
+
+	var flags []string
+	for chunk := range c.Stream(context.Background(), []Message{{Role: RoleUser, Content: "hi"}}) {
+		if chunk.Done {
+			flags = chunk.ModerationFlags
+		}
+	}
+	if !reflect.DeepEqual(flags, []string{"violence"}) {
+		t.Errorf("expected violence flag on final chunk, got %v", flags)
+	}
+}
+
+func TestFormatErrorContentBlocked(t *testing.T) {
+	err := &ContentBlockedError{Stage: ModerationStageInput, Categories: []string{"hate"}}
+	if got := FormatError(err); !strings.Contains(got, "moderation") {
+		t.Errorf("unexpected message %q", got)
+	}
+	if !strings.Contains(err.Error(), "input") || !strings.Contains(err.Error(), "hate") {
+		t.Errorf("unexpected error string %q", err.Error())
+	}
+}
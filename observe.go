@@ -0,0 +1,212 @@
+package allm
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// Operation names reported to an Observer.
+const (
+	OperationChat          = "chat"
+	OperationStream        = "stream"
+	OperationEmbed         = "embed"
+	OperationCountTokens   = "count_tokens"
+	OperationModels        = "models"
+	OperationCreateBatch   = "create_batch"
+	OperationGetBatch      = "get_batch"
+	OperationGenerateImage = "generate_image"
+	OperationEditImage     = "edit_image"
+	OperationSpeak         = "speak"
+	OperationTranscribe    = "transcribe"
+	OperationSpeakStream   = "speak_stream"
+	OperationModerate      = "moderate"
+	OperationRerank        = "rerank"
+	OperationUploadFile    = "upload_file"
+	OperationListFiles     = "list_files"
+	OperationGetFile       = "get_file"
+	OperationDeleteFile    = "delete_file"
+	OperationDownloadFile  = "download_file"
+	OperationRealtime      = "realtime"
+)
+
+// Operation describes a client operation reported to an Observer.
+type Operation struct {
+	Name          string  // OperationChat, OperationStream, ...
+	CorrelationID string  // Correlation ID of the call (see WithCorrelationID)
+	Provider      string  // Provider name
+	Model         string  // Requested model (empty = provider default)
+	MaxTokens     int     // Requested max output tokens (chat and stream only)
+	Temperature   float64 // Requested temperature (chat and stream only)
+}
+
+// OperationResult is the outcome of a client operation.
+type OperationResult struct {
+	ID               string   // Provider request or batch ID, if any
+	Model            string   // Model that responded, if reported
+	InputTokens      int      // Input tokens, as reported in Response.InputTokens
+	OutputTokens     int      // Output tokens
+	CacheReadTokens  int      // Input tokens read from cache
+	CacheWriteTokens int      // Input tokens written to cache
+	FinishReasons    []string // e.g. ["end_turn"]
+	Cost             float64  // USD cost, when known
+	Error            error    // Error, if the operation failed (sanitized)
+}
+
+// Observer instruments client operations, e.g. with tracing and metrics (see
+// the allmotel package). Observers must be safe for concurrent use.
+type Observer interface {
+	// StartOperation is called when an operation starts. The returned context
+	// is used for the operation's provider calls; the returned
+	// OperationObserver is notified as the operation progresses.
+	StartOperation(ctx context.Context, op Operation) (context.Context, OperationObserver)
+}
+
+// OperationObserver receives the progress of one operation.
+type OperationObserver interface {
+	// StartAttempt is called before each provider call of a retried operation
+	// (chat, embed, moderate, rerank). The returned context is used for the
+	// call, and done is called with its sanitized error.
+	StartAttempt(ctx context.Context, attempt int) (attemptCtx context.Context, done func(error))
+
+	// FirstChunk is called when a stream receives its first content.
+	FirstChunk()
+
+	// End is called once when the operation completes.
+	End(result OperationResult)
+}
+
+// HeaderInjector is an optional Observer interface to add headers, such as
+// W3C trace context, to the provider's HTTP requests.
+type HeaderInjector interface {
+	InjectHeaders(ctx context.Context, h http.Header)
+}
+
+// WithObserver sets an observer for client operations.
+func WithObserver(o Observer) Option {
+	return func(c *Client) {
+		c.observer = o
+	}
+}
+
+// InjectHeaders adds the headers of the Observer of the operation running in
+// ctx to h. Providers call it for each HTTP request they send.
+func InjectHeaders(ctx context.Context, h http.Header) {
+	o, ok := ctx.Value(observationKey{}).(observation)
+	if !ok || o.observer == nil {
+		return
+	}
+	if injector, ok := o.observer.(HeaderInjector); ok {
+		injector.InjectHeaders(ctx, h)
+	}
+}
+
+// observationKey is the context key for the running observation.
+type observationKey struct{}
+
+// observation is a running operation, carried in its context.
+type observation struct {
+	operation Operation
+	observer  Observer          // nil = no observer
+	op        OperationObserver // nil = no observer
+}
+
+// operation returns the Operation for a chat-like call with the snapshot's settings.
+func (s clientState) operation(name string) Operation {
+	return Operation{
+		Name:        name,
+		Provider:    s.provider.Name(),
+		Model:       s.model,
+		MaxTokens:   s.maxTokens,
+		Temperature: s.temperature,
+	}
+}
+
+// startOperation starts op, assigning a correlation ID if ctx has none, and
+// notifies the observer. The returned OperationObserver is nil without one.
+func (s clientState) startOperation(ctx context.Context, op Operation) (context.Context, OperationObserver) {
+	if CorrelationID(ctx) == "" {
+		ctx = WithCorrelationID(ctx, newCorrelationID())
+	}
+	op.CorrelationID = CorrelationID(ctx)
+	var o OperationObserver
+	if s.observer != nil {
+		ctx, o = s.observer.StartOperation(ctx, op)
+	}
+	return context.WithValue(ctx, observationKey{}, observation{operation: op, observer: s.observer, op: o}), o
+}
+
+// startAttempt notifies the operation running in ctx of a provider call.
+func startAttempt(ctx context.Context, attempt int) (context.Context, func(error)) {
+	o, ok := ctx.Value(observationKey{}).(observation)
+	if !ok || o.op == nil {
+		return ctx, func(error) {}
+	}
+	return o.op.StartAttempt(ctx, attempt)
+}
+
+// observe runs fn as the operation op and reports its result to the observer.
+// With emitEvents, it also sends HookRequest and HookSuccess or HookError to
+// the hooks; retried operations pass false as retryWithBackoff sends them.
+func observe[T any](ctx context.Context, s clientState, op Operation, emitEvents bool, fn func(context.Context) (T, error)) (T, error) {
+	ctx, o := s.startOperation(ctx, op)
+	if emitEvents {
+		s.emit(ctx, HookEvent{Type: HookRequest, Attempt: 1})
+	}
+	start := time.Now()
+	result, err := fn(ctx)
+	summary := operationResult(result, err)
+	if o != nil {
+		o.End(summary)
+	}
+	if emitEvents {
+		event := resultEvent(summary, time.Since(start))
+		event.Attempt = 1
+		s.emit(ctx, event)
+	}
+	return result, err
+}
+
+// operationResult summarizes the result of an operation.
+func operationResult(result any, err error) OperationResult {
+	out := OperationResult{Error: sanitizeError(err)}
+	if err != nil {
+		return out
+	}
+	switch r := result.(type) {
+	case *Response:
+		out.ID = r.RequestID
+		out.Model = r.Model
+		out.InputTokens = r.InputTokens
+		out.OutputTokens = r.OutputTokens
+		out.CacheReadTokens = r.CacheReadTokens
+		out.CacheWriteTokens = r.CacheWriteTokens
+		out.Cost = r.Cost
+		if r.FinishReason != "" {
+			out.FinishReasons = []string{r.FinishReason}
+		}
+	case *EmbedResponse:
+		out.Model = r.Model
+		out.InputTokens = r.InputTokens
+	case *TokenCount:
+		out.InputTokens = r.InputTokens
+	case *Batch:
+		out.ID = r.ID
+	case *File:
+		out.ID = r.ID
+	}
+	return out
+}
+
+// streamResult summarizes a stream from its final chunk.
+func streamResult(chunk StreamChunk) OperationResult {
+	out := OperationResult{Error: sanitizeError(chunk.Error)}
+	if u := chunk.Usage; u != nil {
+		out.InputTokens = u.InputTokens
+		out.OutputTokens = u.OutputTokens
+		out.CacheReadTokens = u.CacheReadTokens
+		out.CacheWriteTokens = u.CacheWriteTokens
+		out.Cost = u.Cost
+	}
+	return out
+}
@@ -0,0 +1,136 @@
+package allm
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordingObserver records operations and implements HeaderInjector.
+type recordingObserver struct {
+	mu       sync.Mutex
+	ops      []Operation
+	results  []OperationResult
+	attempts []error
+	chunks   int
+}
+
+func (r *recordingObserver) StartOperation(ctx context.Context, op Operation) (context.Context, OperationObserver) {
+	r.mu.Lock()
+	r.ops = append(r.ops, op)
+	r.mu.Unlock()
+	return ctx, r
+}
+
+func (r *recordingObserver) StartAttempt(ctx context.Context, _ int) (context.Context, func(error)) {
+	return ctx, func(err error) {
+		r.mu.Lock()
+		r.attempts = append(r.attempts, err)
+		r.mu.Unlock()
+	}
+}
+
+func (r *recordingObserver) FirstChunk() {
+	r.mu.Lock()
+	r.chunks++
+	r.mu.Unlock()
+}
+
+func (r *recordingObserver) End(result OperationResult) {
+	r.mu.Lock()
+	r.results = append(r.results, result)
+	r.mu.Unlock()
+}
+
+func (r *recordingObserver) InjectHeaders(_ context.Context, h http.Header) {
+	h.Set("X-Test-Trace", "1")
+}
+
+func TestObserverChat(t *testing.T) {
+	obs := &recordingObserver{}
+	provider := &mockProvider{name: "test", err: ErrRateLimited}
+	client := New(provider, WithModel("m"), WithMaxRetries(1), WithRetryBaseDelay(time.Millisecond), WithObserver(obs))
+
+	if _, err := client.Complete(context.Background(), "hi"); !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("expected ErrRateLimited, got %v", err)
+	}
+	provider.mu.Lock()
+	provider.err = nil
+	provider.response = &Response{Content: "ok", Model: "m-1", InputTokens: 3, OutputTokens: 2, FinishReason: "stop"}
+	provider.mu.Unlock()
+	if _, err := client.Complete(context.Background(), "hi"); err != nil {
+		t.Fatalf("Complete: %v", err)
+	}
+
+	if len(obs.ops) != 2 || obs.ops[0].Name != OperationChat || obs.ops[0].Model != "m" || obs.ops[0].CorrelationID == "" {
+		t.Errorf("ops = %+v", obs.ops)
+	}
+	if len(obs.attempts) != 3 || !errors.Is(obs.attempts[1], ErrRateLimited) || obs.attempts[2] != nil {
+		t.Errorf("attempts = %v", obs.attempts)
+	}
+	if !errors.Is(obs.results[0].Error, ErrRateLimited) {
+		t.Errorf("first result = %+v", obs.results[0])
+	}
+	if r := obs.results[1]; r.Model != "m-1" || r.InputTokens != 3 || r.FinishReasons[0] != "stop" {
+		t.Errorf("second result = %+v", r)
+	}
+}
+
+func TestObserverStream(t *testing.T) {
+	obs := &recordingObserver{}
+	provider := &mockProvider{name: "test", chunks: []StreamChunk{
+		{Content: "a"},
+		{Content: "b"},
+		{Done: true, Usage: &StreamUsage{InputTokens: 4, OutputTokens: 2}},
+	}}
+	client := New(provider, WithObserver(obs))
+
+	var n int
+	for range client.Stream(context.Background(), []Message{{Role: RoleUser, Content: "hi"}}) {
+		n++
+	}
+	if n != 3 || obs.chunks != 1 || len(obs.results) != 1 || obs.results[0].OutputTokens != 2 {
+		t.Errorf("chunks = %d, first chunks = %d, results = %+v", n, obs.chunks, obs.results)
+	}
+	if obs.ops[0].Name != OperationStream {
+		t.Errorf("op = %+v", obs.ops[0])
+	}
+}
+
+func TestInjectHeaders(t *testing.T) {
+	h := http.Header{}
+	InjectHeaders(context.Background(), h)
+	if len(h) != 0 {
+		t.Errorf("headers without an observer = %v", h)
+	}
+
+	s := clientState{observer: &recordingObserver{}}
+	ctx, _ := s.startOperation(context.Background(), Operation{Name: OperationChat})
+	InjectHeaders(ctx, h)
+	if h.Get("X-Test-Trace") != "1" {
+		t.Errorf("headers = %v", h)
+	}
+}
+
+func TestObserverFiles(t *testing.T) {
+	obs := &recordingObserver{}
+	client := New(&mockFileStore{mockProvider: mockProvider{name: "test", available: true}}, WithObserver(obs))
+	ctx := context.Background()
+
+	if _, err := client.UploadFile(ctx, &FileUploadRequest{Name: "a.txt", Data: []byte("a")}); err != nil {
+		t.Fatalf("UploadFile: %v", err)
+	}
+	if err := client.DeleteFile(ctx, "file-a.txt"); err != nil {
+		t.Fatalf("DeleteFile: %v", err)
+	}
+
+	if len(obs.ops) != 2 || obs.ops[0].Name != OperationUploadFile || obs.ops[1].Name != OperationDeleteFile {
+		t.Errorf("ops = %+v", obs.ops)
+	}
+	if len(obs.results) != 2 || obs.results[0].ID != "file-a.txt" || obs.results[1].Error != nil {
+		t.Errorf("results = %+v", obs.results)
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"net/http"
 	"os"
 	"time"
 
@@ -40,6 +41,7 @@ type AnthropicProvider struct {
 	temperature float64
 	baseURL     string
 	client      anthropic.Client
+	httpClient  *http.Client // nil = SDK default
 	logger      allm.Logger
 }
 
@@ -90,6 +92,14 @@ func WithAnthropicLogger(logger allm.Logger) AnthropicOption {
 	}
 }
 
+// WithAnthropicHTTPClient sets the HTTP client for API requests, e.g. one
+// with a recording transport (see allmtest.Recorder) or a custom proxy.
+func WithAnthropicHTTPClient(client *http.Client) AnthropicOption {
+	return func(p *AnthropicProvider) {
+		p.httpClient = client
+	}
+}
+
 // Anthropic creates a new Anthropic provider.
 //
 // Authentication (in order of precedence):
@@ -132,6 +142,13 @@ func Anthropic(apiKey string, opts ...AnthropicOption) *AnthropicProvider {
 	if p.baseURL != "" {
 		clientOpts = append(clientOpts, option.WithBaseURL(p.baseURL))
 	}
+	if p.httpClient != nil {
+		clientOpts = append(clientOpts, option.WithHTTPClient(p.httpClient))
+	}
+	clientOpts = append(clientOpts, option.WithMiddleware(func(req *http.Request, next option.MiddlewareNext) (*http.Response, error) {
+		allm.InjectHeaders(req.Context(), req.Header)
+		return next(req)
+	}))
 	p.client = anthropic.NewClient(clientOpts...)
 
 	return p
@@ -147,6 +164,39 @@ func (p *AnthropicProvider) Available() bool {
 	return p.apiKey != "" || p.authToken != ""
 }
 
+// Capabilities returns the request features the Messages API honors.
+// Computer use is not reported: buildParams does not send it yet.
+func (p *AnthropicProvider) Capabilities() allm.Capabilities {
+	return allm.Capabilities{
+		Vision:        true,
+		Documents:     true,
+		Tools:         true,
+		Thinking:      true,
+		Effort:        true,
+		StopSequences: true,
+	}
+}
+
+// anthropicCacheControl converts an allm cache breakpoint.
+func anthropicCacheControl(cc *allm.CacheControl) anthropic.CacheControlEphemeralParam {
+	param := anthropic.NewCacheControlEphemeralParam()
+	if cc.TTL != "" {
+		param.TTL = anthropic.CacheControlEphemeralTTL(cc.TTL)
+	}
+	return param
+}
+
+// setCacheControl marks the last of parts with cc, unless it is nil or the
+// block already has its own breakpoint.
+func setCacheControl(parts []anthropic.ContentBlockParamUnion, cc *allm.CacheControl) {
+	if cc == nil || len(parts) == 0 {
+		return
+	}
+	if target := parts[len(parts)-1].GetCacheControl(); target != nil && target.Type == "" {
+		*target = anthropicCacheControl(cc)
+	}
+}
+
 // buildParams builds MessageNewParams from an allm.Request.
 func (p *AnthropicProvider) buildParams(req *allm.Request) (anthropic.MessageNewParams, error) {
 	var systemBlocks []anthropic.TextBlockParam
@@ -156,7 +206,7 @@ func (p *AnthropicProvider) buildParams(req *allm.Request) (anthropic.MessageNew
 		if m.Role == allm.RoleSystem {
 			block := anthropic.TextBlockParam{Text: m.Content}
 			if m.CacheControl != nil {
-				block.CacheControl = anthropic.NewCacheControlEphemeralParam()
+				block.CacheControl = anthropicCacheControl(m.CacheControl)
 			}
 			systemBlocks = append(systemBlocks, block)
 			continue
@@ -168,6 +218,7 @@ func (p *AnthropicProvider) buildParams(req *allm.Request) (anthropic.MessageNew
 			for _, tr := range m.ToolResults {
 				parts = append(parts, anthropic.NewToolResultBlock(tr.ToolCallID, tr.Content, tr.IsError))
 			}
+			setCacheControl(parts, m.CacheControl)
 			messages = append(messages, anthropic.NewUserMessage(parts...))
 			continue
 		}
@@ -175,30 +226,49 @@ func (p *AnthropicProvider) buildParams(req *allm.Request) (anthropic.MessageNew
 		var parts []anthropic.ContentBlockParamUnion
 
 		if m.Content != "" {
-			textBlock := anthropic.NewTextBlock(m.Content)
-			if m.CacheControl != nil {
-				textBlock.OfText.CacheControl = anthropic.NewCacheControlEphemeralParam()
-			}
-			parts = append(parts, textBlock)
+			parts = append(parts, anthropic.NewTextBlock(m.Content))
 		}
 
-		for _, img := range m.Images {
+		// File blocks are raw JSON overrides, so a message breakpoint that
+		// lands on the last attachment is set when the block is built.
+		lastAttachment := len(m.ToolCalls) == 0
+		for i, img := range m.Images {
+			if img.CacheControl == nil && lastAttachment && i == len(m.Images)-1 && len(m.Documents) == 0 {
+				img.CacheControl = m.CacheControl
+			}
+			if img.FileID != "" {
+				parts = append(parts, anthropicFileImageBlock(img.FileID, img.CacheControl))
+				continue
+			}
 			data := base64.StdEncoding.EncodeToString(img.Data)
-			parts = append(parts, anthropic.NewImageBlockBase64(img.MimeType, data))
+			block := anthropic.NewImageBlockBase64(img.MimeType, data)
+			if img.CacheControl != nil {
+				block.OfImage.CacheControl = anthropicCacheControl(img.CacheControl)
+			}
+			parts = append(parts, block)
 		}
 
-		for _, doc := range m.Documents {
+		for i, doc := range m.Documents {
+			if doc.CacheControl == nil && lastAttachment && i == len(m.Documents)-1 {
+				doc.CacheControl = m.CacheControl
+			}
+			if doc.FileID != "" {
+				parts = append(parts, anthropicFileDocumentBlock(doc))
+				continue
+			}
 			data := base64.StdEncoding.EncodeToString(doc.Data)
 			// Anthropic supports PDF documents natively via document blocks
-			parts = append(parts, anthropic.ContentBlockParamUnion{
-				OfDocument: &anthropic.DocumentBlockParam{
-					Source: anthropic.DocumentBlockParamSourceUnion{
-						OfBase64: &anthropic.Base64PDFSourceParam{
-							Data: data,
-						},
+			block := &anthropic.DocumentBlockParam{
+				Source: anthropic.DocumentBlockParamSourceUnion{
+					OfBase64: &anthropic.Base64PDFSourceParam{
+						Data: data,
 					},
 				},
-			})
+			}
+			if doc.CacheControl != nil {
+				block.CacheControl = anthropicCacheControl(doc.CacheControl)
+			}
+			parts = append(parts, anthropic.ContentBlockParamUnion{OfDocument: block})
 		}
 
 		// Handle assistant messages with tool calls
@@ -216,6 +286,9 @@ func (p *AnthropicProvider) buildParams(req *allm.Request) (anthropic.MessageNew
 			})
 		}
 
+		// A message breakpoint caches the whole message, so it goes on the last block.
+		setCacheControl(parts, m.CacheControl)
+
 		switch m.Role {
 		case allm.RoleUser:
 			messages = append(messages, anthropic.NewUserMessage(parts...))
@@ -260,16 +333,18 @@ func (p *AnthropicProvider) buildParams(req *allm.Request) (anthropic.MessageNew
 
 	if len(req.Tools) > 0 {
 		for _, t := range req.Tools {
-			params.Tools = append(params.Tools, anthropic.ToolUnionParam{
-				OfTool: &anthropic.ToolParam{
-					Name:        t.Name,
-					Description: anthropic.String(t.Description),
-					InputSchema: anthropic.ToolInputSchemaParam{
-						Properties: t.Parameters["properties"],
-						Required:   toStringSlice(t.Parameters["required"]),
-					},
+			tool := &anthropic.ToolParam{
+				Name:        t.Name,
+				Description: anthropic.String(t.Description),
+				InputSchema: anthropic.ToolInputSchemaParam{
+					Properties: t.Parameters["properties"],
+					Required:   toStringSlice(t.Parameters["required"]),
 				},
-			})
+			}
+			if t.CacheControl != nil {
+				tool.CacheControl = anthropicCacheControl(t.CacheControl)
+			}
+			params.Tools = append(params.Tools, anthropic.ToolUnionParam{OfTool: tool})
 		}
 	}
 
@@ -312,7 +387,7 @@ func (p *AnthropicProvider) Complete(ctx context.Context, req *allm.Request) (*a
 		return nil, err
 	}
 
-	message, err := p.client.Messages.New(ctx, params)
+	message, err := p.client.Messages.New(ctx, params, anthropicFilesBeta(req)...)
 	if err != nil {
 		if p.logger != nil {
 			p.logger.Debug("provider complete failed",
@@ -329,22 +404,18 @@ func (p *AnthropicProvider) Complete(ctx context.Context, req *allm.Request) (*a
 		return nil, allm.ErrEmptyResponse
 	}
 
+	// Anthropic reports cache reads and writes separately from input_tokens.
 	resp := &allm.Response{
-		Provider:     p.name,
-		Model:        model,
-		InputTokens:  int(message.Usage.InputTokens),
-		OutputTokens: int(message.Usage.OutputTokens),
-		Latency:      time.Since(start),
-		FinishReason: string(message.StopReason),
-		RequestID:    message.ID, // Anthropic message ID for debugging
-	}
-
-	// Extract cache token usage if available
-	if message.Usage.CacheReadInputTokens > 0 {
-		resp.CacheReadTokens = int(message.Usage.CacheReadInputTokens)
-	}
-	if message.Usage.CacheCreationInputTokens > 0 {
-		resp.CacheWriteTokens = int(message.Usage.CacheCreationInputTokens)
+		Provider:           p.name,
+		Model:              model,
+		InputTokens:        int(message.Usage.InputTokens),
+		OutputTokens:       int(message.Usage.OutputTokens),
+		CacheReadTokens:    int(message.Usage.CacheReadInputTokens),
+		CacheWriteTokens:   int(message.Usage.CacheCreationInputTokens),
+		CacheWrite1hTokens: int(message.Usage.CacheCreation.Ephemeral1hInputTokens),
+		Latency:            time.Since(start),
+		FinishReason:       string(message.StopReason),
+		RequestID:          message.ID, // Anthropic message ID for debugging
 	}
 
 	for _, block := range message.Content {
@@ -419,7 +490,7 @@ func (p *AnthropicProvider) CountTokens(ctx context.Context, req *allm.Request)
 		countParams.Thinking = anthropic.ThinkingConfigParamOfEnabled(int64(req.Thinking.BudgetTokens))
 	}
 
-	result, err := p.client.Messages.CountTokens(ctx, countParams)
+	result, err := p.client.Messages.CountTokens(ctx, countParams, anthropicFilesBeta(req)...)
 	if err != nil {
 		if p.logger != nil {
 			p.logger.Debug("provider count tokens failed",
@@ -467,12 +538,8 @@ func (p *AnthropicProvider) Models(ctx context.Context) ([]allm.Model, error) {
 		if m.MaxTokens > 0 {
 			model.MaxOutput = int(m.MaxTokens)
 		}
-		// Populate capabilities based on model type
-		model.Capabilities = []string{"chat", "streaming"}
-		// Most Claude models support vision and tools
-		if m.ID != "" {
-			model.Capabilities = append(model.Capabilities, "vision", "tools")
-		}
+		// Other capabilities come from the model catalog
+		model.Capabilities = []string{allm.CapabilityChat, allm.CapabilityStreaming}
 		if !m.CreatedAt.IsZero() {
 			model.CreatedAt = m.CreatedAt.Unix()
 		}
@@ -504,7 +571,7 @@ func (p *AnthropicProvider) Stream(ctx context.Context, req *allm.Request) <-cha
 			return
 		}
 
-		stream := p.client.Messages.NewStreaming(ctx, params)
+		stream := p.client.Messages.NewStreaming(ctx, params, anthropicFilesBeta(req)...)
 		defer func() { _ = stream.Close() }()
 
 		var usage *allm.StreamUsage
@@ -538,17 +605,23 @@ func (p *AnthropicProvider) Stream(ctx context.Context, req *allm.Request) <-cha
 				}
 				usage.OutputTokens = int(event.Usage.OutputTokens)
 			}
-			// message_start events contain input token count
-			if event.Type == "message_start" && event.Message.Usage.InputTokens > 0 {
-				if usage == nil {
-					usage = &allm.StreamUsage{}
+			// message_start events contain input and cache token counts
+			if event.Type == "message_start" {
+				u := event.Message.Usage
+				if u.InputTokens > 0 || u.CacheReadInputTokens > 0 || u.CacheCreationInputTokens > 0 {
+					if usage == nil {
+						usage = &allm.StreamUsage{}
+					}
+					usage.InputTokens = int(u.InputTokens)
+					usage.CacheReadTokens = int(u.CacheReadInputTokens)
+					usage.CacheWriteTokens = int(u.CacheCreationInputTokens)
+					usage.CacheWrite1hTokens = int(u.CacheCreation.Ephemeral1hInputTokens)
 				}
-				usage.InputTokens = int(event.Message.Usage.InputTokens)
 			}
 		}
 
 		if err := stream.Err(); err != nil {
-			out <- allm.StreamChunk{Error: err}
+			out <- allm.StreamChunk{Error: wrapAnthropicError(err)}
 			return
 		}
 
@@ -176,6 +176,12 @@ func (p *ClaudeCLIProvider) Available() bool {
 	return err == nil
 }
 
+// Capabilities returns the request features the CLI honors: text prompts
+// with an effort level. Images, documents and tools are not passed through.
+func (p *ClaudeCLIProvider) Capabilities() allm.Capabilities {
+	return allm.Capabilities{Effort: true}
+}
+
 // SetEffort sets the effort level at runtime.
 func (p *ClaudeCLIProvider) SetEffort(effort string) { p.effort = effort }
 
@@ -220,9 +226,10 @@ func (p *ClaudeCLIProvider) SessionPersist() bool { return p.sessionPersist }
 
 // cliResult represents the JSON output from claude CLI (--output-format json).
 type cliResult struct {
-	IsError bool   `json:"is_error"`
-	Result  string `json:"result"`
-	Usage   struct {
+	IsError      bool    `json:"is_error"`
+	Result       string  `json:"result"`
+	TotalCostUSD float64 `json:"total_cost_usd"`
+	Usage        struct {
 		InputTokens              int `json:"input_tokens"`
 		OutputTokens             int `json:"output_tokens"`
 		CacheReadInputTokens     int `json:"cache_read_input_tokens"`
@@ -396,13 +403,16 @@ func (p *ClaudeCLIProvider) Complete(ctx context.Context, req *allm.Request) (*a
 	}
 
 	resp := &allm.Response{
-		Provider:     "claude-cli",
-		Model:        model,
-		Content:      result.Result,
-		InputTokens:  result.Usage.InputTokens + result.Usage.CacheReadInputTokens + result.Usage.CacheCreationInputTokens,
-		OutputTokens: result.Usage.OutputTokens,
-		Latency:      time.Since(start),
-		FinishReason: "end_turn",
+		Provider:         "claude-cli",
+		Model:            model,
+		Content:          result.Result,
+		InputTokens:      result.Usage.InputTokens + result.Usage.CacheReadInputTokens + result.Usage.CacheCreationInputTokens,
+		OutputTokens:     result.Usage.OutputTokens,
+		Latency:          time.Since(start),
+		FinishReason:     "end_turn",
+		CacheReadTokens:  result.Usage.CacheReadInputTokens,
+		CacheWriteTokens: result.Usage.CacheCreationInputTokens,
+		Cost:             result.TotalCostUSD,
 	}
 
 	if p.logger != nil {
@@ -3,6 +3,7 @@ package provider
 import (
 	"context"
 	"fmt"
+	"net/http"
 	"net/url"
 	"os"
 	"time"
@@ -48,7 +49,11 @@ type OpenAICompatibleProvider struct {
 	maxTokens   int
 	temperature float64
 	client      openai.Client
-	embedModel  string // for embeddings (Local, custom)
+	embedModel  string       // for embeddings (Local, custom)
+	docText     bool         // send documents as extracted text instead of file parts
+	httpClient  *http.Client // nil = SDK default
+	rerank      bool         // serve Rerank from the /rerank endpoint
+	rerankModel string       // rerank model (empty = server default)
 	logger      allm.Logger
 }
 
@@ -100,6 +105,26 @@ func WithEmbedModel(model string) CompatOption {
 	}
 }
 
+// WithDocumentTextFallback sends documents as extracted text instead of file
+// content parts, for servers that don't accept PDF input (e.g., Ollama).
+// Text is extracted with allm.ExtractDocumentText.
+func WithDocumentTextFallback() CompatOption {
+	return func(p *OpenAICompatibleProvider) {
+		p.docText = true
+	}
+}
+
+// WithRerank enables Rerank through the server's Cohere-compatible /rerank
+// endpoint, served by vLLM next to /chat/completions, with the given
+// cross-encoder model (empty = server default). Without it, Rerank returns
+// allm.ErrNotSupported, as most servers (e.g., Ollama) have no such endpoint.
+func WithRerank(model string) CompatOption {
+	return func(p *OpenAICompatibleProvider) {
+		p.rerank = true
+		p.rerankModel = model
+	}
+}
+
 // WithProviderLogger sets a logger for provider-level debug tracing.
 func WithProviderLogger(logger allm.Logger) CompatOption {
 	return func(p *OpenAICompatibleProvider) {
@@ -107,6 +132,14 @@ func WithProviderLogger(logger allm.Logger) CompatOption {
 	}
 }
 
+// WithHTTPClient sets the HTTP client for API requests, e.g. one with a
+// recording transport (see allmtest.Recorder) or a custom proxy.
+func WithHTTPClient(client *http.Client) CompatOption {
+	return func(p *OpenAICompatibleProvider) {
+		p.httpClient = client
+	}
+}
+
 // OpenAICompatible creates a new OpenAI-compatible provider.
 // If the provider name is in the registry, it uses those defaults.
 // Otherwise, it creates a custom provider with the given name.
@@ -175,6 +208,20 @@ func (p *OpenAICompatibleProvider) Available() bool {
 	return p.apiKey != ""
 }
 
+// Capabilities returns the request features OpenAI-compatible servers honor.
+// Predicted outputs are OpenAI-only.
+func (p *OpenAICompatibleProvider) Capabilities() allm.Capabilities {
+	return openaiCapabilities(false)
+}
+
+// documentMode returns how documents are sent to this server.
+func (p *OpenAICompatibleProvider) documentMode() documentMode {
+	if p.docText {
+		return documentExtractText
+	}
+	return documentFileParts
+}
+
 // Complete sends a completion request.
 func (p *OpenAICompatibleProvider) Complete(ctx context.Context, req *allm.Request) (*allm.Response, error) {
 	start := time.Now()
@@ -189,7 +236,7 @@ func (p *OpenAICompatibleProvider) Complete(ctx context.Context, req *allm.Reque
 		)
 	}
 
-	messages, err := convertToOpenAI(req.Messages)
+	messages, err := convertToOpenAI(req.Messages, p.documentMode())
 	if err != nil {
 		return nil, err
 	}
@@ -239,7 +286,7 @@ func (p *OpenAICompatibleProvider) Stream(ctx context.Context, req *allm.Request
 			)
 		}
 
-		messages, err := convertToOpenAI(req.Messages)
+		messages, err := convertToOpenAI(req.Messages, p.documentMode())
 		if err != nil {
 			out <- allm.StreamChunk{Error: err}
 			return
@@ -305,6 +352,7 @@ func (p *OpenAICompatibleProvider) buildClient() openai.Client {
 
 	opts := []option.RequestOption{
 		option.WithBaseURL(p.baseURL),
+		option.WithMiddleware(injectHeaders),
 	}
 	if p.apiKey != "" {
 		opts = append(opts, option.WithAPIKey(p.apiKey))
@@ -312,5 +360,8 @@ func (p *OpenAICompatibleProvider) buildClient() openai.Client {
 		// Fallback to environment variable or dummy key for local servers
 		opts = append(opts, option.WithAPIKey("dummy"))
 	}
+	if p.httpClient != nil {
+		opts = append(opts, option.WithHTTPClient(p.httpClient))
+	}
 	return openai.NewClient(opts...)
 }
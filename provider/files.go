@@ -0,0 +1,286 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/anthropics/anthropic-sdk-go"
+	anthropicoption "github.com/anthropics/anthropic-sdk-go/option"
+	"github.com/anthropics/anthropic-sdk-go/packages/param"
+	"github.com/kusandriadi/allm-go"
+	"github.com/openai/openai-go/v3"
+)
+
+// anthropicFilesBetaHeader enables file sources in Anthropic messages.
+const anthropicFilesBetaHeader = anthropic.AnthropicBetaFilesAPI2025_04_14
+
+// readFileBody reads a download body, capped at allm.MaxFileSize.
+func readFileBody(body io.Reader) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(body, allm.MaxFileSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > allm.MaxFileSize {
+		return nil, fmt.Errorf("file exceeds maximum size of %d bytes", allm.MaxFileSize)
+	}
+	return data, nil
+}
+
+// --- Anthropic (Files API, beta) ---
+
+// UploadFile uploads a file using the Anthropic Files API.
+func (p *AnthropicProvider) UploadFile(ctx context.Context, req *allm.FileUploadRequest) (*allm.File, error) {
+	if p.logger != nil {
+		p.logger.Debug("provider upload file",
+			"provider", p.name,
+			"mime_type", req.MimeType,
+			"bytes", len(req.Data),
+		)
+	}
+
+	meta, err := p.client.Beta.Files.Upload(ctx, anthropic.BetaFileUploadParams{
+		File: anthropic.File(bytes.NewReader(req.Data), req.Name, req.MimeType),
+	})
+	if err != nil {
+		if p.logger != nil {
+			p.logger.Debug("provider upload file failed",
+				"provider", p.name,
+				"error", sanitizeProviderError(err),
+			)
+		}
+		return nil, wrapAnthropicError(err)
+	}
+
+	f := anthropicFile(*meta, p.name)
+	if p.logger != nil {
+		p.logger.Debug("provider upload file done",
+			"provider", p.name,
+			"file_id", f.ID,
+		)
+	}
+	return f, nil
+}
+
+// ListFiles lists files stored with the Anthropic Files API.
+func (p *AnthropicProvider) ListFiles(ctx context.Context) ([]allm.File, error) {
+	var files []allm.File
+	iter := p.client.Beta.Files.ListAutoPaging(ctx, anthropic.BetaFileListParams{})
+	for iter.Next() {
+		files = append(files, *anthropicFile(iter.Current(), p.name))
+	}
+	if err := iter.Err(); err != nil {
+		return nil, wrapAnthropicError(err)
+	}
+	return files, nil
+}
+
+// GetFile returns metadata for a file stored with the Anthropic Files API.
+func (p *AnthropicProvider) GetFile(ctx context.Context, fileID string) (*allm.File, error) {
+	meta, err := p.client.Beta.Files.GetMetadata(ctx, fileID, anthropic.BetaFileGetMetadataParams{})
+	if err != nil {
+		return nil, wrapAnthropicError(err)
+	}
+	return anthropicFile(*meta, p.name), nil
+}
+
+// DeleteFile deletes a file stored with the Anthropic Files API.
+func (p *AnthropicProvider) DeleteFile(ctx context.Context, fileID string) error {
+	if _, err := p.client.Beta.Files.Delete(ctx, fileID, anthropic.BetaFileDeleteParams{}); err != nil {
+		return wrapAnthropicError(err)
+	}
+	return nil
+}
+
+// DownloadFile downloads a file from the Anthropic Files API.
+// Only files created by tools or skills are downloadable; uploaded files are not.
+func (p *AnthropicProvider) DownloadFile(ctx context.Context, fileID string) ([]byte, error) {
+	httpResp, err := p.client.Beta.Files.Download(ctx, fileID, anthropic.BetaFileDownloadParams{})
+	if err != nil {
+		return nil, wrapAnthropicError(err)
+	}
+	defer func() { _ = httpResp.Body.Close() }()
+
+	data, err := readFileBody(httpResp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("%s: failed to read file: %w", p.name, err)
+	}
+	return data, nil
+}
+
+// anthropicFile converts Anthropic file metadata to an allm.File.
+func anthropicFile(meta anthropic.FileMetadata, providerName string) *allm.File {
+	return &allm.File{
+		ID:        meta.ID,
+		Name:      meta.Filename,
+		MimeType:  meta.MimeType,
+		Size:      meta.SizeBytes,
+		CreatedAt: meta.CreatedAt,
+		Provider:  providerName,
+	}
+}
+
+// anthropicFileImageBlock builds an image block with a file source.
+// The non-beta SDK params have no file source, so the block is sent as raw JSON.
+func anthropicFileImageBlock(fileID string, cc *allm.CacheControl) anthropic.ContentBlockParamUnion {
+	raw := map[string]any{
+		"type": "image",
+		"source": map[string]any{
+			"type":    "file",
+			"file_id": fileID,
+		},
+	}
+	if cc != nil {
+		raw["cache_control"] = anthropicCacheControl(cc)
+	}
+	block := param.Override[anthropic.ImageBlockParam](raw)
+	return anthropic.ContentBlockParamUnion{OfImage: &block}
+}
+
+// anthropicFileDocumentBlock builds a document block with a file source.
+func anthropicFileDocumentBlock(doc allm.Document) anthropic.ContentBlockParamUnion {
+	raw := map[string]any{
+		"type": "document",
+		"source": map[string]any{
+			"type":    "file",
+			"file_id": doc.FileID,
+		},
+	}
+	if doc.Name != "" {
+		raw["title"] = doc.Name
+	}
+	if doc.CacheControl != nil {
+		raw["cache_control"] = anthropicCacheControl(doc.CacheControl)
+	}
+	block := param.Override[anthropic.DocumentBlockParam](raw)
+	return anthropic.ContentBlockParamUnion{OfDocument: &block}
+}
+
+// anthropicFilesBeta returns the beta header option when a request references uploaded files.
+func anthropicFilesBeta(req *allm.Request) []anthropicoption.RequestOption {
+	if !usesFileIDs(req.Messages) {
+		return nil
+	}
+	return []anthropicoption.RequestOption{
+		anthropicoption.WithHeaderAdd("anthropic-beta", string(anthropicFilesBetaHeader)),
+	}
+}
+
+// usesFileIDs reports whether any image or document references an uploaded file.
+func usesFileIDs(msgs []allm.Message) bool {
+	for _, m := range msgs {
+		for _, img := range m.Images {
+			if img.FileID != "" {
+				return true
+			}
+		}
+		for _, doc := range m.Documents {
+			if doc.FileID != "" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// --- OpenAI (Files API) ---
+
+// UploadFile uploads a file using the OpenAI Files API.
+// The purpose defaults to allm.FilePurposeUserData.
+func (p *OpenAIProvider) UploadFile(ctx context.Context, req *allm.FileUploadRequest) (*allm.File, error) {
+	purpose := req.Purpose
+	if purpose == "" {
+		purpose = allm.FilePurposeUserData
+	}
+
+	if p.logger != nil {
+		p.logger.Debug("provider upload file",
+			"provider", "openai",
+			"purpose", purpose,
+			"bytes", len(req.Data),
+		)
+	}
+
+	obj, err := p.client.Files.New(ctx, openai.FileNewParams{
+		File:    openai.File(bytes.NewReader(req.Data), req.Name, req.MimeType),
+		Purpose: openai.FilePurpose(purpose),
+	})
+	if err != nil {
+		if p.logger != nil {
+			p.logger.Debug("provider upload file failed",
+				"provider", "openai",
+				"error", sanitizeProviderError(err),
+			)
+		}
+		return nil, wrapOpenAIError(err)
+	}
+
+	f := openaiFile(*obj)
+	f.MimeType = req.MimeType // OpenAI does not report MIME types
+	if p.logger != nil {
+		p.logger.Debug("provider upload file done",
+			"provider", "openai",
+			"file_id", f.ID,
+		)
+	}
+	return f, nil
+}
+
+// ListFiles lists files stored with the OpenAI Files API.
+func (p *OpenAIProvider) ListFiles(ctx context.Context) ([]allm.File, error) {
+	var files []allm.File
+	iter := p.client.Files.ListAutoPaging(ctx, openai.FileListParams{})
+	for iter.Next() {
+		files = append(files, *openaiFile(iter.Current()))
+	}
+	if err := iter.Err(); err != nil {
+		return nil, wrapOpenAIError(err)
+	}
+	return files, nil
+}
+
+// GetFile returns metadata for a file stored with the OpenAI Files API.
+func (p *OpenAIProvider) GetFile(ctx context.Context, fileID string) (*allm.File, error) {
+	obj, err := p.client.Files.Get(ctx, fileID)
+	if err != nil {
+		return nil, wrapOpenAIError(err)
+	}
+	return openaiFile(*obj), nil
+}
+
+// DeleteFile deletes a file stored with the OpenAI Files API.
+func (p *OpenAIProvider) DeleteFile(ctx context.Context, fileID string) error {
+	if _, err := p.client.Files.Delete(ctx, fileID); err != nil {
+		return wrapOpenAIError(err)
+	}
+	return nil
+}
+
+// DownloadFile downloads the contents of a file from the OpenAI Files API.
+func (p *OpenAIProvider) DownloadFile(ctx context.Context, fileID string) ([]byte, error) {
+	httpResp, err := p.client.Files.Content(ctx, fileID)
+	if err != nil {
+		return nil, wrapOpenAIError(err)
+	}
+	defer func() { _ = httpResp.Body.Close() }()
+
+	data, err := readFileBody(httpResp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("openai: failed to read file: %w", err)
+	}
+	return data, nil
+}
+
+// openaiFile converts an OpenAI file object to an allm.File.
+func openaiFile(obj openai.FileObject) *allm.File {
+	return &allm.File{
+		ID:        obj.ID,
+		Name:      obj.Filename,
+		Size:      obj.Bytes,
+		Purpose:   string(obj.Purpose),
+		CreatedAt: time.Unix(obj.CreatedAt, 0),
+		Provider:  "openai",
+	}
+}
@@ -1,11 +1,13 @@
 package provider
 
 import (
+	"bytes"
 	"context"
 	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"net"
 	"net/http"
 	"net/url"
@@ -14,6 +16,7 @@ import (
 
 	"github.com/kusandriadi/allm-go"
 	"github.com/openai/openai-go/v3"
+	"github.com/openai/openai-go/v3/option"
 	"github.com/openai/openai-go/v3/packages/ssestream"
 	"github.com/openai/openai-go/v3/shared" // ResponseFormat types
 )
@@ -158,6 +161,58 @@ func wrapHTTPStatusError(statusCode int, err error) error {
 	}
 }
 
+// maxErrorBodySize caps how much of an error response body is read.
+const maxErrorBodySize = 64 * 1024
+
+// injectHeaders is an OpenAI SDK middleware adding the headers of the
+// client's observer (e.g. trace context) to each request.
+func injectHeaders(req *http.Request, next option.MiddlewareNext) (*http.Response, error) {
+	allm.InjectHeaders(req.Context(), req.Header)
+	return next(req)
+}
+
+// postJSON sends a JSON POST request for endpoints the SDKs don't cover
+// (e.g., rerank) and decodes the JSON response into out.
+// apiKey is sent as a Bearer token when non-empty.
+// Non-2xx statuses are mapped to allm sentinel errors via wrapHTTPStatusError.
+func postJSON(ctx context.Context, client *http.Client, url, apiKey string, body, out any) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("encode request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Accept", "application/json")
+	allm.InjectHeaders(ctx, httpReq.Header)
+	if apiKey != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+apiKey)
+	}
+
+	httpResp, err := client.Do(httpReq)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = httpResp.Body.Close() }()
+
+	if httpResp.StatusCode < 200 || httpResp.StatusCode > 299 {
+		msg, _ := io.ReadAll(io.LimitReader(httpResp.Body, maxErrorBodySize))
+		statusErr := fmt.Errorf("HTTP %d: %s", httpResp.StatusCode, truncateErr(string(msg)))
+		if wrapped := wrapHTTPStatusError(httpResp.StatusCode, statusErr); wrapped != nil {
+			return wrapped
+		}
+		return fmt.Errorf("%w: %w", allm.ErrProvider, statusErr)
+	}
+
+	if err := json.NewDecoder(httpResp.Body).Decode(out); err != nil {
+		return fmt.Errorf("decode response: %w", err)
+	}
+	return nil
+}
+
 // wrapOpenAIError wraps OpenAI-compatible API errors with allm sentinel errors.
 func wrapOpenAIError(err error) error {
 	if err == nil {
@@ -172,16 +227,26 @@ func wrapOpenAIError(err error) error {
 	return err
 }
 
-// convertToOpenAI converts allm messages to OpenAI SDK format with image support.
+// documentMode controls how convertToOpenAI sends Message.Documents.
+type documentMode int
+
+const (
+	// documentFileParts sends PDFs as base64 file content parts (or file IDs)
+	// and text documents inline.
+	documentFileParts documentMode = iota
+	// documentExtractText sends the extracted text of every document as a text
+	// part, for compatible servers that do not accept file parts.
+	documentExtractText
+)
+
+// convertToOpenAI converts allm messages to OpenAI SDK format with image and document support.
 // Shared by all OpenAI-compatible providers (OpenAI, Kimi, MiniMax, etc).
-// Returns an error if messages contain documents (OpenAI doesn't support native PDF).
-func convertToOpenAI(msgs []allm.Message) ([]openai.ChatCompletionMessageParamUnion, error) {
+func convertToOpenAI(msgs []allm.Message, docs documentMode) ([]openai.ChatCompletionMessageParamUnion, error) {
 	var messages []openai.ChatCompletionMessageParamUnion
 
 	for _, m := range msgs {
-		// OpenAI doesn't support native document input (e.g., PDF)
-		if len(m.Documents) > 0 {
-			return nil, fmt.Errorf("%w: document input (OpenAI doesn't support native PDF/document input)", allm.ErrNotSupported)
+		if len(m.Documents) > 0 && m.Role != allm.RoleUser {
+			return nil, fmt.Errorf("%w: documents in %s messages", allm.ErrNotSupported, m.Role)
 		}
 
 		// Handle tool result messages
@@ -214,19 +279,30 @@ func convertToOpenAI(msgs []allm.Message) ([]openai.ChatCompletionMessageParamUn
 			continue
 		}
 
-		// Handle messages with images (vision)
-		if len(m.Images) > 0 && m.Role == allm.RoleUser {
+		// Handle messages with images (vision) or file references
+		if (len(m.Images) > 0 || len(m.Documents) > 0) && m.Role == allm.RoleUser {
 			var parts []openai.ChatCompletionContentPartUnionParam
 			if m.Content != "" {
 				parts = append(parts, openai.TextContentPart(m.Content))
 			}
 			for _, img := range m.Images {
+				if img.FileID != "" {
+					parts = append(parts, openaiFilePart(img.FileID))
+					continue
+				}
 				data := base64.StdEncoding.EncodeToString(img.Data)
 				url := "data:" + img.MimeType + ";base64," + data
 				parts = append(parts, openai.ImageContentPart(openai.ChatCompletionContentPartImageImageURLParam{
 					URL: url,
 				}))
 			}
+			for _, doc := range m.Documents {
+				part, err := openaiDocumentPart(doc, docs)
+				if err != nil {
+					return nil, err
+				}
+				parts = append(parts, part)
+			}
 			messages = append(messages, openai.UserMessage(parts))
 			continue
 		}
@@ -244,6 +320,131 @@ func convertToOpenAI(msgs []allm.Message) ([]openai.ChatCompletionMessageParamUn
 	return messages, nil
 }
 
+// openaiFilePart builds a file content part referencing an uploaded file.
+func openaiFilePart(fileID string) openai.ChatCompletionContentPartUnionParam {
+	return openai.FileContentPart(openai.ChatCompletionContentPartFileFileParam{
+		FileID: openai.String(fileID),
+	})
+}
+
+// openaiDocumentPart converts a document to a content part.
+// PDFs become base64 file parts, text documents become text parts, and
+// documentExtractText turns every document into its extracted text.
+func openaiDocumentPart(doc allm.Document, docs documentMode) (openai.ChatCompletionContentPartUnionParam, error) {
+	if doc.FileID != "" {
+		if docs == documentExtractText {
+			return openai.ChatCompletionContentPartUnionParam{}, fmt.Errorf("%w: document file references (server has no file part support)", allm.ErrNotSupported)
+		}
+		return openaiFilePart(doc.FileID), nil
+	}
+
+	if docs == documentExtractText || allm.IsTextDocument(doc.MimeType) {
+		text, err := allm.ExtractDocumentText(doc)
+		if err != nil {
+			return openai.ChatCompletionContentPartUnionParam{}, fmt.Errorf("document %q: %w", doc.Name, err)
+		}
+		return openai.TextContentPart(documentTextBlock(doc.Name, text)), nil
+	}
+
+	if !strings.EqualFold(doc.MimeType, "application/pdf") {
+		return openai.ChatCompletionContentPartUnionParam{}, fmt.Errorf("%w: document type %s (only PDF and text documents)", allm.ErrNotSupported, doc.MimeType)
+	}
+	name := doc.Name
+	if name == "" {
+		name = "document.pdf"
+	}
+	return openai.FileContentPart(openai.ChatCompletionContentPartFileFileParam{
+		FileData: openai.String("data:application/pdf;base64," + base64.StdEncoding.EncodeToString(doc.Data)),
+		Filename: openai.String(name),
+	}), nil
+}
+
+// documentTextBlock wraps extracted document text so the model can tell
+// where each document starts and ends.
+func documentTextBlock(name, text string) string {
+	if name == "" {
+		return "<document>\n" + text + "\n</document>"
+	}
+	return fmt.Sprintf("<document name=%q>\n%s\n</document>", name, text)
+}
+
+// isGPTImageModel reports whether a model is a gpt-image model
+// (always base64 output, supports background and output format).
+func isGPTImageModel(model string) bool {
+	return strings.HasPrefix(model, "gpt-image")
+}
+
+// openaiImageFile wraps image bytes as a multipart file with a name and content type.
+func openaiImageFile(img allm.Image, name string) io.Reader {
+	mimeType := img.MimeType
+	if mimeType == "" {
+		mimeType = "image/png"
+	}
+	ext := strings.TrimPrefix(mimeType, "image/")
+	if ext == "jpeg" {
+		ext = "jpg"
+	}
+	return openai.File(bytes.NewReader(img.Data), name+"."+ext, mimeType)
+}
+
+// openaiImageResponse converts an OpenAI images response, decoding base64 data.
+func openaiImageResponse(result *openai.ImagesResponse, model, outputFormat string, start time.Time) (*allm.ImageResponse, error) {
+	if outputFormat == "" {
+		outputFormat = string(result.OutputFormat)
+	}
+	if outputFormat == "" {
+		outputFormat = "png"
+	}
+
+	resp := &allm.ImageResponse{
+		Provider: "openai",
+		Model:    model,
+		Latency:  time.Since(start),
+	}
+	for i, img := range result.Data {
+		gen := allm.GeneratedImage{
+			URL:           img.URL,
+			RevisedPrompt: img.RevisedPrompt,
+		}
+		if img.B64JSON != "" {
+			data, err := base64.StdEncoding.DecodeString(img.B64JSON)
+			if err != nil {
+				return nil, fmt.Errorf("openai: decode image %d: %w", i, err)
+			}
+			gen.Data = data
+			gen.MimeType = "image/" + outputFormat
+		}
+		resp.Images = append(resp.Images, gen)
+	}
+	return resp, nil
+}
+
+// openaiTranscribeResponse converts a transcription. The basic json format only
+// carries text, so Language falls back to the requested language.
+func openaiTranscribeResponse(t *openai.AudioTranscriptionNewResponseUnion, language string) *allm.TranscribeResponse {
+	resp := &allm.TranscribeResponse{
+		Text:     t.Text,
+		Language: language,
+		Duration: t.Duration,
+		Provider: "openai",
+	}
+	if t.Language != "" {
+		resp.Language = t.Language
+	}
+	for _, seg := range t.Segments {
+		resp.Segments = append(resp.Segments, allm.TranscriptSegment{
+			ID:    int(seg.ID),
+			Start: seg.Start,
+			End:   seg.End,
+			Text:  seg.Text,
+		})
+	}
+	for _, w := range t.Words {
+		resp.Words = append(resp.Words, allm.TranscriptWord{Word: w.Word, Start: w.Start, End: w.End})
+	}
+	return resp
+}
+
 // convertToolsToOpenAI converts allm.Tool definitions to OpenAI SDK format.
 func convertToolsToOpenAI(tools []allm.Tool) []openai.ChatCompletionToolUnionParam {
 	var result []openai.ChatCompletionToolUnionParam
@@ -414,6 +615,9 @@ func openaiCompleteResponse(
 		OutputTokens: int(completion.Usage.CompletionTokens),
 		Latency:      time.Since(start),
 		FinishReason: string(completion.Choices[0].FinishReason),
+		// Cached prompt tokens are included in prompt_tokens, reasoning tokens in completion_tokens
+		CacheReadTokens: int(completion.Usage.PromptTokensDetails.CachedTokens),
+		ThinkingTokens:  int(completion.Usage.CompletionTokensDetails.ReasoningTokens),
 	}
 
 	// System fingerprint for reproducibility
@@ -501,20 +705,39 @@ func openaiStreamLoop(stream *ssestream.Stream[openai.ChatCompletionChunk], out
 		// Parse usage from final chunk (when stream_options.include_usage=true)
 		if chunk.Usage.PromptTokens > 0 || chunk.Usage.CompletionTokens > 0 {
 			usage = &allm.StreamUsage{
-				InputTokens:  int(chunk.Usage.PromptTokens),
-				OutputTokens: int(chunk.Usage.CompletionTokens),
+				InputTokens:     int(chunk.Usage.PromptTokens),
+				OutputTokens:    int(chunk.Usage.CompletionTokens),
+				CacheReadTokens: int(chunk.Usage.PromptTokensDetails.CachedTokens),
+				ThinkingTokens:  int(chunk.Usage.CompletionTokensDetails.ReasoningTokens),
 			}
 		}
 	}
 
 	if err := stream.Err(); err != nil {
-		out <- allm.StreamChunk{Error: err}
+		out <- allm.StreamChunk{Error: wrapOpenAIError(err)}
 		return
 	}
 
 	out <- allm.StreamChunk{Done: true, Usage: usage}
 }
 
+// openaiCapabilities returns the request features sent by the Chat Completions helpers.
+func openaiCapabilities(prediction bool) allm.Capabilities {
+	return allm.Capabilities{
+		Vision:            true,
+		Documents:         true,
+		Tools:             true,
+		JSONMode:          true,
+		JSONSchema:        true,
+		Effort:            true,
+		StopSequences:     true,
+		LogProbs:          true,
+		Seed:              true,
+		Prediction:        prediction,
+		ParallelToolCalls: true,
+	}
+}
+
 // openaiListModels lists models from an OpenAI-compatible API.
 func openaiListModels(ctx context.Context, client openai.Client, providerName string) ([]allm.Model, error) {
 	page, err := client.Models.List(ctx)
@@ -529,20 +752,9 @@ func openaiListModels(ctx context.Context, client openai.Client, providerName st
 			Name:     m.ID,
 			Provider: providerName,
 		}
-		// Populate basic capabilities based on model ID patterns
-		if strings.Contains(m.ID, "gpt") {
-			model.Capabilities = []string{"chat", "streaming", "tools"}
-			if strings.Contains(m.ID, "vision") || strings.Contains(m.ID, "gpt-4") {
-				model.Capabilities = append(model.Capabilities, "vision")
-			}
-		} else if strings.Contains(m.ID, "embedding") {
-			model.Capabilities = []string{"embeddings"}
-		} else if strings.Contains(m.ID, "dall-e") {
-			model.Capabilities = []string{"image-generation"}
-		} else if strings.Contains(m.ID, "whisper") {
-			model.Capabilities = []string{"speech-to-text"}
-		} else if strings.Contains(m.ID, "tts") {
-			model.Capabilities = []string{"text-to-speech"}
+		// Catalog models are filled in by Client.Models; guess the rest from the ID
+		if _, known := allm.LookupModel(m.ID); !known {
+			model.Capabilities = guessModelCapabilities(m.ID)
 		}
 		if m.Created > 0 {
 			model.CreatedAt = m.Created
@@ -552,6 +764,57 @@ func openaiListModels(ctx context.Context, client openai.Client, providerName st
 	return models, nil
 }
 
+// guessModelCapabilities infers capabilities from an OpenAI-style model ID.
+func guessModelCapabilities(id string) []string {
+	switch {
+	case strings.Contains(id, "gpt"):
+		caps := []string{allm.CapabilityChat, allm.CapabilityStreaming, allm.CapabilityTools}
+		if strings.Contains(id, "vision") || strings.Contains(id, "gpt-4") {
+			caps = append(caps, allm.CapabilityVision)
+		}
+		return caps
+	case strings.Contains(id, "embedding"):
+		return []string{allm.CapabilityEmbeddings}
+	case strings.Contains(id, "dall-e"):
+		return []string{allm.CapabilityImageGeneration}
+	case strings.Contains(id, "whisper"):
+		return []string{allm.CapabilitySpeechToText}
+	case strings.Contains(id, "tts"):
+		return []string{allm.CapabilityTextToSpeech}
+	}
+	return nil
+}
+
+// openaiModerationResult converts an OpenAI moderation result to allm format.
+// Categories are read from the raw JSON so new categories are picked up without SDK changes.
+func openaiModerationResult(m openai.Moderation) allm.ModerationResult {
+	result := allm.ModerationResult{
+		Flagged:    m.Flagged,
+		Categories: make(map[string]bool),
+		Scores:     make(map[string]float64),
+	}
+
+	var categories map[string]any
+	if json.Unmarshal([]byte(m.Categories.RawJSON()), &categories) == nil {
+		for cat, v := range categories {
+			if flagged, ok := v.(bool); ok {
+				result.Categories[cat] = flagged
+			}
+		}
+	}
+
+	var scores map[string]any
+	if json.Unmarshal([]byte(m.CategoryScores.RawJSON()), &scores) == nil {
+		for cat, v := range scores {
+			if score, ok := v.(float64); ok {
+				result.Scores[cat] = score
+			}
+		}
+	}
+
+	return result
+}
+
 // openaiEmbed generates embeddings using an OpenAI-compatible API.
 func openaiEmbed(
 	ctx context.Context,
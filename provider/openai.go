@@ -5,6 +5,7 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"net/http"
 	"os"
 	"time"
 
@@ -20,7 +21,9 @@ type OpenAIProvider struct {
 	maxTokens   int
 	temperature float64
 	baseURL     string
+	imageModel  string
 	client      openai.Client
+	httpClient  *http.Client // nil = SDK default
 	logger      allm.Logger
 }
 
@@ -55,6 +58,13 @@ func WithOpenAIBaseURL(url string) OpenAIOption {
 	}
 }
 
+// WithOpenAIImageModel sets the default image generation model (default: dall-e-3).
+func WithOpenAIImageModel(model string) OpenAIOption {
+	return func(p *OpenAIProvider) {
+		p.imageModel = model
+	}
+}
+
 // WithOpenAILogger sets a logger for provider-level debug tracing.
 func WithOpenAILogger(logger allm.Logger) OpenAIOption {
 	return func(p *OpenAIProvider) {
@@ -62,6 +72,14 @@ func WithOpenAILogger(logger allm.Logger) OpenAIOption {
 	}
 }
 
+// WithOpenAIHTTPClient sets the HTTP client for API requests, e.g. one with
+// a recording transport (see allmtest.Recorder) or a custom proxy.
+func WithOpenAIHTTPClient(client *http.Client) OpenAIOption {
+	return func(p *OpenAIProvider) {
+		p.httpClient = client
+	}
+}
+
 // OpenAI creates a new OpenAI provider.
 // If apiKey is empty, it reads from OPENAI_API_KEY environment variable.
 func OpenAI(apiKey string, opts ...OpenAIOption) *OpenAIProvider {
@@ -70,9 +88,10 @@ func OpenAI(apiKey string, opts ...OpenAIOption) *OpenAIProvider {
 	}
 
 	p := &OpenAIProvider{
-		apiKey:    apiKey,
-		model:     "gpt-4o",
-		maxTokens: 4096,
+		apiKey:     apiKey,
+		model:      "gpt-4o",
+		maxTokens:  4096,
+		imageModel: "dall-e-3",
 	}
 
 	for _, opt := range opts {
@@ -94,6 +113,11 @@ func (p *OpenAIProvider) Available() bool {
 	return p.apiKey != ""
 }
 
+// Capabilities returns the request features the Chat Completions API honors.
+func (p *OpenAIProvider) Capabilities() allm.Capabilities {
+	return openaiCapabilities(true)
+}
+
 // Complete sends a completion request.
 func (p *OpenAIProvider) Complete(ctx context.Context, req *allm.Request) (*allm.Response, error) {
 	start := time.Now()
@@ -107,7 +131,7 @@ func (p *OpenAIProvider) Complete(ctx context.Context, req *allm.Request) (*allm
 		)
 	}
 
-	messages, err := convertToOpenAI(req.Messages)
+	messages, err := convertToOpenAI(req.Messages, documentFileParts)
 	if err != nil {
 		return nil, err
 	}
@@ -165,7 +189,7 @@ func (p *OpenAIProvider) Stream(ctx context.Context, req *allm.Request) <-chan a
 			)
 		}
 
-		messages, err := convertToOpenAI(req.Messages)
+		messages, err := convertToOpenAI(req.Messages, documentFileParts)
 		if err != nil {
 			out <- allm.StreamChunk{Error: err}
 			return
@@ -179,14 +203,12 @@ func (p *OpenAIProvider) Stream(ctx context.Context, req *allm.Request) <-chan a
 	return out
 }
 
-// GenerateImage creates images from a text prompt using DALL-E.
+// GenerateImage creates images from a text prompt using DALL-E or gpt-image models.
+// Base64 results are decoded into GeneratedImage.Data.
 func (p *OpenAIProvider) GenerateImage(ctx context.Context, req *allm.ImageRequest) (*allm.ImageResponse, error) {
 	start := time.Now()
 
-	model := "dall-e-3"
-	if req.Model != "" {
-		model = req.Model
-	}
+	model := resolveModel(req.Model, p.imageModel)
 
 	if p.logger != nil {
 		p.logger.Debug("provider generate image",
@@ -211,6 +233,22 @@ func (p *OpenAIProvider) GenerateImage(ctx context.Context, req *allm.ImageReque
 	if req.N > 0 {
 		params.N = openai.Int(int64(req.N))
 	}
+	// gpt-image models always return base64 and reject response_format
+	if req.ResponseFormat != "" && !isGPTImageModel(model) {
+		params.ResponseFormat = openai.ImageGenerateParamsResponseFormat(req.ResponseFormat)
+	}
+	if req.Background != "" {
+		params.Background = openai.ImageGenerateParamsBackground(req.Background)
+	}
+	if req.OutputFormat != "" {
+		params.OutputFormat = openai.ImageGenerateParamsOutputFormat(req.OutputFormat)
+	}
+	if req.OutputCompression > 0 {
+		params.OutputCompression = openai.Int(int64(req.OutputCompression))
+	}
+	if req.Style != "" {
+		params.Style = openai.ImageGenerateParamsStyle(req.Style)
+	}
 
 	result, err := p.client.Images.Generate(ctx, params)
 	if err != nil {
@@ -224,21 +262,119 @@ func (p *OpenAIProvider) GenerateImage(ctx context.Context, req *allm.ImageReque
 		return nil, wrapOpenAIError(err)
 	}
 
-	resp := &allm.ImageResponse{
-		Provider: "openai",
-		Model:    model,
-		Latency:  time.Since(start),
+	resp, err := openaiImageResponse(result, model, req.OutputFormat, start)
+	if err != nil {
+		return nil, err
+	}
+
+	if p.logger != nil {
+		p.logger.Debug("provider generate image done",
+			"provider", "openai",
+			"model", model,
+			"latency", resp.Latency,
+			"images", len(resp.Images),
+		)
 	}
 
-	for _, img := range result.Data {
-		resp.Images = append(resp.Images, allm.GeneratedImage{
-			URL:           img.URL,
-			RevisedPrompt: img.RevisedPrompt,
-		})
+	return resp, nil
+}
+
+// EditImage edits images with gpt-image-1 (default) or dall-e-2, or creates
+// variations with dall-e-2 when the prompt is empty.
+func (p *OpenAIProvider) EditImage(ctx context.Context, req *allm.ImageEditRequest) (*allm.ImageResponse, error) {
+	start := time.Now()
+
+	variation := req.Prompt == ""
+	model := req.Model
+	if model == "" {
+		model = "gpt-image-1"
+		if variation {
+			model = "dall-e-2"
+		}
 	}
 
 	if p.logger != nil {
-		p.logger.Debug("provider generate image done",
+		p.logger.Debug("provider edit image",
+			"provider", "openai",
+			"model", model,
+			"images", len(req.Images),
+			"variation", variation,
+		)
+	}
+
+	var result *openai.ImagesResponse
+	var err error
+	if variation {
+		params := openai.ImageNewVariationParams{
+			Image: openaiImageFile(req.Images[0], "image"),
+			Model: openai.ImageModel(model),
+		}
+		if req.Size != "" {
+			params.Size = openai.ImageNewVariationParamsSize(req.Size)
+		}
+		if req.N > 0 {
+			params.N = openai.Int(int64(req.N))
+		}
+		if req.ResponseFormat != "" {
+			params.ResponseFormat = openai.ImageNewVariationParamsResponseFormat(req.ResponseFormat)
+		}
+		result, err = p.client.Images.NewVariation(ctx, params)
+	} else {
+		params := openai.ImageEditParams{
+			Prompt: req.Prompt,
+			Model:  openai.ImageModel(model),
+		}
+		if len(req.Images) == 1 {
+			params.Image.OfFile = openaiImageFile(req.Images[0], "image")
+		} else {
+			for i, img := range req.Images {
+				params.Image.OfFileArray = append(params.Image.OfFileArray, openaiImageFile(img, fmt.Sprintf("image-%d", i)))
+			}
+		}
+		if req.Mask != nil {
+			params.Mask = openaiImageFile(*req.Mask, "mask")
+		}
+		if req.Size != "" {
+			params.Size = openai.ImageEditParamsSize(req.Size)
+		}
+		if req.Quality != "" {
+			params.Quality = openai.ImageEditParamsQuality(req.Quality)
+		}
+		if req.N > 0 {
+			params.N = openai.Int(int64(req.N))
+		}
+		if req.ResponseFormat != "" && !isGPTImageModel(model) {
+			params.ResponseFormat = openai.ImageEditParamsResponseFormat(req.ResponseFormat)
+		}
+		if req.Background != "" {
+			params.Background = openai.ImageEditParamsBackground(req.Background)
+		}
+		if req.OutputFormat != "" {
+			params.OutputFormat = openai.ImageEditParamsOutputFormat(req.OutputFormat)
+		}
+		if req.OutputCompression > 0 {
+			params.OutputCompression = openai.Int(int64(req.OutputCompression))
+		}
+		result, err = p.client.Images.Edit(ctx, params)
+	}
+	if err != nil {
+		if p.logger != nil {
+			p.logger.Debug("provider edit image failed",
+				"provider", "openai",
+				"model", model,
+				"error", sanitizeProviderError(err),
+			)
+		}
+		return nil, wrapOpenAIError(err)
+	}
+
+	resp, err := openaiImageResponse(result, model, req.OutputFormat, start)
+	if err != nil {
+		return nil, err
+	}
+
+	if p.logger != nil {
+		p.logger.Debug("provider edit image done",
 			"provider", "openai",
 			"model", model,
 			"latency", resp.Latency,
@@ -249,29 +385,127 @@ func (p *OpenAIProvider) GenerateImage(ctx context.Context, req *allm.ImageReque
 	return resp, nil
 }
 
+// Moderate classifies texts using the OpenAI Moderations API.
+func (p *OpenAIProvider) Moderate(ctx context.Context, req *allm.ModerationRequest) (*allm.ModerationResponse, error) {
+	start := time.Now()
+
+	model := "omni-moderation-latest"
+	if req.Model != "" {
+		model = req.Model
+	}
+
+	if p.logger != nil {
+		p.logger.Debug("provider moderate",
+			"provider", "openai",
+			"model", model,
+			"inputs", len(req.Input),
+		)
+	}
+
+	result, err := p.client.Moderations.New(ctx, openai.ModerationNewParams{
+		Input: openai.ModerationNewParamsInputUnion{OfStringArray: req.Input},
+		Model: model,
+	})
+	if err != nil {
+		if p.logger != nil {
+			p.logger.Debug("provider moderate failed",
+				"provider", "openai",
+				"model", model,
+				"error", sanitizeProviderError(err),
+			)
+		}
+		return nil, wrapOpenAIError(err)
+	}
+
+	resp := &allm.ModerationResponse{
+		Model:    result.Model,
+		Provider: "openai",
+		Latency:  time.Since(start),
+	}
+	for _, r := range result.Results {
+		resp.Results = append(resp.Results, openaiModerationResult(r))
+	}
+
+	if p.logger != nil {
+		p.logger.Debug("provider moderate done",
+			"provider", "openai",
+			"model", model,
+			"latency", resp.Latency,
+			"results", len(resp.Results),
+		)
+	}
+
+	return resp, nil
+}
+
 // CreateBatch submits a batch of requests for processing.
 // Note: This is a basic stub implementation. Full batch API requires file upload and polling.
 func (p *OpenAIProvider) CreateBatch(ctx context.Context, requests []allm.BatchRequest) (*allm.Batch, error) {
 	// TODO: Implement full batch API with file upload
-	return nil, fmt.Errorf("openai: batch API not yet implemented")
+	return nil, fmt.Errorf("%w: openai batch API not yet implemented", allm.ErrNotSupported)
 }
 
 // GetBatch retrieves the status and results of a batch job.
 // Note: This is a basic stub implementation.
 func (p *OpenAIProvider) GetBatch(ctx context.Context, batchID string) (*allm.Batch, error) {
 	// TODO: Implement batch retrieval
-	return nil, fmt.Errorf("openai: batch API not yet implemented")
+	return nil, fmt.Errorf("%w: openai batch API not yet implemented", allm.ErrNotSupported)
 }
 
 // Speak converts text to speech using OpenAI TTS.
 func (p *OpenAIProvider) Speak(ctx context.Context, req *allm.SpeechRequest) (*allm.SpeechResponse, error) {
 	start := time.Now()
+	model := resolveModel(req.Model, "tts-1")
 
-	model := "tts-1"
-	if req.Model != "" {
-		model = req.Model
+	httpResp, err := p.speech(ctx, req, model)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = httpResp.Body.Close() }()
+
+	// Read audio data
+	var buf bytes.Buffer
+	if _, err := io.Copy(&buf, httpResp.Body); err != nil {
+		return nil, fmt.Errorf("openai: failed to read audio data: %w", err)
+	}
+
+	format := req.Format
+	if format == "" {
+		format = "mp3" // OpenAI default
+	}
+
+	result := &allm.SpeechResponse{
+		Audio:    buf.Bytes(),
+		Format:   format,
+		Provider: "openai",
+		Model:    model,
+		Latency:  time.Since(start),
 	}
 
+	if p.logger != nil {
+		p.logger.Debug("provider speak done",
+			"provider", "openai",
+			"model", model,
+			"latency", result.Latency,
+			"bytes", len(result.Audio),
+		)
+	}
+
+	return result, nil
+}
+
+// SpeakStream converts text to speech using OpenAI TTS and returns the
+// response body as it arrives. The caller must close the reader.
+func (p *OpenAIProvider) SpeakStream(ctx context.Context, req *allm.SpeechRequest) (io.ReadCloser, error) {
+	httpResp, err := p.speech(ctx, req, resolveModel(req.Model, "tts-1"))
+	if err != nil {
+		return nil, err
+	}
+	return httpResp.Body, nil
+}
+
+// speech sends a TTS request and returns the unread HTTP response.
+func (p *OpenAIProvider) speech(ctx context.Context, req *allm.SpeechRequest, model string) (*http.Response, error) {
 	voice := "alloy"
 	if req.Voice != "" {
 		voice = req.Voice
@@ -311,37 +545,7 @@ func (p *OpenAIProvider) Speak(ctx context.Context, req *allm.SpeechRequest) (*a
 		}
 		return nil, wrapOpenAIError(err)
 	}
-	defer func() { _ = httpResp.Body.Close() }()
-
-	// Read audio data
-	var buf bytes.Buffer
-	if _, err := io.Copy(&buf, httpResp.Body); err != nil {
-		return nil, fmt.Errorf("openai: failed to read audio data: %w", err)
-	}
-
-	format := req.Format
-	if format == "" {
-		format = "mp3" // OpenAI default
-	}
-
-	result := &allm.SpeechResponse{
-		Audio:    buf.Bytes(),
-		Format:   format,
-		Provider: "openai",
-		Model:    model,
-		Latency:  time.Since(start),
-	}
-
-	if p.logger != nil {
-		p.logger.Debug("provider speak done",
-			"provider", "openai",
-			"model", model,
-			"latency", result.Latency,
-			"bytes", len(result.Audio),
-		)
-	}
-
-	return result, nil
+	return httpResp, nil
 }
 
 // Transcribe converts speech to text using OpenAI Whisper.
@@ -373,6 +577,13 @@ func (p *OpenAIProvider) Transcribe(ctx context.Context, req *allm.TranscribeReq
 	if req.Prompt != "" {
 		params.Prompt = openai.String(req.Prompt)
 	}
+	if req.Verbose || req.WordTimestamps {
+		params.ResponseFormat = openai.AudioResponseFormatVerboseJSON
+		params.TimestampGranularities = []string{"segment"}
+		if req.WordTimestamps {
+			params.TimestampGranularities = append(params.TimestampGranularities, "word")
+		}
+	}
 
 	transcription, err := p.client.Audio.Transcriptions.New(ctx, params)
 	if err != nil {
@@ -386,13 +597,9 @@ func (p *OpenAIProvider) Transcribe(ctx context.Context, req *allm.TranscribeReq
 		return nil, wrapOpenAIError(err)
 	}
 
-	result := &allm.TranscribeResponse{
-		Text:     transcription.Text,
-		Language: req.Language, // OpenAI doesn't return detected language in basic response
-		Provider: "openai",
-		Model:    model,
-		Latency:  time.Since(start),
-	}
+	result := openaiTranscribeResponse(transcription, req.Language)
+	result.Model = model
+	result.Latency = time.Since(start)
 
 	if p.logger != nil {
 		p.logger.Debug("provider transcribe done",
@@ -415,9 +622,13 @@ func (p *OpenAIProvider) buildClient() openai.Client {
 
 	opts := []option.RequestOption{
 		option.WithAPIKey(p.apiKey),
+		option.WithMiddleware(injectHeaders),
 	}
 	if p.baseURL != "" {
 		opts = append(opts, option.WithBaseURL(p.baseURL))
 	}
+	if p.httpClient != nil {
+		opts = append(opts, option.WithHTTPClient(p.httpClient))
+	}
 	return openai.NewClient(opts...)
 }
@@ -1,10 +1,13 @@
 package provider
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"strings"
 	"testing"
 	"time"
 
@@ -341,7 +344,7 @@ func TestConvertToOpenAI(t *testing.T) {
 		{Role: allm.RoleAssistant, Content: "Hi there!"},
 	}
 
-	result, err := convertToOpenAI(msgs)
+	result, err := convertToOpenAI(msgs, documentFileParts)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -372,7 +375,7 @@ func TestConvertToOpenAIToolCalls(t *testing.T) {
 		},
 	}
 
-	result, err := convertToOpenAI(msgs)
+	result, err := convertToOpenAI(msgs, documentFileParts)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -401,7 +404,7 @@ func TestConvertToOpenAIToolResults(t *testing.T) {
 		},
 	}
 
-	result, err := convertToOpenAI(msgs)
+	result, err := convertToOpenAI(msgs, documentFileParts)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -606,6 +609,32 @@ func TestOpenAICompleteResponseWithoutReasoning(t *testing.T) {
 	}
 }
 
+func TestOpenAICompleteResponseUsageDetails(t *testing.T) {
+	raw := `{
+		"id": "test",
+		"object": "chat.completion",
+		"choices": [{"index": 0, "message": {"role": "assistant", "content": "ok"}, "finish_reason": "stop"}],
+		"usage": {
+			"prompt_tokens": 100, "completion_tokens": 50,
+			"prompt_tokens_details": {"cached_tokens": 80},
+			"completion_tokens_details": {"reasoning_tokens": 30}
+		}
+	}`
+
+	var completion openai.ChatCompletion
+	if err := json.Unmarshal([]byte(raw), &completion); err != nil {
+		t.Fatalf("failed to unmarshal: %v", err)
+	}
+
+	resp, err := openaiCompleteResponse(&completion, "openai", "o3", time.Now())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.InputTokens != 100 || resp.CacheReadTokens != 80 || resp.ThinkingTokens != 30 {
+		t.Errorf("usage = in %d, cache read %d, thinking %d", resp.InputTokens, resp.CacheReadTokens, resp.ThinkingTokens)
+	}
+}
+
 func TestOpenAICompleteResponseEmpty(t *testing.T) {
 	completion := &openai.ChatCompletion{
 		Choices: []openai.ChatCompletionChoice{},
@@ -668,7 +697,7 @@ func TestEffortToReasoningEffort(t *testing.T) {
 func TestOpenAIChatParamsWithEffort(t *testing.T) {
 	msgs, _ := convertToOpenAI([]allm.Message{
 		{Role: allm.RoleUser, Content: "hello"},
-	})
+	}, documentFileParts)
 	req := &allm.Request{
 		Messages: []allm.Message{{Role: allm.RoleUser, Content: "hello"}},
 		Effort:   allm.EffortHigh,
@@ -682,7 +711,7 @@ func TestOpenAIChatParamsWithEffort(t *testing.T) {
 func TestOpenAIChatParamsWithoutEffort(t *testing.T) {
 	msgs, _ := convertToOpenAI([]allm.Message{
 		{Role: allm.RoleUser, Content: "hello"},
-	})
+	}, documentFileParts)
 	req := &allm.Request{
 		Messages: []allm.Message{{Role: allm.RoleUser, Content: "hello"}},
 	}
@@ -691,3 +720,302 @@ func TestOpenAIChatParamsWithoutEffort(t *testing.T) {
 		t.Errorf("ReasoningEffort = %q, want empty", params.ReasoningEffort)
 	}
 }
+
+func TestOpenAIModerationResult(t *testing.T) {
+	var m openai.Moderation
+	raw := `{
+		"flagged": true,
+		"categories": {"hate": false, "violence": true, "violence/graphic": null},
+		"category_scores": {"hate": 0.01, "violence": 0.92, "violence/graphic": 0.4},
+		"category_applied_input_types": {}
+	}`
+	if err := json.Unmarshal([]byte(raw), &m); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+
+	result := openaiModerationResult(m)
+	if !result.Flagged {
+		t.Error("expected flagged")
+	}
+	if !result.Categories["violence"] || result.Categories["hate"] {
+		t.Errorf("unexpected categories: %v", result.Categories)
+	}
+	if _, ok := result.Categories["violence/graphic"]; ok {
+		t.Error("null category should be skipped")
+	}
+	if result.Scores["violence"] != 0.92 || result.Scores["violence/graphic"] != 0.4 {
+		t.Errorf("unexpected scores: %v", result.Scores)
+	}
+}
+
+func TestConvertToOpenAIFileReferences(t *testing.T) {
+	msgs := []allm.Message{{
+		Role:      allm.RoleUser,
+		Content:   "Compare these",
+		Images:    []allm.Image{{FileID: "file-img"}},
+		Documents: []allm.Document{{FileID: "file-doc", Name: "a.pdf"}},
+	}}
+	result, err := convertToOpenAI(msgs, documentFileParts)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	data, _ := json.Marshal(result[0])
+	for _, want := range []string{`"type":"file"`, `"file_id":"file-img"`, `"file_id":"file-doc"`} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("expected %s in %s", want, data)
+		}
+	}
+
+}
+
+func TestConvertToOpenAIDocuments(t *testing.T) {
+	msgs := []allm.Message{{
+		Role:    allm.RoleUser,
+		Content: "Summarize",
+		Documents: []allm.Document{
+			{MimeType: "application/pdf", Data: []byte("%PDF-1.4"), Name: "spec.pdf"},
+			{MimeType: "text/markdown", Data: []byte("# Notes"), Name: "notes.md"},
+		},
+	}}
+	result, err := convertToOpenAI(msgs, documentFileParts)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	data, _ := json.Marshal(result[0])
+	for _, want := range []string{
+		`"file_data":"data:application/pdf;base64,JVBERi0xLjQ="`,
+		`"filename":"spec.pdf"`,
+		`\u003cdocument name=\"notes.md\"\u003e\n# Notes\n\u003c/document\u003e`,
+	} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("expected %s in %s", want, data)
+		}
+	}
+
+	_, err = convertToOpenAI([]allm.Message{{
+		Role:      allm.RoleUser,
+		Documents: []allm.Document{{MimeType: "application/msword", Data: []byte("doc")}},
+	}}, documentFileParts)
+	if !errors.Is(err, allm.ErrNotSupported) {
+		t.Errorf("expected ErrNotSupported for Word document, got %v", err)
+	}
+
+	_, err = convertToOpenAI([]allm.Message{{
+		Role:      allm.RoleSystem,
+		Documents: []allm.Document{{MimeType: "text/plain", Data: []byte("x")}},
+	}}, documentFileParts)
+	if !errors.Is(err, allm.ErrNotSupported) {
+		t.Errorf("expected ErrNotSupported for system document, got %v", err)
+	}
+}
+
+func TestConvertToOpenAIDocumentTextFallback(t *testing.T) {
+	pdf := []byte("%PDF-1.4\n1 0 obj\n<< /Length 44 >>\nstream\nBT /F1 12 Tf 72 712 Td (Quarterly report) Tj ET\nendstream\nendobj\n%%EOF")
+	msgs := []allm.Message{{
+		Role:      allm.RoleUser,
+		Content:   "Summarize",
+		Documents: []allm.Document{{MimeType: "application/pdf", Data: pdf, Name: "q3.pdf"}},
+	}}
+	result, err := convertToOpenAI(msgs, documentExtractText)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	data, _ := json.Marshal(result[0])
+	if !strings.Contains(string(data), "Quarterly report") || strings.Contains(string(data), "file_data") {
+		t.Errorf("expected extracted text part, got %s", data)
+	}
+
+	_, err = convertToOpenAI([]allm.Message{{
+		Role:      allm.RoleUser,
+		Documents: []allm.Document{{FileID: "file-1"}},
+	}}, documentExtractText)
+	if !errors.Is(err, allm.ErrNotSupported) {
+		t.Errorf("expected ErrNotSupported for file reference, got %v", err)
+	}
+}
+
+func TestCompatDocumentMode(t *testing.T) {
+	p := OpenAICompatible(allm.Local, "")
+	if p.documentMode() != documentFileParts {
+		t.Error("expected file parts by default")
+	}
+	p = OpenAICompatible(allm.Local, "", WithDocumentTextFallback())
+	if p.documentMode() != documentExtractText {
+		t.Error("expected text extraction with WithDocumentTextFallback")
+	}
+}
+
+func TestAnthropicBuildParamsFileReferences(t *testing.T) {
+	p := Anthropic("test-key")
+	req := &allm.Request{Messages: []allm.Message{{
+		Role:      allm.RoleUser,
+		Content:   "Describe",
+		Images:    []allm.Image{{FileID: "file_img"}},
+		Documents: []allm.Document{{FileID: "file_doc", Name: "spec.pdf"}},
+	}}}
+	params, err := p.buildParams(req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	data, _ := json.Marshal(params)
+	for _, want := range []string{
+		`{"source":{"file_id":"file_img","type":"file"},"type":"image"}`,
+		`{"source":{"file_id":"file_doc","type":"file"},"title":"spec.pdf","type":"document"}`,
+	} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("expected %s in %s", want, data)
+		}
+	}
+
+	if len(anthropicFilesBeta(req)) != 1 {
+		t.Error("expected files beta header for file references")
+	}
+	if anthropicFilesBeta(&allm.Request{Messages: []allm.Message{{Role: allm.RoleUser, Content: "hi"}}}) != nil {
+		t.Error("expected no beta header without file references")
+	}
+}
+
+func TestAnthropicBuildParamsCacheControl(t *testing.T) {
+	p := Anthropic("test-key")
+	hour := &allm.CacheControl{Type: allm.CacheEphemeral, TTL: allm.CacheTTL1h}
+	req := &allm.Request{
+		Messages: []allm.Message{
+			{Role: allm.RoleUser, Content: "Read", Documents: []allm.Document{{FileID: "file_doc"}}, CacheControl: hour},
+			{Role: allm.RoleTool, ToolResults: []allm.ToolResult{{ToolCallID: "1", Content: "ok"}}, CacheControl: &allm.CacheControl{Type: allm.CacheEphemeral}},
+			{Role: allm.RoleUser, Content: "Look", Images: []allm.Image{{MimeType: "image/png", Data: []byte("x")}}, CacheControl: hour},
+		},
+		Tools: []allm.Tool{{Name: "lookup", CacheControl: hour}},
+	}
+	params, err := p.buildParams(req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if cc := params.Tools[0].OfTool.CacheControl; cc.TTL != "1h" {
+		t.Errorf("tool cache control = %+v", cc)
+	}
+	data, _ := json.Marshal(params.Messages[0])
+	if !strings.Contains(string(data), `"cache_control":{"ttl":"1h","type":"ephemeral"}`) {
+		t.Errorf("file document breakpoint missing: %s", data)
+	}
+	if cc := params.Messages[1].Content[0].OfToolResult.CacheControl; cc.Type == "" || cc.TTL != "" {
+		t.Errorf("tool result cache control = %+v", cc)
+	}
+	last := params.Messages[2].Content
+	if last[0].OfText.CacheControl.Type != "" || last[1].OfImage.CacheControl.TTL != "1h" {
+		t.Errorf("message breakpoint should be on the last block: %+v", last)
+	}
+}
+
+func TestOpenAIFileConversion(t *testing.T) {
+	f := openaiFile(openai.FileObject{ID: "file-1", Filename: "a.pdf", Bytes: 42, CreatedAt: 1700000000, Purpose: "user_data"})
+	if f.ID != "file-1" || f.Name != "a.pdf" || f.Size != 42 || f.Purpose != "user_data" || f.Provider != "openai" {
+		t.Errorf("unexpected file: %+v", f)
+	}
+	if f.CreatedAt.Unix() != 1700000000 {
+		t.Errorf("unexpected created_at: %v", f.CreatedAt)
+	}
+}
+
+func TestOpenAIImageResponse(t *testing.T) {
+	result := &openai.ImagesResponse{
+		Data: []openai.Image{
+			{B64JSON: "iVBORw==", RevisedPrompt: "a cute cat"},
+			{URL: "https://example.com/a.png"},
+		},
+	}
+	resp, err := openaiImageResponse(result, "gpt-image-1", "webp", time.Now())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(resp.Images[0].Data) != "\x89PNG" || resp.Images[0].MimeType != "image/webp" {
+		t.Errorf("unexpected decoded image: %+v", resp.Images[0])
+	}
+	if resp.Images[1].Data != nil || resp.Images[1].URL == "" {
+		t.Errorf("unexpected URL image: %+v", resp.Images[1])
+	}
+
+	_, err = openaiImageResponse(&openai.ImagesResponse{Data: []openai.Image{{B64JSON: "!!"}}}, "dall-e-3", "", time.Now())
+	if err == nil {
+		t.Error("expected error for invalid base64")
+	}
+
+	if !isGPTImageModel("gpt-image-1") || isGPTImageModel("dall-e-3") {
+		t.Error("unexpected isGPTImageModel result")
+	}
+}
+
+func TestOpenAITranscribeResponse(t *testing.T) {
+	var result openai.AudioTranscriptionNewResponseUnion
+	raw := `{"text":"Hello world.","language":"english","duration":2.5,` +
+		`"segments":[{"id":0,"start":0,"end":2.5,"text":" Hello world."}],` +
+		`"words":[{"word":"Hello","start":0,"end":1},{"word":"world","start":1.2,"end":2.4}]}`
+	if err := json.Unmarshal([]byte(raw), &result); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+
+	resp := openaiTranscribeResponse(&result, "en")
+	if resp.Text != "Hello world." || resp.Language != "english" || resp.Duration != 2.5 || resp.Provider != "openai" {
+		t.Errorf("unexpected response: %+v", resp)
+	}
+	if len(resp.Segments) != 1 || resp.Segments[0].End != 2.5 {
+		t.Errorf("unexpected segments: %+v", resp.Segments)
+	}
+	if len(resp.Words) != 2 || resp.Words[1].Word != "world" || resp.Words[1].Start != 1.2 {
+		t.Errorf("unexpected words: %+v", resp.Words)
+	}
+
+	// Plain JSON responses keep the requested language
+	result = openai.AudioTranscriptionNewResponseUnion{}
+	_ = json.Unmarshal([]byte(`{"text":"Hi"}`), &result)
+	if resp := openaiTranscribeResponse(&result, "en"); resp.Language != "en" || resp.Segments != nil {
+		t.Errorf("unexpected plain response: %+v", resp)
+	}
+}
+
+func TestProviderCapabilities(t *testing.T) {
+	var _ allm.CapabilityReporter = (*AnthropicProvider)(nil)
+	var _ allm.CapabilityReporter = (*OpenAIProvider)(nil)
+	var _ allm.CapabilityReporter = (*OpenAICompatibleProvider)(nil)
+	var _ allm.CapabilityReporter = (*ClaudeCLIProvider)(nil)
+
+	// Computer use is accepted by the type but not sent yet.
+	if caps := Anthropic("k").Capabilities(); !caps.Thinking || caps.JSONSchema || caps.ComputerUse {
+		t.Errorf("Anthropic capabilities = %+v", caps)
+	}
+	if caps := GLM("k").Capabilities(); caps.ComputerUse {
+		t.Error("GLM should not report computer use")
+	}
+	if caps := OpenAI("k").Capabilities(); !caps.JSONSchema || !caps.Prediction || caps.Thinking {
+		t.Errorf("OpenAI capabilities = %+v", caps)
+	}
+	if caps := OpenAICompatible("local", "").Capabilities(); caps.Prediction || !caps.Tools {
+		t.Errorf("compatible capabilities = %+v", caps)
+	}
+	if caps := ClaudeCLI().Capabilities(); caps.Vision || caps.Tools || !caps.Effort {
+		t.Errorf("CLI capabilities = %+v", caps)
+	}
+}
+
+func TestAnthropicCacheWrite1hTokens(t *testing.T) {
+	body := `{"id":"msg_1","type":"message","role":"assistant","model":"claude-sonnet-4-6",
+		"content":[{"type":"text","text":"ok"}],"stop_reason":"end_turn",
+		"usage":{"input_tokens":10,"output_tokens":5,"cache_read_input_tokens":0,"cache_creation_input_tokens":300,
+			"cache_creation":{"ephemeral_5m_input_tokens":100,"ephemeral_1h_input_tokens":200}}}`
+	client := &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": {"application/json"}},
+			Body:       io.NopCloser(strings.NewReader(body)),
+			Request:    r,
+		}, nil
+	})}
+	p := Anthropic("test-key", WithAnthropicHTTPClient(client))
+	resp, err := p.Complete(context.Background(), &allm.Request{Messages: []allm.Message{{Role: allm.RoleUser, Content: "hi"}}, MaxTokens: 64})
+	if err != nil {
+		t.Fatalf("Complete: %v", err)
+	}
+	if resp.CacheWriteTokens != 300 || resp.CacheWrite1hTokens != 200 {
+		t.Errorf("cache writes = %d (1h: %d), want 300 (1h: 200)", resp.CacheWriteTokens, resp.CacheWrite1hTokens)
+	}
+}
@@ -0,0 +1,419 @@
+package provider
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/kusandriadi/allm-go"
+)
+
+// openaiRealtimeModel is the default OpenAI realtime model.
+const openaiRealtimeModel = "gpt-realtime"
+
+// realtimeEventBuffer is the capacity of a realtime session's event channel.
+const realtimeEventBuffer = 64
+
+// ConnectRealtime opens a session with the OpenAI Realtime API over WebSocket.
+func (p *OpenAIProvider) ConnectRealtime(ctx context.Context, cfg *allm.RealtimeConfig) (allm.RealtimeSession, error) {
+	model := resolveModel(cfg.Model, openaiRealtimeModel)
+	wsURL, err := openaiRealtimeURL(p.baseURL, model)
+	if err != nil {
+		return nil, err
+	}
+
+	if p.logger != nil {
+		p.logger.Debug("provider realtime connect",
+			"provider", "openai",
+			"model", model,
+		)
+	}
+
+	header := http.Header{}
+	header.Set("Authorization", "Bearer "+p.apiKey)
+	session, err := dialRealtime(ctx, wsURL, header, p.httpClient, cfg, p.logger)
+	if err != nil {
+		if p.logger != nil {
+			p.logger.Debug("provider realtime connect failed",
+				"provider", "openai",
+				"model", model,
+				"error", sanitizeProviderError(err),
+			)
+		}
+		return nil, err
+	}
+	return session, nil
+}
+
+// openaiRealtimeURL derives the realtime WebSocket URL from the API base URL.
+func openaiRealtimeURL(baseURL, model string) (string, error) {
+	if baseURL == "" {
+		baseURL = "https://api.openai.com/v1"
+	}
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid base URL: %w", err)
+	}
+	switch u.Scheme {
+	case "https":
+		u.Scheme = "wss"
+	case "http":
+		u.Scheme = "ws"
+	default:
+		return "", fmt.Errorf("base URL must use http or https scheme, got: %s", u.Scheme)
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/realtime"
+	u.RawQuery = url.Values{"model": {model}}.Encode()
+	return u.String(), nil
+}
+
+// openaiRealtimeSession implements allm.RealtimeSession.
+type openaiRealtimeSession struct {
+	conn      *wsConn
+	events    chan allm.RealtimeEvent
+	done      chan struct{}
+	closeOnce sync.Once
+	logger    allm.Logger
+}
+
+// dialRealtime connects to a realtime WebSocket URL through client's proxy
+// settings, applies cfg and starts reading server events.
+func dialRealtime(ctx context.Context, wsURL string, header http.Header, client *http.Client, cfg *allm.RealtimeConfig, logger allm.Logger) (*openaiRealtimeSession, error) {
+	conn, err := dialWebSocket(ctx, wsURL, header, client)
+	if err != nil {
+		return nil, fmt.Errorf("openai realtime: %w", err)
+	}
+
+	s := &openaiRealtimeSession{
+		conn:   conn,
+		events: make(chan allm.RealtimeEvent, realtimeEventBuffer),
+		done:   make(chan struct{}),
+		logger: logger,
+	}
+	if err := s.UpdateSession(ctx, cfg); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	go s.readLoop()
+	return s, nil
+}
+
+// send writes a client event.
+func (s *openaiRealtimeSession) send(ctx context.Context, event map[string]any) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("openai realtime: encode event: %w", err)
+	}
+	if err := s.conn.WriteMessage(ctx, wsText, data); err != nil {
+		return fmt.Errorf("openai realtime: %w", err)
+	}
+	return nil
+}
+
+// SendAudio appends audio to the input buffer.
+func (s *openaiRealtimeSession) SendAudio(ctx context.Context, audio []byte) error {
+	return s.send(ctx, map[string]any{
+		"type":  "input_audio_buffer.append",
+		"audio": base64.StdEncoding.EncodeToString(audio),
+	})
+}
+
+// CommitAudio commits the input buffer as a user turn.
+func (s *openaiRealtimeSession) CommitAudio(ctx context.Context) error {
+	return s.send(ctx, map[string]any{"type": "input_audio_buffer.commit"})
+}
+
+// ClearAudio discards uncommitted input audio.
+func (s *openaiRealtimeSession) ClearAudio(ctx context.Context) error {
+	return s.send(ctx, map[string]any{"type": "input_audio_buffer.clear"})
+}
+
+// SendText adds a user text message to the conversation.
+func (s *openaiRealtimeSession) SendText(ctx context.Context, text string) error {
+	return s.send(ctx, map[string]any{
+		"type": "conversation.item.create",
+		"item": map[string]any{
+			"type":    "message",
+			"role":    "user",
+			"content": []map[string]any{{"type": "input_text", "text": text}},
+		},
+	})
+}
+
+// SendToolResult adds a function call output to the conversation.
+func (s *openaiRealtimeSession) SendToolResult(ctx context.Context, result allm.ToolResult) error {
+	return s.send(ctx, map[string]any{
+		"type": "conversation.item.create",
+		"item": map[string]any{
+			"type":    "function_call_output",
+			"call_id": result.ToolCallID,
+			"output":  result.Content,
+		},
+	})
+}
+
+// CreateResponse asks the model to respond.
+func (s *openaiRealtimeSession) CreateResponse(ctx context.Context) error {
+	return s.send(ctx, map[string]any{"type": "response.create"})
+}
+
+// CancelResponse interrupts the in-progress response.
+func (s *openaiRealtimeSession) CancelResponse(ctx context.Context) error {
+	return s.send(ctx, map[string]any{"type": "response.cancel"})
+}
+
+// UpdateSession sends a session.update event.
+func (s *openaiRealtimeSession) UpdateSession(ctx context.Context, cfg *allm.RealtimeConfig) error {
+	return s.send(ctx, map[string]any{
+		"type":    "session.update",
+		"session": openaiRealtimeSessionConfig(cfg),
+	})
+}
+
+// Events returns the server events.
+func (s *openaiRealtimeSession) Events() <-chan allm.RealtimeEvent {
+	return s.events
+}
+
+// Close ends the session.
+func (s *openaiRealtimeSession) Close() error {
+	var err error
+	s.closeOnce.Do(func() {
+		close(s.done)
+		err = s.conn.Close()
+	})
+	return err
+}
+
+// readLoop converts server events until the connection ends.
+func (s *openaiRealtimeSession) readLoop() {
+	defer close(s.events)
+	for {
+		_, data, err := s.conn.ReadMessage()
+		if err != nil {
+			select {
+			case <-s.done:
+			default:
+				if !errors.Is(err, errWSClosed) {
+					s.emit(allm.RealtimeEvent{Type: allm.RealtimeError, Error: fmt.Errorf("openai realtime: %w", err)})
+				}
+				_ = s.Close()
+			}
+			return
+		}
+
+		event, ok := openaiRealtimeEvent(data)
+		if !ok {
+			continue
+		}
+		if !s.emit(event) {
+			return
+		}
+	}
+}
+
+// emit delivers an event unless the session was closed.
+func (s *openaiRealtimeSession) emit(event allm.RealtimeEvent) bool {
+	select {
+	case s.events <- event:
+		return true
+	case <-s.done:
+		return false
+	}
+}
+
+// openaiRealtimeSessionConfig converts a RealtimeConfig to a session object.
+func openaiRealtimeSessionConfig(cfg *allm.RealtimeConfig) map[string]any {
+	session := map[string]any{"type": "realtime"}
+	if cfg.Instructions != "" {
+		session["instructions"] = cfg.Instructions
+	}
+	if len(cfg.Modalities) > 0 {
+		session["output_modalities"] = cfg.Modalities
+	}
+	if cfg.MaxOutputTokens > 0 {
+		session["max_output_tokens"] = cfg.MaxOutputTokens
+	}
+
+	input := map[string]any{}
+	if cfg.InputAudioFormat != "" {
+		input["format"] = openaiRealtimeAudioFormat(cfg.InputAudioFormat)
+	}
+	if cfg.InputTranscription != "" {
+		input["transcription"] = map[string]any{"model": cfg.InputTranscription}
+	}
+	if td := cfg.TurnDetection; td != nil {
+		input["turn_detection"] = openaiTurnDetection(td)
+	}
+	output := map[string]any{}
+	if cfg.OutputAudioFormat != "" {
+		output["format"] = openaiRealtimeAudioFormat(cfg.OutputAudioFormat)
+	}
+	if cfg.Voice != "" {
+		output["voice"] = cfg.Voice
+	}
+	audio := map[string]any{}
+	if len(input) > 0 {
+		audio["input"] = input
+	}
+	if len(output) > 0 {
+		audio["output"] = output
+	}
+	if len(audio) > 0 {
+		session["audio"] = audio
+	}
+
+	if len(cfg.Tools) > 0 {
+		tools := make([]map[string]any, 0, len(cfg.Tools))
+		for _, t := range cfg.Tools {
+			tools = append(tools, map[string]any{
+				"type":        "function",
+				"name":        t.Name,
+				"description": t.Description,
+				"parameters":  t.Parameters,
+			})
+		}
+		session["tools"] = tools
+	}
+	return session
+}
+
+// openaiRealtimeAudioFormat maps an allm audio format name to a realtime format object.
+func openaiRealtimeAudioFormat(format string) map[string]any {
+	switch format {
+	case "g711_ulaw":
+		return map[string]any{"type": "audio/pcmu"}
+	case "g711_alaw":
+		return map[string]any{"type": "audio/pcma"}
+	default:
+		return map[string]any{"type": "audio/pcm", "rate": 24000}
+	}
+}
+
+// openaiTurnDetection converts turn detection settings; TurnDetectionNone disables it.
+func openaiTurnDetection(td *allm.TurnDetection) any {
+	switch td.Type {
+	case allm.TurnDetectionNone:
+		return nil
+	case allm.TurnDetectionSemanticVAD:
+		return map[string]any{"type": allm.TurnDetectionSemanticVAD}
+	}
+	vad := map[string]any{"type": allm.TurnDetectionServerVAD}
+	if td.Threshold > 0 {
+		vad["threshold"] = td.Threshold
+	}
+	if td.PrefixPadding > 0 {
+		vad["prefix_padding_ms"] = td.PrefixPadding.Milliseconds()
+	}
+	if td.SilenceDuration > 0 {
+		vad["silence_duration_ms"] = td.SilenceDuration.Milliseconds()
+	}
+	return vad
+}
+
+// openaiRealtimeServerEvent holds the fields of the server events allm maps.
+type openaiRealtimeServerEvent struct {
+	Type       string `json:"type"`
+	Delta      string `json:"delta"`
+	Transcript string `json:"transcript"`
+	ItemID     string `json:"item_id"`
+	ResponseID string `json:"response_id"`
+	Item       *struct {
+		ID        string `json:"id"`
+		Type      string `json:"type"`
+		CallID    string `json:"call_id"`
+		Name      string `json:"name"`
+		Arguments string `json:"arguments"`
+	} `json:"item"`
+	Response *struct {
+		ID    string `json:"id"`
+		Usage *struct {
+			InputTokens  int `json:"input_tokens"`
+			OutputTokens int `json:"output_tokens"`
+		} `json:"usage"`
+	} `json:"response"`
+	Error *struct {
+		Type    string `json:"type"`
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// openaiRealtimeEvent converts a server event. ok is false for events allm
+// does not surface. Both GA and beta event names are accepted.
+func openaiRealtimeEvent(data []byte) (event allm.RealtimeEvent, ok bool) {
+	var ev openaiRealtimeServerEvent
+	if err := json.Unmarshal(data, &ev); err != nil {
+		return allm.RealtimeEvent{
+			Type:  allm.RealtimeError,
+			Error: fmt.Errorf("%w: openai realtime: invalid server event: %w", allm.ErrProvider, err),
+		}, true
+	}
+
+	event = allm.RealtimeEvent{ResponseID: ev.ResponseID, ItemID: ev.ItemID}
+	switch ev.Type {
+	case "session.updated":
+		event.Type = allm.RealtimeSessionUpdated
+	case "input_audio_buffer.speech_started":
+		event.Type = allm.RealtimeSpeechStarted
+	case "input_audio_buffer.speech_stopped":
+		event.Type = allm.RealtimeSpeechStopped
+	case "conversation.item.input_audio_transcription.completed":
+		event.Type = allm.RealtimeInputTranscript
+		event.Text = ev.Transcript
+	case "response.output_audio.delta", "response.audio.delta":
+		audio, err := base64.StdEncoding.DecodeString(ev.Delta)
+		if err != nil {
+			return allm.RealtimeEvent{
+				Type:  allm.RealtimeError,
+				Error: fmt.Errorf("%w: openai realtime: invalid audio delta: %w", allm.ErrProvider, err),
+			}, true
+		}
+		event.Type = allm.RealtimeAudioDelta
+		event.Audio = audio
+	case "response.output_audio_transcript.delta", "response.audio_transcript.delta":
+		event.Type = allm.RealtimeTranscriptDelta
+		event.Text = ev.Delta
+	case "response.output_text.delta", "response.text.delta":
+		event.Type = allm.RealtimeTextDelta
+		event.Text = ev.Delta
+	case "response.output_item.done":
+		if ev.Item == nil || ev.Item.Type != "function_call" {
+			return allm.RealtimeEvent{}, false
+		}
+		event.Type = allm.RealtimeToolCall
+		event.ItemID = ev.Item.ID
+		event.ToolCall = &allm.ToolCall{
+			ID:        ev.Item.CallID,
+			Name:      ev.Item.Name,
+			Arguments: json.RawMessage(ev.Item.Arguments),
+		}
+	case "response.done":
+		event.Type = allm.RealtimeResponseDone
+		if ev.Response != nil {
+			event.ResponseID = ev.Response.ID
+			if u := ev.Response.Usage; u != nil {
+				event.Usage = &allm.StreamUsage{InputTokens: u.InputTokens, OutputTokens: u.OutputTokens}
+			}
+		}
+	case "error":
+		msg := "unknown error"
+		if ev.Error != nil {
+			msg = ev.Error.Message
+			if ev.Error.Code != "" {
+				msg = ev.Error.Code + ": " + msg
+			}
+		}
+		event.Type = allm.RealtimeError
+		event.Error = fmt.Errorf("%w: openai realtime: %s", allm.ErrProvider, msg)
+	default:
+		return allm.RealtimeEvent{}, false
+	}
+	return event, true
+}
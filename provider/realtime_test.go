@@ -0,0 +1,379 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kusandriadi/allm-go"
+)
+
+// newWSServer starts a local WebSocket stand-in server that runs handle for
+// each upgraded connection.
+func newWSServer(t *testing.T, handle func(c *wsConn, r *http.Request)) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Upgrade") != "websocket" || r.Header.Get("Sec-WebSocket-Version") != "13" {
+			http.Error(w, "upgrade required", http.StatusUpgradeRequired)
+			return
+		}
+		conn, brw, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			return
+		}
+		_, _ = fmt.Fprintf(brw, "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Accept: %s\r\n\r\n",
+			wsAccept(r.Header.Get("Sec-WebSocket-Key")))
+		_ = brw.Flush()
+		c := &wsConn{conn: conn, br: brw.Reader}
+		defer func() { _ = c.Close() }()
+		handle(c, r)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// wsURL converts an httptest server URL to a ws:// URL.
+func wsURL(srv *httptest.Server, path string) string {
+	return "ws" + strings.TrimPrefix(srv.URL, "http") + path
+}
+
+// readEvent reads a client event from the server side.
+// It returns nil once the client has closed the connection.
+func readEvent(t *testing.T, c *wsConn) map[string]any {
+	t.Helper()
+	op, data, err := c.ReadMessage()
+	if err != nil {
+		return nil
+	}
+	if op != wsText {
+		t.Errorf("expected text frame, got opcode %d", op)
+	}
+	var event map[string]any
+	if err := json.Unmarshal(data, &event); err != nil {
+		t.Errorf("server decode: %v", err)
+	}
+	return event
+}
+
+func writeEvent(c *wsConn, event string) {
+	_ = c.WriteMessage(context.Background(), wsText, []byte(event))
+}
+
+func TestRealtimeSession(t *testing.T) {
+	received := make(chan map[string]any, 10)
+	srv := newWSServer(t, func(c *wsConn, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer test-key" {
+			t.Errorf("unexpected authorization header %q", r.Header.Get("Authorization"))
+		}
+		if r.URL.Query().Get("model") != "gpt-realtime" {
+			t.Errorf("unexpected model query %q", r.URL.RawQuery)
+		}
+		for {
+			event := readEvent(t, c)
+			if event == nil {
+				return
+			}
+			received <- event
+			switch event["type"] {
+			case "session.update":
+				writeEvent(c, `{"type":"session.created"}`)
+				writeEvent(c, `{"type":"session.updated"}`)
+			case "input_audio_buffer.append":
+				writeEvent(c, `{"type":"input_audio_buffer.speech_started","item_id":"item_1"}`)
+				writeEvent(c, `{"type":"input_audio_buffer.speech_stopped","item_id":"item_1"}`)
+				writeEvent(c, `{"type":"conversation.item.input_audio_transcription.completed","item_id":"item_1","transcript":"weather?"}`)
+				writeEvent(c, `{"type":"response.output_audio.delta","response_id":"resp_1","delta":"`+base64.StdEncoding.EncodeToString([]byte("pcm"))+`"}`)
+				writeEvent(c, `{"type":"response.output_audio_transcript.delta","response_id":"resp_1","delta":"Let me"}`)
+				writeEvent(c, `{"type":"response.text.delta","response_id":"resp_1","delta":"beta"}`)
+				writeEvent(c, `{"type":"response.output_item.done","response_id":"resp_1","item":{"id":"item_2","type":"function_call","call_id":"call_1","name":"get_weather","arguments":"{\"city\":\"Jakarta\"}"}}`)
+				writeEvent(c, `{"type":"response.output_item.done","response_id":"resp_1","item":{"id":"item_3","type":"message"}}`)
+				writeEvent(c, `{"type":"response.done","response":{"id":"resp_1","usage":{"input_tokens":12,"output_tokens":34}}}`)
+				writeEvent(c, `{"type":"error","error":{"type":"invalid_request_error","code":"bad_event","message":"oops"}}`)
+			}
+		}
+	})
+
+	cfg := &allm.RealtimeConfig{
+		Instructions:       "Be brief.",
+		Voice:              "alloy",
+		InputTranscription: "whisper-1",
+		TurnDetection:      &allm.TurnDetection{Type: allm.TurnDetectionServerVAD, SilenceDuration: 500 * time.Millisecond},
+		Tools:              []allm.Tool{{Name: "get_weather", Description: "Weather", Parameters: map[string]any{"type": "object"}}},
+	}
+	header := http.Header{"Authorization": {"Bearer test-key"}}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	session, err := dialRealtime(ctx, wsURL(srv, "/v1/realtime?model=gpt-realtime"), header, nil, cfg, nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer func() { _ = session.Close() }()
+
+	update := <-received
+	sess := update["session"].(map[string]any)
+	audio := sess["audio"].(map[string]any)
+	input := audio["input"].(map[string]any)
+	vad := input["turn_detection"].(map[string]any)
+	if sess["type"] != "realtime" || sess["instructions"] != "Be brief." || vad["type"] != "server_vad" || vad["silence_duration_ms"] != 500.0 {
+		t.Errorf("unexpected session.update: %v", update)
+	}
+	if audio["output"].(map[string]any)["voice"] != "alloy" || len(sess["tools"].([]any)) != 1 {
+		t.Errorf("unexpected session.update: %v", update)
+	}
+
+	if err := session.SendAudio(ctx, []byte{1, 2, 3}); err != nil {
+		t.Fatalf("send audio: %v", err)
+	}
+	appendEvent := <-received
+	if appendEvent["audio"] != base64.StdEncoding.EncodeToString([]byte{1, 2, 3}) {
+		t.Errorf("unexpected append event: %v", appendEvent)
+	}
+
+	var events []allm.RealtimeEvent
+	for len(events) < 10 {
+		select {
+		case ev := <-session.Events():
+			events = append(events, ev)
+		case <-ctx.Done():
+			t.Fatalf("timed out after %d events", len(events))
+		}
+	}
+	wantTypes := []string{
+		allm.RealtimeSessionUpdated, allm.RealtimeSpeechStarted, allm.RealtimeSpeechStopped,
+		allm.RealtimeInputTranscript, allm.RealtimeAudioDelta, allm.RealtimeTranscriptDelta,
+		allm.RealtimeTextDelta, allm.RealtimeToolCall, allm.RealtimeResponseDone, allm.RealtimeError,
+	}
+	for i, want := range wantTypes {
+		if events[i].Type != want {
+			t.Errorf("event %d: expected %s, got %s", i, want, events[i].Type)
+		}
+	}
+	if events[3].Text != "weather?" || !bytes.Equal(events[4].Audio, []byte("pcm")) || events[4].ResponseID != "resp_1" {
+		t.Errorf("unexpected transcript/audio events: %+v %+v", events[3], events[4])
+	}
+	if call := events[7].ToolCall; call == nil || call.ID != "call_1" || call.Name != "get_weather" || string(call.Arguments) != `{"city":"Jakarta"}` {
+		t.Errorf("unexpected tool call: %+v", events[7])
+	}
+	if u := events[8].Usage; u == nil || u.InputTokens != 12 || u.OutputTokens != 34 {
+		t.Errorf("unexpected usage: %+v", events[8])
+	}
+	if !errors.Is(events[9].Error, allm.ErrProvider) || !strings.Contains(events[9].Error.Error(), "bad_event: oops") {
+		t.Errorf("unexpected error event: %v", events[9].Error)
+	}
+
+	if err := session.SendToolResult(ctx, allm.ToolResult{ToolCallID: "call_1", Content: "sunny"}); err != nil {
+		t.Fatalf("send tool result: %v", err)
+	}
+	item := (<-received)["item"].(map[string]any)
+	if item["type"] != "function_call_output" || item["call_id"] != "call_1" || item["output"] != "sunny" {
+		t.Errorf("unexpected tool result item: %v", item)
+	}
+
+	if err := session.SendText(ctx, "hi"); err != nil {
+		t.Fatalf("send text: %v", err)
+	}
+	if err := session.CreateResponse(ctx); err != nil {
+		t.Fatalf("create response: %v", err)
+	}
+	if (<-received)["type"] != "conversation.item.create" || (<-received)["type"] != "response.create" {
+		t.Error("unexpected events for SendText/CreateResponse")
+	}
+
+	if err := session.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	for range session.Events() {
+	}
+	if err := session.CreateResponse(ctx); err == nil {
+		t.Error("expected error sending on a closed session")
+	}
+}
+
+func TestRealtimeServerClose(t *testing.T) {
+	srv := newWSServer(t, func(c *wsConn, r *http.Request) {
+		readEvent(t, c) // session.update
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	session, err := dialRealtime(ctx, wsURL(srv, "/"), nil, nil, &allm.RealtimeConfig{}, nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer func() { _ = session.Close() }()
+
+	for ev := range session.Events() {
+		t.Errorf("unexpected event after normal close: %+v", ev)
+	}
+}
+
+func TestRealtimeHandshakeError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "slow down", http.StatusTooManyRequests)
+	}))
+	defer srv.Close()
+
+	_, err := dialRealtime(context.Background(), wsURL(srv, "/"), nil, nil, &allm.RealtimeConfig{}, nil)
+	if !errors.Is(err, allm.ErrRateLimited) {
+		t.Errorf("expected ErrRateLimited, got %v", err)
+	}
+}
+
+func TestRealtimeSessionConfig(t *testing.T) {
+	cfg := openaiRealtimeSessionConfig(&allm.RealtimeConfig{
+		Modalities:        []string{"text"},
+		InputAudioFormat:  "g711_ulaw",
+		OutputAudioFormat: "pcm16",
+		TurnDetection:     &allm.TurnDetection{Type: allm.TurnDetectionNone},
+		MaxOutputTokens:   100,
+	})
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	want := `{"audio":{"input":{"format":{"type":"audio/pcmu"},"turn_detection":null},"output":{"format":{"rate":24000,"type":"audio/pcm"}}},"max_output_tokens":100,"output_modalities":["text"],"type":"realtime"}`
+	if string(data) != want {
+		t.Errorf("session config:\n%s\nwant\n%s", data, want)
+	}
+
+	if got, _ := json.Marshal(openaiRealtimeSessionConfig(&allm.RealtimeConfig{})); string(got) != `{"type":"realtime"}` {
+		t.Errorf("expected minimal session config, got %s", got)
+	}
+}
+
+func TestOpenAIRealtimeURL(t *testing.T) {
+	tests := []struct {
+		base, want string
+	}{
+		{"", "wss://api.openai.com/v1/realtime?model=gpt-realtime"},
+		{"https://proxy.example.com/openai/v1/", "wss://proxy.example.com/openai/v1/realtime?model=gpt-realtime"},
+		{"http://gateway.example.com", "ws://gateway.example.com/realtime?model=gpt-realtime"},
+	}
+	for _, tt := range tests {
+		got, err := openaiRealtimeURL(tt.base, "gpt-realtime")
+		if err != nil || got != tt.want {
+			t.Errorf("openaiRealtimeURL(%q) = %q, %v; want %q", tt.base, got, err, tt.want)
+		}
+	}
+	if _, err := openaiRealtimeURL("ftp://example.com", "m"); err == nil {
+		t.Error("expected error for unsupported scheme")
+	}
+}
+
+func TestWebSocketFramingAndPing(t *testing.T) {
+	large := bytes.Repeat([]byte("x"), 70_000)
+	pong := make(chan []byte, 1)
+	srv := newWSServer(t, func(c *wsConn, r *http.Request) {
+		// Ping, then a message fragmented across three frames
+		c.writeMu.Lock()
+		_ = c.writeFrame(wsPing, []byte("hb"))
+		_, _ = c.conn.Write([]byte{0x01, 0x02, 'a', 'b'}) // text, not final
+		_, _ = c.conn.Write([]byte{0x00, 0x01, 'c'})      // continuation
+		_, _ = c.conn.Write([]byte{0x80, 0x01, 'd'})      // final continuation
+		c.writeMu.Unlock()
+
+		if _, frameOp, payload, err := c.readFrame(); err == nil && frameOp == wsPong {
+			pong <- payload
+		}
+		// Echo the next message (exercises 64-bit lengths and client masking)
+		op, data, err := c.ReadMessage()
+		if err == nil {
+			_ = c.WriteMessage(context.Background(), op, data)
+		}
+	})
+
+	c, err := dialWebSocket(context.Background(), wsURL(srv, "/"), nil, nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer func() { _ = c.Close() }()
+
+	op, data, err := c.ReadMessage()
+	if err != nil || op != wsText || string(data) != "abcd" {
+		t.Fatalf("unexpected fragmented message: op=%d data=%q err=%v", op, data, err)
+	}
+	if got := <-pong; string(got) != "hb" {
+		t.Errorf("unexpected pong payload %q", got)
+	}
+
+	if err := c.WriteMessage(context.Background(), wsBinary, large); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	op, data, err = c.ReadMessage()
+	if err != nil || op != wsBinary || !bytes.Equal(data, large) {
+		t.Fatalf("unexpected echo: op=%d len=%d err=%v", op, len(data), err)
+	}
+
+	if _, _, err := c.ReadMessage(); !errors.Is(err, errWSClosed) {
+		t.Errorf("expected errWSClosed, got %v", err)
+	}
+
+	if _, err := dialWebSocket(context.Background(), "http://example.com", nil, nil); err == nil {
+		t.Error("expected error for non-websocket scheme")
+	}
+}
+
+func TestWebSocketProxy(t *testing.T) {
+	srv := newWSServer(t, func(c *wsConn, r *http.Request) {
+		if op, data, err := c.ReadMessage(); err == nil {
+			_ = c.WriteMessage(context.Background(), op, data)
+		}
+	})
+
+	connects := make(chan string, 1)
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodConnect {
+			http.Error(w, "CONNECT only", http.StatusMethodNotAllowed)
+			return
+		}
+		connects <- r.Host + " " + r.Header.Get("Proxy-Authorization")
+		target, err := net.Dial("tcp", r.Host)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		defer func() { _ = target.Close() }()
+		conn, brw, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			return
+		}
+		defer func() { _ = conn.Close() }()
+		_, _ = brw.WriteString("HTTP/1.1 200 Connection established\r\n\r\n")
+		_ = brw.Flush()
+		go func() { _, _ = io.Copy(target, brw) }()
+		_, _ = io.Copy(conn, target)
+	}))
+	defer proxy.Close()
+
+	proxyURL, _ := url.Parse(proxy.URL)
+	proxyURL.User = url.UserPassword("user", "pass")
+	client := &http.Client{Transport: &http.Transport{Proxy: http.ProxyURL(proxyURL)}}
+	c, err := dialWebSocket(context.Background(), wsURL(srv, "/"), nil, client)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer func() { _ = c.Close() }()
+
+	want := strings.TrimPrefix(srv.URL, "http://") + " Basic " + base64.StdEncoding.EncodeToString([]byte("user:pass"))
+	if got := <-connects; got != want {
+		t.Errorf("CONNECT = %q, want %q", got, want)
+	}
+	if err := c.WriteMessage(context.Background(), wsText, []byte("hi")); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if op, data, err := c.ReadMessage(); err != nil || op != wsText || string(data) != "hi" {
+		t.Errorf("unexpected echo: op=%d data=%q err=%v", op, data, err)
+	}
+}
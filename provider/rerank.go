@@ -0,0 +1,285 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/kusandriadi/allm-go"
+)
+
+// rerankAPI identifies the wire format of a rerank endpoint.
+type rerankAPI int
+
+const (
+	// rerankCohere is the Cohere v2 format, also served by vLLM and Jina:
+	// {model, query, documents, top_n} → {results: [{index, relevance_score}]}.
+	rerankCohere rerankAPI = iota
+	// rerankTEI is the Hugging Face Text Embeddings Inference format:
+	// {query, texts} → [{index, score}].
+	rerankTEI
+)
+
+// RerankProvider implements allm.Reranker for dedicated rerank endpoints
+// (Cohere, vLLM, Hugging Face TEI). It is not a chat provider; plug it into
+// a client with allm.WithReranker:
+//
+//	client := allm.New(provider.OpenAI(""),
+//	    allm.WithReranker(provider.CohereRerank("")),
+//	)
+type RerankProvider struct {
+	name       string
+	api        rerankAPI
+	baseURL    string
+	path       string
+	apiKey     string
+	model      string
+	allowLocal bool
+	httpClient *http.Client
+	logger     allm.Logger
+}
+
+// RerankOption configures a RerankProvider.
+type RerankOption func(*RerankProvider)
+
+// WithRerankModel sets the default rerank model.
+func WithRerankModel(model string) RerankOption {
+	return func(p *RerankProvider) {
+		p.model = model
+	}
+}
+
+// WithRerankBaseURL sets a custom base URL (for proxies or self-hosted servers).
+func WithRerankBaseURL(url string) RerankOption {
+	return func(p *RerankProvider) {
+		p.baseURL = strings.TrimRight(url, "/")
+	}
+}
+
+// WithRerankHTTPClient sets the HTTP client used for rerank requests.
+func WithRerankHTTPClient(client *http.Client) RerankOption {
+	return func(p *RerankProvider) {
+		p.httpClient = client
+	}
+}
+
+// WithRerankLogger sets a logger for provider-level debug tracing.
+func WithRerankLogger(logger allm.Logger) RerankOption {
+	return func(p *RerankProvider) {
+		p.logger = logger
+	}
+}
+
+// CohereRerank creates a reranker for the Cohere v2 rerank API.
+// If apiKey is empty, it reads from COHERE_API_KEY environment variable.
+func CohereRerank(apiKey string, opts ...RerankOption) *RerankProvider {
+	if apiKey == "" {
+		apiKey = os.Getenv("COHERE_API_KEY")
+	}
+	return newRerankProvider(&RerankProvider{
+		name:    "cohere",
+		api:     rerankCohere,
+		baseURL: "https://api.cohere.com",
+		path:    "/v2/rerank",
+		apiKey:  apiKey,
+		model:   "rerank-v3.5",
+	}, opts)
+}
+
+// VLLMRerank creates a reranker for a vLLM server's Cohere-compatible /v1/rerank endpoint.
+// The baseURL is the server root (e.g., "http://localhost:8000"); empty uses that default.
+// The model parameter is the served cross-encoder model (e.g., "BAAI/bge-reranker-v2-m3").
+func VLLMRerank(baseURL, model string, opts ...RerankOption) *RerankProvider {
+	if baseURL == "" {
+		baseURL = "http://localhost:8000"
+	}
+	return newRerankProvider(&RerankProvider{
+		name:       "vllm",
+		api:        rerankCohere,
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		path:       "/v1/rerank",
+		model:      model,
+		allowLocal: true,
+	}, opts)
+}
+
+// TEIRerank creates a reranker for a Hugging Face Text Embeddings Inference server.
+// The baseURL is the server root (e.g., "http://localhost:8080"); empty uses that default.
+// TEI serves a single model, so no model name is sent.
+func TEIRerank(baseURL string, opts ...RerankOption) *RerankProvider {
+	if baseURL == "" {
+		baseURL = "http://localhost:8080"
+	}
+	return newRerankProvider(&RerankProvider{
+		name:       "tei",
+		api:        rerankTEI,
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		path:       "/rerank",
+		allowLocal: true,
+	}, opts)
+}
+
+// newRerankProvider applies options and validates the base URL.
+func newRerankProvider(p *RerankProvider, opts []RerankOption) *RerankProvider {
+	for _, opt := range opts {
+		opt(p)
+	}
+	if err := validateBaseURLProvider(p.baseURL, p.allowLocal); err != nil {
+		panic(fmt.Sprintf("%s: %v", p.name, err))
+	}
+	if p.httpClient == nil {
+		p.httpClient = http.DefaultClient
+	}
+	return p
+}
+
+// Name returns the provider name.
+func (p *RerankProvider) Name() string {
+	return p.name
+}
+
+// Available returns true if the reranker is configured.
+// Hosted APIs require an API key; self-hosted servers only need a base URL.
+func (p *RerankProvider) Available() bool {
+	if p.allowLocal {
+		return p.baseURL != ""
+	}
+	return p.apiKey != ""
+}
+
+// Rerank scores documents against the query.
+func (p *RerankProvider) Rerank(ctx context.Context, req *allm.RerankRequest) (*allm.RerankResponse, error) {
+	start := time.Now()
+	model := resolveModel(req.Model, p.model)
+
+	if p.logger != nil {
+		p.logger.Debug("provider rerank",
+			"provider", p.name,
+			"host", safeHost(p.baseURL),
+			"model", model,
+			"documents", len(req.Documents),
+		)
+	}
+
+	var results []allm.RerankResult
+	var err error
+	switch p.api {
+	case rerankTEI:
+		results, err = p.rerankTEI(ctx, req)
+	default:
+		results, err = p.rerankCohere(ctx, req, model)
+	}
+	if err != nil {
+		if p.logger != nil {
+			p.logger.Debug("provider rerank failed",
+				"provider", p.name,
+				"model", model,
+				"error", sanitizeProviderError(err),
+			)
+		}
+		return nil, err
+	}
+
+	for i := range results {
+		if results[i].Index >= 0 && results[i].Index < len(req.Documents) {
+			results[i].Document = req.Documents[results[i].Index]
+		}
+	}
+
+	resp := &allm.RerankResponse{
+		Results:  allm.SortRerankResults(results, req.TopN),
+		Model:    model,
+		Provider: p.name,
+		Latency:  time.Since(start),
+	}
+
+	if p.logger != nil {
+		p.logger.Debug("provider rerank done",
+			"provider", p.name,
+			"model", model,
+			"latency", resp.Latency,
+			"results", len(resp.Results),
+		)
+	}
+
+	return resp, nil
+}
+
+// rerankCohere calls a Cohere-compatible rerank endpoint.
+func (p *RerankProvider) rerankCohere(ctx context.Context, req *allm.RerankRequest, model string) ([]allm.RerankResult, error) {
+	body := map[string]any{
+		"query":     req.Query,
+		"documents": req.Documents,
+	}
+	if model != "" {
+		body["model"] = model
+	}
+	if req.TopN > 0 {
+		body["top_n"] = req.TopN
+	}
+
+	var out struct {
+		Results []struct {
+			Index          int     `json:"index"`
+			RelevanceScore float64 `json:"relevance_score"`
+		} `json:"results"`
+	}
+	if err := postJSON(ctx, p.httpClient, p.baseURL+p.path, p.apiKey, body, &out); err != nil {
+		return nil, fmt.Errorf("%s: rerank: %w", p.name, err)
+	}
+
+	results := make([]allm.RerankResult, len(out.Results))
+	for i, r := range out.Results {
+		results[i] = allm.RerankResult{Index: r.Index, Score: r.RelevanceScore}
+	}
+	return results, nil
+}
+
+// rerankTEI calls a Text Embeddings Inference /rerank endpoint.
+func (p *RerankProvider) rerankTEI(ctx context.Context, req *allm.RerankRequest) ([]allm.RerankResult, error) {
+	body := map[string]any{
+		"query": req.Query,
+		"texts": req.Documents,
+	}
+
+	var out []struct {
+		Index int     `json:"index"`
+		Score float64 `json:"score"`
+	}
+	if err := postJSON(ctx, p.httpClient, p.baseURL+p.path, p.apiKey, body, &out); err != nil {
+		return nil, fmt.Errorf("%s: rerank: %w", p.name, err)
+	}
+
+	results := make([]allm.RerankResult, len(out))
+	for i, r := range out {
+		results[i] = allm.RerankResult{Index: r.Index, Score: r.Score}
+	}
+	return results, nil
+}
+
+// Rerank scores documents using the server's Cohere-compatible /rerank
+// endpoint, if enabled with WithRerank. Otherwise it returns
+// allm.ErrNotSupported; use allm.LLMReranker for those servers.
+func (p *OpenAICompatibleProvider) Rerank(ctx context.Context, req *allm.RerankRequest) (*allm.RerankResponse, error) {
+	if !p.rerank {
+		return nil, fmt.Errorf("%w: %s: rerank (enable with WithRerank)", allm.ErrNotSupported, p.name)
+	}
+	httpClient := p.httpClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	r := &RerankProvider{
+		name:       string(p.name),
+		api:        rerankCohere,
+		baseURL:    strings.TrimRight(p.baseURL, "/"),
+		path:       "/rerank",
+		apiKey:     p.apiKey,
+		model:      p.rerankModel,
+		httpClient: httpClient,
+		logger:     p.logger,
+	}
+	return r.Rerank(ctx, req)
+}
@@ -0,0 +1,166 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/kusandriadi/allm-go"
+)
+
+func TestCohereRerankDefaults(t *testing.T) {
+	p := CohereRerank("co-key")
+	if p.Name() != "cohere" {
+		t.Errorf("Name() = %q, want cohere", p.Name())
+	}
+	if p.baseURL != "https://api.cohere.com" || p.path != "/v2/rerank" {
+		t.Errorf("unexpected endpoint %s%s", p.baseURL, p.path)
+	}
+	if p.model != "rerank-v3.5" {
+		t.Errorf("unexpected default model %q", p.model)
+	}
+	if !p.Available() {
+		t.Error("should be available with key")
+	}
+}
+
+func TestCohereRerankRejectsLocalURL(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("expected panic for localhost base URL")
+		}
+	}()
+	CohereRerank("co-key", WithRerankBaseURL("http://127.0.0.1:9999"))
+}
+
+func TestVLLMRerank(t *testing.T) {
+	var got map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/rerank" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		_ = json.NewDecoder(r.Body).Decode(&got)
+		_, _ = w.Write([]byte(`{"results":[{"index":1,"relevance_score":0.8},{"index":0,"relevance_score":0.2}]}`))
+	}))
+	defer srv.Close()
+
+	p := VLLMRerank(srv.URL, "bge-reranker")
+	resp, err := p.Rerank(context.Background(), &allm.RerankRequest{
+		Query:     "q",
+		Documents: []string{"first", "second"},
+		TopN:      1,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got["model"] != "bge-reranker" || got["top_n"] != float64(1) {
+		t.Errorf("unexpected request body: %v", got)
+	}
+	if len(resp.Results) != 1 || resp.Results[0].Document != "second" || resp.Results[0].Score != 0.8 {
+		t.Errorf("unexpected results: %+v", resp.Results)
+	}
+	if resp.Provider != "vllm" {
+		t.Errorf("unexpected provider %q", resp.Provider)
+	}
+}
+
+func TestTEIRerank(t *testing.T) {
+	var got map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/rerank" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		_ = json.NewDecoder(r.Body).Decode(&got)
+		_, _ = w.Write([]byte(`[{"index":0,"score":0.3},{"index":2,"score":0.95},{"index":1,"score":0.1}]`))
+	}))
+	defer srv.Close()
+
+	p := TEIRerank(srv.URL)
+	resp, err := p.Rerank(context.Background(), &allm.RerankRequest{
+		Query:     "q",
+		Documents: []string{"a", "b", "c"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := got["texts"]; !ok {
+		t.Errorf("expected texts field, got %v", got)
+	}
+	if _, ok := got["model"]; ok {
+		t.Error("TEI request should not send a model")
+	}
+	if resp.Results[0].Document != "c" || resp.Results[2].Document != "b" {
+		t.Errorf("unexpected order: %+v", resp.Results)
+	}
+}
+
+func TestRerankHTTPErrors(t *testing.T) {
+	tests := []struct {
+		status int
+		want   error
+	}{
+		{http.StatusTooManyRequests, allm.ErrRateLimited},
+		{http.StatusBadGateway, allm.ErrServerError},
+		{http.StatusBadRequest, allm.ErrProvider},
+	}
+	for _, tt := range tests {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			http.Error(w, "nope", tt.status)
+		}))
+		p := TEIRerank(srv.URL)
+		_, err := p.Rerank(context.Background(), &allm.RerankRequest{Query: "q", Documents: []string{"a"}})
+		if !errors.Is(err, tt.want) {
+			t.Errorf("status %d: expected %v, got %v", tt.status, tt.want, err)
+		}
+		srv.Close()
+	}
+}
+
+// roundTripFunc adapts a function to http.RoundTripper.
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) { return f(r) }
+
+func TestLocalRerank(t *testing.T) {
+	var auth string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/rerank" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		auth = r.Header.Get("Authorization")
+		_, _ = w.Write([]byte(`{"results":[{"index":0,"relevance_score":0.7}]}`))
+	}))
+	defer srv.Close()
+
+	var viaClient bool
+	httpClient := &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		viaClient = true
+		return http.DefaultTransport.RoundTrip(r)
+	})}
+	p := OpenAICompatible(allm.Local, "local-key", WithBaseURL(srv.URL+"/v1"), WithDefaultModel("llama3"),
+		WithRerank("reranker"), WithHTTPClient(httpClient))
+	client := allm.New(p)
+	resp, err := client.Rerank(context.Background(), &allm.RerankRequest{Query: "q", Documents: []string{"a"}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if auth != "Bearer local-key" {
+		t.Errorf("unexpected auth header %q", auth)
+	}
+	if resp.Model != "reranker" || resp.Results[0].Score != 0.7 {
+		t.Errorf("unexpected response: %+v", resp)
+	}
+	if !viaClient {
+		t.Error("rerank request bypassed the provider's HTTP client")
+	}
+
+	// Without WithRerank, compatible servers do not rerank.
+	_, err = allm.New(OpenAICompatible(allm.Local, "", WithBaseURL(srv.URL+"/v1"))).
+		Rerank(context.Background(), &allm.RerankRequest{Query: "q", Documents: []string{"a"}})
+	if !errors.Is(err, allm.ErrNotSupported) {
+		t.Errorf("expected ErrNotSupported, got %v", err)
+	}
+}
@@ -0,0 +1,446 @@
+package provider
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// Minimal RFC 6455 WebSocket client for the realtime API, so the module does
+// not need a WebSocket dependency. Extensions and subprotocols are not supported.
+
+// WebSocket opcodes.
+const (
+	wsContinuation = 0x0
+	wsText         = 0x1
+	wsBinary       = 0x2
+	wsClose        = 0x8
+	wsPing         = 0x9
+	wsPong         = 0xA
+)
+
+// wsMaxMessageSize caps the size of a received message.
+const wsMaxMessageSize = 16 * 1024 * 1024
+
+// wsGUID is the handshake GUID from RFC 6455.
+const wsGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// errWSClosed is returned by ReadMessage after a close frame.
+var errWSClosed = errors.New("websocket closed")
+
+// wsConn is a WebSocket connection. Client connections mask outgoing frames.
+type wsConn struct {
+	conn      net.Conn
+	br        *bufio.Reader
+	client    bool
+	writeMu   sync.Mutex
+	closeOnce sync.Once
+}
+
+// wsAccept computes the Sec-WebSocket-Accept value for a handshake key.
+func wsAccept(key string) string {
+	h := sha1.Sum([]byte(key + wsGUID))
+	return base64.StdEncoding.EncodeToString(h[:])
+}
+
+// dialWebSocket opens a client WebSocket connection to a ws:// or wss:// URL.
+// ctx bounds the dial and handshake. The proxy, dialer and TLS settings of
+// client's transport are used when it is an *http.Transport; otherwise those
+// of http.DefaultTransport (HTTPS_PROXY and friends) apply.
+func dialWebSocket(ctx context.Context, rawURL string, header http.Header, client *http.Client) (*wsConn, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid websocket URL: %w", err)
+	}
+
+	var httpScheme, port string
+	switch u.Scheme {
+	case "ws":
+		httpScheme, port = "http", "80"
+	case "wss":
+		httpScheme, port = "https", "443"
+	default:
+		return nil, fmt.Errorf("websocket URL must use ws or wss scheme, got: %s", u.Scheme)
+	}
+	addr := u.Host
+	if u.Port() == "" {
+		addr = net.JoinHostPort(u.Hostname(), port)
+	}
+
+	transport := wsTransport(client)
+	var proxyURL *url.URL
+	if transport.Proxy != nil {
+		httpURL := *u
+		httpURL.Scheme = httpScheme
+		proxyURL, err = transport.Proxy(&http.Request{Method: http.MethodGet, URL: &httpURL, Header: http.Header{}})
+		if err != nil {
+			return nil, fmt.Errorf("websocket proxy: %w", err)
+		}
+	}
+
+	var conn net.Conn
+	if proxyURL != nil {
+		conn, err = dialWSProxy(ctx, transport, proxyURL, addr)
+	} else {
+		conn, err = wsDialer(transport)(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme == "wss" {
+		tlsConn := tls.Client(conn, wsTLSConfig(transport.TLSClientConfig, u.Hostname()))
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			_ = conn.Close()
+			return nil, err
+		}
+		conn = tlsConn
+	}
+
+	ws, err := wsHandshake(ctx, conn, u, httpScheme, header)
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	return ws, nil
+}
+
+// wsTransport returns the transport whose settings a WebSocket dial through
+// client follows. Custom RoundTrippers cannot be inspected, so they get the
+// default transport's.
+func wsTransport(client *http.Client) *http.Transport {
+	if client != nil {
+		if t, ok := client.Transport.(*http.Transport); ok {
+			return t
+		}
+	}
+	if t, ok := http.DefaultTransport.(*http.Transport); ok {
+		return t
+	}
+	return &http.Transport{Proxy: http.ProxyFromEnvironment}
+}
+
+// wsDialer returns the transport's dial function, or a plain net.Dialer.
+func wsDialer(t *http.Transport) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	if t.DialContext != nil {
+		return t.DialContext
+	}
+	var d net.Dialer
+	return d.DialContext
+}
+
+// wsTLSConfig derives the TLS config for a connection to serverName from the
+// transport's. HTTP/2 is not offered: WebSocket upgrades need HTTP/1.1.
+func wsTLSConfig(base *tls.Config, serverName string) *tls.Config {
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+	if base != nil {
+		cfg = base.Clone()
+		cfg.MinVersion = max(cfg.MinVersion, tls.VersionTLS12)
+	}
+	if cfg.ServerName == "" {
+		cfg.ServerName = serverName
+	}
+	cfg.NextProtos = nil
+	return cfg
+}
+
+// dialWSProxy opens a tunnel to addr through an HTTP or HTTPS proxy with
+// CONNECT.
+func dialWSProxy(ctx context.Context, t *http.Transport, proxyURL *url.URL, addr string) (net.Conn, error) {
+	var port string
+	switch proxyURL.Scheme {
+	case "http":
+		port = "80"
+	case "https":
+		port = "443"
+	default:
+		return nil, fmt.Errorf("websocket proxy: unsupported scheme %s", proxyURL.Scheme)
+	}
+	proxyAddr := proxyURL.Host
+	if proxyURL.Port() == "" {
+		proxyAddr = net.JoinHostPort(proxyURL.Hostname(), port)
+	}
+
+	conn, err := wsDialer(t)(ctx, "tcp", proxyAddr)
+	if err != nil {
+		return nil, fmt.Errorf("websocket proxy: %w", err)
+	}
+	if proxyURL.Scheme == "https" {
+		tlsConn := tls.Client(conn, wsTLSConfig(t.TLSClientConfig, proxyURL.Hostname()))
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			_ = conn.Close()
+			return nil, fmt.Errorf("websocket proxy: %w", err)
+		}
+		conn = tlsConn
+	}
+	if err := wsConnect(ctx, conn, t, proxyURL, addr); err != nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("websocket proxy: %w", err)
+	}
+	return conn, nil
+}
+
+// wsConnect sends a CONNECT request for addr on a proxy connection and waits
+// for the tunnel to be established.
+func wsConnect(ctx context.Context, conn net.Conn, t *http.Transport, proxyURL *url.URL, addr string) error {
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+		defer func() { _ = conn.SetDeadline(time.Time{}) }()
+	}
+
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: addr},
+		Host:   addr,
+		Header: t.ProxyConnectHeader.Clone(),
+	}
+	if req.Header == nil {
+		req.Header = http.Header{}
+	}
+	if user := proxyURL.User; user != nil {
+		password, _ := user.Password()
+		req.Header.Set("Proxy-Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(user.Username()+":"+password)))
+	}
+	if err := req.Write(conn); err != nil {
+		return err
+	}
+
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		return err
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("CONNECT returned %s", resp.Status)
+	}
+	if br.Buffered() > 0 {
+		return errors.New("unexpected data after CONNECT response")
+	}
+	return nil
+}
+
+// wsHandshake performs the client opening handshake on conn.
+func wsHandshake(ctx context.Context, conn net.Conn, u *url.URL, httpScheme string, header http.Header) (*wsConn, error) {
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+		defer func() { _ = conn.SetDeadline(time.Time{}) }()
+	}
+
+	var nonce [16]byte
+	if _, err := rand.Read(nonce[:]); err != nil {
+		return nil, err
+	}
+	key := base64.StdEncoding.EncodeToString(nonce[:])
+
+	httpURL := *u
+	httpURL.Scheme = httpScheme
+	req, err := http.NewRequest(http.MethodGet, httpURL.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Sec-WebSocket-Key", key)
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	if err := req.Write(conn); err != nil {
+		return nil, err
+	}
+
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		return nil, fmt.Errorf("websocket handshake: %w", err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBodySize))
+		_ = resp.Body.Close()
+		statusErr := fmt.Errorf("websocket handshake: HTTP %d: %s", resp.StatusCode, truncateErr(string(msg)))
+		if wrapped := wrapHTTPStatusError(resp.StatusCode, statusErr); wrapped != nil {
+			return nil, wrapped
+		}
+		return nil, statusErr
+	}
+	if resp.Header.Get("Sec-WebSocket-Accept") != wsAccept(key) {
+		return nil, fmt.Errorf("websocket handshake: invalid Sec-WebSocket-Accept")
+	}
+	return &wsConn{conn: conn, br: br, client: true}, nil
+}
+
+// WriteMessage writes a single-frame message, honoring the ctx deadline.
+// Safe for concurrent use.
+func (c *wsConn) WriteMessage(ctx context.Context, op byte, data []byte) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = c.conn.SetWriteDeadline(deadline)
+		defer func() { _ = c.conn.SetWriteDeadline(time.Time{}) }()
+	}
+	return c.writeFrame(op, data)
+}
+
+// writeFrame writes one final frame. The caller must hold writeMu.
+func (c *wsConn) writeFrame(op byte, data []byte) error {
+	header := make([]byte, 2, 14)
+	header[0] = 0x80 | op
+	switch n := len(data); {
+	case n < 126:
+		header[1] = byte(n)
+	case n <= 0xFFFF:
+		header[1] = 126
+		header = binary.BigEndian.AppendUint16(header, uint16(n))
+	default:
+		header[1] = 127
+		header = binary.BigEndian.AppendUint64(header, uint64(n))
+	}
+
+	payload := data
+	if c.client {
+		header[1] |= 0x80
+		var mask [4]byte
+		if _, err := rand.Read(mask[:]); err != nil {
+			return err
+		}
+		header = append(header, mask[:]...)
+		payload = make([]byte, len(data))
+		for i, b := range data {
+			payload[i] = b ^ mask[i%4]
+		}
+	}
+
+	if _, err := c.conn.Write(header); err != nil {
+		return err
+	}
+	_, err := c.conn.Write(payload)
+	return err
+}
+
+// ReadMessage reads the next text or binary message, reassembling fragments
+// and answering pings. It returns errWSClosed after a close frame.
+func (c *wsConn) ReadMessage() (byte, []byte, error) {
+	var op byte
+	var msg []byte
+	for {
+		fin, frameOp, payload, err := c.readFrame()
+		if err != nil {
+			return 0, nil, err
+		}
+
+		switch frameOp {
+		case wsPing:
+			if err := c.WriteMessage(context.Background(), wsPong, payload); err != nil {
+				return 0, nil, err
+			}
+			continue
+		case wsPong:
+			continue
+		case wsClose:
+			c.writeMu.Lock()
+			_ = c.writeFrame(wsClose, payload[:min(len(payload), 2)])
+			c.writeMu.Unlock()
+			return 0, nil, errWSClosed
+		case wsContinuation:
+			if op == 0 {
+				return 0, nil, fmt.Errorf("websocket: unexpected continuation frame")
+			}
+		case wsText, wsBinary:
+			if op != 0 {
+				return 0, nil, fmt.Errorf("websocket: expected continuation frame")
+			}
+			op = frameOp
+		default:
+			return 0, nil, fmt.Errorf("websocket: unknown opcode %d", frameOp)
+		}
+
+		if len(msg)+len(payload) > wsMaxMessageSize {
+			return 0, nil, fmt.Errorf("websocket: message exceeds %d bytes", wsMaxMessageSize)
+		}
+		msg = append(msg, payload...)
+		if fin {
+			return op, msg, nil
+		}
+	}
+}
+
+// readFrame reads and unmasks a single frame.
+func (c *wsConn) readFrame() (fin bool, op byte, payload []byte, err error) {
+	var head [2]byte
+	if _, err := io.ReadFull(c.br, head[:]); err != nil {
+		return false, 0, nil, err
+	}
+	fin = head[0]&0x80 != 0
+	op = head[0] & 0x0F
+	if head[0]&0x70 != 0 {
+		return false, 0, nil, fmt.Errorf("websocket: unexpected reserved bits")
+	}
+	masked := head[1]&0x80 != 0
+
+	n := uint64(head[1] & 0x7F)
+	switch n {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(c.br, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		n = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(c.br, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		n = binary.BigEndian.Uint64(ext[:])
+	}
+	if n > wsMaxMessageSize {
+		return false, 0, nil, fmt.Errorf("websocket: frame exceeds %d bytes", wsMaxMessageSize)
+	}
+	if op >= wsClose && (n > 125 || !fin) {
+		return false, 0, nil, fmt.Errorf("websocket: invalid control frame")
+	}
+
+	var mask [4]byte
+	if masked {
+		if _, err := io.ReadFull(c.br, mask[:]); err != nil {
+			return false, 0, nil, err
+		}
+	}
+	payload = make([]byte, n)
+	if _, err := io.ReadFull(c.br, payload); err != nil {
+		return false, 0, nil, err
+	}
+	if masked {
+		for i := range payload {
+			payload[i] ^= mask[i%4]
+		}
+	}
+	return fin, op, payload, nil
+}
+
+// Close sends a normal close frame and closes the connection.
+func (c *wsConn) Close() error {
+	var err error
+	c.closeOnce.Do(func() {
+		c.writeMu.Lock()
+		_ = c.conn.SetWriteDeadline(time.Now().Add(time.Second))
+		_ = c.writeFrame(wsClose, []byte{0x03, 0xE8}) // 1000: normal closure
+		c.writeMu.Unlock()
+		err = c.conn.Close()
+	})
+	return err
+}
@@ -0,0 +1,135 @@
+package allm
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// RealtimeEvent types for RealtimeEvent.Type.
+const (
+	RealtimeSessionUpdated  = "session_updated"  // Session configuration was applied
+	RealtimeSpeechStarted   = "speech_started"   // Server VAD detected user speech (stop playback)
+	RealtimeSpeechStopped   = "speech_stopped"   // Server VAD detected the end of user speech
+	RealtimeInputTranscript = "input_transcript" // Transcript of the user's audio (Text)
+	RealtimeAudioDelta      = "audio_delta"      // Chunk of model audio (Audio)
+	RealtimeTranscriptDelta = "transcript_delta" // Chunk of the transcript of model audio (Text)
+	RealtimeTextDelta       = "text_delta"       // Chunk of model text output (Text)
+	RealtimeToolCall        = "tool_call"        // The model called a tool (ToolCall)
+	RealtimeResponseDone    = "response_done"    // The model finished a response (Usage)
+	RealtimeError           = "error"            // The server reported an error (Error)
+)
+
+// Turn detection modes for TurnDetection.Type.
+const (
+	TurnDetectionServerVAD   = "server_vad"   // Silence-based voice activity detection (default)
+	TurnDetectionSemanticVAD = "semantic_vad" // Model-based end-of-turn detection
+	TurnDetectionNone        = "none"         // Manual turns via CommitAudio and CreateResponse
+)
+
+// TurnDetection configures how the server decides that the user finished speaking.
+type TurnDetection struct {
+	Type            string        // TurnDetectionServerVAD, TurnDetectionSemanticVAD or TurnDetectionNone
+	Threshold       float64       // VAD activation threshold 0-1 (server_vad, 0 = provider default)
+	PrefixPadding   time.Duration // Audio kept before detected speech (server_vad, 0 = provider default)
+	SilenceDuration time.Duration // Silence that ends a turn (server_vad, 0 = provider default)
+}
+
+// RealtimeConfig configures a realtime session.
+// Audio is 24kHz 16-bit mono little-endian PCM unless a format is set.
+type RealtimeConfig struct {
+	Model              string         // Realtime model (empty = provider default)
+	Instructions       string         // System instructions
+	Voice              string         // Output voice (e.g., "alloy")
+	Modalities         []string       // Output modalities: "audio" (default) or "text"
+	InputAudioFormat   string         // "pcm16" (default), "g711_ulaw" or "g711_alaw"
+	OutputAudioFormat  string         // "pcm16" (default), "g711_ulaw" or "g711_alaw"
+	InputTranscription string         // Model for transcribing user audio (empty = no transcripts)
+	TurnDetection      *TurnDetection // Turn detection (nil = server VAD with provider defaults)
+	Tools              []Tool         // Tools the model can call
+	MaxOutputTokens    int            // Max tokens per response (0 = provider default)
+}
+
+// RealtimeEvent is a server event received from a realtime session.
+type RealtimeEvent struct {
+	Type       string       // One of the Realtime* event constants
+	Audio      []byte       // Decoded audio (RealtimeAudioDelta)
+	Text       string       // Text or transcript (delta or final, depending on Type)
+	ToolCall   *ToolCall    // Tool call (RealtimeToolCall)
+	Usage      *StreamUsage // Token usage (RealtimeResponseDone)
+	ResponseID string       // Response the event belongs to (if any)
+	ItemID     string       // Conversation item the event belongs to (if any)
+	Error      error        // Server error (RealtimeError)
+}
+
+// RealtimeSession is a duplex, low-latency conversation with a realtime model.
+// Input is sent with the methods below; output arrives on Events.
+// Methods are safe for concurrent use.
+type RealtimeSession interface {
+	// SendAudio appends audio to the input buffer. With server VAD the
+	// server commits the buffer and responds when the user stops speaking.
+	SendAudio(ctx context.Context, audio []byte) error
+	// CommitAudio commits the input buffer as a user turn (TurnDetectionNone).
+	CommitAudio(ctx context.Context) error
+	// ClearAudio discards uncommitted input audio.
+	ClearAudio(ctx context.Context) error
+	// SendText adds a user text message to the conversation.
+	SendText(ctx context.Context, text string) error
+	// SendToolResult adds the result of a tool call to the conversation.
+	// Call CreateResponse to let the model continue.
+	SendToolResult(ctx context.Context, result ToolResult) error
+	// CreateResponse asks the model to respond to the conversation so far.
+	CreateResponse(ctx context.Context) error
+	// CancelResponse interrupts the in-progress response (e.g., on barge-in).
+	CancelResponse(ctx context.Context) error
+	// UpdateSession changes the session configuration.
+	UpdateSession(ctx context.Context, cfg *RealtimeConfig) error
+	// Events returns the server events. The channel is closed when the session ends.
+	Events() <-chan RealtimeEvent
+	// Close ends the session.
+	Close() error
+}
+
+// Realtimer is an optional interface for realtime speech-to-speech sessions.
+// Supported by: OpenAI (Realtime API).
+type Realtimer interface {
+	// ConnectRealtime opens a realtime session.
+	ConnectRealtime(ctx context.Context, cfg *RealtimeConfig) (RealtimeSession, error)
+}
+
+// ConnectRealtime opens a realtime speech-to-speech session. ctx bounds the
+// connection handshake only; end the session with Close. A nil cfg uses
+// provider defaults. Returns an error if the provider does not support realtime sessions.
+func (c *Client) ConnectRealtime(ctx context.Context, cfg *RealtimeConfig) (RealtimeSession, error) {
+	s := c.snapshot()
+
+	if s.provider == nil {
+		return nil, ErrNoProvider
+	}
+
+	rt, ok := s.provider.(Realtimer)
+	if !ok {
+		return nil, fmt.Errorf("%w: realtime sessions", ErrNotSupported)
+	}
+
+	if cfg == nil {
+		cfg = &RealtimeConfig{}
+	}
+	if err := validateRealtimeConfig(cfg); err != nil {
+		return nil, err
+	}
+
+	if s.logger != nil {
+		s.logger.Debug("realtime connect request",
+			"provider", s.provider.Name(),
+			"model", cfg.Model,
+			"voice", cfg.Voice,
+			"tools", len(cfg.Tools),
+		)
+	}
+
+	op := Operation{Name: OperationRealtime, Provider: s.provider.Name(), Model: cfg.Model}
+	return observe(ctx, s, op, true, func(ctx context.Context) (RealtimeSession, error) {
+		return rt.ConnectRealtime(ctx, cfg)
+	})
+}
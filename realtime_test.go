@@ -0,0 +1,69 @@
+package allm
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+// mockRealtimer implements Provider and Realtimer.
+type mockRealtimer struct {
+	mockProvider
+	cfg *RealtimeConfig
+}
+
+func (m *mockRealtimer) ConnectRealtime(_ context.Context, cfg *RealtimeConfig) (RealtimeSession, error) {
+	m.cfg = cfg
+	return nil, nil
+}
+
+func TestConnectRealtime(t *testing.T) {
+	p := &mockRealtimer{mockProvider: mockProvider{name: "test", available: true}}
+	c := New(p)
+
+	if _, err := c.ConnectRealtime(context.Background(), nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if p.cfg == nil {
+		t.Fatal("expected nil config to be replaced with defaults")
+	}
+
+	cfg := &RealtimeConfig{Voice: "alloy", TurnDetection: &TurnDetection{Type: TurnDetectionNone}}
+	if _, err := c.ConnectRealtime(context.Background(), cfg); err != nil || p.cfg != cfg {
+		t.Fatalf("expected config to be passed through, got %v", err)
+	}
+
+	_, err := New(&mockProvider{name: "test"}).ConnectRealtime(context.Background(), nil)
+	if !errors.Is(err, ErrNotSupported) {
+		t.Errorf("expected ErrNotSupported, got %v", err)
+	}
+}
+
+func TestValidateRealtimeConfig(t *testing.T) {
+	invalid := []*RealtimeConfig{
+		{Modalities: []string{"video"}},
+		{InputAudioFormat: "mp3"},
+		{OutputAudioFormat: "opus"},
+		{TurnDetection: &TurnDetection{Type: "push_to_talk"}},
+		{TurnDetection: &TurnDetection{Threshold: 1.5}},
+		{TurnDetection: &TurnDetection{SilenceDuration: -1}},
+		{Tools: []Tool{{Name: ""}}},
+		{MaxOutputTokens: -1},
+	}
+	for i, cfg := range invalid {
+		if err := validateRealtimeConfig(cfg); err == nil {
+			t.Errorf("config %d: expected validation error", i)
+		}
+	}
+
+	valid := &RealtimeConfig{
+		Modalities:        []string{"text"},
+		InputAudioFormat:  "g711_ulaw",
+		OutputAudioFormat: "pcm16",
+		TurnDetection:     &TurnDetection{Type: TurnDetectionSemanticVAD, Threshold: 0.5},
+		Tools:             []Tool{{Name: "lookup"}},
+	}
+	if err := validateRealtimeConfig(valid); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
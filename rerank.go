@@ -0,0 +1,217 @@
+package allm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RerankRequest contains parameters for a rerank request.
+type RerankRequest struct {
+	Query     string   // Search query to rank documents against
+	Documents []string // Candidate documents (e.g., retrieved chunks)
+	TopN      int      // Number of results to return (0 = all documents)
+	Model     string   // Rerank model (empty = provider default)
+}
+
+// RerankResult is a single scored document.
+type RerankResult struct {
+	Index    int     // Position of the document in RerankRequest.Documents
+	Document string  // Document text
+	Score    float64 // Relevance score (higher is more relevant)
+}
+
+// RerankResponse contains the rerank result, sorted by descending score.
+type RerankResponse struct {
+	Results      []RerankResult // Scored documents, most relevant first
+	Model        string         // Model used
+	Provider     string         // Provider name
+	Latency      time.Duration  // Request latency
+	InputTokens  int            // Tokens in input (where reported, e.g. LLMReranker)
+	OutputTokens int            // Tokens in output (LLMReranker)
+}
+
+// Reranker is an optional interface for reranking documents by relevance to a query.
+// Supported by: Cohere, vLLM, TEI (via provider.CohereRerank, provider.VLLMRerank,
+// provider.TEIRerank), OpenAI-compatible servers with a /rerank endpoint
+// (via provider.WithRerank).
+// Any chat provider can be used through LLMReranker.
+type Reranker interface {
+	// Rerank scores documents against the query.
+	Rerank(ctx context.Context, req *RerankRequest) (*RerankResponse, error)
+}
+
+// WithReranker sets a dedicated reranker used by Client.Rerank.
+// Takes precedence over the provider's own Reranker implementation.
+func WithReranker(r Reranker) Option {
+	return func(c *Client) {
+		c.reranker = r
+	}
+}
+
+// Rerank scores documents by relevance to the query.
+// Uses the reranker set by WithReranker, or the provider if it implements Reranker.
+// Returns an error if neither is available.
+func (c *Client) Rerank(ctx context.Context, req *RerankRequest) (*RerankResponse, error) {
+	s := c.snapshot()
+
+	if s.provider == nil {
+		return nil, ErrNoProvider
+	}
+
+	reranker := s.reranker
+	if reranker == nil {
+		r, ok := s.provider.(Reranker)
+		if !ok {
+			return nil, fmt.Errorf("%w: reranking", ErrNotSupported)
+		}
+		reranker = r
+	}
+
+	if err := validateRerankRequest(req, s.maxInputLen); err != nil {
+		return nil, err
+	}
+
+	if s.logger != nil {
+		s.logger.Debug("rerank request",
+			"provider", s.provider.Name(),
+			"model", req.Model,
+			"documents", len(req.Documents),
+			"top_n", req.TopN,
+		)
+	}
+
+	if err := s.checkBudget(); err != nil {
+		return nil, err
+	}
+
+	op := Operation{Name: OperationRerank, Provider: s.provider.Name(), Model: req.Model}
+	resp, err := observe(ctx, s, op, false, func(ctx context.Context) (*RerankResponse, error) {
+		return retryWithBackoff(ctx, s, func(attemptCtx context.Context) (*RerankResponse, error) {
+			return reranker.Rerank(attemptCtx, req)
+		}, "rerank")
+	})
+	if err == nil && resp != nil {
+		s.recordUsage(ctx, s.callUsage(resp.Model, resp.InputTokens, resp.OutputTokens))
+	}
+	return resp, err
+}
+
+// SortRerankResults sorts results by descending score (ties keep document order)
+// and keeps the first topN (0 = keep all).
+func SortRerankResults(results []RerankResult, topN int) []RerankResult {
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].Score == results[j].Score {
+			return results[i].Index < results[j].Index
+		}
+		return results[i].Score > results[j].Score
+	})
+	if topN > 0 && topN < len(results) {
+		results = results[:topN]
+	}
+	return results
+}
+
+// LLMReranker implements Reranker on top of a chat provider.
+// Use it for providers without a native rerank endpoint:
+//
+//	client := allm.New(p, allm.WithReranker(allm.NewLLMReranker(p, "haiku")))
+//	resp, err := client.Rerank(ctx, &allm.RerankRequest{Query: q, Documents: docs, TopN: 5})
+//
+// The model is asked to score every document from 0 to 1 and reply with JSON.
+// Documents the model omits are scored 0.
+type LLMReranker struct {
+	provider Provider
+	model    string
+}
+
+// NewLLMReranker creates an LLM-backed reranker. model may be empty to use
+// the provider default; a small, cheap model is usually sufficient.
+func NewLLMReranker(p Provider, model string) *LLMReranker {
+	return &LLMReranker{provider: p, model: model}
+}
+
+// llmRerankPrompt instructs the model to return relevance scores as JSON.
+const llmRerankPrompt = `You are a relevance ranking system. Score how relevant each document is to the query, from 0 (irrelevant) to 1 (perfect answer).
+Reply with only a JSON array, one object per document, in the form [{"index": 0, "score": 0.5}]. Do not add any other text.`
+
+// Rerank scores documents by asking the chat model for relevance scores.
+func (r *LLMReranker) Rerank(ctx context.Context, req *RerankRequest) (*RerankResponse, error) {
+	if r.provider == nil {
+		return nil, ErrNoProvider
+	}
+	start := time.Now()
+
+	var sb strings.Builder
+	sb.WriteString("Query: ")
+	sb.WriteString(req.Query)
+	sb.WriteString("\n\nDocuments:\n")
+	for i, doc := range req.Documents {
+		sb.WriteString("[" + strconv.Itoa(i) + "] ")
+		sb.WriteString(doc)
+		sb.WriteString("\n")
+	}
+
+	model := req.Model
+	if model == "" {
+		model = r.model
+	}
+
+	resp, err := r.provider.Complete(ctx, &Request{
+		Messages: []Message{
+			{Role: RoleSystem, Content: llmRerankPrompt},
+			{Role: RoleUser, Content: sb.String()},
+		},
+		Model: model,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	scores, err := parseLLMRerankScores(resp.Content)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]RerankResult, len(req.Documents))
+	for i, doc := range req.Documents {
+		results[i] = RerankResult{Index: i, Document: doc, Score: scores[i]}
+	}
+
+	return &RerankResponse{
+		Results:      SortRerankResults(results, req.TopN),
+		Model:        resp.Model,
+		Provider:     r.provider.Name(),
+		Latency:      time.Since(start),
+		InputTokens:  resp.InputTokens,
+		OutputTokens: resp.OutputTokens,
+	}, nil
+}
+
+// parseLLMRerankScores extracts index→score pairs from a model reply.
+// Tolerates surrounding prose or code fences around the JSON array.
+func parseLLMRerankScores(content string) (map[int]float64, error) {
+	begin := strings.Index(content, "[")
+	end := strings.LastIndex(content, "]")
+	if begin < 0 || end < begin {
+		return nil, fmt.Errorf("%w: reranker reply contains no JSON array", ErrEmptyResponse)
+	}
+
+	var entries []struct {
+		Index int     `json:"index"`
+		Score float64 `json:"score"`
+	}
+	if err := json.Unmarshal([]byte(content[begin:end+1]), &entries); err != nil {
+		return nil, fmt.Errorf("allm: parse reranker reply: %w", err)
+	}
+
+	scores := make(map[int]float64, len(entries))
+	for _, e := range entries {
+		scores[e.Index] = e.Score
+	}
+	return scores, nil
+}
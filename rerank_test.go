@@ -0,0 +1,167 @@
+package allm
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// mockReranker implements both Provider and Reranker
+type mockReranker struct {
+	mockProvider
+	lastRerank *RerankRequest
+}
+
+func (m *mockReranker) Rerank(_ context.Context, req *RerankRequest) (*RerankResponse, error) {
+	m.mu.Lock()
+	m.lastRerank = req
+	m.mu.Unlock()
+	results := make([]RerankResult, len(req.Documents))
+	for i, doc := range req.Documents {
+		results[i] = RerankResult{Index: i, Document: doc, Score: float64(i)}
+	}
+	return &RerankResponse{Results: SortRerankResults(results, req.TopN), Provider: m.name}, nil
+}
+
+func TestRerankSupported(t *testing.T) {
+	p := &mockReranker{mockProvider: mockProvider{name: "test", available: true}}
+	c := New(p)
+
+	resp, err := c.Rerank(context.Background(), &RerankRequest{
+		Query:     "go",
+		Documents: []string{"a", "b", "c"},
+		TopN:      2,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(resp.Results) != 2 {
+		t.Fatalf("expected 2 results, got %d", len(resp.Results))
+	}
+	if resp.Results[0].Document != "c" || resp.Results[1].Document != "b" {
+		t.Errorf("unexpected order: %+v", resp.Results)
+	}
+}
+
+func TestRerankNotSupported(t *testing.T) {
+	c := New(&mockProvider{name: "test", available: true})
+	_, err := c.Rerank(context.Background(), &RerankRequest{Query: "q", Documents: []string{"a"}})
+	if !errors.Is(err, ErrNotSupported) {
+		t.Errorf("expected ErrNotSupported, got %v", err)
+	}
+}
+
+func TestRerankNoProvider(t *testing.T) {
+	c := New(nil)
+	_, err := c.Rerank(context.Background(), &RerankRequest{Query: "q", Documents: []string{"a"}})
+	if !errors.Is(err, ErrNoProvider) {
+		t.Errorf("expected ErrNoProvider, got %v", err)
+	}
+}
+
+func TestRerankValidation(t *testing.T) {
+	p := &mockReranker{mockProvider: mockProvider{name: "test", available: true}}
+	c := New(p, WithMaxInputLen(10))
+
+	tests := []struct {
+		name string
+		req  *RerankRequest
+		want error
+	}{
+		{"nil request", nil, ErrEmptyInput},
+		{"empty query", &RerankRequest{Documents: []string{"a"}}, ErrEmptyInput},
+		{"no documents", &RerankRequest{Query: "q"}, ErrEmptyInput},
+		{"too long", &RerankRequest{Query: "q", Documents: []string{strings.Repeat("x", 20)}}, ErrInputTooLong},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := c.Rerank(context.Background(), tt.req)
+			if !errors.Is(err, tt.want) {
+				t.Errorf("expected %v, got %v", tt.want, err)
+			}
+		})
+	}
+
+	_, err := c.Rerank(context.Background(), &RerankRequest{Query: "q", Documents: []string{"a"}, TopN: -1})
+	if err == nil {
+		t.Error("expected error for negative top_n")
+	}
+}
+
+func TestWithRerankerTakesPrecedence(t *testing.T) {
+	dedicated := &mockReranker{mockProvider: mockProvider{name: "dedicated", available: true}}
+	p := &mockReranker{mockProvider: mockProvider{name: "test", available: true}}
+	c := New(p, WithReranker(dedicated))
+
+	resp, err := c.Rerank(context.Background(), &RerankRequest{Query: "q", Documents: []string{"a"}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.Provider != "dedicated" {
+		t.Errorf("expected dedicated reranker, got %q", resp.Provider)
+	}
+	if p.lastRerank != nil {
+		t.Error("provider reranker should not be called")
+	}
+}
+
+func TestLLMReranker(t *testing.T) {
+	p := &mockProvider{
+		name: "test", available: true,
+		response: &Response{
+			Content: "Here you go:\n```json\n[{\"index\": 0, \"score\": 0.1}, {\"index\": 2, \"score\": 0.9}]\n```",
+			Model:   "small",
+		},
+	}
+	c := New(p, WithReranker(NewLLMReranker(p, "small")))
+
+	resp, err := c.Rerank(context.Background(), &RerankRequest{
+		Query:     "capital of France",
+		Documents: []string{"Berlin", "Madrid", "Paris"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(resp.Results) != 3 {
+		t.Fatalf("expected 3 results, got %d", len(resp.Results))
+	}
+	if resp.Results[0].Document != "Paris" || resp.Results[0].Score != 0.9 {
+		t.Errorf("expected Paris first, got %+v", resp.Results[0])
+	}
+	if resp.Results[2].Document != "Madrid" || resp.Results[2].Score != 0 {
+		t.Errorf("expected omitted document scored 0 last, got %+v", resp.Results[2])
+	}
+
+	req := p.getLastReq()
+	if req.Model != "small" {
+		t.Errorf("expected model 'small', got %q", req.Model)
+	}
+	if !strings.Contains(req.Messages[1].Content, "[2] Paris") {
+		t.Errorf("prompt missing numbered documents: %q", req.Messages[1].Content)
+	}
+}
+
+func TestLLMRerankerInvalidReply(t *testing.T) {
+	p := &mockProvider{name: "test", available: true, response: &Response{Content: "I cannot rank these."}}
+	r := NewLLMReranker(p, "")
+	_, err := r.Rerank(context.Background(), &RerankRequest{Query: "q", Documents: []string{"a"}})
+	if !errors.Is(err, ErrEmptyResponse) {
+		t.Errorf("expected ErrEmptyResponse, got %v", err)
+	}
+}
+
+func TestSortRerankResults(t *testing.T) {
+	results := []RerankResult{
+		{Index: 0, Score: 0.5},
+		{Index: 1, Score: 0.9},
+		{Index: 2, Score: 0.5},
+	}
+	sorted := SortRerankResults(results, 0)
+	if sorted[0].Index != 1 || sorted[1].Index != 0 || sorted[2].Index != 2 {
+		t.Errorf("unexpected order: %+v", sorted)
+	}
+	if len(SortRerankResults(results, 1)) != 1 {
+		t.Error("expected topN to limit results")
+	}
+}
@@ -8,26 +8,33 @@ import (
 // retryableOperation is a function that can be retried.
 type retryableOperation[T any] func(ctx context.Context) (T, error)
 
-// retryWithBackoff executes an operation with exponential backoff retry logic.
-// It handles logging, hooks, error classification, and per-attempt timeouts.
-// Used by Chat() and Embed() to avoid code duplication.
+// retryWithBackoff executes an operation with exponential backoff retry logic
+// and logs its outcome. Used by Embed(), moderation, reranking and summaries;
+// Chat() and Stream() go through the middleware chain instead.
 func retryWithBackoff[T any](
 	ctx context.Context,
 	s clientState,
 	op retryableOperation[T],
 	opName string,
+) (T, error) {
+	start := time.Now()
+	result, err := retry(ctx, s, op, opName)
+	logOutcome(s, opName, result, err, time.Since(start))
+	return result, err
+}
+
+// retry executes an operation with exponential backoff retry logic.
+// It handles retry logging, hooks, error classification, and per-attempt timeouts.
+func retry[T any](
+	ctx context.Context,
+	s clientState,
+	op retryableOperation[T],
+	opName string,
 ) (T, error) {
 	var zero T
 	maxAttempts := 1 + s.maxRetries
 
-	if s.hook != nil {
-		s.hook(HookEvent{
-			Type:     HookRequest,
-			Provider: s.provider.Name(),
-			Model:    s.model,
-			Attempt:  1,
-		})
-	}
+	s.emit(ctx, HookEvent{Type: HookRequest, Attempt: 1})
 
 	var lastErr error
 
@@ -44,15 +51,11 @@ func retryWithBackoff[T any](
 					"error", sanitizeError(lastErr),
 				)
 			}
-			if s.hook != nil {
-				s.hook(HookEvent{
-					Type:     HookRetry,
-					Provider: s.provider.Name(),
-					Model:    s.model,
-					Attempt:  attempt + 1,
-					Error:    sanitizeError(lastErr),
-				})
-			}
+			s.emit(ctx, HookEvent{
+				Type:    HookRetry,
+				Attempt: attempt + 1,
+				Error:   sanitizeError(lastErr),
+			})
 			select {
 			case <-time.After(delay):
 			case <-ctx.Done():
@@ -71,6 +74,7 @@ func retryWithBackoff[T any](
 		}
 
 		attemptCtx, attemptCancel := context.WithTimeout(ctx, s.timeout)
+		attemptCtx, attemptDone := startAttempt(attemptCtx, attempt+1)
 		start := time.Now()
 		result, err := op(attemptCtx)
 		latency := time.Since(start)
@@ -79,50 +83,13 @@ func retryWithBackoff[T any](
 		if err != nil {
 			err = classifyError(err, attemptCtx)
 		}
+		attemptDone(sanitizeError(err))
 		attemptCancel()
 
 		if err == nil {
-			if s.logger != nil {
-				logArgs := []any{
-					"provider", s.provider.Name(),
-					"model", s.model,
-					"latency", latency,
-					"attempt", attempt + 1,
-				}
-				// Add token counts for debug tracing
-				if resp, ok := any(result).(*Response); ok && resp != nil {
-					logArgs = append(logArgs,
-						"input_tokens", resp.InputTokens,
-						"output_tokens", resp.OutputTokens,
-						"finish_reason", resp.FinishReason,
-					)
-					if len(resp.ToolCalls) > 0 {
-						logArgs = append(logArgs, "tool_calls", len(resp.ToolCalls))
-					}
-				}
-				if resp, ok := any(result).(*EmbedResponse); ok && resp != nil {
-					logArgs = append(logArgs,
-						"embeddings", len(resp.Embeddings),
-						"input_tokens", resp.InputTokens,
-					)
-				}
-				s.logger.Info(opName+" request succeeded", logArgs...)
-			}
-			if s.hook != nil {
-				event := HookEvent{
-					Type:     HookSuccess,
-					Provider: s.provider.Name(),
-					Model:    s.model,
-					Latency:  latency,
-					Attempt:  attempt + 1,
-				}
-				// Enrich with token counts if result is a *Response
-				if resp, ok := any(result).(*Response); ok && resp != nil {
-					event.InputTokens = resp.InputTokens
-					event.OutputTokens = resp.OutputTokens
-				}
-				s.hook(event)
-			}
+			event := resultEvent(operationResult(result, nil), latency)
+			event.Attempt = attempt + 1
+			s.emit(ctx, event)
 			return result, nil
 		}
 
@@ -130,27 +97,56 @@ func retryWithBackoff[T any](
 
 		// Don't retry non-transient errors or on the last attempt
 		if !isRetryable(lastErr) || attempt == maxAttempts-1 {
-			if s.logger != nil {
-				s.logger.Error(opName+" request failed",
-					"provider", s.provider.Name(),
-					"model", s.model,
-					"error", sanitizeError(lastErr),
-					"attempt", attempt+1,
-				)
-			}
-			if s.hook != nil {
-				s.hook(HookEvent{
-					Type:     HookError,
-					Provider: s.provider.Name(),
-					Model:    s.model,
-					Latency:  latency,
-					Error:    sanitizeError(lastErr),
-					Attempt:  attempt + 1,
-				})
-			}
+			s.emit(ctx, HookEvent{
+				Type:    HookError,
+				Latency: latency,
+				Error:   sanitizeError(lastErr),
+				Attempt: attempt + 1,
+			})
 			return zero, lastErr
 		}
 	}
 
 	return zero, lastErr
 }
+
+// logOutcome logs the success or failure of an operation with its latency and,
+// for chat and embedding responses, token counts.
+func logOutcome[T any](s clientState, opName string, result T, err error, latency time.Duration) {
+	if s.logger == nil {
+		return
+	}
+	if err != nil {
+		s.logger.Error(opName+" request failed",
+			"provider", s.provider.Name(),
+			"model", s.model,
+			"error", sanitizeError(err),
+			"latency", latency,
+		)
+		return
+	}
+
+	logArgs := []any{
+		"provider", s.provider.Name(),
+		"model", s.model,
+		"latency", latency,
+	}
+	// Add token counts for debug tracing
+	if resp, ok := any(result).(*Response); ok && resp != nil {
+		logArgs = append(logArgs,
+			"input_tokens", resp.InputTokens,
+			"output_tokens", resp.OutputTokens,
+			"finish_reason", resp.FinishReason,
+		)
+		if len(resp.ToolCalls) > 0 {
+			logArgs = append(logArgs, "tool_calls", len(resp.ToolCalls))
+		}
+	}
+	if resp, ok := any(result).(*EmbedResponse); ok && resp != nil {
+		logArgs = append(logArgs,
+			"embeddings", len(resp.Embeddings),
+			"input_tokens", resp.InputTokens,
+		)
+	}
+	s.logger.Info(opName+" request succeeded", logArgs...)
+}
@@ -0,0 +1,334 @@
+package allm
+
+import (
+	"bufio"
+	"bytes"
+	"container/heap"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"regexp"
+	"strconv"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Tokenizer counts the tokens in a text offline.
+type Tokenizer interface {
+	// Count returns the number of tokens in text.
+	Count(text string) int
+}
+
+// BPE encoding names for LoadBPE.
+const (
+	EncodingCL100k = "cl100k_base" // GPT-4, GPT-3.5, text-embedding-3
+	EncodingO200k  = "o200k_base"  // GPT-4o, GPT-4.1, GPT-5, o-series
+)
+
+// Pre-tokenization patterns of the tiktoken encodings. RE2 has no lookahead,
+// so the trailing `\s+(?!\S)|\s+` alternatives are a captured `\s+` that
+// splitPieces shortens by one character when non-whitespace follows.
+// Go's \s is ASCII-only, so Unicode whitespace is spelled out.
+const (
+	bpeSpace = `\s\x0b\x{85}\p{Z}`
+
+	cl100kPattern = `^(?:(?i:'s|'t|'re|'ve|'m|'ll|'d)|[^\r\n\p{L}\p{N}]?\p{L}+|\p{N}{1,3}| ?[^` + bpeSpace + `\p{L}\p{N}]+[\r\n]*|[` + bpeSpace + `]*[\r\n]+|([` + bpeSpace + `]+))`
+
+	o200kPattern = `^(?:[^\r\n\p{L}\p{N}]?[\p{Lu}\p{Lt}\p{Lm}\p{Lo}\p{M}]*[\p{Ll}\p{Lm}\p{Lo}\p{M}]+(?i:'s|'t|'re|'ve|'m|'ll|'d)?` +
+		`|[^\r\n\p{L}\p{N}]?[\p{Lu}\p{Lt}\p{Lm}\p{Lo}\p{M}]+[\p{Ll}\p{Lm}\p{Lo}\p{M}]*(?i:'s|'t|'re|'ve|'m|'ll|'d)?` +
+		`|\p{N}{1,3}| ?[^` + bpeSpace + `\p{L}\p{N}]+[\r\n/]*|[` + bpeSpace + `]*[\r\n]+|([` + bpeSpace + `]+))`
+)
+
+var (
+	cl100kRegexp = sync.OnceValue(func() *regexp.Regexp { return regexp.MustCompile(cl100kPattern) })
+	o200kRegexp  = sync.OnceValue(func() *regexp.Regexp { return regexp.MustCompile(o200kPattern) })
+)
+
+// splitPieces splits text into pre-tokenization pieces using re.
+func splitPieces(re *regexp.Regexp, text string) []string {
+	var pieces []string
+	for pos := 0; pos < len(text); {
+		m := re.FindStringSubmatchIndex(text[pos:])
+		if m == nil {
+			// Unreachable for valid patterns; consume one rune to guarantee progress.
+			_, size := utf8.DecodeRuneInString(text[pos:])
+			pieces = append(pieces, text[pos:pos+size])
+			pos += size
+			continue
+		}
+		end := pos + m[1]
+		// Emulate `\s+(?!\S)`: leave the last space for the following word.
+		if m[2] >= 0 && end < len(text) {
+			if r, _ := utf8.DecodeRuneInString(text[end:]); !isBPESpace(r) {
+				if last, size := utf8.DecodeLastRuneInString(text[pos:end]); size < end-pos && isBPESpace(last) {
+					end -= size
+				}
+			}
+		}
+		pieces = append(pieces, text[pos:end])
+		pos = end
+	}
+	return pieces
+}
+
+// isBPESpace reports whether r is whitespace in the pre-tokenization patterns.
+func isBPESpace(r rune) bool {
+	return unicode.IsSpace(r) || unicode.Is(unicode.Z, r)
+}
+
+// BPE is a byte-pair encoding tokenizer using a tiktoken vocabulary.
+// The vocabularies are not bundled with allm; load them with LoadBPE or LoadBPEFile.
+type BPE struct {
+	name  string
+	ranks map[string]int
+	re    *regexp.Regexp
+}
+
+// LoadBPE reads a vocabulary in tiktoken format (one "base64-token rank" pair
+// per line, as in cl100k_base.tiktoken) for the given encoding. The vocabulary
+// must contain every single byte, so that any text can be encoded.
+func LoadBPE(r io.Reader, encoding string) (*BPE, error) {
+	var re *regexp.Regexp
+	switch encoding {
+	case EncodingCL100k:
+		re = cl100kRegexp()
+	case EncodingO200k:
+		re = o200kRegexp()
+	default:
+		return nil, fmt.Errorf("unknown BPE encoding: %s", encoding)
+	}
+
+	ranks := make(map[string]int)
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		fields := bytes.Fields(scanner.Bytes())
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("invalid BPE vocabulary line %d", line)
+		}
+		token, err := base64.StdEncoding.DecodeString(string(fields[0]))
+		if err != nil {
+			return nil, fmt.Errorf("invalid BPE vocabulary line %d: %w", line, err)
+		}
+		rank, err := strconv.Atoi(string(fields[1]))
+		if err != nil {
+			return nil, fmt.Errorf("invalid BPE vocabulary line %d: %w", line, err)
+		}
+		ranks[string(token)] = rank
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read BPE vocabulary: %w", err)
+	}
+	if len(ranks) == 0 {
+		return nil, fmt.Errorf("empty BPE vocabulary")
+	}
+	for c := 0; c < 256; c++ {
+		if _, ok := ranks[string([]byte{byte(c)})]; !ok {
+			return nil, fmt.Errorf("BPE vocabulary is missing byte 0x%02x", c)
+		}
+	}
+	return &BPE{name: encoding, ranks: ranks, re: re}, nil
+}
+
+// LoadBPEFile loads a tiktoken vocabulary file (see LoadBPE).
+func LoadBPEFile(path, encoding string) (*BPE, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+	return LoadBPE(f, encoding)
+}
+
+// Name returns the encoding name.
+func (b *BPE) Name() string {
+	return b.name
+}
+
+// Encode returns the token IDs of text. Special tokens are encoded as text.
+func (b *BPE) Encode(text string) []int {
+	var ids []int
+	for _, piece := range splitPieces(b.re, text) {
+		ids = append(ids, b.encodePiece([]byte(piece))...)
+	}
+	return ids
+}
+
+// Count returns the number of tokens in text.
+func (b *BPE) Count(text string) int {
+	n := 0
+	for _, piece := range splitPieces(b.re, text) {
+		if _, ok := b.ranks[piece]; ok {
+			n++
+			continue
+		}
+		n += len(b.encodePiece([]byte(piece)))
+	}
+	return n
+}
+
+// encodePiece merges the lowest-ranked adjacent pair until no pair is in the
+// vocabulary. Candidate pairs are kept in a heap by rank, so each merge only
+// rescores the pairs next to it.
+func (b *BPE) encodePiece(piece []byte) []int {
+	if rank, ok := b.ranks[string(piece)]; ok {
+		return []int{rank}
+	}
+
+	// Parts form a linked list over byte offsets: part i is piece[i:next[i]].
+	// Merged-away parts have next[i] == -1.
+	n := len(piece)
+	next, prev := make([]int, n), make([]int, n)
+	for i := range piece {
+		next[i], prev[i] = i+1, i-1
+	}
+	pairRank := func(i int) (int, bool) {
+		if i < 0 || next[i] >= n {
+			return 0, false
+		}
+		rank, ok := b.ranks[string(piece[i:next[next[i]]])]
+		return rank, ok
+	}
+
+	merges := make(bpeMerges, 0, n)
+	for i := 0; i < n-1; i++ {
+		if rank, ok := pairRank(i); ok {
+			merges = append(merges, bpeMerge{rank: rank, pos: i})
+		}
+	}
+	heap.Init(&merges)
+	for merges.Len() > 0 {
+		m := heap.Pop(&merges).(bpeMerge)
+		if next[m.pos] < 0 {
+			continue
+		}
+		if rank, ok := pairRank(m.pos); !ok || rank != m.rank {
+			continue // stale: a neighbor was merged since this pair was queued
+		}
+		i, j := m.pos, next[m.pos]
+		next[i] = next[j]
+		if next[i] < n {
+			prev[next[i]] = i
+		}
+		next[j] = -1
+		for _, pos := range []int{prev[i], i} {
+			if rank, ok := pairRank(pos); ok {
+				heap.Push(&merges, bpeMerge{rank: rank, pos: pos})
+			}
+		}
+	}
+
+	var ids []int
+	for i := 0; i < n; i = next[i] {
+		// Every part is a vocabulary token: LoadBPE requires all single bytes.
+		ids = append(ids, b.ranks[string(piece[i:next[i]])])
+	}
+	return ids
+}
+
+// bpeMerge is a candidate merge of the part at pos with the next part.
+type bpeMerge struct {
+	rank int
+	pos  int
+}
+
+// bpeMerges is a min-heap of candidate merges, lowest rank and then leftmost first.
+type bpeMerges []bpeMerge
+
+func (h bpeMerges) Len() int      { return len(h) }
+func (h bpeMerges) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+func (h bpeMerges) Less(i, j int) bool {
+	if h[i].rank != h[j].rank {
+		return h[i].rank < h[j].rank
+	}
+	return h[i].pos < h[j].pos
+}
+func (h *bpeMerges) Push(x any) { *h = append(*h, x.(bpeMerge)) }
+func (h *bpeMerges) Pop() any {
+	old := *h
+	m := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return m
+}
+
+// HeuristicTokenizer estimates tokens without a vocabulary, from the same
+// pieces cl100k splits text into. It needs no data files but is approximate.
+// Calibrate it against provider-reported counts to improve accuracy.
+type HeuristicTokenizer struct {
+	mu     sync.RWMutex
+	tokens float64 // calibrated token counts, summed
+	raw    float64 // uncalibrated estimates of the same texts, summed
+}
+
+// NewHeuristicTokenizer returns an uncalibrated heuristic tokenizer.
+func NewHeuristicTokenizer() *HeuristicTokenizer {
+	return &HeuristicTokenizer{}
+}
+
+// Count returns the estimated number of tokens in text.
+func (h *HeuristicTokenizer) Count(text string) int {
+	if text == "" {
+		return 0
+	}
+	h.mu.RLock()
+	scale := 1.0
+	if h.raw > 0 {
+		scale = h.tokens / h.raw
+	}
+	h.mu.RUnlock()
+	return max(1, int(math.Round(rawHeuristicCount(text)*scale)))
+}
+
+// Calibrate adjusts the estimate so text counts as tokens, e.g. using the
+// input tokens a provider reported for a known prompt. Later calls refine
+// earlier ones: the estimate is scaled by the ratio of all calibrated tokens
+// to their uncalibrated estimates, so longer samples weigh more.
+func (h *HeuristicTokenizer) Calibrate(text string, tokens int) {
+	raw := rawHeuristicCount(text)
+	if raw == 0 || tokens <= 0 {
+		return
+	}
+	h.mu.Lock()
+	h.tokens += float64(tokens)
+	h.raw += raw
+	h.mu.Unlock()
+}
+
+// rawHeuristicCount estimates tokens per pre-tokenization piece: short words
+// are one token, long words split every ~7 letters, digits go in groups of
+// three, CJK is about one token per character and other scripts about two
+// characters per token.
+func rawHeuristicCount(text string) float64 {
+	total := 0.0
+	for _, piece := range splitPieces(cl100kRegexp(), text) {
+		var letters, cjk, other, punct int
+		for _, r := range piece {
+			switch {
+			case unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul):
+				cjk++
+			case r < utf8.RuneSelf && (unicode.IsLetter(r) || unicode.IsDigit(r)):
+				letters++
+			case unicode.IsLetter(r) || unicode.IsMark(r) || unicode.IsDigit(r):
+				other++
+			case !isBPESpace(r):
+				punct++
+			}
+		}
+		switch {
+		case cjk > 0 || other > 0:
+			total += float64(cjk) + math.Ceil(float64(other)/2) + math.Ceil(float64(letters)/7)
+		case letters > 0:
+			total += math.Ceil(float64(letters) / 7)
+		case punct > 0:
+			total += math.Ceil(float64(punct) / 3)
+		default:
+			total++ // whitespace
+		}
+	}
+	return total
+}
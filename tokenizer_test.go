@@ -0,0 +1,344 @@
+package allm
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// testVocab returns a tiktoken-format vocabulary with every single byte plus a
+// few merges: "he", "ll", "hell", "hello".
+func testVocab() string {
+	var b strings.Builder
+	for i := 0; i < 256; i++ {
+		fmt.Fprintf(&b, "%s %d\n", base64.StdEncoding.EncodeToString([]byte{byte(i)}), i)
+	}
+	for i, tok := range []string{"he", "ll", "hell", "hello"} {
+		fmt.Fprintf(&b, "%s %d\n", base64.StdEncoding.EncodeToString([]byte(tok)), 256+i)
+	}
+	return b.String()
+}
+
+func TestSplitPiecesCL100k(t *testing.T) {
+	got := splitPieces(cl100kRegexp(), "Hello world's  123456 !!\n\nfoo")
+	want := []string{"Hello", " world", "'s", " ", " ", "123", "456", " !!\n\n", "foo"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("pieces = %q, want %q", got, want)
+	}
+
+	got = splitPieces(cl100kRegexp(), "a\n\n  b   ")
+	want = []string{"a", "\n\n", " ", " b", "   "}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("pieces = %q, want %q", got, want)
+	}
+}
+
+func TestSplitPiecesO200k(t *testing.T) {
+	got := splitPieces(o200kRegexp(), "HelloWorld's path/to")
+	want := []string{"Hello", "World's", " path", "/to"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("pieces = %q, want %q", got, want)
+	}
+}
+
+func TestSplitPiecesCoversInput(t *testing.T) {
+	text := "Grüße, 世界!\t  x\r\n  ünïcode 12345 'LL"
+	for _, re := range []func() string{
+		func() string { return strings.Join(splitPieces(cl100kRegexp(), text), "") },
+		func() string { return strings.Join(splitPieces(o200kRegexp(), text), "") },
+	} {
+		if got := re(); got != text {
+			t.Errorf("joined pieces = %q, want %q", got, text)
+		}
+	}
+}
+
+func TestBPE(t *testing.T) {
+	bpe, err := LoadBPE(strings.NewReader(testVocab()), EncodingCL100k)
+	if err != nil {
+		t.Fatalf("LoadBPE: %v", err)
+	}
+	if bpe.Name() != EncodingCL100k {
+		t.Errorf("Name = %q", bpe.Name())
+	}
+
+	// "hello" is one token; " hello" is split off as " " + "hello".
+	got := bpe.Encode("hello hello")
+	want := []int{259, ' ', 259}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Encode = %v, want %v", got, want)
+	}
+	if n := bpe.Count("hello hello"); n != 3 {
+		t.Errorf("Count = %d, want 3", n)
+	}
+	if n := bpe.Count("shell"); n != 2 { // "s" + "hell", merged via "he" and "ll"
+		t.Errorf("Count(shell) = %d, want 2", n)
+	}
+	if n := bpe.Count("hex"); n != 2 { // "he" + "x"
+		t.Errorf("Count(hex) = %d, want 2", n)
+	}
+	if got := bpe.Encode("hellohellolll"); !reflect.DeepEqual(got, []int{259, 259, 257, 'l'}) {
+		t.Errorf("Encode(hellohellolll) = %v", got)
+	}
+	if n := bpe.Count(strings.Repeat("l", 10001)); n != 5001 {
+		t.Errorf("Count(l*10001) = %d, want 5001", n)
+	}
+	if n := bpe.Count(""); n != 0 {
+		t.Errorf("Count(\"\") = %d, want 0", n)
+	}
+}
+
+func TestLoadBPEFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.tiktoken")
+	if err := os.WriteFile(path, []byte(testVocab()), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	bpe, err := LoadBPEFile(path, EncodingO200k)
+	if err != nil {
+		t.Fatalf("LoadBPEFile: %v", err)
+	}
+	if n := bpe.Count("hello"); n != 1 {
+		t.Errorf("Count = %d, want 1", n)
+	}
+
+	if _, err := LoadBPEFile(filepath.Join(t.TempDir(), "missing"), EncodingO200k); err == nil {
+		t.Error("expected error for missing file")
+	}
+}
+
+func TestLoadBPEErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     string
+		encoding string
+	}{
+		{"unknown encoding", testVocab(), "p50k_base"},
+		{"empty", "", EncodingCL100k},
+		{"bad base64", "!!! 1\n", EncodingCL100k},
+		{"bad rank", "aGk= x\n", EncodingCL100k},
+		{"extra field", "aGk= 1 2\n", EncodingCL100k},
+		{"missing bytes", "aGk= 1\n", EncodingCL100k},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := LoadBPE(strings.NewReader(tt.data), tt.encoding); err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+}
+
+func TestHeuristicTokenizer(t *testing.T) {
+	h := NewHeuristicTokenizer()
+	tests := []struct {
+		text     string
+		min, max int
+	}{
+		{"", 0, 0},
+		{"hello", 1, 1},
+		{"The quick brown fox jumps over the lazy dog.", 9, 12},
+		{"func main() { fmt.Println(\"hi\") }", 8, 16},
+		{"你好世界", 4, 4},
+		{"internationalization", 2, 4},
+	}
+	for _, tt := range tests {
+		if n := h.Count(tt.text); n < tt.min || n > tt.max {
+			t.Errorf("Count(%q) = %d, want %d..%d", tt.text, n, tt.min, tt.max)
+		}
+	}
+}
+
+func TestHeuristicTokenizerCalibrate(t *testing.T) {
+	h := NewHeuristicTokenizer()
+	text := strings.Repeat("hello world ", 50)
+	before := h.Count(text)
+
+	h.Calibrate(text, before*3)
+	if after := h.Count(text); after != before*3 {
+		t.Errorf("calibrated count = %d, want %d", after, before*3)
+	}
+
+	// Invalid samples are ignored.
+	h.Calibrate("", 10)
+	h.Calibrate(text, 0)
+	if after := h.Count(text); after != before*3 {
+		t.Errorf("count after invalid samples = %d, want %d", after, before*3)
+	}
+
+	// Samples are weighted by size: a sample as long as the first one that
+	// counts as estimated pulls the ratio halfway back, to 2.
+	h.Calibrate(text, before)
+	if after := h.Count(text); after != before*2 {
+		t.Errorf("count after second sample = %d, want %d", after, before*2)
+	}
+}
+
+// wordTokenizer counts one token per whitespace-separated word.
+type wordTokenizer struct{}
+
+func (wordTokenizer) Count(text string) int { return len(strings.Fields(text)) }
+
+// recordingTokenizer counts words and records the texts it was asked to count.
+type recordingTokenizer struct {
+	texts []string
+}
+
+func (r *recordingTokenizer) Count(text string) int {
+	r.texts = append(r.texts, text)
+	return len(strings.Fields(text))
+}
+
+func testPNG(t *testing.T, w, h int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, w, h))); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestTokenEstimator(t *testing.T) {
+	e := NewTokenEstimator(wordTokenizer{})
+
+	req := &Request{
+		Model: "m",
+		Messages: []Message{
+			{Role: RoleSystem, Content: "be brief"},
+			{Role: RoleUser, Content: "one two three"},
+		},
+	}
+	count, err := e.CountTokens(context.Background(), req)
+	if err != nil {
+		t.Fatalf("CountTokens: %v", err)
+	}
+	want := replyTokenOverhead + 2*messageTokenOverhead + 2 + 3
+	if count.InputTokens != want || count.Model != "m" || count.Provider != "estimate" {
+		t.Errorf("count = %+v, want %d tokens", count, want)
+	}
+
+	// Tools, tool calls, results and schemas add to the count.
+	req.Tools = []Tool{{Name: "lookup", Description: "find a thing", Parameters: map[string]any{"type": "object"}}}
+	req.ResponseFormat = &ResponseFormat{Type: ResponseFormatJSONSchema, Schema: map[string]any{"type": "object"}}
+	req.Messages = append(req.Messages,
+		Message{Role: RoleAssistant, ToolCalls: []ToolCall{{ID: "1", Name: "lookup", Arguments: []byte(`{"q": "x"}`)}}},
+		Message{Role: RoleTool, ToolResults: []ToolResult{{ToolCallID: "1", Content: "found it"}}},
+	)
+	if got := e.EstimateRequest(req); got <= want+toolTokenOverhead {
+		t.Errorf("EstimateRequest = %d, want more than %d", got, want+toolTokenOverhead)
+	}
+}
+
+func TestTokenEstimatorCachesDocuments(t *testing.T) {
+	tok := &recordingTokenizer{}
+	e := NewTokenEstimator(tok)
+	pdf := Document{MimeType: "application/pdf", Data: buildPDF(true, "BT (Page one) Tj ET")}
+	msg := Message{Role: RoleUser, Documents: []Document{pdf}}
+
+	first := e.EstimateMessage(msg)
+	if second := e.EstimateMessage(msg); second != first {
+		t.Errorf("second estimate = %d, want %d", second, first)
+	}
+	extracted := 0
+	for _, text := range tok.texts {
+		if text == "Page one" {
+			extracted++
+		}
+	}
+	if extracted != 1 {
+		t.Errorf("document text counted %d times, want 1", extracted)
+	}
+
+	// Different content is counted on its own.
+	other := Document{MimeType: "application/pdf", Data: buildPDF(true, "BT (Page one two) Tj ET")}
+	if got := e.EstimateMessage(Message{Role: RoleUser, Documents: []Document{other}}); got != first+1 {
+		t.Errorf("estimate of other document = %d, want %d", got, first+1)
+	}
+}
+
+func TestTokenEstimatorAttachments(t *testing.T) {
+	e := NewTokenEstimator(wordTokenizer{})
+	base := e.EstimateMessage(Message{Role: RoleUser})
+
+	tests := []struct {
+		name string
+		msg  Message
+		want int
+	}{
+		{"small image", Message{Images: []Image{{MimeType: "image/png", Data: testPNG(t, 100, 75)}}}, 10},
+		{"large image", Message{Images: []Image{{MimeType: "image/png", Data: testPNG(t, 3000, 3000)}}}, imageMaxTokens},
+		{"undecodable image", Message{Images: []Image{{MimeType: "image/webp", Data: []byte("RIFF")}}}, imageMaxTokens},
+		{"file image", Message{Images: []Image{{FileID: "file-1"}}}, imageMaxTokens},
+		{"text document", Message{Documents: []Document{{MimeType: "text/plain", Data: []byte("a b c")}}}, 3},
+		{"scanned pdf", Message{Documents: []Document{{MimeType: "application/pdf", Data: []byte("%PDF /Type /Pages /Type /Page /Type/Page")}}}, 2 * pdfPageTokens},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := e.EstimateMessage(tt.msg) - base; got != tt.want {
+				t.Errorf("tokens = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWithTokenCounter(t *testing.T) {
+	client := New(&mockProvider{}, WithTokenCounter(NewTokenEstimator(wordTokenizer{})))
+	count, err := client.CountTokens(context.Background(), []Message{{Role: RoleUser, Content: "a b"}})
+	if err != nil {
+		t.Fatalf("CountTokens: %v", err)
+	}
+	if want := replyTokenOverhead + messageTokenOverhead + 2; count.InputTokens != want {
+		t.Errorf("InputTokens = %d, want %d", count.InputTokens, want)
+	}
+}
+
+func TestTruncateMessagesEstimatesWithoutTokenCounter(t *testing.T) {
+	s := clientState{
+		provider:           &mockProvider{},
+		maxContextTokens:   60,
+		truncationStrategy: TruncateTail,
+	}
+	messages := []Message{
+		{Role: RoleUser, Content: strings.Repeat("word ", 100)},
+		{Role: RoleAssistant, Content: "ok"},
+		{Role: RoleUser, Content: "last"},
+	}
+	truncated, _, err := truncateMessages(context.Background(), s, messages)
+	if err != nil {
+		t.Fatalf("truncateMessages: %v", err)
+	}
+	if len(truncated) != 2 || truncated[1].Content != "last" {
+		t.Errorf("truncated = %+v", truncated)
+	}
+
+	s.truncationStrategy = TruncateNone
+	if _, _, err := truncateMessages(context.Background(), s, messages); err == nil {
+		t.Error("expected error for TruncateNone over the limit")
+	}
+}
+
+func TestWithTokenCounterOverridesProvider(t *testing.T) {
+	s := clientState{provider: &mockCounter{}, tokenCounter: errCounter{}}
+	counter, ok := s.counter()
+	if !ok {
+		t.Fatal("counter not found")
+	}
+	if _, err := counter.CountTokens(context.Background(), &Request{}); !errors.Is(err, ErrNotSupported) {
+		t.Errorf("expected configured counter, got err %v", err)
+	}
+}
+
+// errCounter is a TokenCounter that always fails.
+type errCounter struct{}
+
+func (errCounter) CountTokens(context.Context, *Request) (*TokenCount, error) {
+	return nil, ErrNotSupported
+}
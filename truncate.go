@@ -0,0 +1,218 @@
+package allm
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// summaryMaxTokens caps the length of a TruncateSummarize summary. The summary
+// budget (at most a quarter of the context limit) is reserved in the context window.
+const summaryMaxTokens = 1024
+
+// summaryPrompt instructs the model that writes TruncateSummarize summaries.
+const summaryPrompt = "Summarize the following conversation excerpt so it can replace the original messages. " +
+	"Keep facts, names, numbers, decisions, tool results and open questions. Be concise; write plain prose."
+
+// WithTruncationKeep sets how many turns TruncateMiddle and TruncateSummarize
+// always keep at the start and end of the conversation (default 1 and 1).
+// A turn is a message, or an assistant tool call together with its results.
+func WithTruncationKeep(first, last int) Option {
+	return func(c *Client) {
+		if first >= 0 {
+			c.truncateKeepFirst = first
+		}
+		if last >= 1 {
+			c.truncateKeepLast = last
+		}
+	}
+}
+
+// WithSummaryModel sets the model TruncateSummarize uses to summarize dropped
+// messages, typically a cheaper one (default: the chat model).
+func WithSummaryModel(model string) Option {
+	return func(c *Client) {
+		c.summaryModel = model
+	}
+}
+
+// splitTurns separates system messages and groups the rest into turns that are
+// kept or dropped together, so tool results are never separated from the
+// assistant message that called the tools.
+func splitTurns(messages []Message) (system []Message, turns [][]Message) {
+	for _, m := range messages {
+		switch {
+		case m.Role == RoleSystem:
+			system = append(system, m)
+		case m.Role == RoleTool && len(turns) > 0:
+			turns[len(turns)-1] = append(turns[len(turns)-1], m)
+		default:
+			turns = append(turns, []Message{m})
+		}
+	}
+	return system, turns
+}
+
+// joinTurns flattens system messages and turns into one message list.
+func joinTurns(system []Message, turns ...[][]Message) []Message {
+	out := append([]Message(nil), system...)
+	for _, group := range turns {
+		for _, turn := range group {
+			out = append(out, turn...)
+		}
+	}
+	return out
+}
+
+// countMessageTokens counts the input tokens of messages sent with client state s.
+func countMessageTokens(ctx context.Context, s clientState, counter TokenCounter, messages []Message) (int, error) {
+	count, err := counter.CountTokens(ctx, buildRequest(messages, s))
+	if err != nil {
+		return 0, err
+	}
+	return count.InputTokens, nil
+}
+
+// truncateTail drops the oldest turns until the context fits, keeping system
+// messages and at least the last turn.
+func truncateTail(ctx context.Context, s clientState, counter TokenCounter, messages []Message) []Message {
+	system, turns := splitTurns(messages)
+	for len(turns) > 1 {
+		turns = turns[1:]
+		truncated := joinTurns(system, turns)
+
+		tokens, err := countMessageTokens(ctx, s, counter, truncated)
+		if err != nil || tokens <= s.maxContextTokens {
+			return truncated
+		}
+	}
+	return joinTurns(system, turns)
+}
+
+// truncateMiddle drops turns after the first truncateKeepFirst, oldest first,
+// until the context fits in limit tokens. The last truncateKeepLast turns are
+// always kept. It returns the kept messages and the dropped turns in order.
+func truncateMiddle(ctx context.Context, s clientState, counter TokenCounter, messages []Message, limit int) (kept, dropped []Message) {
+	system, turns := splitTurns(messages)
+	first := min(s.truncateKeepFirst, len(turns))
+	last := max(s.truncateKeepLast, 1)
+
+	head := turns[:first]
+	middle := turns[first:]
+	var droppedTurns [][]Message
+	for len(middle) > last {
+		droppedTurns = append(droppedTurns, middle[0])
+		middle = middle[1:]
+
+		tokens, err := countMessageTokens(ctx, s, counter, joinTurns(system, head, middle))
+		if err != nil || tokens <= limit {
+			break
+		}
+	}
+	return joinTurns(system, head, middle), joinTurns(nil, droppedTurns)
+}
+
+// truncateSummarize drops middle turns like TruncateMiddle, leaving room for a
+// summary, and prepends a summary of the dropped messages to the first kept
+// user turn after them. The summary is not a system message, because some
+// providers move system messages to the top of the prompt.
+func truncateSummarize(ctx context.Context, s clientState, counter TokenCounter, messages []Message) (kept []Message, dropped int, err error) {
+	budget := max(min(summaryMaxTokens, s.maxContextTokens/4), 1)
+	kept, droppedMessages := truncateMiddle(ctx, s, counter, messages, s.maxContextTokens-budget)
+	if len(droppedMessages) == 0 {
+		return kept, 0, nil
+	}
+
+	summary, err := summarizeMessages(ctx, s, droppedMessages, budget)
+	if err != nil {
+		return nil, 0, fmt.Errorf("summarize: %w", err)
+	}
+	summary = "Summary of earlier conversation:\n" + summary
+
+	system, turns := splitTurns(kept)
+	first := min(s.truncateKeepFirst, len(turns))
+	for i := first; i < len(turns); i++ {
+		if turns[i][0].Role != RoleUser {
+			continue
+		}
+		m := turns[i][0]
+		if m.Content != "" {
+			m.Content = summary + "\n\n" + m.Content
+		} else {
+			m.Content = summary
+		}
+		turns[i] = append([]Message{m}, turns[i][1:]...)
+		return joinTurns(system, turns), len(droppedMessages), nil
+	}
+	// No user turn follows the dropped ones: the summary becomes one.
+	out := joinTurns(system, turns[:first])
+	out = append(out, Message{Role: RoleUser, Content: summary})
+	return append(out, joinTurns(nil, turns[first:])...), len(droppedMessages), nil
+}
+
+// summarizeMessages asks the summary model for a summary of messages in at most maxTokens.
+func summarizeMessages(ctx context.Context, s clientState, messages []Message, maxTokens int) (string, error) {
+	model := s.summaryModel
+	if model == "" {
+		model = s.model
+	}
+	req := &Request{
+		Model:     model,
+		MaxTokens: maxTokens,
+		Messages: []Message{
+			{Role: RoleSystem, Content: summaryPrompt},
+			{Role: RoleUser, Content: renderTranscript(messages)},
+		},
+	}
+
+	if s.logger != nil {
+		s.logger.Debug("summarizing truncated messages",
+			"provider", s.provider.Name(),
+			"model", model,
+			"messages", len(messages),
+		)
+	}
+
+	if err := s.checkBudget(); err != nil {
+		return "", err
+	}
+	resp, err := s.chain().Complete(ctx, req)
+	if err != nil {
+		return "", err
+	}
+	if !resp.Cached {
+		s.recordUsage(ctx, s.responseUsage(resp))
+	}
+	if strings.TrimSpace(resp.Content) == "" {
+		return "", ErrEmptyResponse
+	}
+	return strings.TrimSpace(resp.Content), nil
+}
+
+// renderTranscript renders messages as plain text for summarization.
+// Attachments are replaced by placeholders.
+func renderTranscript(messages []Message) string {
+	var b strings.Builder
+	for _, m := range messages {
+		if m.Content != "" {
+			fmt.Fprintf(&b, "%s: %s\n", m.Role, m.Content)
+		}
+		for range m.Images {
+			fmt.Fprintf(&b, "%s: [image]\n", m.Role)
+		}
+		for _, doc := range m.Documents {
+			fmt.Fprintf(&b, "%s: [document %s]\n", m.Role, doc.Name)
+		}
+		for _, tc := range m.ToolCalls {
+			fmt.Fprintf(&b, "%s called %s(%s)\n", m.Role, tc.Name, tc.Arguments)
+		}
+		for _, tr := range m.ToolResults {
+			label := "tool result"
+			if tr.IsError {
+				label = "tool error"
+			}
+			fmt.Fprintf(&b, "%s: %s\n", label, tr.Content)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,195 @@
+package allm
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// mockCounter counts one token per byte of message content and returns a
+// fixed summary from Complete calls that carry the summary prompt.
+type mockCounter struct {
+	mockProvider
+	countMu   sync.Mutex
+	summaries []*Request
+}
+
+func (m *mockCounter) CountTokens(_ context.Context, req *Request) (*TokenCount, error) {
+	n := 0
+	for _, msg := range req.Messages {
+		n += len(msg.Content)
+		for _, tr := range msg.ToolResults {
+			n += len(tr.Content)
+		}
+	}
+	return &TokenCount{InputTokens: n}, nil
+}
+
+func (m *mockCounter) Complete(ctx context.Context, req *Request) (*Response, error) {
+	if len(req.Messages) > 0 && req.Messages[0].Content == summaryPrompt {
+		m.countMu.Lock()
+		m.summaries = append(m.summaries, req)
+		m.countMu.Unlock()
+		return &Response{Content: "SUMMARY", InputTokens: 50}, nil
+	}
+	return m.mockProvider.Complete(ctx, req)
+}
+
+// toolConversation returns a conversation whose oldest turns include a tool call/result pair.
+func toolConversation() []Message {
+	return []Message{
+		{Role: RoleSystem, Content: "sys"},
+		{Role: RoleUser, Content: strings.Repeat("a", 100)},
+		{Role: RoleAssistant, ToolCalls: []ToolCall{{ID: "c1", Name: "lookup"}}},
+		{Role: RoleTool, ToolResults: []ToolResult{{ToolCallID: "c1", Content: strings.Repeat("r", 100)}}},
+		{Role: RoleAssistant, Content: strings.Repeat("b", 100)},
+		{Role: RoleUser, Content: strings.Repeat("c", 100)},
+		{Role: RoleAssistant, Content: strings.Repeat("d", 100)},
+		{Role: RoleUser, Content: "last"},
+	}
+}
+
+// checkToolPairs fails if a tool result is not preceded by its tool call.
+func checkToolPairs(t *testing.T, msgs []Message) {
+	t.Helper()
+	for i, m := range msgs {
+		if m.Role == RoleTool && (i == 0 || len(msgs[i-1].ToolCalls) == 0) {
+			t.Errorf("tool result at %d is separated from its tool call: %+v", i, msgs)
+		}
+	}
+}
+
+func TestSplitTurns(t *testing.T) {
+	system, turns := splitTurns(toolConversation())
+	if len(system) != 1 || len(turns) != 6 || len(turns[1]) != 2 || turns[1][1].Role != RoleTool {
+		t.Errorf("unexpected turns: system=%d turns=%+v", len(system), turns)
+	}
+}
+
+func TestTruncateTailKeepsToolPairs(t *testing.T) {
+	p := &mockCounter{mockProvider: mockProvider{name: "test"}}
+	s := clientState{provider: p, maxContextTokens: 350, truncationStrategy: TruncateTail}
+
+	got, _, err := truncateMessages(context.Background(), s, toolConversation())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// Dropping the first user turn alone would leave 403 tokens; the tool
+	// call and its result must go together.
+	if len(got) != 5 || got[0].Role != RoleSystem || got[1].Content[0] != 'b' {
+		t.Errorf("unexpected truncation: %+v", got)
+	}
+	checkToolPairs(t, got)
+}
+
+func TestTruncateMiddle(t *testing.T) {
+	p := &mockCounter{mockProvider: mockProvider{name: "test"}}
+	s := clientState{provider: p, maxContextTokens: 250, truncationStrategy: TruncateMiddle, truncateKeepFirst: 1, truncateKeepLast: 1}
+
+	got, _, err := truncateMessages(context.Background(), s, toolConversation())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// sys + first user turn + ... + last; the tool pair is dropped as a unit
+	if got[0].Role != RoleSystem || got[1].Content[0] != 'a' || got[len(got)-1].Content != "last" {
+		t.Errorf("expected first and last turns to be kept: %+v", got)
+	}
+	if len(got) != 4 || got[2].Content[0] != 'd' {
+		t.Errorf("unexpected truncation: %+v", got)
+	}
+	checkToolPairs(t, got)
+
+	// Never drops protected turns even if still over the limit
+	s.maxContextTokens = 1
+	s.truncateKeepLast = 2
+	got, _, _ = truncateMessages(context.Background(), s, toolConversation())
+	if len(got) != 4 || got[2].Content[0] != 'd' || got[3].Content != "last" {
+		t.Errorf("expected first and last two turns: %+v", got)
+	}
+}
+
+func TestTruncateSummarize(t *testing.T) {
+	var rec eventRecorder
+	p := &mockCounter{mockProvider: mockProvider{name: "test", available: true, response: &Response{Content: "ok"}}}
+	client := New(p,
+		WithModel("big"),
+		WithMaxContextTokens(400), // 100 reserved for the summary
+		WithTruncationStrategy(TruncateSummarize),
+		WithSummaryModel("cheap"),
+		WithHook(rec.hook),
+	)
+
+	if _, err := client.Chat(context.Background(), toolConversation()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(p.summaries) != 1 {
+		t.Fatalf("expected one summary request, got %d", len(p.summaries))
+	}
+	sumReq := p.summaries[0]
+	transcript := sumReq.Messages[1].Content
+	if sumReq.Model != "cheap" || sumReq.MaxTokens != 100 || !strings.Contains(transcript, "assistant called lookup") ||
+		!strings.Contains(transcript, "tool result: rrr") || strings.Contains(transcript, "last") {
+		t.Errorf("unexpected summary request: model=%s transcript=%q", sumReq.Model, transcript)
+	}
+
+	// sys + first turn + turns fitting in 300 tokens, with the summary
+	// prepended to the first kept user turn
+	sent := p.getLastReq().Messages
+	if len(sent) != 4 || sent[1].Content[0] != 'a' || sent[2].Content[0] != 'd' ||
+		sent[3].Role != RoleUser || sent[3].Content != "Summary of earlier conversation:\nSUMMARY\n\nlast" {
+		t.Errorf("unexpected summarized request: %+v", sent)
+	}
+	if sent[0].Role != RoleSystem || sent[0].Content != "sys" {
+		t.Errorf("system prompt should stay first: %+v", sent[0])
+	}
+
+	// The summary call is counted, and the dropped messages are reported.
+	if u := client.Usage(); u.Requests != 2 || u.InputTokens != 50 {
+		t.Errorf("usage = %+v, want the summary call counted", u)
+	}
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	var dropped int
+	for _, e := range rec.events {
+		if e.Type == HookTruncation {
+			dropped = e.TruncatedMessages
+		}
+	}
+	if dropped != 4 {
+		t.Errorf("truncation event dropped %d messages, want 4", dropped)
+	}
+}
+
+func TestTruncateSummarizeError(t *testing.T) {
+	p := &failingSummarizer{mockCounter: &mockCounter{mockProvider: mockProvider{name: "test"}}}
+	s := clientState{provider: p, timeout: time.Second, maxContextTokens: 1, truncationStrategy: TruncateSummarize, truncateKeepFirst: 1, truncateKeepLast: 1}
+
+	_, _, err := truncateMessages(context.Background(), s, toolConversation())
+	if !errors.Is(err, ErrProvider) {
+		t.Errorf("expected summary error, got %v", err)
+	}
+}
+
+// failingSummarizer fails every completion.
+type failingSummarizer struct {
+	*mockCounter
+}
+
+func (f *failingSummarizer) Complete(context.Context, *Request) (*Response, error) {
+	return nil, ErrProvider
+}
+
+func TestWithTruncationKeep(t *testing.T) {
+	c := New(nil)
+	if c.truncateKeepFirst != 1 || c.truncateKeepLast != 1 {
+		t.Errorf("unexpected defaults: %d/%d", c.truncateKeepFirst, c.truncateKeepLast)
+	}
+	c = New(nil, WithTruncationKeep(0, 3))
+	if c.truncateKeepFirst != 0 || c.truncateKeepLast != 3 {
+		t.Errorf("unexpected keep: %d/%d", c.truncateKeepFirst, c.truncateKeepLast)
+	}
+}
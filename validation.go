@@ -27,10 +27,23 @@ const (
 	MaxImageGenPromptLength = 32_000
 	// MaxImageGenCount is the maximum number of images per generation request.
 	MaxImageGenCount = 10
+	// MaxImageEditSources is the maximum number of source images in an edit request.
+	MaxImageEditSources = 16
+	// MaxAudioChunkSize is the default largest transcription upload (25MB, the OpenAI limit).
+	// Larger audio is split into chunks by Client.Transcribe.
+	MaxAudioChunkSize = 25 * 1024 * 1024
 	// MaxBatchSize is the maximum number of requests in a batch.
 	MaxBatchSize = 50_000
 	// MaxCustomIDLength is the maximum length for a batch request custom ID.
 	MaxCustomIDLength = 256
+	// MaxRerankDocuments is the maximum number of documents in a rerank request.
+	MaxRerankDocuments = 1000
+	// MaxFileSize is the maximum size of an uploaded file (500MB, the Anthropic Files API limit).
+	MaxFileSize = 500 * 1024 * 1024
+	// MaxFileIDLength is the maximum length for a provider file ID.
+	MaxFileIDLength = 256
+	// MaxFileNameLength is the maximum length for an uploaded filename.
+	MaxFileNameLength = 255
 )
 
 // Temperature and penalty bounds (OpenAI standard).
@@ -132,6 +145,12 @@ func validateRequest(req *Request) error {
 	// Validate image MIME types and sizes
 	for _, msg := range req.Messages {
 		for j, img := range msg.Images {
+			if img.FileID != "" {
+				if err := validateFileID(img.FileID); err != nil {
+					return fmt.Errorf("image %d: %w", j, err)
+				}
+				continue
+			}
 			if img.MimeType == "" {
 				return fmt.Errorf("image %d has empty MIME type", j)
 			}
@@ -145,6 +164,13 @@ func validateRequest(req *Request) error {
 				return fmt.Errorf("image %d exceeds maximum size of %d bytes (%d bytes)", j, MaxImageSize, len(img.Data))
 			}
 		}
+		for j, doc := range msg.Documents {
+			if doc.FileID != "" {
+				if err := validateFileID(doc.FileID); err != nil {
+					return fmt.Errorf("document %d: %w", j, err)
+				}
+			}
+		}
 	}
 
 	// Validate ResponseFormat
@@ -198,6 +224,177 @@ func validateImageRequest(req *ImageRequest) error {
 	if req.Model != "" && len(req.Model) > MaxModelNameLength {
 		return fmt.Errorf("model name exceeds maximum length of %d", MaxModelNameLength)
 	}
+	if req.Style != "" && req.Style != "vivid" && req.Style != "natural" {
+		return fmt.Errorf("invalid image style: %s (must be vivid or natural)", req.Style)
+	}
+	return validateImageOutput(req.ResponseFormat, req.Background, req.OutputFormat, req.OutputCompression)
+}
+
+// validateImageOutput validates image response and output format options.
+func validateImageOutput(responseFormat, background, outputFormat string, compression int) error {
+	switch responseFormat {
+	case "", ImageResponseURL, ImageResponseB64JSON:
+	default:
+		return fmt.Errorf("invalid image response_format: %s (must be %s or %s)", responseFormat, ImageResponseURL, ImageResponseB64JSON)
+	}
+	switch background {
+	case "", "transparent", "opaque", "auto":
+	default:
+		return fmt.Errorf("invalid image background: %s (must be transparent, opaque or auto)", background)
+	}
+	switch outputFormat {
+	case "", "png", "jpeg", "webp":
+	default:
+		return fmt.Errorf("invalid image output_format: %s (must be png, jpeg or webp)", outputFormat)
+	}
+	if background == "transparent" && outputFormat == "jpeg" {
+		return fmt.Errorf("transparent background requires png or webp output")
+	}
+	if compression < 0 || compression > 100 {
+		return fmt.Errorf("image output_compression must be between 0 and 100")
+	}
+	return nil
+}
+
+// validateImageEditRequest validates image edit request parameters.
+func validateImageEditRequest(req *ImageEditRequest) error {
+	if req == nil || len(req.Images) == 0 {
+		return ErrEmptyInput
+	}
+	if len(req.Images) > MaxImageEditSources {
+		return fmt.Errorf("image edit sources exceed maximum of %d", MaxImageEditSources)
+	}
+	if len(req.Prompt) > MaxImageGenPromptLength {
+		return fmt.Errorf("image prompt exceeds maximum length of %d", MaxImageGenPromptLength)
+	}
+	if req.Prompt == "" && (len(req.Images) != 1 || req.Mask != nil) {
+		return fmt.Errorf("image variations require exactly one source image and no mask")
+	}
+	images := req.Images
+	if req.Mask != nil {
+		images = append(append([]Image{}, images...), *req.Mask)
+	}
+	for i, img := range images {
+		if len(img.Data) == 0 {
+			return fmt.Errorf("image %d has empty data", i)
+		}
+		if len(img.Data) > MaxImageSize {
+			return fmt.Errorf("image %d exceeds maximum size of %d bytes (%d bytes)", i, MaxImageSize, len(img.Data))
+		}
+		if img.MimeType != "" && !AllowedImageMIMETypes[strings.ToLower(img.MimeType)] {
+			return fmt.Errorf("image %d has unsupported MIME type: %s", i, img.MimeType)
+		}
+	}
+	if req.N < 0 {
+		return fmt.Errorf("image count cannot be negative")
+	}
+	if req.N > MaxImageGenCount {
+		return fmt.Errorf("image count exceeds maximum of %d", MaxImageGenCount)
+	}
+	if len(req.Model) > MaxModelNameLength {
+		return fmt.Errorf("model name exceeds maximum length of %d", MaxModelNameLength)
+	}
+	return validateImageOutput(req.ResponseFormat, req.Background, req.OutputFormat, req.OutputCompression)
+}
+
+// validateRerankRequest validates rerank request parameters.
+func validateRerankRequest(req *RerankRequest, maxInputLen int) error {
+	if req == nil || req.Query == "" || len(req.Documents) == 0 {
+		return ErrEmptyInput
+	}
+	if len(req.Documents) > MaxRerankDocuments {
+		return fmt.Errorf("rerank documents exceed maximum of %d", MaxRerankDocuments)
+	}
+	if req.TopN < 0 {
+		return fmt.Errorf("rerank top_n cannot be negative")
+	}
+	if len(req.Model) > MaxModelNameLength {
+		return fmt.Errorf("model name exceeds maximum length of %d", MaxModelNameLength)
+	}
+	totalLen := len(req.Query)
+	for _, doc := range req.Documents {
+		totalLen += len(doc)
+	}
+	if totalLen > maxInputLen {
+		return ErrInputTooLong
+	}
+	return nil
+}
+
+// validateFileUpload validates file upload parameters.
+func validateFileUpload(req *FileUploadRequest) error {
+	if req == nil || len(req.Data) == 0 {
+		return ErrEmptyInput
+	}
+	if req.Name == "" {
+		return fmt.Errorf("file name is required")
+	}
+	if len(req.Name) > MaxFileNameLength {
+		return fmt.Errorf("file name exceeds maximum length of %d", MaxFileNameLength)
+	}
+	if len(req.Data) > MaxFileSize {
+		return fmt.Errorf("file exceeds maximum size of %d bytes (%d bytes)", MaxFileSize, len(req.Data))
+	}
+	return nil
+}
+
+// validateFileID validates a provider file ID.
+func validateFileID(id string) error {
+	if id == "" {
+		return ErrEmptyInput
+	}
+	if len(id) > MaxFileIDLength {
+		return fmt.Errorf("file ID exceeds maximum length of %d", MaxFileIDLength)
+	}
+	if strings.ContainsAny(id, "/?#\\ \t\r\n") {
+		return fmt.Errorf("file ID contains invalid characters")
+	}
+	return nil
+}
+
+// realtimeAudioFormats are the accepted RealtimeConfig audio formats.
+var realtimeAudioFormats = map[string]bool{"": true, "pcm16": true, "g711_ulaw": true, "g711_alaw": true}
+
+// validateRealtimeConfig validates realtime session configuration.
+func validateRealtimeConfig(cfg *RealtimeConfig) error {
+	if len(cfg.Model) > MaxModelNameLength {
+		return fmt.Errorf("model name exceeds maximum length of %d", MaxModelNameLength)
+	}
+	for _, m := range cfg.Modalities {
+		if m != "audio" && m != "text" {
+			return fmt.Errorf("invalid modality: %s (must be audio or text)", m)
+		}
+	}
+	if !realtimeAudioFormats[cfg.InputAudioFormat] {
+		return fmt.Errorf("invalid input audio format: %s", cfg.InputAudioFormat)
+	}
+	if !realtimeAudioFormats[cfg.OutputAudioFormat] {
+		return fmt.Errorf("invalid output audio format: %s", cfg.OutputAudioFormat)
+	}
+	if td := cfg.TurnDetection; td != nil {
+		switch td.Type {
+		case "", TurnDetectionServerVAD, TurnDetectionSemanticVAD, TurnDetectionNone:
+		default:
+			return fmt.Errorf("invalid turn detection type: %s", td.Type)
+		}
+		if td.Threshold < 0 || td.Threshold > 1 {
+			return fmt.Errorf("turn detection threshold must be between 0 and 1")
+		}
+		if td.PrefixPadding < 0 || td.SilenceDuration < 0 {
+			return fmt.Errorf("turn detection durations cannot be negative")
+		}
+	}
+	for i, tool := range cfg.Tools {
+		if tool.Name == "" {
+			return fmt.Errorf("tool %d has empty name", i)
+		}
+		if len(tool.Name) > MaxToolNameLength {
+			return fmt.Errorf("tool %d name exceeds maximum length of %d", i, MaxToolNameLength)
+		}
+	}
+	if cfg.MaxOutputTokens < 0 {
+		return fmt.Errorf("max_tokens cannot be negative")
+	}
 	return nil
 }
 
@@ -238,7 +435,8 @@ func sanitizeError(err error) error {
 		errors.Is(err, ErrCanceled) || errors.Is(err, ErrEmptyResponse) ||
 		errors.Is(err, ErrNoProvider) || errors.Is(err, ErrEmptyInput) ||
 		errors.Is(err, ErrInputTooLong) || errors.Is(err, ErrProvider) ||
-		errors.Is(err, ErrNotSupported) {
+		errors.Is(err, ErrNotSupported) || errors.Is(err, ErrContentBlocked) ||
+		errors.Is(err, ErrBudgetExceeded) {
 		return err
 	}
 	// Wrap provider errors — expose message but strip potential key material
@@ -0,0 +1,244 @@
+package vectorstore
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"maps"
+	"sync"
+
+	"github.com/kusandriadi/allm-go"
+)
+
+// minRowsPerWorker is the smallest share of rows worth a search goroutine.
+const minRowsPerWorker = 4096
+
+// ctxCheckRows is how many rows a search worker scores between checks for
+// cancellation.
+const ctxCheckRows = 1024
+
+// Flat is an exact Index that scores the query against every vector.
+// Vectors are stored contiguously and split among parallel workers, so
+// search time grows linearly with the number of vectors.
+type Flat[T allm.Float] struct {
+	mu     sync.RWMutex
+	cfg    config
+	kernel kernel[T]
+	dim    int
+	data   []T // row i is data[i*dim : (i+1)*dim]
+	norms  []float64
+	ids    []string
+	meta   []map[string]string
+	rows   map[string]int // ID to row
+}
+
+var (
+	_ Index[float32] = (*Flat[float32])(nil)
+	_ Index[float64] = (*Flat[float64])(nil)
+)
+
+// NewFlat returns an empty flat index of T vectors.
+func NewFlat[T allm.Float](opts ...Option) *Flat[T] {
+	return newFlat[T](newConfig(opts))
+}
+
+func newFlat[T allm.Float](cfg config) *Flat[T] {
+	return &Flat[T]{cfg: cfg, kernel: newKernel[T](cfg.metric), dim: cfg.dimension, rows: make(map[string]int)}
+}
+
+// vector returns the vector of row.
+func (f *Flat[T]) vector(row int) []T {
+	return f.data[row*f.dim : (row+1)*f.dim]
+}
+
+// Add implements Index.
+func (f *Flat[T]) Add(_ context.Context, records ...Record[T]) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	dim, err := checkRecords(f.dim, records)
+	if err != nil {
+		return err
+	}
+	f.dim = dim
+	for _, r := range records {
+		meta := maps.Clone(r.Metadata)
+		if row, ok := f.rows[r.ID]; ok {
+			copy(f.vector(row), r.Vector)
+			f.norms[row] = f.kernel.norm(r.Vector)
+			f.meta[row] = meta
+			continue
+		}
+		f.rows[r.ID] = len(f.ids)
+		f.ids = append(f.ids, r.ID)
+		f.data = append(f.data, r.Vector...)
+		f.norms = append(f.norms, f.kernel.norm(r.Vector))
+		f.meta = append(f.meta, meta)
+	}
+	return nil
+}
+
+// Delete implements Index. The last row is moved into each deleted row, so
+// storage stays contiguous.
+func (f *Flat[T]) Delete(_ context.Context, ids ...string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, id := range ids {
+		row, ok := f.rows[id]
+		if !ok {
+			continue
+		}
+		last := len(f.ids) - 1
+		if row != last {
+			copy(f.vector(row), f.vector(last))
+			f.norms[row] = f.norms[last]
+			f.ids[row] = f.ids[last]
+			f.meta[row] = f.meta[last]
+			f.rows[f.ids[row]] = row
+		}
+		f.meta[last] = nil
+		f.data = f.data[:last*f.dim]
+		f.norms = f.norms[:last]
+		f.ids = f.ids[:last]
+		f.meta = f.meta[:last]
+		delete(f.rows, id)
+	}
+	return nil
+}
+
+// Search implements Index. It returns ctx.Err() if ctx is canceled during
+// the search.
+func (f *Flat[T]) Search(ctx context.Context, query []T, k int, opts ...SearchOption) ([]Result, error) {
+	if k <= 0 {
+		return nil, ErrInvalidK
+	}
+	cfg := newSearchConfig(opts)
+
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	n := len(f.ids)
+	if n == 0 {
+		return nil, nil
+	}
+	if len(query) != f.dim {
+		return nil, fmt.Errorf("%w: query has %d dimensions, index has %d", ErrDimensionMismatch, len(query), f.dim)
+	}
+
+	qnorm := f.kernel.norm(query)
+	workers := min(f.cfg.workers, (n+minRowsPerWorker-1)/minRowsPerWorker)
+	per := (n + workers - 1) / workers
+	tops := make([]*topK, workers)
+	var wg sync.WaitGroup
+	for w := range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			tops[w] = f.scan(ctx, query, qnorm, w*per, min((w+1)*per, n), k, cfg)
+		}()
+	}
+	wg.Wait()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	best := tops[0]
+	for _, top := range tops[1:] {
+		for _, h := range top.hits {
+			best.push(h)
+		}
+	}
+	hits := best.sorted()
+	results := make([]Result, len(hits))
+	for i, h := range hits {
+		results[i] = Result{ID: f.ids[h.row], Score: h.score, Metadata: maps.Clone(f.meta[h.row])}
+	}
+	return results, nil
+}
+
+// scan returns the best k accepted rows in [lo, hi).
+func (f *Flat[T]) scan(ctx context.Context, query []T, qnorm float64, lo, hi, k int, cfg *searchConfig) *topK {
+	top := newTopK(k)
+	similarity := f.kernel.score
+	for row := lo; row < hi; row++ {
+		if (row-lo)%ctxCheckRows == 0 && ctx.Err() != nil {
+			return top
+		}
+		score := similarity(query, f.vector(row), qnorm, f.norms[row])
+		if top.full() && score <= top.worst() {
+			continue
+		}
+		if cfg.accepts(f.meta[row], score) {
+			top.push(hit{row: row, score: score})
+		}
+	}
+	return top
+}
+
+// Len implements Index.
+func (f *Flat[T]) Len() int {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return len(f.ids)
+}
+
+// Dimension returns the vector dimension, or 0 if no vector was added yet.
+func (f *Flat[T]) Dimension() int {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.dim
+}
+
+// Get returns a copy of the record with the given ID.
+func (f *Flat[T]) Get(id string) (Record[T], bool) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	row, ok := f.rows[id]
+	if !ok {
+		return Record[T]{}, false
+	}
+	return f.record(row), true
+}
+
+// record returns a copy of row.
+func (f *Flat[T]) record(row int) Record[T] {
+	return Record[T]{
+		ID:       f.ids[row],
+		Vector:   append([]T(nil), f.vector(row)...),
+		Metadata: maps.Clone(f.meta[row]),
+	}
+}
+
+// Save implements Persistent.
+func (f *Flat[T]) Save(w io.Writer, format Format) error {
+	f.mu.RLock()
+	snap := &snapshot[T]{Kind: kindFlat, Metric: f.cfg.metric, Dimension: f.dim, Records: make([]Record[T], len(f.ids))}
+	for row := range f.ids {
+		snap.Records[row] = f.record(row)
+	}
+	f.mu.RUnlock()
+	return encodeSnapshot(w, format, snap)
+}
+
+// Load implements Persistent. It replaces the contents and metric of the
+// index with those of a snapshot written by any index's Save.
+func (f *Flat[T]) Load(r io.Reader, format Format) error {
+	snap, err := decodeSnapshot[T](r, format)
+	if err != nil {
+		return err
+	}
+	f.mu.RLock()
+	cfg := f.cfg
+	f.mu.RUnlock()
+	cfg.metric, cfg.dimension = snap.Metric, snap.Dimension
+	loaded := newFlat[T](cfg)
+	if err := loaded.Add(context.Background(), liveRecords(snap)...); err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.cfg, f.kernel, f.dim, f.data, f.norms, f.ids, f.meta, f.rows = loaded.cfg, loaded.kernel, loaded.dim, loaded.data, loaded.norms, loaded.ids, loaded.meta, loaded.rows
+	return nil
+}
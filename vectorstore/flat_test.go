@@ -0,0 +1,348 @@
+package vectorstore
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"math"
+	"math/rand/v2"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/kusandriadi/allm-go"
+)
+
+// bruteForce returns the IDs of the k records closest to query, scored with
+// allm's metrics.
+func bruteForce[T allm.Float](metric Metric, records []Record[T], query []T, k int) []string {
+	type scored struct {
+		id    string
+		score float64
+	}
+	all := make([]scored, len(records))
+	for i, r := range records {
+		var score float64
+		switch metric {
+		case DotProduct:
+			score = allm.DotProduct(query, r.Vector)
+		case Euclidean:
+			score = -allm.EuclideanDistance(query, r.Vector)
+		default:
+			score = allm.CosineSimilarity(query, r.Vector)
+		}
+		all[i] = scored{r.ID, score}
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].score > all[j].score })
+	ids := make([]string, 0, k)
+	for _, s := range all[:min(k, len(all))] {
+		ids = append(ids, s.id)
+	}
+	return ids
+}
+
+// randomRecords returns n records with IDs "0".."n-1" and a "parity" label.
+func randomRecords(rng *rand.Rand, n, dim int) []Record[float32] {
+	records := make([]Record[float32], n)
+	for i, v := range randomVectors(rng, n, dim) {
+		parity := "even"
+		if i%2 == 1 {
+			parity = "odd"
+		}
+		records[i] = Record[float32]{ID: strconv.Itoa(i), Vector: v, Metadata: map[string]string{"parity": parity}}
+	}
+	return records
+}
+
+func resultIDs(results []Result) []string {
+	ids := make([]string, len(results))
+	for i, r := range results {
+		ids[i] = r.ID
+	}
+	return ids
+}
+
+func TestFlatSearch(t *testing.T) {
+	ctx := context.Background()
+	idx := NewFlat[float32]()
+	err := idx.Add(ctx,
+		Record[float32]{ID: "x", Vector: []float32{1, 0}, Metadata: map[string]string{"axis": "x"}},
+		Record[float32]{ID: "y", Vector: []float32{0, 1}, Metadata: map[string]string{"axis": "y"}},
+		Record[float32]{ID: "xy", Vector: []float32{1, 1}},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := idx.Search(ctx, []float32{1, 0.1}, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := resultIDs(results); !slices.Equal(got, []string{"x", "xy"}) {
+		t.Errorf("results = %v", got)
+	}
+	if results[0].Metadata["axis"] != "x" || results[0].Score <= results[1].Score {
+		t.Errorf("first result = %+v", results[0])
+	}
+
+	results, _ = idx.Search(ctx, []float32{1, 0.1}, 5, WithFilter(Match(map[string]string{"axis": "y"})))
+	if got := resultIDs(results); !slices.Equal(got, []string{"y"}) {
+		t.Errorf("filtered results = %v", got)
+	}
+
+	results, _ = idx.Search(ctx, []float32{1, 0}, 5, WithMinScore(0.5))
+	if got := resultIDs(results); !slices.Equal(got, []string{"x", "xy"}) {
+		t.Errorf("min score results = %v", got)
+	}
+
+	// Results own their metadata.
+	results[0].Metadata["axis"] = "changed"
+	if r, _ := idx.Get("x"); r.Metadata["axis"] != "x" {
+		t.Error("result metadata aliases the index")
+	}
+}
+
+func TestFlatErrors(t *testing.T) {
+	ctx := context.Background()
+	idx := NewFlat[float32](WithDimension(2))
+
+	if results, err := idx.Search(ctx, []float32{1, 0}, 1); err != nil || results != nil {
+		t.Errorf("empty search = %v, %v", results, err)
+	}
+	if err := idx.Add(ctx, Record[float32]{ID: "", Vector: []float32{1, 0}}); !errors.Is(err, ErrEmptyID) {
+		t.Errorf("empty ID: %v", err)
+	}
+	if err := idx.Add(ctx, Record[float32]{ID: "a", Vector: []float32{1, 0, 0}}); !errors.Is(err, ErrDimensionMismatch) {
+		t.Errorf("wrong dimension: %v", err)
+	}
+	// A bad record rejects the whole batch.
+	if err := idx.Add(ctx, Record[float32]{ID: "a", Vector: []float32{1, 0}}, Record[float32]{ID: "b"}); !errors.Is(err, ErrDimensionMismatch) || idx.Len() != 0 {
+		t.Errorf("partial batch: %v, len %d", err, idx.Len())
+	}
+
+	_ = idx.Add(ctx, Record[float32]{ID: "a", Vector: []float32{1, 0}})
+	if _, err := idx.Search(ctx, []float32{1}, 1); !errors.Is(err, ErrDimensionMismatch) {
+		t.Errorf("query dimension: %v", err)
+	}
+	if _, err := idx.Search(ctx, []float32{1, 0}, 0); !errors.Is(err, ErrInvalidK) {
+		t.Errorf("k = 0: %v", err)
+	}
+}
+
+func TestFlatUpsertDelete(t *testing.T) {
+	ctx := context.Background()
+	idx := NewFlat[float32](WithMetric(Euclidean))
+	_ = idx.Add(ctx,
+		Record[float32]{ID: "a", Vector: []float32{0, 0}},
+		Record[float32]{ID: "b", Vector: []float32{5, 5}},
+		Record[float32]{ID: "c", Vector: []float32{9, 9}},
+	)
+
+	_ = idx.Add(ctx, Record[float32]{ID: "a", Vector: []float32{10, 10}, Metadata: map[string]string{"v": "2"}})
+	if idx.Len() != 3 {
+		t.Fatalf("Len after upsert = %d", idx.Len())
+	}
+	if r, ok := idx.Get("a"); !ok || r.Vector[0] != 10 || r.Metadata["v"] != "2" {
+		t.Errorf("Get(a) = %+v, %v", r, ok)
+	}
+
+	if err := idx.Delete(ctx, "a", "missing"); err != nil {
+		t.Fatal(err)
+	}
+	results, _ := idx.Search(ctx, []float32{10, 10}, 3)
+	if got := resultIDs(results); !slices.Equal(got, []string{"c", "b"}) {
+		t.Errorf("results after delete = %v", got)
+	}
+	if math.Abs(results[0].Score+math.Sqrt2) > 1e-6 {
+		t.Errorf("euclidean score = %v", results[0].Score)
+	}
+
+	_ = idx.Delete(ctx, "b", "c")
+	if _, ok := idx.Get("c"); ok || idx.Len() != 0 {
+		t.Errorf("index not empty: %d", idx.Len())
+	}
+}
+
+func TestFlatMatchesBruteForce(t *testing.T) {
+	ctx := context.Background()
+	rng := rand.New(rand.NewPCG(3, 4))
+	records := randomRecords(rng, 20000, 16)
+
+	for _, metric := range []Metric{Cosine, DotProduct, Euclidean} {
+		// Several workers, each with its own share of rows.
+		idx := NewFlat[float32](WithMetric(metric), WithWorkers(4))
+		if err := idx.Add(ctx, records...); err != nil {
+			t.Fatal(err)
+		}
+		for _, query := range randomVectors(rng, 5, 16) {
+			results, err := idx.Search(ctx, query, 10)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, want := resultIDs(results), bruteForce(metric, records, query, 10); !slices.Equal(got, want) {
+				t.Errorf("%s: got %v, want %v", metric, got, want)
+			}
+
+			odd := WithFilter(Match(map[string]string{"parity": "odd"}))
+			results, _ = idx.Search(ctx, query, 10, odd)
+			for _, r := range results {
+				if r.Metadata["parity"] != "odd" {
+					t.Fatalf("filter leaked %+v", r)
+				}
+			}
+		}
+	}
+}
+
+// toFloat64Records converts records to float64 vectors.
+func toFloat64Records(records []Record[float32]) []Record[float64] {
+	out := make([]Record[float64], len(records))
+	for i, r := range records {
+		out[i] = Record[float64]{ID: r.ID, Vector: toFloat64(r.Vector), Metadata: r.Metadata}
+	}
+	return out
+}
+
+func TestFlatFloat64(t *testing.T) {
+	ctx := context.Background()
+	rng := rand.New(rand.NewPCG(5, 6))
+	records32 := randomRecords(rng, 2000, 16)
+	records := toFloat64Records(records32)
+
+	for _, metric := range []Metric{Cosine, DotProduct, Euclidean} {
+		idx := NewFlat[float64](WithMetric(metric))
+		if err := idx.Add(ctx, records...); err != nil {
+			t.Fatal(err)
+		}
+		for _, query := range randomVectors(rng, 5, 16) {
+			q := toFloat64(query)
+			results, err := idx.Search(ctx, q, 10)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, want := resultIDs(results), bruteForce(metric, records, q, 10); !slices.Equal(got, want) {
+				t.Errorf("%s: got %v, want %v", metric, got, want)
+			}
+		}
+	}
+
+	// Snapshots load across vector types.
+	idx := NewFlat[float32]()
+	_ = idx.Add(ctx, records32...)
+	for _, format := range []Format{Gob, JSON} {
+		var buf bytes.Buffer
+		if err := idx.Save(&buf, format); err != nil {
+			t.Fatal(err)
+		}
+		loaded := NewFlat[float64]()
+		if err := loaded.Load(&buf, format); err != nil {
+			t.Fatalf("load float32 snapshot: %v", err)
+		}
+		if r, ok := loaded.Get("7"); !ok || !slices.Equal(ToFloat32(r.Vector), records32[7].Vector) {
+			t.Errorf("format %d: record 7 = %v, want %v", format, r.Vector, records32[7].Vector)
+		}
+	}
+}
+
+func TestFlatCanceled(t *testing.T) {
+	idx := NewFlat[float32]()
+	_ = idx.Add(context.Background(), randomRecords(rand.New(rand.NewPCG(5, 6)), 100, 4)...)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := idx.Search(ctx, []float32{1, 0, 0, 0}, 3); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+}
+
+func TestFlatConcurrent(t *testing.T) {
+	ctx := context.Background()
+	rng := rand.New(rand.NewPCG(7, 8))
+	idx := NewFlat[float32]()
+	records := randomRecords(rng, 2000, 8)
+	queries := randomVectors(rng, 8, 8)
+
+	var wg sync.WaitGroup
+	for w := range 8 {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for i := w; i < len(records); i += 8 {
+				_ = idx.Add(ctx, records[i])
+				if i%3 == 0 {
+					_ = idx.Delete(ctx, records[i].ID)
+				}
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for range 50 {
+				if _, err := idx.Search(ctx, queries[w], 5); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if want := len(records) - (len(records)+2)/3; idx.Len() != want {
+		t.Errorf("Len = %d, want %d", idx.Len(), want)
+	}
+}
+
+func TestFlatPersistence(t *testing.T) {
+	ctx := context.Background()
+	rng := rand.New(rand.NewPCG(9, 10))
+	records := randomRecords(rng, 300, 12)
+	idx := NewFlat[float32](WithMetric(DotProduct))
+	_ = idx.Add(ctx, records...)
+	query := randomVectors(rng, 1, 12)[0]
+	want, _ := idx.Search(ctx, query, 5)
+
+	dir := t.TempDir()
+	for _, name := range []string{"index.gob", "index.json"} {
+		path := filepath.Join(dir, name)
+		if err := SaveFile(idx, path); err != nil {
+			t.Fatalf("SaveFile(%s): %v", name, err)
+		}
+		loaded := NewFlat[float32]() // metric comes from the snapshot
+		if err := LoadFile(loaded, path); err != nil {
+			t.Fatalf("LoadFile(%s): %v", name, err)
+		}
+		if loaded.Len() != len(records) || loaded.Dimension() != 12 {
+			t.Fatalf("%s: loaded %d records of %d dimensions", name, loaded.Len(), loaded.Dimension())
+		}
+		got, _ := loaded.Search(ctx, query, 5)
+		if !slices.Equal(resultIDs(got), resultIDs(want)) || got[0].Score != want[0].Score {
+			t.Errorf("%s: results %v, want %v", name, resultIDs(got), resultIDs(want))
+		}
+	}
+
+	var buf bytes.Buffer
+	_ = idx.Save(&buf, JSON)
+	if !bytes.Contains(buf.Bytes(), []byte(`"metric":"dot_product"`)) {
+		t.Errorf("JSON snapshot = %.200s", buf.String())
+	}
+	if err := NewFlat[float32]().Load(bytes.NewReader([]byte(`{"version":99}`)), JSON); err == nil {
+		t.Error("expected error for unknown snapshot version")
+	}
+	if err := LoadFile(NewFlat[float32](), filepath.Join(dir, "missing.gob")); err == nil {
+		t.Error("expected error for missing file")
+	}
+}
+
+func BenchmarkFlatSearch(b *testing.B) {
+	ctx := context.Background()
+	rng := rand.New(rand.NewPCG(11, 12))
+	idx := NewFlat[float32]()
+	_ = idx.Add(ctx, randomRecords(rng, 100000, 256)...)
+	query := randomVectors(rng, 1, 256)[0]
+	b.ResetTimer()
+	for b.Loop() {
+		if _, err := idx.Search(ctx, query, 10); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,518 @@
+package vectorstore
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"io"
+	"maps"
+	"math"
+	"math/rand/v2"
+	"slices"
+	"sync"
+
+	"github.com/kusandriadi/allm-go"
+)
+
+// HNSW is an approximate Index built on a hierarchical navigable small world
+// graph (Malkov and Yashunin, 2016). Each vector is a node linked to its
+// nearest neighbors on a random number of layers, sparse at the top and
+// complete at the bottom. A search descends greedily from the top layer and
+// then explores the base layer with a candidate list of efSearch nodes, so it
+// visits a small fraction of the vectors. Recall is tuned with WithM,
+// WithEfConstruction and WithEfSearch, and measured against Flat. Like Flat,
+// it scores vectors with allm's distance functions, or the float32 kernels
+// for float32 vectors.
+//
+// Deleted and replaced records are tombstoned: they stay in the graph to keep
+// it connected, but are never returned. Compact rebuilds the graph without
+// them.
+type HNSW[T allm.Float] struct {
+	mu         sync.RWMutex
+	cfg        config
+	kernel     kernel[T]
+	dim        int
+	nodes      []*hnswNode[T]
+	rows       map[string]int // ID to live node
+	entry      int            // top-layer entry point, -1 if empty
+	maxLevel   int
+	tombstones int
+	rng        *rand.Rand
+	visited    sync.Pool // *visitedSet
+}
+
+// visitedSet marks the nodes seen by a search. A node is visited if its mark
+// equals the current generation, so the set is cleared by incrementing it.
+type visitedSet struct {
+	marks []uint32
+	gen   uint32
+}
+
+// visitedSet returns an empty set for the nodes of h. Put it back in
+// h.visited when done.
+func (h *HNSW[T]) visitedSet() *visitedSet {
+	v, _ := h.visited.Get().(*visitedSet)
+	if v == nil || len(v.marks) < len(h.nodes) {
+		return &visitedSet{marks: make([]uint32, len(h.nodes)+len(h.nodes)/4+64), gen: 1}
+	}
+	v.gen++
+	if v.gen == 0 {
+		clear(v.marks)
+		v.gen = 1
+	}
+	return v
+}
+
+// visit marks node i and reports whether it was already marked.
+func (v *visitedSet) visit(i int) bool {
+	if v.marks[i] == v.gen {
+		return true
+	}
+	v.marks[i] = v.gen
+	return false
+}
+
+// hnswNode is a vector in the graph.
+type hnswNode[T allm.Float] struct {
+	id        string
+	vector    []T
+	norm      float64
+	meta      map[string]string
+	friends   [][]int32 // neighbors on each layer up to the node's level
+	tombstone bool
+}
+
+var (
+	_ Index[float32] = (*HNSW[float32])(nil)
+	_ Index[float64] = (*HNSW[float64])(nil)
+)
+
+// NewHNSW returns an empty HNSW index of T vectors.
+func NewHNSW[T allm.Float](opts ...Option) *HNSW[T] {
+	return newHNSW[T](newConfig(opts))
+}
+
+func newHNSW[T allm.Float](cfg config) *HNSW[T] {
+	return &HNSW[T]{
+		cfg:    cfg,
+		kernel: newKernel[T](cfg.metric),
+		dim:    cfg.dimension,
+		rows:   make(map[string]int),
+		entry:  -1,
+		rng:    rand.New(rand.NewPCG(cfg.seed, cfg.seed)),
+	}
+}
+
+// similarity returns the score of node i against q, whose norm is qnorm.
+func (h *HNSW[T]) similarity(q []T, qnorm float64, i int) float64 {
+	n := h.nodes[i]
+	return h.kernel.score(q, n.vector, qnorm, n.norm)
+}
+
+// maxFriends returns the neighbor limit of layer l.
+func (h *HNSW[T]) maxFriends(l int) int {
+	if l == 0 {
+		return 2 * h.cfg.m
+	}
+	return h.cfg.m
+}
+
+// randomLevel draws a node level from the exponential distribution of the
+// paper, with normalization factor 1/ln(M).
+func (h *HNSW[T]) randomLevel() int {
+	return int(-math.Log(1-h.rng.Float64()) / math.Log(float64(h.cfg.m)))
+}
+
+// Add implements Index. Replacing a record tombstones its old node.
+func (h *HNSW[T]) Add(_ context.Context, records ...Record[T]) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	dim, err := checkRecords(h.dim, records)
+	if err != nil {
+		return err
+	}
+	h.dim = dim
+	for _, r := range records {
+		h.remove(r.ID)
+		h.insert(&hnswNode[T]{
+			id:     r.ID,
+			vector: slices.Clone(r.Vector),
+			norm:   h.kernel.norm(r.Vector),
+			meta:   maps.Clone(r.Metadata),
+		}, h.randomLevel())
+	}
+	return nil
+}
+
+// insert links n into the graph with the given level.
+func (h *HNSW[T]) insert(n *hnswNode[T], level int) {
+	id := len(h.nodes)
+	n.friends = make([][]int32, level+1)
+	h.nodes = append(h.nodes, n)
+	h.rows[n.id] = id
+	if h.entry < 0 {
+		h.entry, h.maxLevel = id, level
+		return
+	}
+
+	ep := hit{row: h.entry, score: h.similarity(n.vector, n.norm, h.entry)}
+	for l := h.maxLevel; l > level; l-- {
+		ep = h.greedy(n.vector, n.norm, ep, l)
+	}
+	for l := min(level, h.maxLevel); l >= 0; l-- {
+		found := h.searchLayer(context.Background(), n.vector, n.norm, ep, h.cfg.efConstruction, l, nil)
+		for _, nb := range h.selectNeighbors(found, h.cfg.m) {
+			n.friends[l] = append(n.friends[l], int32(nb.row))
+			h.link(nb.row, id, l)
+		}
+		ep = found[0]
+	}
+	if level > h.maxLevel {
+		h.entry, h.maxLevel = id, level
+	}
+}
+
+// link adds to as a neighbor of from on layer l, pruning the neighbors of
+// from if it has too many.
+func (h *HNSW[T]) link(from, to, l int) {
+	n := h.nodes[from]
+	n.friends[l] = append(n.friends[l], int32(to))
+	limit := h.maxFriends(l)
+	if len(n.friends[l]) <= limit {
+		return
+	}
+	candidates := make([]hit, len(n.friends[l]))
+	for i, f := range n.friends[l] {
+		candidates[i] = hit{row: int(f), score: h.similarity(n.vector, n.norm, int(f))}
+	}
+	slices.SortFunc(candidates, func(a, b hit) int { return cmp.Compare(b.score, a.score) })
+	n.friends[l] = n.friends[l][:0]
+	for _, c := range h.selectNeighbors(candidates, limit) {
+		n.friends[l] = append(n.friends[l], int32(c.row))
+	}
+}
+
+// selectNeighbors picks up to m of candidates, sorted best first, with the
+// heuristic of the paper: a candidate is kept if it is closer to the query
+// than to every kept neighbor, which favors neighbors in different
+// directions. Remaining slots are filled with the best pruned candidates.
+func (h *HNSW[T]) selectNeighbors(candidates []hit, m int) []hit {
+	if len(candidates) <= m {
+		return candidates
+	}
+	kept := make([]hit, 0, m)
+	var pruned []hit
+	for _, c := range candidates {
+		if len(kept) == m {
+			break
+		}
+		cn := h.nodes[c.row]
+		diverse := true
+		for _, k := range kept {
+			if h.similarity(cn.vector, cn.norm, k.row) > c.score {
+				diverse = false
+				break
+			}
+		}
+		if diverse {
+			kept = append(kept, c)
+		} else {
+			pruned = append(pruned, c)
+		}
+	}
+	for _, c := range pruned {
+		if len(kept) == m {
+			break
+		}
+		kept = append(kept, c)
+	}
+	return kept
+}
+
+// greedy walks layer l from ep to the node closest to q.
+func (h *HNSW[T]) greedy(q []T, qnorm float64, ep hit, l int) hit {
+	for changed := true; changed; {
+		changed = false
+		for _, f := range h.nodes[ep.row].friends[l] {
+			if s := h.similarity(q, qnorm, int(f)); s > ep.score {
+				ep, changed = hit{row: int(f), score: s}, true
+			}
+		}
+	}
+	return ep
+}
+
+// searchLayer returns up to ef nodes of layer l closest to q, best first,
+// starting from ep. If accept is non-nil, only accepted nodes are returned,
+// but the others are still traversed. The search stops early, with partial
+// results, if ctx is canceled.
+func (h *HNSW[T]) searchLayer(ctx context.Context, q []T, qnorm float64, ep hit, ef, l int, accept func(n *hnswNode[T], score float64) bool) []hit {
+	visited := h.visitedSet()
+	defer h.visited.Put(visited)
+	visited.visit(ep.row)
+	candidates := maxHeap{ep}
+	results := newTopK(ef)
+	if accept == nil || accept(h.nodes[ep.row], ep.score) {
+		results.push(ep)
+	}
+
+	for steps := 0; len(candidates) > 0; steps++ {
+		if steps%ctxCheckRows == 0 && ctx.Err() != nil {
+			break
+		}
+		c := candidates.pop()
+		if results.full() && c.score < results.worst() {
+			break
+		}
+		for _, f := range h.nodes[c.row].friends[l] {
+			row := int(f)
+			if visited.visit(row) {
+				continue
+			}
+			s := h.similarity(q, qnorm, row)
+			if results.full() && s <= results.worst() {
+				continue
+			}
+			candidates.push(hit{row: row, score: s})
+			if accept == nil || accept(h.nodes[row], s) {
+				results.push(hit{row: row, score: s})
+			}
+		}
+	}
+	return results.sorted()
+}
+
+// Delete implements Index. Deleted records are tombstoned.
+func (h *HNSW[T]) Delete(_ context.Context, ids ...string) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, id := range ids {
+		h.remove(id)
+	}
+	return nil
+}
+
+// remove tombstones the live node of id, if any.
+func (h *HNSW[T]) remove(id string) {
+	row, ok := h.rows[id]
+	if !ok {
+		return
+	}
+	h.nodes[row].tombstone = true
+	h.nodes[row].meta = nil
+	delete(h.rows, id)
+	h.tombstones++
+}
+
+// Search implements Index. Filtered searches traverse rejected nodes too, so
+// they return k results whenever k records match, but restrictive filters
+// make them slower. It returns ctx.Err() if ctx is canceled during the
+// search.
+func (h *HNSW[T]) Search(ctx context.Context, query []T, k int, opts ...SearchOption) ([]Result, error) {
+	if k <= 0 {
+		return nil, ErrInvalidK
+	}
+	cfg := newSearchConfig(opts)
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	if len(h.rows) == 0 {
+		return nil, nil
+	}
+	if len(query) != h.dim {
+		return nil, fmt.Errorf("%w: query has %d dimensions, index has %d", ErrDimensionMismatch, len(query), h.dim)
+	}
+
+	qnorm := h.kernel.norm(query)
+	ep := hit{row: h.entry, score: h.similarity(query, qnorm, h.entry)}
+	for l := h.maxLevel; l > 0; l-- {
+		ep = h.greedy(query, qnorm, ep, l)
+	}
+	accept := func(n *hnswNode[T], score float64) bool {
+		return !n.tombstone && cfg.accepts(n.meta, score)
+	}
+	found := h.searchLayer(ctx, query, qnorm, ep, max(h.cfg.efSearch, k), 0, accept)
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	results := make([]Result, min(k, len(found)))
+	for i := range results {
+		n := h.nodes[found[i].row]
+		results[i] = Result{ID: n.id, Score: found[i].score, Metadata: maps.Clone(n.meta)}
+	}
+	return results, nil
+}
+
+// Len implements Index.
+func (h *HNSW[T]) Len() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.rows)
+}
+
+// Dimension returns the vector dimension, or 0 if no vector was added yet.
+func (h *HNSW[T]) Dimension() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.dim
+}
+
+// Tombstones returns the number of deleted or replaced nodes still in the
+// graph.
+func (h *HNSW[T]) Tombstones() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.tombstones
+}
+
+// Get returns a copy of the record with the given ID.
+func (h *HNSW[T]) Get(id string) (Record[T], bool) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	row, ok := h.rows[id]
+	if !ok {
+		return Record[T]{}, false
+	}
+	return h.record(row), true
+}
+
+// record returns a copy of node i.
+func (h *HNSW[T]) record(i int) Record[T] {
+	n := h.nodes[i]
+	return Record[T]{ID: n.id, Vector: slices.Clone(n.vector), Metadata: maps.Clone(n.meta)}
+}
+
+// Compact rebuilds the graph without tombstones, reclaiming their memory.
+// Searches and updates wait until it finishes.
+func (h *HNSW[T]) Compact() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.tombstones == 0 {
+		return
+	}
+	old := h.nodes
+	h.reset()
+	for _, n := range old {
+		if !n.tombstone {
+			h.insert(n, len(n.friends)-1)
+		}
+	}
+}
+
+// reset empties the graph, keeping the configuration and dimension.
+func (h *HNSW[T]) reset() {
+	h.nodes = nil
+	h.rows = make(map[string]int)
+	h.entry, h.maxLevel, h.tombstones = -1, 0, 0
+}
+
+// Save implements Persistent. The snapshot holds the graph, so Load restores
+// it without rebuilding. Flat indexes can load it too.
+func (h *HNSW[T]) Save(w io.Writer, format Format) error {
+	h.mu.RLock()
+	snap := &snapshot[T]{
+		Kind:      kindHNSW,
+		Metric:    h.cfg.metric,
+		Dimension: h.dim,
+		Records:   make([]Record[T], len(h.nodes)),
+		Graph: &graphSnapshot{
+			M:              h.cfg.m,
+			EfConstruction: h.cfg.efConstruction,
+			EfSearch:       h.cfg.efSearch,
+			Entry:          h.entry,
+			Friends:        make([][][]int32, len(h.nodes)),
+		},
+	}
+	for i, n := range h.nodes {
+		snap.Records[i] = h.record(i)
+		snap.Graph.Friends[i] = n.friends
+		if n.tombstone {
+			snap.Deleted = append(snap.Deleted, i)
+		}
+	}
+	// Adjacency lists are shared with the graph, so encode before unlocking.
+	err := encodeSnapshot(w, format, snap)
+	h.mu.RUnlock()
+	return err
+}
+
+// Load implements Persistent. It replaces the contents and metric of the
+// index with those of a snapshot written by any index's Save. Graphs saved
+// by HNSW are restored as saved, with their M and ef settings; other
+// snapshots are indexed from scratch.
+func (h *HNSW[T]) Load(r io.Reader, format Format) error {
+	snap, err := decodeSnapshot[T](r, format)
+	if err != nil {
+		return err
+	}
+	h.mu.RLock()
+	cfg := h.cfg
+	h.mu.RUnlock()
+	cfg.metric, cfg.dimension = snap.Metric, snap.Dimension
+	if g := snap.Graph; g != nil {
+		cfg.m, cfg.efConstruction, cfg.efSearch = g.M, g.EfConstruction, g.EfSearch
+	}
+	dim, err := checkRecords(snap.Dimension, snap.Records)
+	if err != nil {
+		return err
+	}
+	cfg.dimension = dim
+	loaded := newHNSW[T](cfg)
+
+	if snap.Graph == nil {
+		if err := loaded.Add(context.Background(), liveRecords(snap)...); err != nil {
+			return err
+		}
+	} else if err := loaded.restore(snap); err != nil {
+		return err
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.cfg, h.kernel, h.dim, h.nodes, h.rows = loaded.cfg, loaded.kernel, loaded.dim, loaded.nodes, loaded.rows
+	h.entry, h.maxLevel, h.tombstones, h.rng = loaded.entry, loaded.maxLevel, loaded.tombstones, loaded.rng
+	return nil
+}
+
+// restore rebuilds h from a saved graph, checking that its links are valid.
+func (h *HNSW[T]) restore(snap *snapshot[T]) error {
+	g := snap.Graph
+	n := len(snap.Records)
+	if len(g.Friends) != n || (n > 0 && (g.Entry < 0 || g.Entry >= n)) {
+		return fmt.Errorf("vectorstore: corrupt HNSW snapshot: %d nodes, %d adjacency lists, entry %d", n, len(g.Friends), g.Entry)
+	}
+	deleted := make(map[int]bool, len(snap.Deleted))
+	for _, i := range snap.Deleted {
+		deleted[i] = true
+	}
+
+	h.entry = -1
+	if n > 0 {
+		h.entry, h.maxLevel = g.Entry, len(g.Friends[g.Entry])-1
+	}
+	h.nodes = make([]*hnswNode[T], n)
+	for i, r := range snap.Records {
+		friends := g.Friends[i]
+		if len(friends) == 0 || len(friends)-1 > h.maxLevel {
+			return fmt.Errorf("vectorstore: corrupt HNSW snapshot: node %d has %d layers", i, len(friends))
+		}
+		for l, layer := range friends {
+			for _, f := range layer {
+				if f < 0 || int(f) >= n || len(g.Friends[f]) <= l {
+					return fmt.Errorf("vectorstore: corrupt HNSW snapshot: node %d links to %d on layer %d", i, f, l)
+				}
+			}
+		}
+		h.nodes[i] = &hnswNode[T]{id: r.ID, vector: r.Vector, norm: h.kernel.norm(r.Vector), meta: r.Metadata, friends: friends}
+		if deleted[i] {
+			h.nodes[i].tombstone, h.nodes[i].meta = true, nil
+			h.tombstones++
+		} else {
+			h.rows[r.ID] = i
+		}
+	}
+	return nil
+}
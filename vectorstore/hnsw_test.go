@@ -0,0 +1,359 @@
+package vectorstore
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"math/rand/v2"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/kusandriadi/allm-go"
+)
+
+// recall returns the fraction of the true top-k neighbors of queries that
+// idx returns.
+func recall[T allm.Float](tb testing.TB, idx Index[T], metric Metric, records []Record[T], queries [][]T, k int) float64 {
+	tb.Helper()
+	hits := 0
+	for _, q := range queries {
+		results, err := idx.Search(context.Background(), q, k)
+		if err != nil {
+			tb.Fatal(err)
+		}
+		got := resultIDs(results)
+		for _, id := range bruteForce(metric, records, q, k) {
+			if slices.Contains(got, id) {
+				hits++
+			}
+		}
+	}
+	return float64(hits) / float64(len(queries)*k)
+}
+
+// clusteredRecords returns n records scattered around clusters random
+// centers, which resembles embeddings better than uniform noise.
+func clusteredRecords(rng *rand.Rand, n, dim, clusters int) []Record[float32] {
+	centers := randomVectors(rng, clusters, dim)
+	records := randomRecords(rng, n, dim)
+	for i := range records {
+		center := centers[rng.IntN(clusters)]
+		for j := range records[i].Vector {
+			records[i].Vector[j] = center[j] + 0.6*records[i].Vector[j]
+		}
+	}
+	return records
+}
+
+// queriesNear returns n queries close to random records.
+func queriesNear(rng *rand.Rand, records []Record[float32], n int) [][]float32 {
+	queries := randomVectors(rng, n, len(records[0].Vector))
+	for _, q := range queries {
+		r := records[rng.IntN(len(records))].Vector
+		for j := range q {
+			q[j] = r[j] + 0.5*q[j]
+		}
+	}
+	return queries
+}
+
+func TestHNSWSearch(t *testing.T) {
+	ctx := context.Background()
+	idx := NewHNSW[float32]()
+	err := idx.Add(ctx,
+		Record[float32]{ID: "x", Vector: []float32{1, 0}, Metadata: map[string]string{"axis": "x"}},
+		Record[float32]{ID: "y", Vector: []float32{0, 1}, Metadata: map[string]string{"axis": "y"}},
+		Record[float32]{ID: "xy", Vector: []float32{1, 1}},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := idx.Search(ctx, []float32{1, 0.1}, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := resultIDs(results); !slices.Equal(got, []string{"x", "xy"}) {
+		t.Errorf("results = %v", got)
+	}
+	if results[0].Metadata["axis"] != "x" {
+		t.Errorf("first result = %+v", results[0])
+	}
+
+	results, _ = idx.Search(ctx, []float32{1, 0.1}, 5, WithFilter(Match(map[string]string{"axis": "y"})))
+	if got := resultIDs(results); !slices.Equal(got, []string{"y"}) {
+		t.Errorf("filtered results = %v", got)
+	}
+
+	if _, err := idx.Search(ctx, []float32{1}, 1); !errors.Is(err, ErrDimensionMismatch) {
+		t.Errorf("query dimension: %v", err)
+	}
+	if _, err := idx.Search(ctx, []float32{1, 0}, -1); !errors.Is(err, ErrInvalidK) {
+		t.Errorf("k = -1: %v", err)
+	}
+	if err := idx.Add(ctx, Record[float32]{ID: "z", Vector: []float32{1}}); !errors.Is(err, ErrDimensionMismatch) {
+		t.Errorf("add dimension: %v", err)
+	}
+}
+
+func TestHNSWRecall(t *testing.T) {
+	rng := rand.New(rand.NewPCG(21, 22))
+	records := clusteredRecords(rng, 1000, 16, 20)
+	queries := queriesNear(rng, records, 50)
+
+	for _, metric := range []Metric{Cosine, DotProduct, Euclidean} {
+		idx := NewHNSW[float32](WithMetric(metric), WithM(8), WithEfConstruction(64), WithEfSearch(32))
+		if err := idx.Add(context.Background(), records...); err != nil {
+			t.Fatal(err)
+		}
+		if r := recall(t, idx, metric, records, queries, 10); r < 0.9 {
+			t.Errorf("%s recall@10 = %.3f, want >= 0.9", metric, r)
+		}
+	}
+}
+
+func TestHNSWFloat64Recall(t *testing.T) {
+	rng := rand.New(rand.NewPCG(21, 22))
+	records32 := clusteredRecords(rng, 1000, 16, 20)
+	records := toFloat64Records(records32)
+	var queries [][]float64
+	for _, q := range queriesNear(rng, records32, 20) {
+		queries = append(queries, toFloat64(q))
+	}
+
+	for _, metric := range []Metric{Cosine, Euclidean} {
+		idx := NewHNSW[float64](WithMetric(metric), WithM(8), WithEfConstruction(64), WithEfSearch(32))
+		if err := idx.Add(context.Background(), records...); err != nil {
+			t.Fatal(err)
+		}
+		if r := recall(t, idx, metric, records, queries, 10); r < 0.9 {
+			t.Errorf("%s recall@10 = %.3f, want >= 0.9", metric, r)
+		}
+	}
+}
+
+func TestHNSWFilterFindsRareMatches(t *testing.T) {
+	ctx := context.Background()
+	rng := rand.New(rand.NewPCG(23, 24))
+	records := randomRecords(rng, 1000, 8)
+	for i := range records {
+		records[i].Metadata["rare"] = strconv.FormatBool(i%125 == 0)
+	}
+	idx := NewHNSW[float32]()
+	_ = idx.Add(ctx, records...)
+
+	results, err := idx.Search(ctx, randomVectors(rng, 1, 8)[0], 20, WithFilter(Match(map[string]string{"rare": "true"})))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// All 8 matching records are reachable although they are far apart.
+	if len(results) != 8 {
+		t.Errorf("got %d results, want 8", len(results))
+	}
+}
+
+func TestHNSWDeleteAndCompact(t *testing.T) {
+	ctx := context.Background()
+	rng := rand.New(rand.NewPCG(25, 26))
+	records := randomRecords(rng, 500, 8)
+	idx := NewHNSW[float32](WithMetric(Euclidean))
+	_ = idx.Add(ctx, records...)
+	query := records[7].Vector
+
+	_ = idx.Delete(ctx, "7", "missing")
+	if idx.Len() != 499 || idx.Tombstones() != 1 {
+		t.Fatalf("Len = %d, tombstones = %d", idx.Len(), idx.Tombstones())
+	}
+	if _, ok := idx.Get("7"); ok {
+		t.Error("Get returned a deleted record")
+	}
+	results, _ := idx.Search(ctx, query, 5)
+	if slices.Contains(resultIDs(results), "7") {
+		t.Errorf("deleted record returned: %v", resultIDs(results))
+	}
+
+	// Replacing a record tombstones the old node.
+	_ = idx.Add(ctx, Record[float32]{ID: "8", Vector: query, Metadata: map[string]string{"v": "2"}})
+	if idx.Len() != 499 || idx.Tombstones() != 2 {
+		t.Fatalf("after replace: Len = %d, tombstones = %d", idx.Len(), idx.Tombstones())
+	}
+	results, _ = idx.Search(ctx, query, 1)
+	if len(results) != 1 || results[0].ID != "8" || results[0].Metadata["v"] != "2" || results[0].Score != 0 {
+		t.Errorf("replaced record = %+v", results)
+	}
+
+	idx.Compact()
+	if idx.Len() != 499 || idx.Tombstones() != 0 {
+		t.Fatalf("after compact: Len = %d, tombstones = %d", idx.Len(), idx.Tombstones())
+	}
+	results, _ = idx.Search(ctx, query, 1)
+	if len(results) != 1 || results[0].ID != "8" {
+		t.Errorf("after compact = %v", resultIDs(results))
+	}
+
+	ids := make([]string, 0, len(records))
+	for _, r := range records {
+		ids = append(ids, r.ID)
+	}
+	_ = idx.Delete(ctx, ids...)
+	if results, err := idx.Search(ctx, query, 3); err != nil || len(results) != 0 {
+		t.Errorf("search of emptied index = %v, %v", results, err)
+	}
+}
+
+func TestHNSWDeterministic(t *testing.T) {
+	ctx := context.Background()
+	records := randomRecords(rand.New(rand.NewPCG(27, 28)), 300, 8)
+	var snapshots [2]bytes.Buffer
+	for i := range snapshots {
+		idx := NewHNSW[float32](WithSeed(7))
+		_ = idx.Add(ctx, records...)
+		_ = idx.Save(&snapshots[i], JSON)
+	}
+	if !bytes.Equal(snapshots[0].Bytes(), snapshots[1].Bytes()) {
+		t.Error("same seed and records built different graphs")
+	}
+}
+
+func TestHNSWPersistence(t *testing.T) {
+	ctx := context.Background()
+	rng := rand.New(rand.NewPCG(29, 30))
+	records := randomRecords(rng, 400, 12)
+	idx := NewHNSW[float32](WithMetric(DotProduct), WithM(8), WithEfSearch(40))
+	_ = idx.Add(ctx, records...)
+	_ = idx.Delete(ctx, "0", "1")
+	query := randomVectors(rng, 1, 12)[0]
+	want, _ := idx.Search(ctx, query, 5)
+
+	dir := t.TempDir()
+	for _, name := range []string{"index.gob", "index.json"} {
+		path := filepath.Join(dir, name)
+		if err := SaveFile(idx, path); err != nil {
+			t.Fatalf("SaveFile(%s): %v", name, err)
+		}
+		loaded := NewHNSW[float32]()
+		if err := LoadFile(loaded, path); err != nil {
+			t.Fatalf("LoadFile(%s): %v", name, err)
+		}
+		if loaded.Len() != 398 || loaded.Tombstones() != 2 || loaded.cfg.m != 8 || loaded.cfg.metric != DotProduct {
+			t.Fatalf("%s: Len %d, tombstones %d, cfg %+v", name, loaded.Len(), loaded.Tombstones(), loaded.cfg)
+		}
+		// The restored graph answers exactly like the saved one.
+		got, _ := loaded.Search(ctx, query, 5)
+		if !slices.Equal(resultIDs(got), resultIDs(want)) {
+			t.Errorf("%s: results %v, want %v", name, resultIDs(got), resultIDs(want))
+		}
+		// Inserts continue from the restored graph.
+		if err := loaded.Add(ctx, Record[float32]{ID: "new", Vector: query}); err != nil {
+			t.Fatal(err)
+		}
+		if got, _ := loaded.Search(ctx, query, 1); len(got) != 1 || got[0].ID != "new" {
+			t.Errorf("%s: search after insert = %v", name, resultIDs(got))
+		}
+
+		// Flat loads HNSW snapshots, skipping tombstones.
+		flat := NewFlat[float32]()
+		if err := LoadFile(flat, path); err != nil || flat.Len() != 398 {
+			t.Errorf("%s: flat load = %d records, %v", name, flat.Len(), err)
+		}
+	}
+
+	// HNSW indexes Flat snapshots.
+	var buf bytes.Buffer
+	flat := NewFlat[float32]()
+	_ = flat.Add(ctx, records...)
+	_ = flat.Save(&buf, Gob)
+	loaded := NewHNSW[float32]()
+	if err := loaded.Load(&buf, Gob); err != nil || loaded.Len() != len(records) {
+		t.Fatalf("load flat snapshot = %d records, %v", loaded.Len(), err)
+	}
+	if r := recall(t, loaded, Cosine, records, [][]float32{query}, 5); r < 0.8 {
+		t.Errorf("recall after loading flat snapshot = %.2f", r)
+	}
+}
+
+func TestHNSWCorruptSnapshot(t *testing.T) {
+	cases := []string{
+		`{"version":1,"metric":"cosine","dimension":1,"records":[{"id":"a","vector":[1]}],"graph":{"m":4,"entry":3,"friends":[[[]]]}}`,
+		`{"version":1,"metric":"cosine","dimension":1,"records":[{"id":"a","vector":[1]}],"graph":{"m":4,"entry":0,"friends":[[[5]]]}}`,
+		`{"version":1,"metric":"cosine","dimension":1,"records":[{"id":"a","vector":[1]}],"graph":{"m":4,"entry":0,"friends":[]}}`,
+		`{"version":1,"metric":"cosine","dimension":2,"records":[{"id":"a","vector":[1]}]}`,
+	}
+	for _, snap := range cases {
+		idx := NewHNSW[float32]()
+		if err := idx.Load(bytes.NewReader([]byte(snap)), JSON); err == nil {
+			t.Errorf("expected error loading %s", snap)
+		}
+	}
+}
+
+func TestHNSWConcurrent(t *testing.T) {
+	ctx := context.Background()
+	rng := rand.New(rand.NewPCG(31, 32))
+	idx := NewHNSW[float32](WithEfConstruction(32))
+	records := randomRecords(rng, 800, 8)
+	queries := randomVectors(rng, 4, 8)
+
+	var wg sync.WaitGroup
+	for w := range 4 {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for i := w; i < len(records); i += 4 {
+				_ = idx.Add(ctx, records[i])
+				if i%5 == 0 {
+					_ = idx.Delete(ctx, records[i].ID)
+				}
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for range 50 {
+				if _, err := idx.Search(ctx, queries[w], 5); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if want := len(records) - len(records)/5; idx.Len() != want {
+		t.Errorf("Len = %d, want %d", idx.Len(), want)
+	}
+}
+
+// BenchmarkHNSWRecall reports recall@10 against brute force for several
+// efSearch values, with the matching flat search as the baseline.
+func BenchmarkHNSWRecall(b *testing.B) {
+	ctx := context.Background()
+	rng := rand.New(rand.NewPCG(35, 36))
+	records := clusteredRecords(rng, 20000, 64, 200)
+	queries := queriesNear(rng, records, 100)
+	idx := NewHNSW[float32]()
+	if err := idx.Add(ctx, records...); err != nil {
+		b.Fatal(err)
+	}
+	flat := NewFlat[float32]()
+	_ = flat.Add(ctx, records...)
+
+	b.Run("flat", func(b *testing.B) {
+		for i := 0; b.Loop(); i++ {
+			_, _ = flat.Search(ctx, queries[i%len(queries)], 10)
+		}
+	})
+	for _, ef := range []int{16, 64, 256} {
+		b.Run("ef="+strconv.Itoa(ef), func(b *testing.B) {
+			idx.mu.Lock()
+			idx.cfg.efSearch = ef
+			idx.mu.Unlock()
+			r := recall(b, idx, Cosine, records, queries, 10)
+			for i := 0; b.Loop(); i++ {
+				_, _ = idx.Search(ctx, queries[i%len(queries)], 10)
+			}
+			b.ReportMetric(r, "recall@10")
+		})
+	}
+}
@@ -0,0 +1,173 @@
+package vectorstore
+
+import (
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/kusandriadi/allm-go"
+)
+
+// Format is an encoding for saved indexes.
+type Format int
+
+const (
+	// Gob is compact and fast to load. It is the default for SaveFile.
+	Gob Format = iota
+	// JSON is human-readable and portable to other languages.
+	JSON
+)
+
+// Persistent is an index that can be saved and restored.
+type Persistent interface {
+	// Save writes a snapshot of the index to w.
+	Save(w io.Writer, format Format) error
+	// Load replaces the index with a snapshot read from r.
+	Load(r io.Reader, format Format) error
+}
+
+// snapshotVersion is the version of the snapshot layout.
+const snapshotVersion = 1
+
+// Index kinds recorded in snapshots.
+const (
+	kindFlat = "flat"
+	kindHNSW = "hnsw"
+)
+
+// snapshot is the saved form of an index. Vectors are saved as numbers, so
+// an index can load snapshots saved with another vector type.
+type snapshot[T allm.Float] struct {
+	Version   int            `json:"version"`
+	Kind      string         `json:"kind"`
+	Metric    Metric         `json:"metric"`
+	Dimension int            `json:"dimension"`
+	Records   []Record[T]    `json:"records"`
+	Deleted   []int          `json:"deleted,omitempty"` // Indexes of tombstoned records
+	Graph     *graphSnapshot `json:"graph,omitempty"`
+}
+
+// graphSnapshot is the saved graph of an HNSW index.
+type graphSnapshot struct {
+	M              int         `json:"m"`
+	EfConstruction int         `json:"ef_construction"`
+	EfSearch       int         `json:"ef_search"`
+	Entry          int         `json:"entry"`
+	Friends        [][][]int32 `json:"friends"` // Neighbors of each record on each of its layers
+}
+
+// liveRecords returns the records of snap that are not tombstoned.
+func liveRecords[T allm.Float](snap *snapshot[T]) []Record[T] {
+	if len(snap.Deleted) == 0 {
+		return snap.Records
+	}
+	deleted := make(map[int]bool, len(snap.Deleted))
+	for _, i := range snap.Deleted {
+		deleted[i] = true
+	}
+	live := make([]Record[T], 0, len(snap.Records)-len(snap.Deleted))
+	for i, r := range snap.Records {
+		if !deleted[i] {
+			live = append(live, r)
+		}
+	}
+	return live
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (m Metric) MarshalText() ([]byte, error) {
+	switch m {
+	case Cosine, DotProduct, Euclidean:
+		return []byte(m.String()), nil
+	default:
+		return nil, fmt.Errorf("vectorstore: unknown metric %d", int(m))
+	}
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (m *Metric) UnmarshalText(text []byte) error {
+	for _, known := range []Metric{Cosine, DotProduct, Euclidean} {
+		if string(text) == known.String() {
+			*m = known
+			return nil
+		}
+	}
+	return fmt.Errorf("vectorstore: unknown metric %q", text)
+}
+
+func encodeSnapshot[T allm.Float](w io.Writer, format Format, snap *snapshot[T]) error {
+	snap.Version = snapshotVersion
+	switch format {
+	case Gob:
+		return gob.NewEncoder(w).Encode(snap)
+	case JSON:
+		return json.NewEncoder(w).Encode(snap)
+	default:
+		return fmt.Errorf("vectorstore: unknown format %d", int(format))
+	}
+}
+
+func decodeSnapshot[T allm.Float](r io.Reader, format Format) (*snapshot[T], error) {
+	var snap snapshot[T]
+	var err error
+	switch format {
+	case Gob:
+		err = gob.NewDecoder(r).Decode(&snap)
+	case JSON:
+		err = json.NewDecoder(r).Decode(&snap)
+	default:
+		return nil, fmt.Errorf("vectorstore: unknown format %d", int(format))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("vectorstore: decode snapshot: %w", err)
+	}
+	if snap.Version != snapshotVersion {
+		return nil, fmt.Errorf("vectorstore: unsupported snapshot version %d", snap.Version)
+	}
+	return &snap, nil
+}
+
+// formatOf returns JSON for paths ending in .json and Gob otherwise.
+func formatOf(path string) Format {
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		return JSON
+	}
+	return Gob
+}
+
+// SaveFile saves idx to path, as JSON if path ends in .json and as gob
+// otherwise. The file is written atomically.
+func SaveFile(idx Persistent, path string) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	if err := idx.Save(tmp, formatOf(path)); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	return nil
+}
+
+// LoadFile loads idx from a file written by SaveFile.
+func LoadFile(idx Persistent, path string) error {
+	f, err := os.Open(path) // #nosec G304 -- path is chosen by the caller
+	if err != nil {
+		return err
+	}
+	defer func() { _ = f.Close() }()
+	return idx.Load(f, formatOf(path))
+}
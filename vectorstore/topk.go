@@ -0,0 +1,130 @@
+package vectorstore
+
+// hit is a scored row of an index.
+type hit struct {
+	row   int
+	score float64
+}
+
+// topK keeps the k highest-scoring hits pushed to it, as a min-heap with the
+// worst kept hit at the root.
+type topK struct {
+	k    int
+	hits []hit
+}
+
+func newTopK(k int) *topK {
+	return &topK{k: k, hits: make([]hit, 0, min(k, 1024))}
+}
+
+// full reports whether k hits are kept.
+func (t *topK) full() bool {
+	return len(t.hits) == t.k
+}
+
+// worst returns the lowest kept score. It is only meaningful when full.
+func (t *topK) worst() float64 {
+	return t.hits[0].score
+}
+
+// push offers h, replacing the worst kept hit if h is better.
+func (t *topK) push(h hit) {
+	if len(t.hits) < t.k {
+		t.hits = append(t.hits, h)
+		t.up(len(t.hits) - 1)
+		return
+	}
+	if h.score > t.hits[0].score {
+		t.hits[0] = h
+		t.down(0)
+	}
+}
+
+// pop removes and returns the worst kept hit.
+func (t *topK) pop() hit {
+	h := t.hits[0]
+	last := len(t.hits) - 1
+	t.hits[0] = t.hits[last]
+	t.hits = t.hits[:last]
+	t.down(0)
+	return h
+}
+
+// sorted empties t and returns its hits, best first.
+func (t *topK) sorted() []hit {
+	out := make([]hit, len(t.hits))
+	for i := len(out) - 1; i >= 0; i-- {
+		out[i] = t.pop()
+	}
+	return out
+}
+
+func (t *topK) up(i int) {
+	for i > 0 {
+		parent := (i - 1) / 2
+		if t.hits[parent].score <= t.hits[i].score {
+			return
+		}
+		t.hits[parent], t.hits[i] = t.hits[i], t.hits[parent]
+		i = parent
+	}
+}
+
+func (t *topK) down(i int) {
+	n := len(t.hits)
+	for {
+		least := i
+		if l := 2*i + 1; l < n && t.hits[l].score < t.hits[least].score {
+			least = l
+		}
+		if r := 2*i + 2; r < n && t.hits[r].score < t.hits[least].score {
+			least = r
+		}
+		if least == i {
+			return
+		}
+		t.hits[least], t.hits[i] = t.hits[i], t.hits[least]
+		i = least
+	}
+}
+
+// maxHeap is an unbounded heap of hits with the best hit at the root.
+type maxHeap []hit
+
+// push adds h.
+func (m *maxHeap) push(h hit) {
+	*m = append(*m, h)
+	s := *m
+	for i := len(s) - 1; i > 0; {
+		parent := (i - 1) / 2
+		if s[parent].score >= s[i].score {
+			return
+		}
+		s[parent], s[i] = s[i], s[parent]
+		i = parent
+	}
+}
+
+// pop removes and returns the best hit.
+func (m *maxHeap) pop() hit {
+	s := *m
+	h := s[0]
+	last := len(s) - 1
+	s[0] = s[last]
+	s = s[:last]
+	*m = s
+	for i := 0; ; {
+		best := i
+		if l := 2*i + 1; l < last && s[l].score > s[best].score {
+			best = l
+		}
+		if r := 2*i + 2; r < last && s[r].score > s[best].score {
+			best = r
+		}
+		if best == i {
+			return h
+		}
+		s[best], s[i] = s[i], s[best]
+		i = best
+	}
+}
@@ -0,0 +1,350 @@
+// Package vectorstore provides in-memory nearest-neighbor search over
+// embeddings, such as those returned by allm.Client.Embed.
+//
+// Flat is an exact index that scores every vector, in parallel, on each
+// search. HNSW is an approximate graph index for collections too large to
+// scan, from hundreds of thousands of vectors up. Both implement Index, are
+// safe for concurrent use and can be saved to and loaded from disk as gob or
+// JSON.
+//
+// Indexes are generic over the allm.Float vector type and score vectors with
+// allm's DotProduct and EuclideanDistance. float32 indexes take half the
+// memory of float64 embeddings and are scored with unrolled float32 kernels
+// that vectorize; their scores match allm's up to float32 rounding.
+// ToFloat32 converts embeddings for them.
+//
+//	idx := vectorstore.NewFlat[float64](vectorstore.WithMetric(vectorstore.Cosine))
+//	resp, _ := client.Embed(ctx, docs...)
+//	for i, emb := range resp.Embeddings {
+//	    _ = idx.Add(ctx, vectorstore.Record[float64]{
+//	        ID:       ids[i],
+//	        Vector:   emb,
+//	        Metadata: map[string]string{"lang": "en"},
+//	    })
+//	}
+//
+//	q, _ := client.Embed(ctx, "How do I reset my password?")
+//	results, _ := idx.Search(ctx, q.Embeddings[0], 5,
+//	    vectorstore.WithFilter(vectorstore.Match(map[string]string{"lang": "en"})),
+//	)
+package vectorstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"runtime"
+
+	"github.com/kusandriadi/allm-go"
+)
+
+// Sentinel errors.
+var (
+	ErrEmptyID           = errors.New("vectorstore: empty record ID")
+	ErrDimensionMismatch = errors.New("vectorstore: vector dimension mismatch")
+	ErrInvalidK          = errors.New("vectorstore: k must be positive")
+)
+
+// Index is a searchable collection of vectors of type T.
+type Index[T allm.Float] interface {
+	// Add inserts records, replacing records with the same ID.
+	// Vectors are copied. All vectors must have the index's dimension.
+	Add(ctx context.Context, records ...Record[T]) error
+
+	// Delete removes the records with the given IDs. Unknown IDs are ignored.
+	Delete(ctx context.Context, ids ...string) error
+
+	// Search returns up to k records closest to query, best first.
+	Search(ctx context.Context, query []T, k int, opts ...SearchOption) ([]Result, error)
+
+	// Len returns the number of records.
+	Len() int
+}
+
+// Record is a vector with its ID and optional metadata.
+type Record[T allm.Float] struct {
+	ID       string            `json:"id"`
+	Vector   []T               `json:"vector"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
+// Result is a search hit.
+type Result struct {
+	ID       string
+	Score    float64           // Similarity to the query; higher is closer (see Metric)
+	Metadata map[string]string // Copy of the record's metadata
+}
+
+// Metric is the similarity measure used to rank vectors.
+type Metric int
+
+const (
+	// Cosine ranks by cosine similarity, from -1 to 1. Zero vectors score 0.
+	Cosine Metric = iota
+	// DotProduct ranks by dot product. Same order as Cosine for unit vectors,
+	// and faster.
+	DotProduct
+	// Euclidean ranks by Euclidean distance. Score is the negated distance,
+	// so that higher is still closer.
+	Euclidean
+)
+
+// String returns the metric name.
+func (m Metric) String() string {
+	switch m {
+	case Cosine:
+		return "cosine"
+	case DotProduct:
+		return "dot_product"
+	case Euclidean:
+		return "euclidean"
+	default:
+		return fmt.Sprintf("Metric(%d)", int(m))
+	}
+}
+
+// kernel scores vectors of type T with a metric.
+type kernel[T allm.Float] struct {
+	// score returns the similarity of a and b, which have equal length,
+	// given their Euclidean norms.
+	score func(a, b []T, normA, normB float64) float64
+	// norm returns the Euclidean norm of v.
+	norm func(v []T) float64
+}
+
+// newKernel returns the kernel of m for T: allm's DotProduct and
+// EuclideanDistance, or the float32 kernels when T is float32. Cosine divides
+// the dot product by the cached norms, matching allm.CosineSimilarity.
+func newKernel[T allm.Float](m Metric) kernel[T] {
+	if k, ok := any(float32Kernel(m)).(kernel[T]); ok {
+		return k
+	}
+	k := kernel[T]{norm: func(v []T) float64 { return math.Sqrt(allm.DotProduct(v, v)) }}
+	switch m {
+	case DotProduct:
+		k.score = func(a, b []T, _, _ float64) float64 { return allm.DotProduct(a, b) }
+	case Euclidean:
+		k.score = func(a, b []T, _, _ float64) float64 { return -allm.EuclideanDistance(a, b) }
+	default:
+		k.score = func(a, b []T, normA, normB float64) float64 {
+			return cosine(allm.DotProduct(a, b), normA, normB)
+		}
+	}
+	return k
+}
+
+// float32Kernel returns the kernel of m built on dot and squaredDistance.
+func float32Kernel(m Metric) kernel[float32] {
+	k := kernel[float32]{norm: func(v []float32) float64 { return math.Sqrt(float64(dot(v, v))) }}
+	switch m {
+	case DotProduct:
+		k.score = func(a, b []float32, _, _ float64) float64 { return float64(dot(a, b)) }
+	case Euclidean:
+		k.score = func(a, b []float32, _, _ float64) float64 { return -math.Sqrt(float64(squaredDistance(a, b))) }
+	default:
+		k.score = func(a, b []float32, normA, normB float64) float64 {
+			return cosine(float64(dot(a, b)), normA, normB)
+		}
+	}
+	return k
+}
+
+// cosine returns the cosine similarity of vectors with the given dot product
+// and norms, or 0 if either is a zero vector.
+func cosine(product, normA, normB float64) float64 {
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return product / (normA * normB)
+}
+
+// Filter reports whether a record with the given metadata may be returned.
+// Filters are called concurrently and must not modify metadata.
+type Filter func(metadata map[string]string) bool
+
+// Match returns a Filter accepting records whose metadata has every key of
+// want with the same value.
+func Match(want map[string]string) Filter {
+	return func(metadata map[string]string) bool {
+		for k, v := range want {
+			if got, ok := metadata[k]; !ok || got != v {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// SearchOption configures a search.
+type SearchOption func(*searchConfig)
+
+type searchConfig struct {
+	filter   Filter
+	minScore float64
+}
+
+// WithFilter restricts results to records accepted by f.
+func WithFilter(f Filter) SearchOption {
+	return func(c *searchConfig) { c.filter = f }
+}
+
+// WithMinScore drops results scoring below min.
+func WithMinScore(min float64) SearchOption {
+	return func(c *searchConfig) { c.minScore = min }
+}
+
+func newSearchConfig(opts []SearchOption) *searchConfig {
+	cfg := &searchConfig{minScore: math.Inf(-1)}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	return cfg
+}
+
+// accepts reports whether a record with metadata and score may be returned.
+func (c *searchConfig) accepts(metadata map[string]string, score float64) bool {
+	return score >= c.minScore && (c.filter == nil || c.filter(metadata))
+}
+
+// Option configures an index.
+type Option func(*config)
+
+type config struct {
+	metric         Metric
+	dimension      int
+	workers        int
+	m              int
+	efConstruction int
+	efSearch       int
+	seed           uint64
+}
+
+// WithMetric sets the similarity metric (default: Cosine).
+func WithMetric(m Metric) Option {
+	return func(c *config) { c.metric = m }
+}
+
+// WithDimension fixes the vector dimension. By default it is taken from the
+// first vector added.
+func WithDimension(n int) Option {
+	return func(c *config) { c.dimension = n }
+}
+
+// WithWorkers sets how many goroutines score vectors during a search
+// (default: GOMAXPROCS).
+func WithWorkers(n int) Option {
+	return func(c *config) { c.workers = n }
+}
+
+// WithM sets the number of neighbors an HNSW node links to on each layer
+// above the base layer, which gets twice as many (default: 16). Higher values
+// improve recall on high-dimensional data at the cost of memory and build
+// time. HNSW only.
+func WithM(m int) Option {
+	return func(c *config) { c.m = m }
+}
+
+// WithEfConstruction sets the candidate list size used to find neighbors
+// when inserting into an HNSW index (default: 200). Higher values build a
+// better graph more slowly. HNSW only.
+func WithEfConstruction(ef int) Option {
+	return func(c *config) { c.efConstruction = ef }
+}
+
+// WithEfSearch sets the candidate list size of HNSW searches (default: 50).
+// Searches use at least k. Higher values trade speed for recall. HNSW only.
+func WithEfSearch(ef int) Option {
+	return func(c *config) { c.efSearch = ef }
+}
+
+// WithSeed seeds the random layer assignment of HNSW nodes, so that
+// inserting the same records in the same order builds the same graph
+// (default: 1). HNSW only.
+func WithSeed(seed uint64) Option {
+	return func(c *config) { c.seed = seed }
+}
+
+func newConfig(opts []Option) config {
+	cfg := config{
+		metric:         Cosine,
+		workers:        runtime.GOMAXPROCS(0),
+		m:              16,
+		efConstruction: 200,
+		efSearch:       50,
+		seed:           1,
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	cfg.workers = max(cfg.workers, 1)
+	cfg.m = max(cfg.m, 2)
+	cfg.efConstruction = max(cfg.efConstruction, cfg.m)
+	cfg.efSearch = max(cfg.efSearch, 1)
+	return cfg
+}
+
+// ToFloat32 converts a vector, such as an allm.EmbedResponse embedding, to
+// float32, e.g. to store float64 embeddings in a float32 index.
+func ToFloat32[T allm.Float](v []T) []float32 {
+	out := make([]float32, len(v))
+	for i, x := range v {
+		out[i] = float32(x)
+	}
+	return out
+}
+
+// checkRecords validates records against dim, the index dimension (0 = not
+// yet known), and returns the dimension the index has after adding them.
+func checkRecords[T allm.Float](dim int, records []Record[T]) (int, error) {
+	for _, r := range records {
+		if r.ID == "" {
+			return dim, ErrEmptyID
+		}
+		if dim == 0 {
+			dim = len(r.Vector)
+		}
+		if len(r.Vector) != dim || dim == 0 {
+			return dim, fmt.Errorf("%w: record %q has %d dimensions, index has %d", ErrDimensionMismatch, r.ID, len(r.Vector), dim)
+		}
+	}
+	return dim, nil
+}
+
+// dot returns the dot product of a and b, which have equal length. Four
+// independent accumulators let the compiler pipeline and vectorize the loop.
+func dot(a, b []float32) float32 {
+	b = b[:len(a)]
+	var s0, s1, s2, s3 float32
+	i := 0
+	for ; i+4 <= len(a); i += 4 {
+		s0 += a[i] * b[i]
+		s1 += a[i+1] * b[i+1]
+		s2 += a[i+2] * b[i+2]
+		s3 += a[i+3] * b[i+3]
+	}
+	for ; i < len(a); i++ {
+		s0 += a[i] * b[i]
+	}
+	return (s0 + s1) + (s2 + s3)
+}
+
+// squaredDistance returns the squared Euclidean distance between a and b,
+// which have equal length.
+func squaredDistance(a, b []float32) float32 {
+	b = b[:len(a)]
+	var s0, s1, s2, s3 float32
+	i := 0
+	for ; i+4 <= len(a); i += 4 {
+		d0, d1, d2, d3 := a[i]-b[i], a[i+1]-b[i+1], a[i+2]-b[i+2], a[i+3]-b[i+3]
+		s0 += d0 * d0
+		s1 += d1 * d1
+		s2 += d2 * d2
+		s3 += d3 * d3
+	}
+	for ; i < len(a); i++ {
+		d := a[i] - b[i]
+		s0 += d * d
+	}
+	return (s0 + s1) + (s2 + s3)
+}
@@ -0,0 +1,124 @@
+package vectorstore
+
+import (
+	"encoding/json"
+	"math"
+	"math/rand/v2"
+	"testing"
+
+	"github.com/kusandriadi/allm-go"
+)
+
+// randomVectors returns n random vectors of dim dimensions.
+func randomVectors(rng *rand.Rand, n, dim int) [][]float32 {
+	out := make([][]float32, n)
+	for i := range out {
+		out[i] = make([]float32, dim)
+		for j := range out[i] {
+			out[i][j] = rng.Float32()*2 - 1
+		}
+	}
+	return out
+}
+
+func TestKernelsMatchAllm(t *testing.T) {
+	rng := rand.New(rand.NewPCG(1, 2))
+	for _, dim := range []int{1, 3, 4, 7, 64, 1537} {
+		v := randomVectors(rng, 2, dim)
+		a, b := v[0], v[1]
+		cases := []struct {
+			metric Metric
+			want   float64
+		}{
+			{Cosine, allm.CosineSimilarity(a, b)},
+			{DotProduct, allm.DotProduct(a, b)},
+			{Euclidean, -allm.EuclideanDistance(a, b)},
+		}
+		for _, tc := range cases {
+			k := newKernel[float32](tc.metric)
+			got := k.score(a, b, k.norm(a), k.norm(b))
+			if math.Abs(got-tc.want) > 1e-3*math.Max(1, math.Abs(tc.want)) {
+				t.Errorf("dim %d %s = %v, want %v", dim, tc.metric, got, tc.want)
+			}
+
+			a64, b64 := toFloat64(a), toFloat64(b)
+			k64 := newKernel[float64](tc.metric)
+			if got := k64.score(a64, b64, k64.norm(a64), k64.norm(b64)); math.Abs(got-tc.want) > 1e-9*math.Max(1, math.Abs(tc.want)) {
+				t.Errorf("dim %d %s float64 = %v, want %v", dim, tc.metric, got, tc.want)
+			}
+		}
+	}
+
+	zero := []float32{0, 0}
+	if got := newKernel[float32](Cosine).score(zero, []float32{1, 0}, 0, 1); got != 0 {
+		t.Errorf("cosine with zero vector = %v", got)
+	}
+}
+
+// toFloat64 converts v to float64.
+func toFloat64(v []float32) []float64 {
+	out := make([]float64, len(v))
+	for i, x := range v {
+		out[i] = float64(x)
+	}
+	return out
+}
+
+func TestMatch(t *testing.T) {
+	f := Match(map[string]string{"lang": "en", "tier": "gold"})
+	cases := []struct {
+		meta map[string]string
+		want bool
+	}{
+		{map[string]string{"lang": "en", "tier": "gold", "x": "y"}, true},
+		{map[string]string{"lang": "en"}, false},
+		{map[string]string{"lang": "de", "tier": "gold"}, false},
+		{nil, false},
+	}
+	for _, tc := range cases {
+		if got := f(tc.meta); got != tc.want {
+			t.Errorf("Match(%v) = %v, want %v", tc.meta, got, tc.want)
+		}
+	}
+	if !Match(nil)(nil) {
+		t.Error("empty Match should accept everything")
+	}
+}
+
+func TestToFloat32(t *testing.T) {
+	got := ToFloat32([]float64{0.5, -1, 2})
+	if len(got) != 3 || got[0] != 0.5 || got[1] != -1 || got[2] != 2 {
+		t.Errorf("ToFloat32 = %v", got)
+	}
+}
+
+func TestMetricText(t *testing.T) {
+	for _, m := range []Metric{Cosine, DotProduct, Euclidean} {
+		data, err := json.Marshal(m)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var back Metric
+		if err := json.Unmarshal(data, &back); err != nil || back != m {
+			t.Errorf("%s round trip = %v, %v", data, back, err)
+		}
+	}
+	if _, err := json.Marshal(Metric(9)); err == nil {
+		t.Error("expected error for unknown metric")
+	}
+	var m Metric
+	if err := json.Unmarshal([]byte(`"manhattan"`), &m); err == nil {
+		t.Error("expected error for unknown metric name")
+	}
+}
+
+func TestTopK(t *testing.T) {
+	top := newTopK(3)
+	for i, s := range []float64{0.1, 0.9, 0.5, 0.3, 0.7, 0.2} {
+		top.push(hit{row: i, score: s})
+	}
+	got := top.sorted()
+	if len(got) != 3 || got[0].row != 1 || got[1].row != 4 || got[2].row != 2 {
+		t.Errorf("sorted = %+v", got)
+	}
+}